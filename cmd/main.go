@@ -5,6 +5,10 @@ import (
 
 	"github.com/Confialink/wallet-users/internal/commands"
 	auth2 "github.com/Confialink/wallet-users/internal/services/auth"
+	"github.com/Confialink/wallet-users/internal/services/events"
+	"github.com/Confialink/wallet-users/internal/services/gdpr"
+	messagebroker "github.com/Confialink/wallet-users/internal/services/message-broker"
+	"github.com/Confialink/wallet-users/internal/services/retention"
 	"github.com/Confialink/wallet-users/internal/validators"
 	"github.com/Confialink/wallet-users/internal/workers"
 	"github.com/jasonlvhit/gocron"
@@ -38,11 +42,18 @@ func main() {
 
 		scheduler *gocron.Scheduler,
 		usersRepo *repositories.UsersRepository,
+		tokenRepo *repositories.TokenRepository,
 		securityQuestionsRepo *repositories.SecurityQuestionRepository,
 		userGroupsRepo *repositories.UserGroupsRepository,
 		sysSettings *syssettings.SysSettings,
 		passwordService *services.Password,
 		tokenService *auth2.TokenService,
+		outbox *events.Outbox,
+		erasure *gdpr.Erasure,
+		export *gdpr.Export,
+		consents *gdpr.Consents,
+		purger *retention.Purger,
+		broker messagebroker.MessageBroker,
 		formBuilder *forms.Factory,
 		engineValidator *validator.Validate,
 	) {
@@ -55,9 +66,18 @@ func main() {
 
 		createRootUserCommand := commands.Init(c)
 		commands.AddCommand(createRootUserCommand)
+		commands.AddCommand(commands.NewRotateJwtKeys(c))
+		commands.AddCommand(commands.NewPurgeRetention(c))
 		commands.Run()
 
-		workers.Start(scheduler, usersRepo, tokenService, sysSettings, logger)
+		if err := sysSettings.SubscribeToChanges(broker); err != nil {
+			logger.Error("cannot subscribe to settings changes, settings are refreshed by TTL only", "err", err)
+		}
+		if err := consents.SubscribeToDocumentChanges(broker); err != nil {
+			logger.Error("cannot subscribe to customization changes, new versions of documents do not require consent", "err", err)
+		}
+
+		workers.Start(scheduler, usersRepo, tokenRepo, tokenService, sysSettings, outbox, erasure, export, purger, logger)
 		if err := formBuilder.InitForms(); err != nil {
 			log.Fatal("cannot initialize forms: " + err.Error())
 		}
@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/inconshreveable/log15"
+	"go.uber.org/dig"
+
+	"github.com/Confialink/wallet-users/internal/services/retention"
+)
+
+// PurgeRetention deletes rows kept longer than retention periods in system settings, the same as the retention worker
+type PurgeRetention struct {
+	name        string
+	usage       string
+	description string
+	container   *dig.Container
+	logger      log15.Logger
+}
+
+func NewPurgeRetention(container *dig.Container) *PurgeRetention {
+	return &PurgeRetention{
+		name:  "purge-retention",
+		usage: "purge-retention?dry-run=true",
+		description: "Delete expired confirmation codes, blocked IPs and invites, old failed sign in attempts and access log " +
+			"according to retention periods in system settings. " +
+			"Use \"dry-run=true\" to print the number of rows to delete without deleting them.",
+		container: container,
+	}
+}
+
+func (c *PurgeRetention) Name() string {
+	return c.name
+}
+
+func (c *PurgeRetention) Description() string {
+	return fmt.Sprintf("%s:\nUsage:\t%s\nDescription:\t%s\n\n", c.name, c.usage, c.description)
+}
+
+func (c *PurgeRetention) Handle(args url.Values) {
+	err := c.container.Invoke(func(purger *retention.Purger, logger log15.Logger) {
+		c.logger = logger
+		if err := c.purge(args, purger); err != nil {
+			c.logger.Error(err.Error())
+			os.Exit(1)
+		}
+	})
+	if err != nil {
+		c.logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func (c *PurgeRetention) purge(args url.Values, purger *retention.Purger) error {
+	dryRun := args.Get("dry-run") == "true"
+	results, err := purger.Purge(dryRun)
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	for _, result := range results {
+		if result.Err != nil {
+			failed = true
+			fmt.Printf("%s: failed after %d rows: %s\n", result.Table, result.Rows, result.Err)
+			continue
+		}
+		if dryRun {
+			fmt.Printf("%s: %d rows older than %s would be deleted\n", result.Table, result.Rows, result.Cutoff.Format("2006-01-02 15:04:05"))
+		} else {
+			fmt.Printf("%s: %d rows older than %s deleted in %s\n", result.Table, result.Rows, result.Cutoff.Format("2006-01-02 15:04:05"), result.Duration)
+		}
+	}
+	if failed {
+		return fmt.Errorf("some tables are not purged")
+	}
+	return nil
+}
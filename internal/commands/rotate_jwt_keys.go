@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/Confialink/wallet-pkg-utils"
+	"github.com/inconshreveable/log15"
+	"go.uber.org/dig"
+
+	"github.com/Confialink/wallet-users/internal/services/auth"
+)
+
+// RotateJwtKeys generates a new signing key, makes it current and retires keys which can not sign valid tokens anymore
+type RotateJwtKeys struct {
+	name        string
+	usage       string
+	description string
+	container   *dig.Container
+	logger      log15.Logger
+}
+
+func NewRotateJwtKeys(container *dig.Container) *RotateJwtKeys {
+	return &RotateJwtKeys{
+		name:  "rotate-jwt-keys",
+		usage: "rotate-jwt-keys?retire-after=720h",
+		description: "Generate a new signing key and sign new tokens with it. Tokens signed with previous keys remain valid. " +
+			"Previous keys replaced earlier than \"retire-after\" ago are retired, " +
+			"the value must not be less than the lifetime of refresh tokens (default " + auth.ClaimRefreshTokenExp + "). " +
+			"Use \"retire-only=true\" to retire keys without generating a new one.",
+		container: container,
+	}
+}
+
+func (c *RotateJwtKeys) Name() string {
+	return c.name
+}
+
+func (c *RotateJwtKeys) Description() string {
+	return fmt.Sprintf("%s:\nUsage:\t%s\nDescription:\t%s\n\n", c.name, c.usage, c.description)
+}
+
+func (c *RotateJwtKeys) Handle(args url.Values) {
+	err := c.container.Invoke(func(signingKeys *auth.SigningKeys, logger log15.Logger) {
+		c.logger = logger
+		if err := c.rotate(args, signingKeys); err != nil {
+			c.logger.Error(err.Error())
+			os.Exit(1)
+		}
+	})
+	if err != nil {
+		c.logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func (c *RotateJwtKeys) rotate(args url.Values, signingKeys *auth.SigningKeys) error {
+	retireAfter := utils.MustParseDuration(auth.ClaimRefreshTokenExp)
+	if value := args.Get("retire-after"); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("parameter \"retire-after\" is invalid: %s\n usage: \"%s\"", err, c.usage)
+		}
+		retireAfter = duration
+	}
+
+	if args.Get("retire-only") != "true" {
+		key, err := signingKeys.Rotate()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("new signing key %s is current\n", key.Kid)
+	}
+
+	retired, err := signingKeys.Retire(retireAfter)
+	if err != nil {
+		return err
+	}
+	for _, key := range retired {
+		fmt.Printf("signing key %s is retired\n", key.Kid)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package config
+
+import (
+	"github.com/Confialink/wallet-pkg-env_config"
+)
+
+type CaptchaConfiguration struct {
+	// VerifyURL is the siteverify endpoint of the CAPTCHA provider (reCAPTCHA, hCaptcha, Turnstile)
+	VerifyURL string
+	// Secret is the secret key of the site at the provider, CAPTCHA is not required if neither it nor StubToken is set
+	Secret string
+	// StubToken is the only token accepted when there is no secret, it is meant for tests and development
+	StubToken string
+}
+
+func initCaptchaConfig() *CaptchaConfiguration {
+	return &CaptchaConfiguration{
+		VerifyURL: env_config.Env("VELMIE_WALLET_USERS_CAPTCHA_VERIFY_URL", "https://www.google.com/recaptcha/api/siteverify"),
+		Secret:    env_config.Env("VELMIE_WALLET_USERS_CAPTCHA_SECRET", ""),
+		StubToken: env_config.Env("VELMIE_WALLET_USERS_CAPTCHA_STUB_TOKEN", ""),
+	}
+}
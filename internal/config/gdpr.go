@@ -0,0 +1,26 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Confialink/wallet-pkg-env_config"
+)
+
+// GdprConfiguration holds parameters of processing of personal data
+type GdprConfiguration struct {
+	// ErasureGracePeriod is how long an approved erasure request waits before personal data is erased,
+	// the user can cancel the request in the meantime
+	ErasureGracePeriod time.Duration
+}
+
+// Init initializes environment variables
+func (c *GdprConfiguration) Init() error {
+	days, err := strconv.Atoi(env_config.Env("VELMIE_WALLET_USERS_GDPR_ERASURE_GRACE_DAYS", "30"))
+	if err != nil || days < 0 {
+		return fmt.Errorf("VELMIE_WALLET_USERS_GDPR_ERASURE_GRACE_DAYS environment variable must be a non-negative integer")
+	}
+	c.ErasureGracePeriod = time.Duration(days) * 24 * time.Hour
+	return nil
+}
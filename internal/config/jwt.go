@@ -3,6 +3,7 @@ package config
 import (
 	"github.com/Confialink/wallet-pkg-env_config"
 
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"github.com/dgrijalva/jwt-go"
@@ -16,6 +17,9 @@ type JwtConfiguration struct {
 	PublicKeyPath string
 	jwt.SigningMethod
 	Secret string
+	// KeysEncryptionKey is the AES-256 key private keys of the key ring are encrypted with in the database,
+	// keys are stored in plain text if it is not set
+	KeysEncryptionKey []byte
 }
 
 // Init initializes environment variables
@@ -46,6 +50,16 @@ func (c *JwtConfiguration) Init() error {
 		c.SigningMethod = signingMethod
 	}
 
+	// the key is optional, so existing deployments keep working with keys stored in plain text until it is set
+	if value := os.Getenv("VELMIE_WALLET_USERS_JWT_KEYS_ENCRYPTION_KEY"); value != "" {
+		encryptionKey, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(encryptionKey) != 32 {
+			return errors.New("VELMIE_WALLET_USERS_JWT_KEYS_ENCRYPTION_KEY environment variable " +
+				"must be 32 random bytes encoded with base64")
+		}
+		c.KeysEncryptionKey = encryptionKey
+	}
+
 	switch c.SigningMethod.(type) {
 	case *jwt.SigningMethodHMAC:
 		secret := os.Getenv("VELMIE_WALLET_USERS_JWT_SECRET")
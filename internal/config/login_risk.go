@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/Confialink/wallet-pkg-env_config"
+)
+
+// LoginRiskConfiguration holds parameters of risk scoring of sign in attempts
+type LoginRiskConfiguration struct {
+	// GeoIPDatabase is a CSV file of IP ranges with locations, impossible travel is not detected if it is empty
+	GeoIPDatabase string
+	// StepUpScore is the risk score (0-100) from which sign in has to be confirmed by a code sent
+	// to the email or the phone of the user, 0 disables confirmation
+	StepUpScore int
+}
+
+// Init initializes environment variables
+func (c *LoginRiskConfiguration) Init() error {
+	score, err := strconv.Atoi(env_config.Env("VELMIE_WALLET_USERS_LOGIN_RISK_STEP_UP_SCORE", "60"))
+	if err != nil || score < 0 || score > 100 {
+		return fmt.Errorf("VELMIE_WALLET_USERS_LOGIN_RISK_STEP_UP_SCORE environment variable must be an integer from 0 to 100")
+	}
+	c.StepUpScore = score
+
+	c.GeoIPDatabase = env_config.Env("VELMIE_WALLET_USERS_GEOIP_DATABASE", "")
+	if c.GeoIPDatabase != "" {
+		if _, err := os.Stat(c.GeoIPDatabase); err != nil {
+			return fmt.Errorf("failed to read %s, set correct file with VELMIE_WALLET_USERS_GEOIP_DATABASE: %s", c.GeoIPDatabase, err)
+		}
+	}
+	return nil
+}
@@ -13,6 +13,13 @@ type Configuration struct {
 	RPC           *RPCConfiguration
 	JWT           *JwtConfiguration
 	MessageBroker *MessageBroker
+	Mfa           *MfaConfiguration
+	Oidc          *OidcConfiguration
+	Password      *PasswordConfiguration
+	RateLimit     *RateLimitConfiguration
+	Captcha       *CaptchaConfiguration
+	LoginRisk     *LoginRiskConfiguration
+	Gdpr          *GdprConfiguration
 }
 
 // Create a new config instance.
@@ -45,6 +52,26 @@ func InitConfig(logger log15.Logger) (err error) {
 		return
 	}
 
+	password := &PasswordConfiguration{}
+	if err = password.Init(); err != nil {
+		return
+	}
+
+	rateLimit := &RateLimitConfiguration{}
+	if err = rateLimit.Init(); err != nil {
+		return
+	}
+
+	loginRisk := &LoginRiskConfiguration{}
+	if err = loginRisk.Init(); err != nil {
+		return
+	}
+
+	gdpr := &GdprConfiguration{}
+	if err = gdpr.Init(); err != nil {
+		return
+	}
+
 	//conf.Server = cfg
 	conf = &Configuration{
 		Server:        server,
@@ -53,6 +80,13 @@ func InitConfig(logger log15.Logger) (err error) {
 		RPC:           rpc,
 		JWT:           jwt,
 		MessageBroker: initMessageBrokerConfig(),
+		Mfa:           initMfaConfig(),
+		Oidc:          initOidcConfig(),
+		Password:      password,
+		RateLimit:     rateLimit,
+		Captcha:       initCaptchaConfig(),
+		LoginRisk:     loginRisk,
+		Gdpr:          gdpr,
 	}
 
 	validateConfig(conf, logger)
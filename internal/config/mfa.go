@@ -0,0 +1,33 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/Confialink/wallet-pkg-env_config"
+)
+
+type MfaConfiguration struct {
+	// TotpIssuer is shown by authenticator apps next to the account name
+	TotpIssuer string
+	// WebauthnRPID is the domain WebAuthn credentials are bound to, it must be equal to
+	// or a registrable suffix of the domain of the web application
+	WebauthnRPID string
+	// WebauthnRPName is shown by authenticators during registration
+	WebauthnRPName string
+	// WebauthnOrigins are origins of web applications which may use WebAuthn credentials
+	WebauthnOrigins []string
+}
+
+func initMfaConfig() *MfaConfiguration {
+	origins := strings.Split(env_config.Env("VELMIE_WALLET_USERS_WEBAUTHN_ORIGINS", "http://localhost"), ",")
+	for i := range origins {
+		origins[i] = strings.TrimSpace(origins[i])
+	}
+
+	return &MfaConfiguration{
+		TotpIssuer:      env_config.Env("VELMIE_WALLET_USERS_MFA_TOTP_ISSUER", "Velmie Wallet"),
+		WebauthnRPID:    env_config.Env("VELMIE_WALLET_USERS_WEBAUTHN_RP_ID", "localhost"),
+		WebauthnRPName:  env_config.Env("VELMIE_WALLET_USERS_WEBAUTHN_RP_NAME", "Velmie Wallet"),
+		WebauthnOrigins: origins,
+	}
+}
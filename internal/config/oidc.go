@@ -0,0 +1,30 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/Confialink/wallet-pkg-env_config"
+)
+
+type OidcConfiguration struct {
+	// Issuer is the public base url of public routes, e.g. https://api.example.com/users/public/v1.
+	// It is the "iss" claim of id tokens and the discovery document is served under it.
+	Issuer string
+	// LoginURL is the page of the web application which signs in the user and confirms the authorization request.
+	// Parameters of the request are passed to the page as the query string.
+	LoginURL string
+	// FederatedRedirectURL is the page of the web application external identity providers redirect the user back to.
+	// The page passes the code and the state to the federated sign in callback. It must be registered at every provider.
+	FederatedRedirectURL string
+}
+
+func initOidcConfig() *OidcConfiguration {
+	return &OidcConfiguration{
+		Issuer:   strings.TrimRight(env_config.Env("VELMIE_WALLET_USERS_OIDC_ISSUER", "http://localhost/users/public/v1"), "/"),
+		LoginURL: env_config.Env("VELMIE_WALLET_USERS_OIDC_LOGIN_URL", "http://localhost/oauth/authorize"),
+		FederatedRedirectURL: env_config.Env(
+			"VELMIE_WALLET_USERS_FEDERATED_REDIRECT_URL",
+			"http://localhost/auth/federated/callback",
+		),
+	}
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/Confialink/wallet-pkg-env_config"
+)
+
+// PasswordConfiguration holds parameters of argon2id password hashing and the breached passwords dataset.
+// Changing hashing parameters makes stored hashes to be upgraded on the next sign in of their users.
+type PasswordConfiguration struct {
+	// Argon2Time is the number of passes over the memory
+	Argon2Time uint32
+	// Argon2Memory is the size of the memory in KiB
+	Argon2Memory uint32
+	// Argon2Threads is the number of lanes used to compute a hash
+	Argon2Threads uint8
+	// Argon2KeyLength is the length of a hash in bytes
+	Argon2KeyLength uint32
+	// Argon2SaltLength is the length of a random salt in bytes
+	Argon2SaltLength uint32
+	// PwnedPasswordsDir is a directory of Pwned Passwords range files, passwords are not checked against breaches if it is empty
+	PwnedPasswordsDir string
+}
+
+// Init initializes environment variables
+func (c *PasswordConfiguration) Init() error {
+	values := []struct {
+		env    string
+		def    string
+		bits   int
+		target func(uint64)
+	}{
+		{"VELMIE_WALLET_USERS_ARGON2_TIME", "1", 32, func(v uint64) { c.Argon2Time = uint32(v) }},
+		{"VELMIE_WALLET_USERS_ARGON2_MEMORY", "65536", 32, func(v uint64) { c.Argon2Memory = uint32(v) }},
+		{"VELMIE_WALLET_USERS_ARGON2_THREADS", "4", 8, func(v uint64) { c.Argon2Threads = uint8(v) }},
+		{"VELMIE_WALLET_USERS_ARGON2_KEY_LENGTH", "32", 32, func(v uint64) { c.Argon2KeyLength = uint32(v) }},
+		{"VELMIE_WALLET_USERS_ARGON2_SALT_LENGTH", "16", 32, func(v uint64) { c.Argon2SaltLength = uint32(v) }},
+	}
+
+	for _, value := range values {
+		parsed, err := strconv.ParseUint(env_config.Env(value.env, value.def), 10, value.bits)
+		if err != nil || parsed == 0 {
+			return fmt.Errorf("%s environment variable must be a positive integer", value.env)
+		}
+		value.target(parsed)
+	}
+
+	c.PwnedPasswordsDir = env_config.Env("VELMIE_WALLET_USERS_PWNED_PASSWORDS_DIR", "")
+	if c.PwnedPasswordsDir != "" {
+		info, err := os.Stat(c.PwnedPasswordsDir)
+		if err != nil {
+			return fmt.Errorf("failed to read %s, set correct directory with VELMIE_WALLET_USERS_PWNED_PASSWORDS_DIR: %s", c.PwnedPasswordsDir, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory (VELMIE_WALLET_USERS_PWNED_PASSWORDS_DIR)", c.PwnedPasswordsDir)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Confialink/wallet-pkg-env_config"
+
+	"github.com/Confialink/wallet-users/pkg/ratelimit"
+)
+
+const (
+	// RateLimitBackendMemory keeps buckets in memory of every instance of the service
+	RateLimitBackendMemory = "memory"
+	// RateLimitBackendDb keeps buckets in the database, so they are shared by instances of the service
+	RateLimitBackendDb = "db"
+
+	RateLimitRouteSignIn           = "signin"
+	RateLimitRouteSignUp           = "signup"
+	RateLimitRouteForgotPassword   = "forgot-password"
+	RateLimitRouteResetPassword    = "reset-password"
+	RateLimitRouteConfirmationCode = "confirmation-code"
+	RateLimitRouteSecondFactor     = "second-factor"
+	RateLimitRouteWebauthnSignIn   = "webauthn-signin"
+)
+
+// RateLimitRule limits requests to a route per client IP and per identifier the request is made for
+// (an email, a phone number, a confirmation code or a user), the zero limit does not throttle requests
+type RateLimitRule struct {
+	IP         ratelimit.Limit
+	Identifier ratelimit.Limit
+}
+
+type RateLimitConfiguration struct {
+	Backend string
+	// Routes are rules by route names
+	Routes map[string]RateLimitRule
+}
+
+// Init initializes environment variables, rules are read from VELMIE_WALLET_USERS_RATE_LIMIT_<ROUTE>_IP
+// and VELMIE_WALLET_USERS_RATE_LIMIT_<ROUTE>_IDENTIFIER in the "requests/period" format, e.g. 5/1m
+func (c *RateLimitConfiguration) Init() error {
+	c.Backend = env_config.Env("VELMIE_WALLET_USERS_RATE_LIMIT_BACKEND", RateLimitBackendMemory)
+	if c.Backend != RateLimitBackendMemory && c.Backend != RateLimitBackendDb {
+		return fmt.Errorf(
+			"VELMIE_WALLET_USERS_RATE_LIMIT_BACKEND must be one of %s, %s",
+			RateLimitBackendMemory,
+			RateLimitBackendDb,
+		)
+	}
+
+	defaults := map[string][2]string{
+		RateLimitRouteSignIn:           {"30/1m", "10/1m"},
+		RateLimitRouteSignUp:           {"10/1h", "3/1h"},
+		RateLimitRouteForgotPassword:   {"10/1h", "3/1h"},
+		RateLimitRouteResetPassword:    {"20/1h", "5/1h"},
+		RateLimitRouteConfirmationCode: {"20/1m", "5/1m"},
+		RateLimitRouteSecondFactor:     {"30/1m", "5/1m"},
+		RateLimitRouteWebauthnSignIn:   {"30/1m", "10/1m"},
+	}
+
+	c.Routes = make(map[string]RateLimitRule, len(defaults))
+	for route, values := range defaults {
+		prefix := "VELMIE_WALLET_USERS_RATE_LIMIT_" + strings.ToUpper(strings.Replace(route, "-", "_", -1))
+
+		ip, err := ratelimit.ParseLimit(env_config.Env(prefix+"_IP", values[0]))
+		if err != nil {
+			return fmt.Errorf("%s_IP: %s", prefix, err)
+		}
+		identifier, err := ratelimit.ParseLimit(env_config.Env(prefix+"_IDENTIFIER", values[1]))
+		if err != nil {
+			return fmt.Errorf("%s_IDENTIFIER: %s", prefix, err)
+		}
+		c.Routes[route] = RateLimitRule{IP: ip, Identifier: identifier}
+	}
+	return nil
+}
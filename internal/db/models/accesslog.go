@@ -6,9 +6,14 @@ import (
 
 // AccessLog is the abstract accesslog model
 type AccessLog struct {
-	ALID      uint64    `gorm:"primary_key:yes;column:alid;unique_index" json:"alid"`
-	UID       string    `gorm:"column:uid;not null;default:0;" json:"uid"`
-	IP        string    `gorm:"column:ip;not null;default:0;" json:"ip"`
+	ALID      uint64 `gorm:"primary_key:yes;column:alid;unique_index" json:"alid"`
+	UID       string `gorm:"column:uid;not null;default:0;" json:"uid"`
+	IP        string `gorm:"column:ip;not null;default:0;" json:"ip"`
+	UserAgent string `gorm:"column:user_agent" json:"userAgent"`
+	// DeviceFingerprint is a hash of the device id sent by the client or of the user agent
+	DeviceFingerprint string `gorm:"column:device_fingerprint" json:"-"`
+	// RiskScore is the risk score (0-100) of the sign in
+	RiskScore uint8     `gorm:"column:risk_score" json:"riskScore"`
 	CreatedAt time.Time `json:"createdAt"`
 }
 
@@ -10,6 +10,12 @@ const (
 	// ConfirmationCodeSubjectSetPassword is used to enable a user to set a password when admin creates a profile
 	ConfirmationCodeSubjectSetPassword           = "set_password"
 	ConfirmationCodeSubjectEmailVerificationCode = "email_verification"
+	// ConfirmationCodeSubjectLoginVerification is sent to confirm a risky sign in
+	ConfirmationCodeSubjectLoginVerification = "login_verification"
+	// ConfirmationCodeSubjectEmailChange is sent to the new address to confirm the change of the email
+	ConfirmationCodeSubjectEmailChange = "email_change"
+	// ConfirmationCodeSubjectEmailChangeCancel is sent to the old address to cancel the change of the email
+	ConfirmationCodeSubjectEmailChangeCancel = "email_change_cancel"
 )
 
 type ConfirmationCode struct {
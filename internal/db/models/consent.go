@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// Types of documents users consent to
+const (
+	// ConsentDocumentTerms is the terms and conditions of the service
+	ConsentDocumentTerms = "terms"
+	// ConsentDocumentPrivacyPolicy is the privacy policy, it is the GDPR policy accepted at sign up
+	ConsentDocumentPrivacyPolicy = "privacy_policy"
+	// ConsentDocumentMarketing is the agreement to receive marketing communications
+	ConsentDocumentMarketing = "marketing"
+)
+
+// Consent is a record of the consent ledger: a user accepted the version of the document.
+// Records are never updated except for the withdrawal time, a new version is accepted by a new record.
+type Consent struct {
+	ID           uint64 `gorm:"primary_key" json:"id"`
+	UserUID      string `gorm:"column:user_uid" json:"uid"`
+	DocumentType string `gorm:"column:document_type" json:"documentType"`
+	// Version is the version of the document or the hash of its content
+	Version     string     `gorm:"column:version" json:"version"`
+	IP          string     `gorm:"column:ip" json:"ip"`
+	AcceptedAt  *time.Time `gorm:"column:accepted_at" json:"acceptedAt"`
+	WithdrawnAt *time.Time `gorm:"column:withdrawn_at" json:"withdrawnAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+func (*Consent) TableName() string {
+	return "users_consents"
+}
+
+// IsActive reports whether the consent is not withdrawn
+func (c *Consent) IsActive() bool {
+	return c.WithdrawnAt == nil
+}
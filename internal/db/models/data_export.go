@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// Statuses of exports of personal data
+const (
+	// DataExportStatusPending waits for the worker
+	DataExportStatusPending = "pending"
+	// DataExportStatusProcessing is set while the bundle is built
+	DataExportStatusProcessing = "processing"
+	// DataExportStatusReady is set when the bundle is uploaded to the files service
+	DataExportStatusReady = "ready"
+	// DataExportStatusFailed is set when the bundle can not be built or uploaded
+	DataExportStatusFailed = "failed"
+)
+
+// DataExport is a request to export personal data held about a user (GDPR right of access)
+type DataExport struct {
+	ID          uint64     `gorm:"primary_key" json:"id"`
+	UserUID     string     `gorm:"column:user_uid" json:"uid"`
+	RequestedBy string     `gorm:"column:requested_by" json:"requestedBy"`
+	Status      string     `gorm:"column:status" json:"status"`
+	FileName    string     `gorm:"column:file_name" json:"fileName"`
+	Error       string     `gorm:"column:error" json:"-"`
+	CompletedAt *time.Time `gorm:"column:completed_at" json:"completedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+func (*DataExport) TableName() string {
+	return "users_data_exports"
+}
+
+// IsOpen reports whether the bundle is not built yet
+func (e *DataExport) IsOpen() bool {
+	return e.Status == DataExportStatusPending || e.Status == DataExportStatusProcessing
+}
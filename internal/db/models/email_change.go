@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// Statuses of email changes
+const (
+	// EmailChangeStatusPending waits for the code sent to the new address
+	EmailChangeStatusPending = "pending"
+	// EmailChangeStatusConfirmed is set when the email of the user is replaced by the new address
+	EmailChangeStatusConfirmed = "confirmed"
+	// EmailChangeStatusCancelled is set when the change is cancelled from the old address or replaced by another one
+	EmailChangeStatusCancelled = "cancelled"
+)
+
+// EmailChange is a request of a user to replace the email, the new address is kept here until it is confirmed
+type EmailChange struct {
+	ID                uint64 `gorm:"primary_key" json:"-"`
+	UserUID           string `gorm:"column:user_uid" json:"-"`
+	OldEmail          string `gorm:"column:old_email" json:"-"`
+	OldEmailConfirmed bool   `gorm:"column:old_email_confirmed" json:"-"`
+	NewEmail          string `gorm:"column:new_email" json:"newEmail"`
+	Status            string `gorm:"column:status" json:"status"`
+	// CancelCodeID is the confirmation code sent to the old address to cancel the change
+	CancelCodeID uint64     `gorm:"column:cancel_code_id" json:"-"`
+	ConfirmedAt  *time.Time `gorm:"column:confirmed_at" json:"confirmedAt"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+func (*EmailChange) TableName() string {
+	return "users_email_changes"
+}
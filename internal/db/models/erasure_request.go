@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+// Statuses of erasure requests
+const (
+	// ErasureRequestStatusRequested waits for a review by an admin
+	ErasureRequestStatusRequested = "requested"
+	// ErasureRequestStatusApproved waits for the end of the grace period
+	ErasureRequestStatusApproved = "approved"
+	// ErasureRequestStatusRejected is set by an admin or when the user still has cards or accounts at erasure time
+	ErasureRequestStatusRejected = "rejected"
+	// ErasureRequestStatusCancelled is set when the user withdraws the request
+	ErasureRequestStatusCancelled = "cancelled"
+	// ErasureRequestStatusErased is set when personal data of the user is erased
+	ErasureRequestStatusErased = "erased"
+)
+
+// ErasureRequest is a request of a user to erase personal data (GDPR right to erasure)
+type ErasureRequest struct {
+	ID         uint64     `gorm:"primary_key" json:"id"`
+	UserUID    string     `gorm:"column:user_uid" json:"uid"`
+	Status     string     `gorm:"column:status" json:"status"`
+	ReviewedBy string     `gorm:"column:reviewed_by" json:"reviewedBy"`
+	ReviewedAt *time.Time `gorm:"column:reviewed_at" json:"reviewedAt"`
+	Reason     string     `gorm:"column:reason" json:"reason"`
+	EraseAfter *time.Time `gorm:"column:erase_after" json:"eraseAfter"`
+	ErasedAt   *time.Time `gorm:"column:erased_at" json:"erasedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+func (ErasureRequest) TableName() string {
+	return "users_erasure_requests"
+}
+
+// IsOpen reports whether the request may still lead to erasure
+func (r *ErasureRequest) IsOpen() bool {
+	return r.Status == ErasureRequestStatusRequested || r.Status == ErasureRequestStatusApproved
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// IdentityProvider is an external OpenID Connect provider (a company IdP) users may sign in with
+type IdentityProvider struct {
+	ID uint64 `gorm:"primary_key" json:"id"`
+	// Slug identifies the provider in public urls
+	Slug         string `gorm:"column:slug" json:"slug"`
+	Name         string `gorm:"column:name" json:"name"`
+	Issuer       string `gorm:"column:issuer" json:"issuer"`
+	ClientID     string `gorm:"column:client_id" json:"clientId"`
+	ClientSecret string `gorm:"column:client_secret" json:"-"`
+	// Scopes and Domains are space delimited lists
+	Scopes string `gorm:"column:scopes" json:"-"`
+	// Domains are email domains of the company, password sign in of their users is refused if PasswordLoginDisabled is set
+	Domains string `gorm:"column:domains" json:"-"`
+	// RoleClaim is a claim of the id token which values are mapped to roles, e.g. "groups" or "realm_access.roles".
+	// RoleMapping is a JSON object of claim values and role names.
+	RoleClaim   string `gorm:"column:role_claim" json:"roleClaim"`
+	RoleMapping string `gorm:"column:role_mapping" json:"-"`
+	// DefaultRole is assigned if none of the claim values is mapped
+	DefaultRole string `gorm:"column:default_role" json:"defaultRole"`
+	// GroupClaim and GroupMapping map claim values to ids of user groups
+	GroupClaim   string `gorm:"column:group_claim" json:"groupClaim"`
+	GroupMapping string `gorm:"column:group_mapping" json:"-"`
+	// JitEnabled allows to create a user on the first sign in
+	JitEnabled            bool      `gorm:"column:jit_enabled;not null;default:false" json:"jitEnabled"`
+	PasswordLoginDisabled bool      `gorm:"column:password_login_disabled;not null;default:false" json:"passwordLoginDisabled"`
+	IsActive              bool      `gorm:"column:is_active;not null;default:true" json:"isActive"`
+	CreatedAt             time.Time `json:"createdAt"`
+	UpdatedAt             time.Time `json:"updatedAt"`
+}
+
+func (*IdentityProvider) TableName() string {
+	return "identity_providers"
+}
+
+func (p *IdentityProvider) ScopeList() []string {
+	return strings.Fields(p.Scopes)
+}
+
+func (p *IdentityProvider) DomainList() []string {
+	return strings.Fields(p.Domains)
+}
+
+// HasDomain checks if the email belongs to one of domains of the provider
+func (p *IdentityProvider) HasDomain(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, d := range p.DomainList() {
+		if strings.ToLower(d) == domain {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleMap returns role names by claim values
+func (p *IdentityProvider) RoleMap() (map[string]string, error) {
+	roles := map[string]string{}
+	if p.RoleMapping == "" {
+		return roles, nil
+	}
+	err := json.Unmarshal([]byte(p.RoleMapping), &roles)
+	return roles, err
+}
+
+// GroupMap returns ids of user groups by claim values
+func (p *IdentityProvider) GroupMap() (map[string]uint64, error) {
+	groups := map[string]uint64{}
+	if p.GroupMapping == "" {
+		return groups, nil
+	}
+	err := json.Unmarshal([]byte(p.GroupMapping), &groups)
+	return groups, err
+}
+
+// UserIdentity links a subject of an identity provider to a user
+type UserIdentity struct {
+	ID          uint64            `gorm:"primary_key" json:"id"`
+	UserUID     string            `gorm:"column:user_uid" json:"-"`
+	ProviderID  uint64            `gorm:"column:provider_id" json:"providerId"`
+	Provider    *IdentityProvider `gorm:"foreignkey:ProviderID;association_autoupdate:false;association_autocreate:false" json:"-"`
+	Subject     string            `gorm:"column:subject" json:"-"`
+	Email       string            `gorm:"column:email" json:"email"`
+	LastLoginAt *time.Time        `gorm:"column:last_login_at" json:"lastLoginAt"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+}
+
+func (*UserIdentity) TableName() string {
+	return "users_identities"
+}
+
+// FederatedLoginState is a pending sign in at an identity provider, the state is stored as a hash and can be used only once.
+// UserUID is set if a signed in user links the provider to the account.
+type FederatedLoginState struct {
+	ID           uint64    `gorm:"primary_key"`
+	StateHash    string    `gorm:"column:state_hash"`
+	ProviderID   uint64    `gorm:"column:provider_id"`
+	Nonce        string    `gorm:"column:nonce"`
+	CodeVerifier string    `gorm:"column:code_verifier"`
+	UserUID      string    `gorm:"column:user_uid"`
+	ExpiresAt    time.Time `gorm:"column:expires_at"`
+	CreatedAt    time.Time
+}
+
+func (*FederatedLoginState) TableName() string {
+	return "federated_login_states"
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	// JwtKeyStatusCurrent is the key new tokens are signed with
+	JwtKeyStatusCurrent = "current"
+	// JwtKeyStatusPrevious is the key replaced by rotation, it is still used to verify issued tokens
+	JwtKeyStatusPrevious = "previous"
+	// JwtKeyStatusRetired is the key which is not used anymore, its private part is erased
+	JwtKeyStatusRetired = "retired"
+)
+
+// JwtKey is a member of the key ring used to sign and verify tokens
+type JwtKey struct {
+	ID        uint64 `gorm:"primary_key"`
+	Kid       string `gorm:"column:kid"`
+	Algorithm string `gorm:"column:algorithm"`
+	// PrivateKey is PEM encoded ECDSA private key or HMAC secret
+	PrivateKey string `gorm:"column:private_key"`
+	// PublicKey is PEM encoded ECDSA public key, it is empty for HMAC
+	PublicKey  string     `gorm:"column:public_key"`
+	Status     string     `gorm:"column:status"`
+	CreatedAt  time.Time  `gorm:"column:created_at"`
+	PromotedAt *time.Time `gorm:"column:promoted_at"`
+	DemotedAt  *time.Time `gorm:"column:demoted_at"`
+	RetiredAt  *time.Time `gorm:"column:retired_at"`
+}
+
+func (*JwtKey) TableName() string {
+	return "jwt_keys"
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// Statuses of sign in attempts
+const (
+	LoginStatusSuccess = "success"
+	LoginStatusFailed  = "failed"
+)
+
+// LoginHistoryEntry is a successful sign in from the access log or a failed attempt to sign in
+type LoginHistoryEntry struct {
+	UID       string    `gorm:"column:uid" json:"uid"`
+	IP        string    `gorm:"column:ip" json:"ip"`
+	UserAgent string    `gorm:"column:user_agent" json:"userAgent"`
+	RiskScore uint8     `gorm:"column:risk_score" json:"riskScore"`
+	Status    string    `gorm:"column:status" json:"status"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"createdAt"`
+}
+
+// TableName sets LoginHistoryEntry's table name to be `users_login_history`,
+// it is a view over `users_accesslog` and `fail_auth_attempts`
+func (LoginHistoryEntry) TableName() string {
+	return "users_login_history"
+}
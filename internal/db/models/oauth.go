@@ -0,0 +1,69 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// OAuthClient is an application registered to sign in users via OpenID Connect
+type OAuthClient struct {
+	ID       uint64 `gorm:"primary_key" json:"id"`
+	ClientID string `gorm:"column:client_id" json:"clientId"`
+	// SecretHash is empty for public clients (single page and mobile apps) which can't keep a secret
+	SecretHash string `gorm:"column:secret_hash" json:"-"`
+	Name       string `gorm:"column:name" json:"name"`
+	// RedirectURIs and Scopes are space delimited lists
+	RedirectURIs string    `gorm:"column:redirect_uris" json:"-"`
+	Scopes       string    `gorm:"column:scopes" json:"-"`
+	IsActive     bool      `gorm:"column:is_active;not null;default:true" json:"isActive"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+func (*OAuthClient) TableName() string {
+	return "oauth_clients"
+}
+
+// IsConfidential shows if the client must authenticate with the secret at the token endpoint
+func (c *OAuthClient) IsConfidential() bool {
+	return c.SecretHash != ""
+}
+
+func (c *OAuthClient) RedirectURIList() []string {
+	return strings.Fields(c.RedirectURIs)
+}
+
+func (c *OAuthClient) ScopeList() []string {
+	return strings.Fields(c.Scopes)
+}
+
+// HasRedirectURI checks if the uri is registered, uris are compared as exact strings
+func (c *OAuthClient) HasRedirectURI(uri string) bool {
+	for _, registered := range c.RedirectURIList() {
+		if registered == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// OAuthAuthorizationCode is issued to a client after the user has signed in, it is stored as a hash
+// and can be exchanged for tokens only once
+type OAuthAuthorizationCode struct {
+	ID                  uint64    `gorm:"primary_key"`
+	CodeHash            string    `gorm:"column:code_hash"`
+	ClientID            string    `gorm:"column:client_id"`
+	UserUID             string    `gorm:"column:user_uid"`
+	RedirectURI         string    `gorm:"column:redirect_uri"`
+	Scope               string    `gorm:"column:scope"`
+	Nonce               string    `gorm:"column:nonce"`
+	CodeChallenge       string    `gorm:"column:code_challenge"`
+	CodeChallengeMethod string    `gorm:"column:code_challenge_method"`
+	AuthTime            time.Time `gorm:"column:auth_time"`
+	ExpiresAt           time.Time `gorm:"column:expires_at"`
+	CreatedAt           time.Time
+}
+
+func (*OAuthAuthorizationCode) TableName() string {
+	return "oauth_authorization_codes"
+}
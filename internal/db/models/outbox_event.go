@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// OutboxEvent is a domain event which is stored in the same transaction as the change
+// and is published to the message broker afterwards
+type OutboxEvent struct {
+	ID          uint64     `gorm:"primary_key"`
+	EventID     string     `gorm:"column:event_id"`
+	Subject     string     `gorm:"column:subject"`
+	Payload     string     `gorm:"column:payload"`
+	Attempts    uint       `gorm:"column:attempts"`
+	LastError   string     `gorm:"column:last_error"`
+	CreatedAt   time.Time  `gorm:"column:created_at"`
+	PublishedAt *time.Time `gorm:"column:published_at"`
+}
+
+func (*OutboxEvent) TableName() string {
+	return "outbox_events"
+}
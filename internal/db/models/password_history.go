@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// PasswordHistory is a hash of a password the user has set, the latest record is the current password
+type PasswordHistory struct {
+	ID        uint64    `gorm:"primary_key"`
+	UserUID   string    `gorm:"column:user_uid"`
+	Password  string    `gorm:"column:password"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
+
+func (*PasswordHistory) TableName() string {
+	return "users_password_history"
+}
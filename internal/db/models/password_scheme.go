@@ -0,0 +1,49 @@
+package models
+
+import "strings"
+
+const (
+	// PasswordPrefixArgon2id starts argon2id hashes, it is the current scheme
+	PasswordPrefixArgon2id = "$argon2id$"
+
+	PasswordSchemeArgon2id = "argon2id"
+	// PasswordSchemeDrupalSha512 is stretched sha512 of Drupal 7 ($S$)
+	PasswordSchemeDrupalSha512 = "drupal-sha512"
+	// PasswordSchemeDrupalMd5 is stretched md5 of phpass ($H$, $P$)
+	PasswordSchemeDrupalMd5 = "drupal-md5"
+	// PasswordSchemeDrupalMd5Wrapped is a phpass hash of md5 of a password (U$), it is how Drupal 7 imported Drupal 6 hashes
+	PasswordSchemeDrupalMd5Wrapped = "drupal-md5-wrapped"
+	PasswordSchemeBcrypt           = "bcrypt"
+	PasswordSchemeUnknown          = "unknown"
+)
+
+// PasswordSchemePrefix tells which scheme hashes starting with the prefix are made by
+type PasswordSchemePrefix struct {
+	Prefix string
+	Scheme string
+}
+
+// passwordSchemePrefixes lists prefixes of hashes of all known schemes
+var passwordSchemePrefixes = []PasswordSchemePrefix{
+	{PasswordPrefixArgon2id, PasswordSchemeArgon2id},
+	{"U$", PasswordSchemeDrupalMd5Wrapped},
+	{"$S$", PasswordSchemeDrupalSha512},
+	{"$H$", PasswordSchemeDrupalMd5},
+	{"$P$", PasswordSchemeDrupalMd5},
+	{"$2a$", PasswordSchemeBcrypt},
+}
+
+// PasswordScheme returns the scheme the hash is made by
+func PasswordScheme(hash string) string {
+	for _, item := range passwordSchemePrefixes {
+		if strings.HasPrefix(hash, item.Prefix) {
+			return item.Scheme
+		}
+	}
+	return PasswordSchemeUnknown
+}
+
+// PasswordSchemePrefixes returns prefixes of hashes of all known schemes
+func PasswordSchemePrefixes() []PasswordSchemePrefix {
+	return append([]PasswordSchemePrefix(nil), passwordSchemePrefixes...)
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+// Phone numbers of a user which are changed after confirmation
+const (
+	// PhoneChangeFieldPhoneNumber is the phone number the user signs in with
+	PhoneChangeFieldPhoneNumber = "phoneNumber"
+	// PhoneChangeFieldSmsPhoneNumber is the phone number the user receives SMS to
+	PhoneChangeFieldSmsPhoneNumber = "smsPhoneNumber"
+)
+
+// Statuses of phone number changes
+const (
+	// PhoneChangeStatusPending waits for the code sent to the new number
+	PhoneChangeStatusPending = "pending"
+	// PhoneChangeStatusConfirmed is set when the number of the user is replaced by the new one
+	PhoneChangeStatusConfirmed = "confirmed"
+	// PhoneChangeStatusCancelled is set when the change is replaced by another one
+	PhoneChangeStatusCancelled = "cancelled"
+)
+
+// PhoneChange is a request of a user to replace a phone number, the new number is kept here until it is confirmed
+type PhoneChange struct {
+	ID             uint64 `gorm:"primary_key" json:"-"`
+	UserUID        string `gorm:"column:user_uid" json:"-"`
+	Field          string `gorm:"column:field" json:"field"`
+	OldPhoneNumber string `gorm:"column:old_phone_number" json:"-"`
+	NewPhoneNumber string `gorm:"column:new_phone_number" json:"newPhoneNumber"`
+	Status         string `gorm:"column:status" json:"status"`
+	// ConfirmationCodeID is the code sent to the new number, only this code confirms the change
+	ConfirmationCodeID *uint64    `gorm:"column:confirmation_code_id" json:"-"`
+	ConfirmedAt        *time.Time `gorm:"column:confirmed_at" json:"confirmedAt"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	UpdatedAt          time.Time  `json:"updatedAt"`
+}
+
+func (*PhoneChange) TableName() string {
+	return "users_phone_changes"
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// RateLimitBucket is a token bucket of rate limiting shared by instances of the service
+type RateLimitBucket struct {
+	Key   string  `gorm:"primary_key;column:key"`
+	Taken float64 `gorm:"column:taken"`
+	// TakenAt is the time a token was taken last, tokens are refilled since then
+	TakenAt time.Time `gorm:"column:taken_at"`
+	// ExpiresAt is the time the bucket is refilled, expired buckets are removed
+	ExpiresAt time.Time `gorm:"column:expires_at"`
+}
+
+func (*RateLimitBucket) TableName() string {
+	return "rate_limit_buckets"
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	// SecurityEventRefreshTokenReuse is recorded when a rotated refresh token is presented again
+	SecurityEventRefreshTokenReuse = "refresh_token_reuse"
+	// SecurityEventRiskySignIn is recorded when sign in has to be confirmed because of its risk score
+	SecurityEventRiskySignIn = "risky_sign_in"
+	// SecurityEventEmailChangeRequested is recorded when a user asks to change the email
+	SecurityEventEmailChangeRequested = "email_change_requested"
+	// SecurityEventEmailChangeCancelled is recorded when the change of the email is cancelled from the old address
+	SecurityEventEmailChangeCancelled = "email_change_cancelled"
+	// SecurityEventPhoneChangeRequested is recorded when a user asks to change a phone number
+	SecurityEventPhoneChangeRequested = "phone_change_requested"
+	// SecurityEventErasureRequested is recorded when a user asks to erase personal data
+	SecurityEventErasureRequested = "erasure_requested"
+)
+
+// SecurityEvent is a record about suspicious activity related to a user account
+type SecurityEvent struct {
+	ID        uint64    `gorm:"primary_key" json:"id"`
+	UserUID   string    `gorm:"column:user_uid" json:"-"`
+	Type      string    `gorm:"column:type" json:"type"`
+	IP        string    `gorm:"column:ip" json:"ip"`
+	UserAgent string    `gorm:"column:user_agent" json:"userAgent"`
+	Details   string    `gorm:"column:details" json:"details"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (*SecurityEvent) TableName() string {
+	return "security_events"
+}
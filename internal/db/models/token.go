@@ -14,4 +14,22 @@ type Token struct {
 	RefreshToken   *Token `gorm:"foreignkey:RefreshTokenId;association_foreignkey:ID;association_autoupdate:false"`
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
+	// ExpiresAt is the "exp" claim of the token, it is nil for tokens issued before it was stored
+	ExpiresAt *time.Time `gorm:"column:expires_at"`
+
+	// Session (device) metadata, it is filled for refresh tokens only
+	DeviceID    string     `gorm:"column:device_id"`
+	UserAgent   string     `gorm:"column:user_agent"`
+	IP          string     `gorm:"column:ip"`
+	FirstSeenAt *time.Time `gorm:"column:first_seen_at"`
+	LastUsedAt  *time.Time `gorm:"column:last_used_at"`
+
+	// FamilyID is shared by all refresh tokens produced by rotation of the same sign in
+	FamilyID string `gorm:"column:family_id"`
+	// RevokedAt is set when the refresh token is rotated, revoked tokens are kept in order to detect reuse
+	RevokedAt *time.Time `gorm:"column:revoked_at"`
+
+	// ClientID and Scope are set for tokens issued to OAuth clients, empty ClientID means the wallet itself
+	ClientID string `gorm:"column:client_id"`
+	Scope    string `gorm:"column:scope"`
 }
@@ -37,10 +37,22 @@ const (
 	ProfileTypePersonal  = "Personal"
 	ProfileTypeCorporate = "Corporate"
 
-	letterBytes       = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
-	minPasswordLength = 8
+	letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	// generatedPasswordLength is the length of generated passwords,
+	// requirements to passwords set by users are defined by the password policy in system settings
+	generatedPasswordLength = 16
 
 	ChallengeNameNewPasswordRequired = "new_password_required"
+	// ChallengeNameMfaRequired means that a user has to provide a TOTP or a recovery code to complete sign in
+	ChallengeNameMfaRequired = "mfa_required"
+	// ChallengeNameMfaSetupRequired means that a user must enroll an authenticator before tokens are issued
+	ChallengeNameMfaSetupRequired = "mfa_setup_required"
+	// ChallengeNameCaptchaRequired means that sign in must be repeated with a CAPTCHA token after too many failed attempts
+	ChallengeNameCaptchaRequired = "captcha_required"
+	// ChallengeNameStepUpRequired means that a risky sign in must be confirmed by a code sent to the email or the phone
+	ChallengeNameStepUpRequired = "step_up_required"
+	// ChallengeNameConsentRequired means that a new version of the terms or the privacy policy must be accepted
+	ChallengeNameConsentRequired = "consent_required"
 )
 
 // User is the abstract user model
@@ -131,9 +143,10 @@ func (user *User) GetProfileType() string {
 // $H$.... - drupal md5
 // $P$.... - drupal md5
 // $2a$... - bcrypt
+// $argon2id$... - argon2id
 func (user *User) IsPasswordEncrypted() bool {
 	var password = user.Password
-	var isbcrypt = regexp.MustCompile(`^U\$|\$S\$|\$H\$|\$P\$|\$2a\$`)
+	var isbcrypt = regexp.MustCompile(`^U\$|\$S\$|\$H\$|\$P\$|\$2a\$|^\$argon2id\$`)
 	var hashLength = 55 // number of characters in a hashed password
 
 	if len(password) >= hashLength && isbcrypt.MatchString(password) {
@@ -144,7 +157,7 @@ func (user *User) IsPasswordEncrypted() bool {
 
 // GeneratePassword generates new password
 func (user *User) GeneratePassword() error {
-	b := make([]byte, minPasswordLength)
+	b := make([]byte, generatedPasswordLength)
 	for i := range b {
 		b[i] = letterBytes[rand.Intn(len(letterBytes))]
 	}
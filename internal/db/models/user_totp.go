@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// UserTotp keeps a TOTP (RFC 6238) authenticator enrolled by a user
+type UserTotp struct {
+	ID           uint64     `gorm:"primary_key" json:"-"`
+	UserUID      string     `gorm:"column:user_uid" json:"-"`
+	Secret       string     `gorm:"column:secret" json:"-"`
+	IsConfirmed  bool       `gorm:"column:is_confirmed;not null;default:false" json:"isConfirmed"`
+	LastUsedStep int64      `gorm:"column:last_used_step;not null;default:0" json:"-"`
+	ConfirmedAt  *time.Time `gorm:"column:confirmed_at" json:"confirmedAt"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+func (*UserTotp) TableName() string {
+	return "users_totp"
+}
+
+// MfaRecoveryCode is a one-time code which may be used instead of a TOTP code
+type MfaRecoveryCode struct {
+	ID        uint64     `gorm:"primary_key" json:"-"`
+	UserUID   string     `gorm:"column:user_uid" json:"-"`
+	CodeHash  string     `gorm:"column:code_hash" json:"-"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"usedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+func (*MfaRecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+)
+
+// Ceremonies a WebAuthn challenge may be issued for
+const (
+	WebauthnCeremonyRegistration = "registration"
+	// WebauthnCeremonyMfa is an assertion used as the second factor after the password
+	WebauthnCeremonyMfa = "mfa"
+	// WebauthnCeremonyLogin is an assertion of a discoverable credential used instead of the password
+	WebauthnCeremonyLogin = "login"
+)
+
+// WebauthnCredential is a FIDO2 credential (security key or passkey) registered by a user
+type WebauthnCredential struct {
+	ID           uint64     `gorm:"primary_key" json:"id"`
+	UserUID      string     `gorm:"column:user_uid" json:"-"`
+	CredentialID string     `gorm:"column:credential_id" json:"credentialId"`
+	PublicKey    []byte     `gorm:"column:public_key" json:"-"`
+	SignCount    uint32     `gorm:"column:sign_count;not null;default:0" json:"-"`
+	AAGUID       string     `gorm:"column:aaguid" json:"aaguid"`
+	Name         string     `gorm:"column:name" json:"name"`
+	Transports   string     `gorm:"column:transports" json:"-"`
+	UserVerified bool       `gorm:"column:user_verified;not null;default:false" json:"userVerified"`
+	LastUsedAt   *time.Time `gorm:"column:last_used_at" json:"lastUsedAt"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+func (*WebauthnCredential) TableName() string {
+	return "users_webauthn_credentials"
+}
+
+// WebauthnChallenge is a pending ceremony, the challenge is stored as a hash and can be used only once
+type WebauthnChallenge struct {
+	ID            uint64    `gorm:"primary_key"`
+	ChallengeHash string    `gorm:"column:challenge_hash"`
+	Ceremony      string    `gorm:"column:ceremony"`
+	UserUID       string    `gorm:"column:user_uid"`
+	ExpiresAt     time.Time `gorm:"column:expires_at"`
+	CreatedAt     time.Time
+}
+
+func (*WebauthnChallenge) TableName() string {
+	return "webauthn_challenges"
+}
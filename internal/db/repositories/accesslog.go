@@ -8,6 +8,7 @@ import (
 // AccesLogRepositoryHandler is interface for repository functionality that ought to be implemented manually.
 type AccesLogRepositoryHandler interface {
 	FindByUID(uid string) (*models.AccessLog, error)
+	FindRecentByUID(uid string, limit int) ([]*models.AccessLog, error)
 	Create(accessLog *models.AccessLog) error
 }
 
@@ -30,9 +31,22 @@ func (repo *AccesLogRepository) FindByUID(uid string) (*models.AccessLog, error)
 	return accessLog, nil
 }
 
+// FindRecentByUID returns the latest records of the user, the newest first
+func (repo *AccesLogRepository) FindRecentByUID(uid string, limit int) ([]*models.AccessLog, error) {
+	var accessLogs []*models.AccessLog
+	if err := repo.DB.Raw("SELECT `alid`, `uid`, INET_NTOA(`ip`) AS `ip`, `user_agent`, `device_fingerprint`, `risk_score`, `created_at` "+
+		"FROM users_accesslog WHERE `uid` = ? ORDER BY `created_at` DESC, `alid` DESC LIMIT ?", uid, limit).
+		Scan(&accessLogs).Error; err != nil {
+		return nil, err
+	}
+	return accessLogs, nil
+}
+
 // Create creates new record
 func (repo *AccesLogRepository) Create(accessLog *models.AccessLog) error {
-	if err := repo.DB.Exec("INSERT INTO users_accesslog (`uid`, `ip`, `created_at`) VALUES (?, INET_ATON(?), CURRENT_TIMESTAMP())", accessLog.UID, accessLog.IP).
+	if err := repo.DB.Exec("INSERT INTO users_accesslog (`uid`, `ip`, `user_agent`, `device_fingerprint`, `risk_score`, `created_at`) "+
+		"VALUES (?, INET_ATON(?), ?, ?, ?, CURRENT_TIMESTAMP())",
+		accessLog.UID, accessLog.IP, accessLog.UserAgent, accessLog.DeviceFingerprint, accessLog.RiskScore).
 		Error; err != nil {
 		return err
 	}
@@ -30,6 +30,15 @@ func (r *AddressRepository) FindPhysicalByUser(userId string) ([]*models.Address
 	return r.findByUserAndType(userId, models.AddressTypePhysical)
 }
 
+// FindByUser returns mailing and physical addresses of the user
+func (r *AddressRepository) FindByUser(userId string) ([]*models.Address, error) {
+	var records []*models.Address
+	if err := r.db.Where("user_id = ?", userId).Order("id").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("could not find addresses with user_id `%s` in database", userId)
+	}
+	return records, nil
+}
+
 func (r *AddressRepository) findByUserAndType(userId, addressType string) ([]*models.Address, error) {
 	var records []*models.Address
 	if err := r.db.Where("user_id = ? AND type = ?", userId, addressType).Find(&records).Error; err != nil {
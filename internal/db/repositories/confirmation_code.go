@@ -88,12 +88,32 @@ func (repo *ConfirmationCodeRepository) CreateNewVerificationCode(code *models.C
 	return repo.Create(code)
 }
 
+// FindActiveByCodeSubjectAndUser finds not expired code of the user
+func (repo *ConfirmationCodeRepository) FindActiveByCodeSubjectAndUser(code string, subject string, user *models.User) (*models.ConfirmationCode, error) {
+	model := &models.ConfirmationCode{}
+	if err := repo.DB.
+		Where("user_uid = ?", user.UID).
+		Where("subject = ?", subject).
+		Where("code = ?", code).
+		Where("expires_at >= ?", time.Now()).
+		First(model).Error; err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// CheckPhoneCode checks the code sent to the current phone number of the user,
+// a code sent to the new number of a pending phone change does not confirm the current one
 func (repo *ConfirmationCodeRepository) CheckPhoneCode(phoneCode string, user *models.User) error {
 	return repo.DB.
 		Where("user_uid = ?", user.UID).
 		Where("subject = ?", models.ConfirmationCodeSubjectPhoneVerificationCode).
 		Where("code = ?", phoneCode).
 		Where("expires_at >= ?", time.Now()).
+		Where("id NOT IN (?)", repo.DB.Model(&models.PhoneChange{}).
+			Select("confirmation_code_id").
+			Where("status = ? AND confirmation_code_id IS NOT NULL", models.PhoneChangeStatusPending).
+			QueryExpr()).
 		First(&models.ConfirmationCode{}).Error
 }
 
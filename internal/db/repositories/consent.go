@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+type ConsentRepository struct {
+	DB *gorm.DB
+}
+
+func NewConsentRepository(db *gorm.DB) *ConsentRepository {
+	return &ConsentRepository{DB: db}
+}
+
+func (repo *ConsentRepository) Create(consent *models.Consent) error {
+	return repo.DB.Create(consent).Error
+}
+
+// FindByUID returns the consent ledger of the user, the latest records first
+func (repo *ConsentRepository) FindByUID(uid string) ([]*models.Consent, error) {
+	var consents []*models.Consent
+	if err := repo.DB.Where("user_uid = ?", uid).Order("id DESC").Find(&consents).Error; err != nil {
+		return nil, err
+	}
+	return consents, nil
+}
+
+// FindActive returns the latest consent of the user to the document which is not withdrawn
+func (repo *ConsentRepository) FindActive(uid, documentType string) (*models.Consent, error) {
+	consent := &models.Consent{}
+	if err := repo.DB.Where("user_uid = ? AND document_type = ? AND withdrawn_at IS NULL", uid, documentType).
+		Order("id DESC").
+		First(consent).Error; err != nil {
+		return nil, err
+	}
+	return consent, nil
+}
+
+// Withdraw sets the withdrawal time of consents of the user to the document, it returns the number of withdrawn consents
+func (repo *ConsentRepository) Withdraw(uid, documentType string, at time.Time) (int64, error) {
+	res := repo.DB.Model(&models.Consent{}).
+		Where("user_uid = ? AND document_type = ? AND withdrawn_at IS NULL", uid, documentType).
+		Update("withdrawn_at", at)
+	return res.RowsAffected, res.Error
+}
+
+// SetChallengeForOutdated sets the challenge of users who consented to the document but not to its version.
+// A challenge which is already set is kept. It returns the number of updated users.
+func (repo *ConsentRepository) SetChallengeForOutdated(documentType, version, challengeName string) (int64, error) {
+	consented := repo.DB.Model(&models.Consent{}).Select("user_uid").
+		Where("document_type = ? AND withdrawn_at IS NULL", documentType)
+	res := repo.DB.Model(&models.User{}).
+		Where("challenge_name IS NULL").
+		Where("uid IN (?)", consented.QueryExpr()).
+		Where("uid NOT IN (?)", consented.Where("version = ?", version).QueryExpr()).
+		UpdateColumn("challenge_name", challengeName)
+	return res.RowsAffected, res.Error
+}
+
+func (copy ConsentRepository) WrapContext(db *gorm.DB) *ConsentRepository {
+	copy.DB = db
+	return &copy
+}
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+type DataExportRepository struct {
+	DB *gorm.DB
+}
+
+func NewDataExportRepository(db *gorm.DB) *DataExportRepository {
+	return &DataExportRepository{DB: db}
+}
+
+func (repo *DataExportRepository) Create(export *models.DataExport) error {
+	return repo.DB.Create(export).Error
+}
+
+func (repo *DataExportRepository) Save(export *models.DataExport) error {
+	return repo.DB.Save(export).Error
+}
+
+// FindLatestByUID returns the last requested export of the user
+func (repo *DataExportRepository) FindLatestByUID(uid string) (*models.DataExport, error) {
+	export := &models.DataExport{}
+	if err := repo.DB.Where("user_uid = ?", uid).Order("id DESC").First(export).Error; err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// FindPending returns exports waiting for the worker in the order they were requested
+func (repo *DataExportRepository) FindPending(limit int) ([]*models.DataExport, error) {
+	var exports []*models.DataExport
+	err := repo.DB.Where("status = ?", models.DataExportStatusPending).
+		Order("id").
+		Limit(limit).
+		Find(&exports).Error
+	return exports, err
+}
+
+// Claim moves the pending export to processing, false is returned if another instance has claimed it before
+func (repo *DataExportRepository) Claim(export *models.DataExport) (bool, error) {
+	res := repo.DB.Model(&models.DataExport{}).
+		Where("id = ? AND status = ?", export.ID, models.DataExportStatusPending).
+		Update("status", models.DataExportStatusProcessing)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+	export.Status = models.DataExportStatusProcessing
+	return true, nil
+}
+
+func (copy DataExportRepository) WrapContext(db *gorm.DB) *DataExportRepository {
+	copy.DB = db
+	return &copy
+}
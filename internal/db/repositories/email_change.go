@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+type EmailChangeRepository struct {
+	DB *gorm.DB
+}
+
+func NewEmailChangeRepository(db *gorm.DB) *EmailChangeRepository {
+	return &EmailChangeRepository{DB: db}
+}
+
+func (repo *EmailChangeRepository) Create(change *models.EmailChange) error {
+	return repo.DB.Create(change).Error
+}
+
+func (repo *EmailChangeRepository) Save(change *models.EmailChange) error {
+	return repo.DB.Save(change).Error
+}
+
+// FindPendingByUID returns the email change of the user which waits for confirmation
+func (repo *EmailChangeRepository) FindPendingByUID(uid string) (*models.EmailChange, error) {
+	change := &models.EmailChange{}
+	if err := repo.DB.Where("user_uid = ? AND status = ?", uid, models.EmailChangeStatusPending).
+		Order("id DESC").
+		First(change).Error; err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// FindByCancelCodeID returns the email change the cancel code is sent for
+func (repo *EmailChangeRepository) FindByCancelCodeID(codeID uint64) (*models.EmailChange, error) {
+	change := &models.EmailChange{}
+	if err := repo.DB.Where("cancel_code_id = ?", codeID).First(change).Error; err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// CancelPendingByUID cancels email changes of the user which wait for confirmation
+func (repo *EmailChangeRepository) CancelPendingByUID(uid string) error {
+	return repo.DB.Model(&models.EmailChange{}).
+		Where("user_uid = ? AND status = ?", uid, models.EmailChangeStatusPending).
+		Update("status", models.EmailChangeStatusCancelled).Error
+}
+
+func (copy EmailChangeRepository) WrapContext(db *gorm.DB) *EmailChangeRepository {
+	copy.DB = db
+	return &copy
+}
@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/Confialink/wallet-pkg-list_params"
+	"github.com/Confialink/wallet-pkg-list_params/adapters"
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+type ErasureRequestRepository struct {
+	DB *gorm.DB
+}
+
+func NewErasureRequestRepository(db *gorm.DB) *ErasureRequestRepository {
+	return &ErasureRequestRepository{DB: db}
+}
+
+func (repo *ErasureRequestRepository) Create(request *models.ErasureRequest) error {
+	return repo.DB.Create(request).Error
+}
+
+func (repo *ErasureRequestRepository) Save(request *models.ErasureRequest) error {
+	return repo.DB.Save(request).Error
+}
+
+func (repo *ErasureRequestRepository) FindByID(id uint64) (*models.ErasureRequest, error) {
+	request := &models.ErasureRequest{}
+	if err := repo.DB.Where("id = ?", id).First(request).Error; err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// FindOpenByUID returns the request of the user which is not reviewed yet or waits for erasure
+func (repo *ErasureRequestRepository) FindOpenByUID(uid string) (*models.ErasureRequest, error) {
+	request := &models.ErasureRequest{}
+	if err := repo.DB.
+		Where("user_uid = ? AND status IN (?)", uid, []string{
+			models.ErasureRequestStatusRequested,
+			models.ErasureRequestStatusApproved,
+		}).
+		Order("id DESC").
+		First(request).Error; err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// FindDue returns approved requests which grace period is over
+func (repo *ErasureRequestRepository) FindDue(now time.Time, limit int) ([]*models.ErasureRequest, error) {
+	var requests []*models.ErasureRequest
+	err := repo.DB.
+		Where("status = ? AND erase_after <= ?", models.ErasureRequestStatusApproved, now).
+		Order("erase_after").
+		Limit(limit).
+		Find(&requests).Error
+	return requests, err
+}
+
+// GetList returns erasure requests by passed params
+func (repo *ErasureRequestRepository) GetList(params *list_params.ListParams) ([]*models.ErasureRequest, error) {
+	var requests []*models.ErasureRequest
+	adapter := adapters.NewGorm(repo.DB)
+	err := adapter.LoadList(&requests, params, models.ErasureRequest{}.TableName())
+	return requests, err
+}
+
+func (copy ErasureRequestRepository) WrapContext(db *gorm.DB) *ErasureRequestRepository {
+	copy.DB = db
+	return &copy
+}
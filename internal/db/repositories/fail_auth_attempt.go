@@ -39,6 +39,28 @@ func (repo *FailAuthAttemptRepository) GetCountByUID(uid string, timeFrom time.T
 	return count
 }
 
+// GetLatestByIp returns the time of the latest attempt from the ip created since timeFrom, it is nil if there are none
+func (repo *FailAuthAttemptRepository) GetLatestByIp(ip string, timeFrom time.Time) *time.Time {
+	var latest struct{ CreatedAt *time.Time }
+	repo.DB.Model(&models.FailAuthAttempt{}).
+		Select("MAX(created_at) AS created_at").
+		Where("ip = INET_ATON(?) AND created_at >= ?", ip, timeFrom).
+		Scan(&latest)
+
+	return latest.CreatedAt
+}
+
+// GetLatestByUID returns the time of the latest attempt of the user created since timeFrom, it is nil if there are none
+func (repo *FailAuthAttemptRepository) GetLatestByUID(uid string, timeFrom time.Time) *time.Time {
+	var latest struct{ CreatedAt *time.Time }
+	repo.DB.Model(&models.FailAuthAttempt{}).
+		Select("MAX(created_at) AS created_at").
+		Where("uid = ? AND created_at >= ?", uid, timeFrom).
+		Scan(&latest)
+
+	return latest.CreatedAt
+}
+
 func (repo *FailAuthAttemptRepository) DeleteAllByIp(ip string) error {
 	if err := repo.DB.Where("ip = INET_ATON(?)", ip).Delete(models.FailAuthAttempt{}).Error; err != nil {
 		return err
@@ -0,0 +1,163 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+type IdentityProviderRepository struct {
+	DB *gorm.DB
+}
+
+func NewIdentityProviderRepository(db *gorm.DB) *IdentityProviderRepository {
+	return &IdentityProviderRepository{DB: db}
+}
+
+func (repo *IdentityProviderRepository) FindAll() ([]*models.IdentityProvider, error) {
+	var list []*models.IdentityProvider
+	if err := repo.DB.Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (repo *IdentityProviderRepository) FindActive() ([]*models.IdentityProvider, error) {
+	var list []*models.IdentityProvider
+	if err := repo.DB.Where("is_active = ?", true).Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (repo *IdentityProviderRepository) FindByID(id uint64) (*models.IdentityProvider, error) {
+	model := &models.IdentityProvider{}
+	if err := repo.DB.Where("id = ?", id).First(model).Error; err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func (repo *IdentityProviderRepository) FindBySlug(slug string) (*models.IdentityProvider, error) {
+	model := &models.IdentityProvider{}
+	if err := repo.DB.Where("slug = ?", slug).First(model).Error; err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func (repo *IdentityProviderRepository) Create(model *models.IdentityProvider) error {
+	return repo.DB.Create(model).Error
+}
+
+func (repo *IdentityProviderRepository) Update(model *models.IdentityProvider) error {
+	return repo.DB.Save(model).Error
+}
+
+func (repo *IdentityProviderRepository) Delete(model *models.IdentityProvider) error {
+	return repo.DB.Delete(model).Error
+}
+
+func (copy IdentityProviderRepository) WrapContext(db *gorm.DB) *IdentityProviderRepository {
+	copy.DB = db
+	return &copy
+}
+
+type UserIdentityRepository struct {
+	DB *gorm.DB
+}
+
+func NewUserIdentityRepository(db *gorm.DB) *UserIdentityRepository {
+	return &UserIdentityRepository{DB: db}
+}
+
+func (repo *UserIdentityRepository) FindByUserUID(uid string) ([]*models.UserIdentity, error) {
+	var list []*models.UserIdentity
+	if err := repo.DB.Preload("Provider").Where("user_uid = ?", uid).Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (repo *UserIdentityRepository) FindByProviderAndSubject(providerID uint64, subject string) (*models.UserIdentity, error) {
+	model := &models.UserIdentity{}
+	if err := repo.DB.Where("provider_id = ? AND subject = ?", providerID, subject).First(model).Error; err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func (repo *UserIdentityRepository) FindByUserAndProvider(uid string, providerID uint64) (*models.UserIdentity, error) {
+	model := &models.UserIdentity{}
+	if err := repo.DB.Where("user_uid = ? AND provider_id = ?", uid, providerID).First(model).Error; err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func (repo *UserIdentityRepository) Create(model *models.UserIdentity) error {
+	return repo.DB.Create(model).Error
+}
+
+func (repo *UserIdentityRepository) UpdateLastLogin(model *models.UserIdentity, email string, at time.Time) error {
+	return repo.DB.Model(model).Updates(map[string]interface{}{"email": email, "last_login_at": at}).Error
+}
+
+// DeleteByIDAndUserUID removes the identity only if it is linked to the given user
+func (repo *UserIdentityRepository) DeleteByIDAndUserUID(id uint64, uid string) error {
+	res := repo.DB.Where("id = ? AND user_uid = ?", id, uid).Delete(&models.UserIdentity{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (copy UserIdentityRepository) WrapContext(db *gorm.DB) *UserIdentityRepository {
+	copy.DB = db
+	return &copy
+}
+
+type FederatedLoginStateRepository struct {
+	DB *gorm.DB
+}
+
+func NewFederatedLoginStateRepository(db *gorm.DB) *FederatedLoginStateRepository {
+	return &FederatedLoginStateRepository{DB: db}
+}
+
+func (repo *FederatedLoginStateRepository) Create(model *models.FederatedLoginState) error {
+	return repo.DB.Create(model).Error
+}
+
+// Consume finds a not expired state and removes it. It returns a record not found error
+// if there is no such state or it has been consumed concurrently, so every state can be used only once.
+func (repo *FederatedLoginStateRepository) Consume(stateHash string) (*models.FederatedLoginState, error) {
+	model := &models.FederatedLoginState{}
+	if err := repo.DB.Where("state_hash = ? AND expires_at > ?", stateHash, time.Now()).First(model).Error; err != nil {
+		return nil, err
+	}
+
+	res := repo.DB.Where("id = ?", model.ID).Delete(&models.FederatedLoginState{})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return model, nil
+}
+
+// DeleteExpired removes states of sign ins which were not completed
+func (repo *FederatedLoginStateRepository) DeleteExpired() error {
+	return repo.DB.Where("expires_at <= ?", time.Now()).Delete(&models.FederatedLoginState{}).Error
+}
+
+func (copy FederatedLoginStateRepository) WrapContext(db *gorm.DB) *FederatedLoginStateRepository {
+	copy.DB = db
+	return &copy
+}
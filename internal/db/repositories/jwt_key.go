@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+type JwtKeyRepository struct {
+	DB *gorm.DB
+}
+
+func NewJwtKeyRepository(db *gorm.DB) *JwtKeyRepository {
+	return &JwtKeyRepository{DB: db}
+}
+
+// FindNotRetiredByAlgorithm returns current and previous keys of the algorithm
+func (repo *JwtKeyRepository) FindNotRetiredByAlgorithm(algorithm string) ([]*models.JwtKey, error) {
+	var keys []*models.JwtKey
+	if err := repo.DB.
+		Where("algorithm = ? AND status <> ?", algorithm, models.JwtKeyStatusRetired).
+		Order("id DESC").
+		Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// FindPreviousDemotedBefore returns keys of the algorithm replaced by rotation earlier than the given time
+func (repo *JwtKeyRepository) FindPreviousDemotedBefore(algorithm string, before time.Time) ([]*models.JwtKey, error) {
+	var keys []*models.JwtKey
+	if err := repo.DB.
+		Where("algorithm = ? AND status = ? AND demoted_at < ?", algorithm, models.JwtKeyStatusPrevious, before).
+		Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (repo *JwtKeyRepository) Create(key *models.JwtKey) error {
+	return repo.DB.Create(key).Error
+}
+
+// Promote makes the key current, the current key of the same algorithm becomes previous
+func (repo *JwtKeyRepository) Promote(key *models.JwtKey) error {
+	now := time.Now()
+	tx := repo.DB.Begin()
+	if err := tx.Model(&models.JwtKey{}).
+		Where("algorithm = ? AND status = ?", key.Algorithm, models.JwtKeyStatusCurrent).
+		Updates(map[string]interface{}{"status": models.JwtKeyStatusPrevious, "demoted_at": now}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	key.Status = models.JwtKeyStatusCurrent
+	key.PromotedAt = &now
+	if err := tx.Save(key).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// Retire marks the key as retired and erases its private part
+func (repo *JwtKeyRepository) Retire(key *models.JwtKey) error {
+	now := time.Now()
+	key.Status = models.JwtKeyStatusRetired
+	key.PrivateKey = ""
+	key.RetiredAt = &now
+	return repo.DB.Model(key).
+		Updates(map[string]interface{}{"status": key.Status, "private_key": key.PrivateKey, "retired_at": now}).Error
+}
+
+// UpdatePrivateKey replaces the stored private key of the key
+func (repo *JwtKeyRepository) UpdatePrivateKey(key *models.JwtKey, privateKey string) error {
+	if err := repo.DB.Model(key).UpdateColumn("private_key", privateKey).Error; err != nil {
+		return err
+	}
+	key.PrivateKey = privateKey
+	return nil
+}
+
+func (copy JwtKeyRepository) WrapContext(db *gorm.DB) *JwtKeyRepository {
+	copy.DB = db
+	return &copy
+}
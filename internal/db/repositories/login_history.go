@@ -0,0 +1,32 @@
+package repositories
+
+import (
+	"github.com/Confialink/wallet-pkg-list_params"
+	"github.com/Confialink/wallet-pkg-list_params/adapters"
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+// LoginHistoryRepository reads sign ins and failed attempts to sign in of users
+type LoginHistoryRepository struct {
+	DB *gorm.DB
+}
+
+// NewLoginHistoryRepository creates new repository
+func NewLoginHistoryRepository(db *gorm.DB) *LoginHistoryRepository {
+	return &LoginHistoryRepository{db}
+}
+
+// GetList returns entries of the login history by passed params
+func (repo *LoginHistoryRepository) GetList(params *list_params.ListParams) ([]*models.LoginHistoryEntry, error) {
+	var entries []*models.LoginHistoryEntry
+	adapter := adapters.NewGorm(repo.DB)
+	err := adapter.LoadList(&entries, params, models.LoginHistoryEntry{}.TableName())
+	return entries, err
+}
+
+func (copy LoginHistoryRepository) WrapContext(db *gorm.DB) *LoginHistoryRepository {
+	copy.DB = db
+	return &copy
+}
@@ -0,0 +1,102 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+type OAuthClientRepository struct {
+	DB *gorm.DB
+}
+
+func NewOAuthClientRepository(db *gorm.DB) *OAuthClientRepository {
+	return &OAuthClientRepository{DB: db}
+}
+
+func (repo *OAuthClientRepository) FindAll() ([]*models.OAuthClient, error) {
+	var list []*models.OAuthClient
+	if err := repo.DB.Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (repo *OAuthClientRepository) FindByID(id uint64) (*models.OAuthClient, error) {
+	model := &models.OAuthClient{}
+	if err := repo.DB.Where("id = ?", id).First(model).Error; err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func (repo *OAuthClientRepository) FindByClientID(clientID string) (*models.OAuthClient, error) {
+	model := &models.OAuthClient{}
+	if err := repo.DB.Where("client_id = ?", clientID).First(model).Error; err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func (repo *OAuthClientRepository) Create(model *models.OAuthClient) error {
+	return repo.DB.Create(model).Error
+}
+
+func (repo *OAuthClientRepository) Update(model *models.OAuthClient) error {
+	return repo.DB.Save(model).Error
+}
+
+func (repo *OAuthClientRepository) Delete(model *models.OAuthClient) error {
+	return repo.DB.Delete(model).Error
+}
+
+func (copy OAuthClientRepository) WrapContext(db *gorm.DB) *OAuthClientRepository {
+	copy.DB = db
+	return &copy
+}
+
+type OAuthAuthorizationCodeRepository struct {
+	DB *gorm.DB
+}
+
+func NewOAuthAuthorizationCodeRepository(db *gorm.DB) *OAuthAuthorizationCodeRepository {
+	return &OAuthAuthorizationCodeRepository{DB: db}
+}
+
+func (repo *OAuthAuthorizationCodeRepository) Create(model *models.OAuthAuthorizationCode) error {
+	return repo.DB.Create(model).Error
+}
+
+// Consume finds a not expired code and removes it. It returns a record not found error
+// if there is no such code or it has been consumed concurrently, so every code can be used only once.
+func (repo *OAuthAuthorizationCodeRepository) Consume(codeHash string) (*models.OAuthAuthorizationCode, error) {
+	model := &models.OAuthAuthorizationCode{}
+	if err := repo.DB.Where("code_hash = ? AND expires_at > ?", codeHash, time.Now()).First(model).Error; err != nil {
+		return nil, err
+	}
+
+	res := repo.DB.Where("id = ?", model.ID).Delete(&models.OAuthAuthorizationCode{})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return model, nil
+}
+
+// DeleteExpired removes codes which were not exchanged for tokens
+func (repo *OAuthAuthorizationCodeRepository) DeleteExpired() error {
+	return repo.DB.Where("expires_at <= ?", time.Now()).Delete(&models.OAuthAuthorizationCode{}).Error
+}
+
+func (repo *OAuthAuthorizationCodeRepository) DeleteByClientID(clientID string) error {
+	return repo.DB.Where("client_id = ?", clientID).Delete(&models.OAuthAuthorizationCode{}).Error
+}
+
+func (copy OAuthAuthorizationCodeRepository) WrapContext(db *gorm.DB) *OAuthAuthorizationCodeRepository {
+	copy.DB = db
+	return &copy
+}
@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+type OutboxEventRepository struct {
+	DB *gorm.DB
+}
+
+func NewOutboxEventRepository(db *gorm.DB) *OutboxEventRepository {
+	return &OutboxEventRepository{DB: db}
+}
+
+func (repo *OutboxEventRepository) Create(event *models.OutboxEvent) error {
+	return repo.DB.Create(event).Error
+}
+
+// FindUnpublishedForUpdate returns the oldest not published events and locks them till the end of the transaction,
+// so concurrent relays do not publish the same events
+func (repo *OutboxEventRepository) FindUnpublishedForUpdate(limit int) ([]*models.OutboxEvent, error) {
+	var events []*models.OutboxEvent
+	if err := repo.DB.
+		Set("gorm:query_option", "FOR UPDATE").
+		Where("published_at IS NULL").
+		Order("id ASC").
+		Limit(limit).
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (repo *OutboxEventRepository) MarkPublished(id uint64, publishedAt time.Time) error {
+	return repo.DB.Model(&models.OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"published_at": publishedAt, "attempts": gorm.Expr("attempts + 1")}).Error
+}
+
+func (repo *OutboxEventRepository) MarkFailed(id uint64, lastError string) error {
+	return repo.DB.Model(&models.OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"last_error": lastError, "attempts": gorm.Expr("attempts + 1")}).Error
+}
+
+func (copy OutboxEventRepository) WrapContext(db *gorm.DB) *OutboxEventRepository {
+	copy.DB = db
+	return &copy
+}
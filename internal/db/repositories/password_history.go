@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+type PasswordHistoryRepository struct {
+	DB *gorm.DB
+}
+
+func NewPasswordHistoryRepository(db *gorm.DB) *PasswordHistoryRepository {
+	return &PasswordHistoryRepository{DB: db}
+}
+
+// FindLatestByUserUID returns the latest passwords of the user, the newest one goes first
+func (repo *PasswordHistoryRepository) FindLatestByUserUID(uid string, limit uint64) ([]*models.PasswordHistory, error) {
+	var list []*models.PasswordHistory
+	if err := repo.DB.Where("user_uid = ?", uid).Order("id desc").Limit(limit).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (repo *PasswordHistoryRepository) Create(model *models.PasswordHistory) error {
+	return repo.DB.Create(model).Error
+}
+
+// DeleteExceptLatest removes passwords of the user except the given number of the latest ones
+func (repo *PasswordHistoryRepository) DeleteExceptLatest(uid string, keep uint64) error {
+	// the derived table is required by MySQL which can't select from the table it deletes from
+	return repo.DB.Exec(
+		"DELETE FROM users_password_history WHERE user_uid = ? AND id NOT IN "+
+			"(SELECT id FROM (SELECT id FROM users_password_history WHERE user_uid = ? ORDER BY id DESC LIMIT ?) AS latest)",
+		uid, uid, keep,
+	).Error
+}
+
+func (copy PasswordHistoryRepository) WrapContext(db *gorm.DB) *PasswordHistoryRepository {
+	copy.DB = db
+	return &copy
+}
@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+// PersonalDataRepository erases personal data of a user across tables of the service.
+// The uid is kept everywhere, so references from other services stay valid.
+type PersonalDataRepository struct {
+	DB *gorm.DB
+}
+
+func NewPersonalDataRepository(db *gorm.DB) *PersonalDataRepository {
+	return &PersonalDataRepository{DB: db}
+}
+
+// PseudonymizeUser replaces identifying fields of the user by values derived from the uid,
+// the user can not sign in anymore
+func (repo *PersonalDataRepository) PseudonymizeUser(uid string) error {
+	return repo.DB.Table("users").Where("uid = ?", uid).UpdateColumns(map[string]interface{}{
+		"email":                          fmt.Sprintf("erased-%s@erased.invalid", uid),
+		"username":                       "erased-" + uid,
+		"password":                       "",
+		"first_name":                     "",
+		"last_name":                      "",
+		"middle_name":                    "",
+		"nickname":                       "",
+		"phone_number":                   "",
+		"sms_phone_number":               nil,
+		"is_email_confirmed":             false,
+		"is_phone_confirmed":             false,
+		"status":                         models.StatusCanceled,
+		"last_login_ip":                  "",
+		"company_id":                     nil,
+		"profile_image_id":               nil,
+		"country_of_residence_iso_two":   "",
+		"country_of_citizenship_iso_two": "",
+		"date_of_birth":                  nil,
+		"document_type":                  nil,
+		"document_personal_id":           "",
+		"fax":                            "",
+		"home_phone_number":              "",
+		"office_phone_number":            "",
+		"position":                       "",
+		"internal_notes":                 "",
+		"updated_at":                     time.Now(),
+	}).Error
+}
+
+// PseudonymizeCompany replaces names of the company of the user unless another user belongs to it
+func (repo *PersonalDataRepository) PseudonymizeCompany(companyID uint64, uid string) error {
+	var count uint64
+	if err := repo.DB.Table("users").
+		Where("company_id = ? AND uid <> ?", companyID, uid).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	return repo.DB.Table("companies").Where("id = ?", companyID).UpdateColumns(map[string]interface{}{
+		"company_name":        fmt.Sprintf("erased-%d", companyID),
+		"director_first_name": "",
+		"director_last_name":  "",
+		"mask_name":           "",
+		"updated_at":          time.Now(),
+	}).Error
+}
+
+// DeleteAddresses deletes mailing and physical addresses of the user
+func (repo *PersonalDataRepository) DeleteAddresses(uid string) error {
+	return repo.DB.Where("user_id = ?", uid).Delete(&models.Address{}).Error
+}
+
+// DeleteAttributeValues deletes values of custom attributes of the user
+func (repo *PersonalDataRepository) DeleteAttributeValues(uid string) error {
+	return repo.DB.Where("user_id = ?", uid).Delete(&models.UserAttributeValue{}).Error
+}
+
+// DeleteSecurityQuestionsAnswers deletes answers to security questions of the user
+func (repo *PersonalDataRepository) DeleteSecurityQuestionsAnswers(uid string) error {
+	return repo.DB.Where("uid = ?", uid).Delete(&models.SecurityQuestionsAnswer{}).Error
+}
+
+// PseudonymizeAccessLog clears IP addresses and devices of sign ins and failed attempts to sign in of the user,
+// dates are kept
+func (repo *PersonalDataRepository) PseudonymizeAccessLog(uid string) error {
+	if err := repo.DB.Table(models.AccessLog{}.TableName()).Where("uid = ?", uid).UpdateColumns(map[string]interface{}{
+		"ip":                 0,
+		"user_agent":         "",
+		"device_fingerprint": "",
+	}).Error; err != nil {
+		return err
+	}
+	return repo.DB.Model(&models.FailAuthAttempt{}).Where("uid = ?", uid).UpdateColumn("ip", 0).Error
+}
+
+// PseudonymizeSecurityEvents clears IP addresses, devices and details of security events of the user,
+// types and dates are kept
+func (repo *PersonalDataRepository) PseudonymizeSecurityEvents(uid string) error {
+	return repo.DB.Model(&models.SecurityEvent{}).Where("user_uid = ?", uid).UpdateColumns(map[string]interface{}{
+		"ip":         "",
+		"user_agent": "",
+		"details":    "{}",
+	}).Error
+}
+
+// PseudonymizeConsents clears IP addresses of consents of the user,
+// documents, versions and dates are kept as proof of consent
+func (repo *PersonalDataRepository) PseudonymizeConsents(uid string) error {
+	return repo.DB.Model(&models.Consent{}).Where("user_uid = ?", uid).UpdateColumn("ip", "").Error
+}
+
+// DetachVerificationFiles unlinks documents from verifications of the user and cancels the verifications,
+// the files themselves are deleted by the files service
+func (repo *PersonalDataRepository) DetachVerificationFiles(uid string) error {
+	if err := repo.DB.
+		Where("verification_id IN (?)", repo.DB.Model(&models.Verification{}).Select("id").Where("user_uid = ?", uid).QueryExpr()).
+		Delete(&models.VerificationFile{}).Error; err != nil {
+		return err
+	}
+	return repo.DB.Model(&models.Verification{}).
+		Where("user_uid = ?", uid).
+		UpdateColumn("status", models.VerificationStatusCancelled).Error
+}
+
+// DeleteContactChanges deletes requested changes of the email and phone numbers of the user,
+// confirmation codes and links to identity providers
+func (repo *PersonalDataRepository) DeleteContactChanges(uid string) error {
+	for _, value := range []interface{}{
+		&models.EmailChange{},
+		&models.PhoneChange{},
+		&models.UserIdentity{},
+		&models.ConfirmationCode{},
+	} {
+		if err := repo.DB.Where("user_uid = ?", uid).Delete(value).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (copy PersonalDataRepository) WrapContext(db *gorm.DB) *PersonalDataRepository {
+	copy.DB = db
+	return &copy
+}
@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+type PhoneChangeRepository struct {
+	DB *gorm.DB
+}
+
+func NewPhoneChangeRepository(db *gorm.DB) *PhoneChangeRepository {
+	return &PhoneChangeRepository{DB: db}
+}
+
+func (repo *PhoneChangeRepository) Create(change *models.PhoneChange) error {
+	return repo.DB.Create(change).Error
+}
+
+func (repo *PhoneChangeRepository) Save(change *models.PhoneChange) error {
+	return repo.DB.Save(change).Error
+}
+
+// FindPendingByUID returns the phone number change of the user which waits for confirmation
+func (repo *PhoneChangeRepository) FindPendingByUID(uid string) (*models.PhoneChange, error) {
+	change := &models.PhoneChange{}
+	if err := repo.DB.Where("user_uid = ? AND status = ?", uid, models.PhoneChangeStatusPending).
+		Order("id DESC").
+		First(change).Error; err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// CancelPendingByUID cancels phone number changes of the user which wait for confirmation
+func (repo *PhoneChangeRepository) CancelPendingByUID(uid string) error {
+	return repo.DB.Model(&models.PhoneChange{}).
+		Where("user_uid = ? AND status = ?", uid, models.PhoneChangeStatusPending).
+		Update("status", models.PhoneChangeStatusCancelled).Error
+}
+
+func (copy PhoneChangeRepository) WrapContext(db *gorm.DB) *PhoneChangeRepository {
+	copy.DB = db
+	return &copy
+}
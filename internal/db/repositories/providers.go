@@ -20,5 +20,27 @@ func Providers() []interface{} {
 		NewUserAttributeValueRepository,
 		NewAttributeRepository,
 		NewCompanyRepository,
+		NewUserTotpRepository,
+		NewMfaRecoveryCodeRepository,
+		NewSecurityEventRepository,
+		NewJwtKeyRepository,
+		NewOutboxEventRepository,
+		NewWebauthnCredentialRepository,
+		NewWebauthnChallengeRepository,
+		NewOAuthClientRepository,
+		NewOAuthAuthorizationCodeRepository,
+		NewIdentityProviderRepository,
+		NewUserIdentityRepository,
+		NewFederatedLoginStateRepository,
+		NewPasswordHistoryRepository,
+		NewRateLimitBucketRepository,
+		NewLoginHistoryRepository,
+		NewEmailChangeRepository,
+		NewPhoneChangeRepository,
+		NewErasureRequestRepository,
+		NewPersonalDataRepository,
+		NewDataExportRepository,
+		NewConsentRepository,
+		NewRetentionRepository,
 	}
 }
@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+type RateLimitBucketRepository struct {
+	DB *gorm.DB
+}
+
+func NewRateLimitBucketRepository(db *gorm.DB) *RateLimitBucketRepository {
+	return &RateLimitBucketRepository{DB: db}
+}
+
+// FindOrCreateForUpdate returns the bucket of the key and locks it till the end of the transaction,
+// a full bucket is created if there is no one
+func (repo *RateLimitBucketRepository) FindOrCreateForUpdate(key string, now time.Time) (*models.RateLimitBucket, error) {
+	// concurrent requests may create the same bucket, only the first one is inserted
+	if err := repo.DB.Exec(
+		"INSERT IGNORE INTO rate_limit_buckets (`key`, taken, taken_at, expires_at) VALUES (?, 0, ?, ?)",
+		key, now, now,
+	).Error; err != nil {
+		return nil, err
+	}
+
+	var bucket models.RateLimitBucket
+	if err := repo.DB.
+		Set("gorm:query_option", "FOR UPDATE").
+		Where("`key` = ?", key).
+		First(&bucket).Error; err != nil {
+		return nil, err
+	}
+	return &bucket, nil
+}
+
+// Update saves tokens of the bucket
+func (repo *RateLimitBucketRepository) Update(bucket *models.RateLimitBucket) error {
+	return repo.DB.Model(bucket).UpdateColumns(map[string]interface{}{
+		"taken":      bucket.Taken,
+		"taken_at":   bucket.TakenAt,
+		"expires_at": bucket.ExpiresAt,
+	}).Error
+}
+
+// DeleteExpired removes buckets refilled before the given time, they are equal to new ones
+func (repo *RateLimitBucketRepository) DeleteExpired(before time.Time) error {
+	return repo.DB.Where("expires_at < ?", before).Delete(&models.RateLimitBucket{}).Error
+}
+
+func (copy RateLimitBucketRepository) WrapContext(db *gorm.DB) *RateLimitBucketRepository {
+	copy.DB = db
+	return &copy
+}
@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// RetentionRepository deletes rows which are kept longer than the retention period.
+// Tables and conditions are defined by the code, never by input.
+type RetentionRepository struct {
+	DB *gorm.DB
+}
+
+func NewRetentionRepository(db *gorm.DB) *RetentionRepository {
+	return &RetentionRepository{DB: db}
+}
+
+// DeleteBatch deletes up to limit rows of the table matching the condition with the cutoff time as its parameter,
+// it returns the number of deleted rows
+func (repo *RetentionRepository) DeleteBatch(table, condition string, cutoff time.Time, limit uint) (int64, error) {
+	res := repo.DB.Exec(fmt.Sprintf("DELETE FROM `%s` WHERE %s LIMIT ?", table, condition), cutoff, limit)
+	return res.RowsAffected, res.Error
+}
+
+// Count returns the number of rows of the table matching the condition with the cutoff time as its parameter
+func (repo *RetentionRepository) Count(table, condition string, cutoff time.Time) (int64, error) {
+	var count int64
+	err := repo.DB.Table(table).Where(condition, cutoff).Count(&count).Error
+	return count, err
+}
+
+func (copy RetentionRepository) WrapContext(db *gorm.DB) *RetentionRepository {
+	copy.DB = db
+	return &copy
+}
@@ -0,0 +1,35 @@
+package repositories
+
+import (
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+type SecurityEventRepository struct {
+	DB *gorm.DB
+}
+
+func NewSecurityEventRepository(db *gorm.DB) *SecurityEventRepository {
+	return &SecurityEventRepository{DB: db}
+}
+
+func (repo *SecurityEventRepository) Create(event *models.SecurityEvent) error {
+	return repo.DB.Create(event).Error
+}
+
+// FindByUID returns security events of the user, the latest go first
+func (repo *SecurityEventRepository) FindByUID(uid string) ([]*models.SecurityEvent, error) {
+	var events []*models.SecurityEvent
+	if err := repo.DB.Where("user_uid = ?", uid).
+		Order("created_at DESC").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (copy SecurityEventRepository) WrapContext(db *gorm.DB) *SecurityEventRepository {
+	copy.DB = db
+	return &copy
+}
@@ -1,6 +1,8 @@
 package repositories
 
 import (
+	"time"
+
 	"github.com/Confialink/wallet-users/internal/db/models"
 	"github.com/jinzhu/gorm"
 )
@@ -18,6 +20,7 @@ func (repo *TokenRepository) FindTokenBySignedString(signedString string) (*mode
 	model := &models.Token{}
 	if err := repo.DB.Where("signed_string = ?", signedString).
 		Preload("User").
+		Preload("RefreshToken").
 		First(&model).Error; err != nil {
 		return nil, err
 	}
@@ -52,6 +55,90 @@ func (repo *TokenRepository) FindAccessTokenByRefreshTokenId(refreshTokenId uint
 	return token, nil
 }
 
+// DeleteExpired removes up to limit tokens expired before the time, it returns the number of removed tokens.
+// Access tokens of removed refresh tokens are removed by the foreign key cascade and are not counted.
+func (repo *TokenRepository) DeleteExpired(before time.Time, limit uint) (int64, error) {
+	res := repo.DB.Exec("DELETE FROM `tokens` WHERE expires_at < ? LIMIT ?", before, limit)
+	return res.RowsAffected, res.Error
+}
+
+// DeleteCreatedBeforeWithoutExpiry removes up to limit tokens which were issued before the expiry time was stored
+// and were created before the time, it returns the number of removed tokens
+func (repo *TokenRepository) DeleteCreatedBeforeWithoutExpiry(before time.Time, limit uint) (int64, error) {
+	res := repo.DB.Exec("DELETE FROM `tokens` WHERE expires_at IS NULL AND created_at < ? LIMIT ?", before, limit)
+	return res.RowsAffected, res.Error
+}
+
+// FindSessionsByUID returns refresh tokens of the user, each refresh token represents a session on a device
+func (repo *TokenRepository) FindSessionsByUID(uid string, subject string) ([]*models.Token, error) {
+	var tokens []*models.Token
+	if err := repo.DB.Where("user_uid = ? AND subject = ? AND revoked_at IS NULL", uid, subject).
+		Order("last_used_at DESC").
+		Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// FindActiveSessionsByUID returns refresh tokens of the user which are not expired at the time.
+// Tokens issued before the expiry time was stored are returned as well, they are removed by the retention purge.
+func (repo *TokenRepository) FindActiveSessionsByUID(uid string, subject string, now time.Time) ([]*models.Token, error) {
+	var tokens []*models.Token
+	if err := repo.DB.Where("user_uid = ? AND subject = ? AND revoked_at IS NULL", uid, subject).
+		Where("expires_at > ? OR expires_at IS NULL", now).
+		Order("last_used_at DESC").
+		Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (repo *TokenRepository) FindByIDAndUIDAndSubject(id uint64, uid string, subject string) (*models.Token, error) {
+	model := &models.Token{}
+	if err := repo.DB.Where("id = ? AND user_uid = ? AND subject = ? AND revoked_at IS NULL", id, uid, subject).
+		First(model).Error; err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// DeleteByUIDAndDeviceID removes tokens of the user which were issued for the given device
+func (repo *TokenRepository) DeleteByUIDAndDeviceID(uid string, deviceID string) error {
+	if err := repo.DB.Where("user_uid = ? AND device_id = ?", uid, deviceID).
+		Delete(&models.Token{}).
+		Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// MarkRevoked marks the token as revoked but keeps it. It returns the number of revoked tokens,
+// which is 0 if the token has been already revoked.
+func (repo *TokenRepository) MarkRevoked(id uint64, revokedAt time.Time) (int64, error) {
+	res := repo.DB.Model(&models.Token{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		UpdateColumn("revoked_at", revokedAt)
+	return res.RowsAffected, res.Error
+}
+
+// DeleteByFamilyID removes all refresh tokens of the family, access tokens are removed by the foreign key cascade
+func (repo *TokenRepository) DeleteByFamilyID(familyID string) error {
+	if err := repo.DB.Where("family_id = ?", familyID).
+		Delete(&models.Token{}).
+		Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateLastUsedAt sets last usage time of the session
+func (repo *TokenRepository) UpdateLastUsedAt(id uint64, lastUsedAt time.Time) error {
+	return repo.DB.Model(&models.Token{}).
+		Where("id = ?", id).
+		UpdateColumn("last_used_at", lastUsedAt).
+		Error
+}
+
 func (repo *TokenRepository) DeleteTokensByUID(uid string) error {
 	if err := repo.DB.Where("user_uid = ?", uid).
 		Delete(&models.Token{}).
@@ -83,3 +170,38 @@ func (repo *TokenRepository) Delete(token *models.Token) error {
 	}
 	return nil
 }
+
+// DeleteByRefreshTokenID removes access tokens issued along with the refresh token
+func (repo *TokenRepository) DeleteByRefreshTokenID(refreshTokenId uint64) error {
+	if err := repo.DB.Where("refresh_token_id = ?", refreshTokenId).
+		Delete(&models.Token{}).
+		Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteByClientID removes all tokens issued to the OAuth client
+func (repo *TokenRepository) DeleteByClientID(clientID string) error {
+	if err := repo.DB.Where("client_id = ?", clientID).
+		Delete(&models.Token{}).
+		Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// FindByClientIDAndSubject returns tokens issued to the OAuth client
+func (repo *TokenRepository) FindByClientIDAndSubject(clientID string, subject string) ([]*models.Token, error) {
+	var tokens []*models.Token
+	if err := repo.DB.Where("client_id = ? AND subject = ?", clientID, subject).
+		Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (copy TokenRepository) WrapContext(db *gorm.DB) *TokenRepository {
+	copy.DB = db
+	return &copy
+}
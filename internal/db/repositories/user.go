@@ -253,6 +253,18 @@ func (repo *UsersRepository) IsExistByEmailWithoutCurrent(email, currentUserUID
 	return count > 0, nil
 }
 
+// IsExistByPhoneNumberWithoutCurrent checks if another user signs in with the phone number
+func (repo *UsersRepository) IsExistByPhoneNumberWithoutCurrent(phoneNumber, currentUserUID string) (bool, error) {
+	var count uint64
+	if err := repo.DB.Model(&models.User{}).
+		Where("phone_number = ?", phoneNumber).
+		Where("uid != ?", currentUserUID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
 // FindByEmailOrPhoneNumber find user by email or username
 func (repo *UsersRepository) FindByEmailOrPhoneNumber(value string) (*models.User, error) {
 	user := &models.User{}
@@ -368,6 +380,15 @@ func (repo *UsersRepository) UpdateStatusInfo(user *models.User) error {
 	return nil
 }
 
+// UpdateRoleAndGroup updates role and user group, nil group removes the user from the group
+func (repo *UsersRepository) UpdateRoleAndGroup(user *models.User) error {
+	updateData := map[string]interface{}{"RoleName": user.RoleName, "UserGroupId": user.UserGroupId}
+	if err := repo.DB.Model(&user).Updates(updateData).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
 // UpdatePasswordAndChallengeName updates password and challenge name
 func (repo *UsersRepository) UpdatePasswordAndChallengeName(user *models.User, data *models.User) error {
 	updateData := map[string]interface{}{"Password": data.Password, "ChallengeName": data.ChallengeName}
@@ -377,6 +398,59 @@ func (repo *UsersRepository) UpdatePasswordAndChallengeName(user *models.User, d
 	return nil
 }
 
+// UpdateEmail updates email and the flag of its confirmation
+func (repo *UsersRepository) UpdateEmail(user *models.User) error {
+	updateData := map[string]interface{}{"Email": user.Email, "IsEmailConfirmed": user.IsEmailConfirmed}
+	return repo.DB.Model(user).Updates(updateData).Error
+}
+
+// UpdatePhoneNumbers updates phone numbers and the flag of confirmation of the phone number
+func (repo *UsersRepository) UpdatePhoneNumbers(user *models.User) error {
+	updateData := map[string]interface{}{
+		"PhoneNumber":      user.PhoneNumber,
+		"SmsPhoneNumber":   user.SmsPhoneNumber,
+		"IsPhoneConfirmed": user.IsPhoneConfirmed,
+	}
+	return repo.DB.Model(user).Updates(updateData).Error
+}
+
+// UpdateChallengeName updates challenge name only
+func (repo *UsersRepository) UpdateChallengeName(user *models.User) error {
+	return repo.DB.Model(user).Update("ChallengeName", user.ChallengeName).Error
+}
+
+// UpdatePasswordHash replaces the hash of the password of the user, the password itself is not changed
+// so updated_at is kept as is
+func (repo *UsersRepository) UpdatePasswordHash(user *models.User, hash string) error {
+	return repo.DB.Model(user).UpdateColumn("Password", hash).Error
+}
+
+// PasswordSchemeCount is a number of users whose passwords are hashed by the scheme
+type PasswordSchemeCount struct {
+	Scheme string `json:"scheme"`
+	Count  uint64 `json:"count"`
+}
+
+// CountByPasswordScheme counts users by schemes their passwords are hashed by
+func (repo *UsersRepository) CountByPasswordScheme() ([]*PasswordSchemeCount, error) {
+	var counts []*PasswordSchemeCount
+	var conditions []string
+	var args []interface{}
+	for _, item := range models.PasswordSchemePrefixes() {
+		// "_" and "%" are not escaped since no prefix contains them
+		conditions = append(conditions, "WHEN password LIKE ? THEN ?")
+		args = append(args, item.Prefix+"%", item.Scheme)
+	}
+	args = append(args, models.PasswordSchemeUnknown)
+
+	query := "SELECT CASE " + strings.Join(conditions, " ") + " ELSE ? END AS scheme, COUNT(*) AS count " +
+		"FROM users GROUP BY scheme ORDER BY scheme"
+	if err := repo.DB.Raw(query, args...).Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
 // CountByUserGroupID count users by user group id
 func (repo *UsersRepository) CountByUserGroupID(userGroupID uint64) (uint64, error) {
 	var user models.User
@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+type UserTotpRepository struct {
+	DB *gorm.DB
+}
+
+func NewUserTotpRepository(db *gorm.DB) *UserTotpRepository {
+	return &UserTotpRepository{DB: db}
+}
+
+// FindByUserUID returns TOTP authenticator of the given user
+func (repo *UserTotpRepository) FindByUserUID(uid string) (*models.UserTotp, error) {
+	model := &models.UserTotp{}
+	if err := repo.DB.Where("user_uid = ?", uid).First(model).Error; err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// Save creates or updates the given authenticator
+func (repo *UserTotpRepository) Save(model *models.UserTotp) error {
+	return repo.DB.Save(model).Error
+}
+
+// UpdateLastUsedStep stores the step only if it is greater than the stored one, so a code cannot be used twice.
+// It returns false if the step has already been used.
+func (repo *UserTotpRepository) UpdateLastUsedStep(model *models.UserTotp, step int64) (bool, error) {
+	res := repo.DB.Model(&models.UserTotp{}).
+		Where("id = ? AND last_used_step < ?", model.ID, step).
+		Update("last_used_step", step)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (repo *UserTotpRepository) DeleteByUserUID(uid string) error {
+	return repo.DB.Where("user_uid = ?", uid).Delete(&models.UserTotp{}).Error
+}
+
+func (copy UserTotpRepository) WrapContext(db *gorm.DB) *UserTotpRepository {
+	copy.DB = db
+	return &copy
+}
+
+type MfaRecoveryCodeRepository struct {
+	DB *gorm.DB
+}
+
+func NewMfaRecoveryCodeRepository(db *gorm.DB) *MfaRecoveryCodeRepository {
+	return &MfaRecoveryCodeRepository{DB: db}
+}
+
+// ReplaceForUser removes all existing recovery codes of the user and stores the given ones
+func (repo *MfaRecoveryCodeRepository) ReplaceForUser(uid string, codes []*models.MfaRecoveryCode) error {
+	if err := repo.DB.Where("user_uid = ?", uid).Delete(&models.MfaRecoveryCode{}).Error; err != nil {
+		return err
+	}
+	for _, code := range codes {
+		if err := repo.DB.Create(code).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseCode marks an unused code as used. It returns false if there is no such unused code.
+func (repo *MfaRecoveryCodeRepository) UseCode(uid, codeHash string) (bool, error) {
+	res := repo.DB.Model(&models.MfaRecoveryCode{}).
+		Where("user_uid = ? AND code_hash = ? AND used_at IS NULL", uid, codeHash).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (repo *MfaRecoveryCodeRepository) CountUnused(uid string) (int, error) {
+	var count int
+	if err := repo.DB.Model(&models.MfaRecoveryCode{}).
+		Where("user_uid = ? AND used_at IS NULL", uid).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (repo *MfaRecoveryCodeRepository) DeleteByUserUID(uid string) error {
+	return repo.DB.Where("user_uid = ?", uid).Delete(&models.MfaRecoveryCode{}).Error
+}
+
+func (copy MfaRecoveryCodeRepository) WrapContext(db *gorm.DB) *MfaRecoveryCodeRepository {
+	copy.DB = db
+	return &copy
+}
@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+type WebauthnCredentialRepository struct {
+	DB *gorm.DB
+}
+
+func NewWebauthnCredentialRepository(db *gorm.DB) *WebauthnCredentialRepository {
+	return &WebauthnCredentialRepository{DB: db}
+}
+
+// FindByUserUID returns credentials of the given user ordered by creation
+func (repo *WebauthnCredentialRepository) FindByUserUID(uid string) ([]*models.WebauthnCredential, error) {
+	var list []*models.WebauthnCredential
+	if err := repo.DB.Where("user_uid = ?", uid).Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// FindByCredentialID returns a credential by its base64url encoded id
+func (repo *WebauthnCredentialRepository) FindByCredentialID(credentialID string) (*models.WebauthnCredential, error) {
+	model := &models.WebauthnCredential{}
+	if err := repo.DB.Where("credential_id = ?", credentialID).First(model).Error; err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func (repo *WebauthnCredentialRepository) CountByUserUID(uid string) (int, error) {
+	var count int
+	if err := repo.DB.Model(&models.WebauthnCredential{}).Where("user_uid = ?", uid).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (repo *WebauthnCredentialRepository) Create(model *models.WebauthnCredential) error {
+	return repo.DB.Create(model).Error
+}
+
+// UpdateSignCount stores the counter only if it was not changed by a concurrent sign in with the same credential.
+// It returns false if the stored counter differs from the given model.
+func (repo *WebauthnCredentialRepository) UpdateSignCount(model *models.WebauthnCredential, signCount uint32) (bool, error) {
+	res := repo.DB.Model(&models.WebauthnCredential{}).
+		Where("id = ? AND sign_count = ?", model.ID, model.SignCount).
+		Updates(map[string]interface{}{"sign_count": signCount, "last_used_at": time.Now()})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// DeleteByIDAndUserUID removes a credential of the user, it returns false if there is no such credential
+func (repo *WebauthnCredentialRepository) DeleteByIDAndUserUID(id uint64, uid string) (bool, error) {
+	res := repo.DB.Where("id = ? AND user_uid = ?", id, uid).Delete(&models.WebauthnCredential{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+func (repo *WebauthnCredentialRepository) DeleteByUserUID(uid string) error {
+	return repo.DB.Where("user_uid = ?", uid).Delete(&models.WebauthnCredential{}).Error
+}
+
+func (copy WebauthnCredentialRepository) WrapContext(db *gorm.DB) *WebauthnCredentialRepository {
+	copy.DB = db
+	return &copy
+}
+
+type WebauthnChallengeRepository struct {
+	DB *gorm.DB
+}
+
+func NewWebauthnChallengeRepository(db *gorm.DB) *WebauthnChallengeRepository {
+	return &WebauthnChallengeRepository{DB: db}
+}
+
+func (repo *WebauthnChallengeRepository) Create(model *models.WebauthnChallenge) error {
+	return repo.DB.Create(model).Error
+}
+
+// Consume removes a not expired challenge issued for the given ceremony and user.
+// It returns false if there is no such challenge, so every challenge can be used only once.
+func (repo *WebauthnChallengeRepository) Consume(challengeHash, ceremony, uid string) (bool, error) {
+	res := repo.DB.
+		Where("challenge_hash = ? AND ceremony = ? AND user_uid = ? AND expires_at > ?", challengeHash, ceremony, uid, time.Now()).
+		Delete(&models.WebauthnChallenge{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// DeleteExpired removes challenges of abandoned ceremonies
+func (repo *WebauthnChallengeRepository) DeleteExpired() error {
+	return repo.DB.Where("expires_at <= ?", time.Now()).Delete(&models.WebauthnChallenge{}).Error
+}
+
+func (copy WebauthnChallengeRepository) WrapContext(db *gorm.DB) *WebauthnChallengeRepository {
+	copy.DB = db
+	return &copy
+}
@@ -19,8 +19,11 @@ import (
 	"github.com/Confialink/wallet-users/internal/services"
 	"github.com/Confialink/wallet-users/internal/services/auth"
 	"github.com/Confialink/wallet-users/internal/services/csv"
+	"github.com/Confialink/wallet-users/internal/services/events"
 	"github.com/Confialink/wallet-users/internal/services/invites"
 	messagebroker "github.com/Confialink/wallet-users/internal/services/message-broker"
+	"github.com/Confialink/wallet-users/internal/services/passwordpolicy"
+	"github.com/Confialink/wallet-users/internal/services/ratelimit"
 	"github.com/Confialink/wallet-users/internal/services/users"
 	"github.com/Confialink/wallet-users/internal/validators"
 	"github.com/Confialink/wallet-users/rpc/cmd/server/usersserver"
@@ -46,7 +49,10 @@ func init() {
 	providers = append(providers, auth.Providers()...)
 	providers = append(providers, csv.Providers()...)
 	providers = append(providers, users.Providers()...)
+	providers = append(providers, passwordpolicy.Providers()...)
+	providers = append(providers, ratelimit.Providers()...)
 	providers = append(providers, invites.Providers()...)
+	providers = append(providers, events.Providers()...)
 	providers = append(providers, repositories.Providers()...)
 	providers = append(providers, usersserver.Providers()...)
 	providers = append(providers, responses.Providers()...)
@@ -6,7 +6,7 @@ import (
 )
 
 type ResetPassword struct {
-	NewPassword     string `binding:"required,min=8,max=128,specialCharacterRequired,numberRequired,uppercaseLetterRequired,lowercaseLetterRequired"`
+	NewPassword     string `binding:"required,max=128"`
 	ConfirmPassword string `binding:"required,eqfield=NewPassword"`
 }
 
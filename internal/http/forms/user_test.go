@@ -7,6 +7,7 @@ import (
 
 	pbSettings "github.com/Confialink/wallet-settings/rpc/proto/settings"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/inconshreveable/log15"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	validatorPkg "gopkg.in/go-playground/validator.v8"
@@ -161,14 +162,14 @@ func TestUserSignUp(t *testing.T) {
 	client.On("Get", context.Background(), req).Return(resp, nil)
 	clientFactory := &mocks.ClientFactory{}
 	clientFactory.On("NewClient").Return(client, nil)
-	defaultUserClassService := form_conditions.NewDefaultUserClass(syssettings.NewSysSettings(clientFactory))
+	defaultUserClassService := form_conditions.NewDefaultUserClass(syssettings.NewSysSettings(clientFactory, log15.New()))
 	conditionRegistry := form_conditions.NewConditionRegistry()
 	err = conditionRegistry.Register(defaultUserClassService)
 	assert.Nil(t, err, "service must not return an error")
 	logger := logger.Logger{}
 	service := NewUser(
 		validatorPkg.New(&validatorPkg.Config{TagName: "binding"}),
-		syssettings.NewSysSettings(clientFactory),
+		syssettings.NewSysSettings(clientFactory, log15.New()),
 		factory,
 		conditionRegistry,
 		&logger,
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,6 +20,7 @@ import (
 	"github.com/Confialink/wallet-users/internal/services"
 	"github.com/Confialink/wallet-users/internal/services/accounts"
 	"github.com/Confialink/wallet-users/internal/services/auth"
+	"github.com/Confialink/wallet-users/internal/services/events"
 	"github.com/Confialink/wallet-users/internal/services/notifications"
 	"github.com/Confialink/wallet-users/internal/services/syssettings"
 	"github.com/Confialink/wallet-users/internal/services/users"
@@ -41,6 +44,11 @@ type AuthService struct {
 	userLoaderService       *users.UserLoaderService
 	signUpResponse          *httpAuth.SignUpResponse
 	accountsService         *accounts.AccountsService
+	mfa                     *auth.Mfa
+	webauthn                *auth.Webauthn
+	federated               *auth.Federated
+	outbox                  *events.Outbox
+	loginRisk               *auth.LoginRisk
 }
 
 func NewAuthService(
@@ -60,6 +68,11 @@ func NewAuthService(
 	userLoaderService *users.UserLoaderService,
 	signUpResponse *httpAuth.SignUpResponse,
 	accountsService *accounts.AccountsService,
+	mfa *auth.Mfa,
+	webauthn *auth.Webauthn,
+	federated *auth.Federated,
+	outbox *events.Outbox,
+	loginRisk *auth.LoginRisk,
 ) *AuthService {
 	return &AuthService{
 		Repository:              repository,
@@ -78,6 +91,11 @@ func NewAuthService(
 		userLoaderService:       userLoaderService,
 		signUpResponse:          signUpResponse,
 		accountsService:         accountsService,
+		mfa:                     mfa,
+		webauthn:                webauthn,
+		federated:               federated,
+		outbox:                  outbox,
+		loginRisk:               loginRisk,
 	}
 }
 
@@ -186,18 +204,55 @@ func (srv *AuthService) SignInHandler(ctx *gin.Context) {
 
 	user, err := srv.Repository.GetUsersRepository().FindByEmailOrPhoneNumber(validator.UserModel.Email)
 	if err != nil {
+		user = nil
+	}
+
+	// attempts made before the delay after the previous failure is over are rejected and are not counted as failed
+	if wait := srv.AuthBlocker.FailedLoginRetryAfter(user, ip); wait > 0 {
+		seconds := int(math.Ceil(wait.Seconds()))
+		ctx.Header("Retry-After", strconv.Itoa(seconds))
+		e := responses.NewCommonErrorByCode(responses.TooManyRequests, "Too many failed attempts, try again later.")
+		e.Meta = struct {
+			RetryAfter int `json:"retryAfter"`
+		}{seconds}
+		srv.ResponseService.SetError(ctx, e)
+		return
+	}
+
+	// attempts rejected because of CAPTCHA are not counted as failed
+	if e := srv.AuthBlocker.CheckCaptcha(user, ip, validator.Data.CaptchaToken); e != nil {
+		srv.ResponseService.SetError(ctx, e)
+		return
+	}
+
+	if user == nil {
+		// the response must not depend on existence of the user
+		if srv.federatedLoginRequired(ctx, validator.UserModel.Email) {
+			return
+		}
 		srv.AuthBlocker.AddIPFailAttempt(ip)
 		// Returns a "401 StatusUnauthorized" response
 		srv.ResponseService.Error(ctx, responses.CodeInvalidUsernamePassword, "Invalid username or password.")
 		return
 	}
 
-	res, errResp := srv.authService.LoginUser(user, validator.UserModel, nil, ip)
+	// users of company domains sign in with the identity provider, the root user may always use the password
+	if !user.IsRoot() && srv.federatedLoginRequired(ctx, user.Email) {
+		return
+	}
+
+	res, errResp := srv.authService.LoginUser(user, validator.UserModel, getTokenOptions(ctx), ip)
 	if errResp != nil {
 		srv.ResponseService.SetError(ctx, errResp)
 		return
 	}
 
+	// sign in is not completed until the second factor is passed, see MfaVerifyHandler
+	if res.MfaToken != nil {
+		srv.ResponseService.SuccessResponse(ctx, http.StatusOK, res)
+		return
+	}
+
 	if posthook, ok := interface{}(srv).(AuthWithAfterSignIn); ok {
 		if e := posthook.AfterSignIn(ctx, user); e != nil {
 			r := responses.NewResponse().SetStatus(http.StatusUnauthorized).AddError(e)
@@ -219,17 +274,31 @@ func (srv *AuthService) AfterSignIn(ctx *gin.Context, user *models.User) *respon
 	// set last time login
 	lastLoginAt := time.Now()
 	info := &models.User{LastLoginIp: ip, LastLoginAt: &lastLoginAt}
-	err = srv.Repository.GetUsersRepository().UpdateLastLoginInfo(user, info)
+	tx := srv.Repository.GetUsersRepository().DB.Begin()
+	err = srv.Repository.GetUsersRepository().WrapContext(tx).UpdateLastLoginInfo(user, info)
+	if err == nil {
+		err = srv.outbox.Record(tx, events.UserLoggedIn, &events.UserLoggedInData{UID: user.UID, IP: ip, At: lastLoginAt})
+	}
 	if err != nil {
+		tx.Rollback()
 		logger.Error("failed to update last login info", "error", err)
+	} else {
+		tx.Commit()
 	}
 
 	// insert record into accesslog
-	err = srv.Repository.GetAccesLogRepository().Create(&models.AccessLog{UID: user.UID, IP: ip})
-	if err != nil {
+	device := getTokenOptions(ctx).Device
+	assessment := srv.loginRisk.Assess(user, device)
+	if err = srv.loginRisk.Record(user, device, assessment); err != nil {
 		logger.Error("failed to insert record into accesslog", "error", err)
 	}
 
+	if assessment.Has(auth.RiskFactorNewDevice) {
+		if _, err = srv.notificationsService.NewSignIn(user.UID); err != nil {
+			logger.Error("failed to send new sign in notification", "error", err)
+		}
+	}
+
 	srv.AuthBlocker.ClearAllOldFailAttempts(user)
 
 	return nil
@@ -255,7 +324,7 @@ func (srv *AuthService) RefreshHandler(ctx *gin.Context) {
 		return
 	}
 
-	output, err := srv.tokenService.RefreshTokens(accessToken, refreshToken, nil)
+	output, err := srv.tokenService.RefreshTokens(accessToken, refreshToken, getTokenOptions(ctx))
 	if err != nil {
 		logger.Error("failed to refresh token", "error", err)
 		// Returns a "401 StatusUnauthorized" response
@@ -284,8 +353,12 @@ func (srv *AuthService) SimpleSignUpHandler(ctx *gin.Context) {
 		return
 	}
 
-	user, err = srv.UserService.CreateNew(user)
+	user, err = srv.UserService.CreateNew(user, ctx.ClientIP())
 	if err != nil {
+		if typedErr, ok := err.(errors.TypedError); ok {
+			errors.AddErrors(ctx, typedErr)
+			return
+		}
 		logger.Error("failed to create user by request", "error", err)
 		srv.ResponseService.Error(ctx, responses.CannotCreateUserWithRegistrationRequest, "Can't create user.")
 		return
@@ -333,7 +406,7 @@ func (srv *AuthService) SignOutHandler(ctx *gin.Context) {
 	ctx.JSON(http.StatusNoContent, nil)
 }
 
-// SignOutDeviceHandler remove the access token and device
+// SignOutDeviceHandler remove the access token and all sessions of the given device
 func (srv *AuthService) SignOutDeviceHandler(ctx *gin.Context) {
 	logger := srv.Logger.New("action", "SignOutDeviceHandler")
 
@@ -355,6 +428,12 @@ func (srv *AuthService) SignOutDeviceHandler(ctx *gin.Context) {
 		return
 	}
 
+	if currentUser := GetCurrentUser(ctx); currentUser != nil {
+		if err := srv.tokenService.RevokeDeviceSessions(currentUser.UID, validator.DeviceID); err != nil {
+			logger.Error("failed to revoke device sessions", "error", err)
+		}
+	}
+
 	ctx.JSON(http.StatusNoContent, nil)
 }
 
@@ -404,6 +483,11 @@ func (srv *AuthService) ResetPassword(ctx *gin.Context) {
 
 	err := srv.UserService.ResetPassword(form.NewPassword, form.ConfirmationCode)
 	if err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response if the password violates the password policy
+		if typedErr, ok := err.(errors.TypedError); ok {
+			errors.AddErrors(ctx, typedErr)
+			return
+		}
 		logger.Error("failed to reset password", "error", err)
 		// Returns a "400 StatusBadRequest" response
 		srv.ResponseService.Error(ctx, responses.InvalidConfirmationCode, "Can't reset password.")
@@ -437,6 +521,12 @@ func (srv *AuthService) ChangePasswordHandler(ctx *gin.Context) {
 		return
 	}
 
+	if err := srv.UserService.ValidatePassword(user, validator.Model.ProposedPassword, "proposedPassword"); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		srv.ResponseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
 	hash, err := srv.PasswordService.UserHashPassword(validator.Model.ProposedPassword)
 	if err != nil {
 		logger.Error("failed to create password hash", "error", err)
@@ -451,7 +541,7 @@ func (srv *AuthService) ChangePasswordHandler(ctx *gin.Context) {
 		user.ChallengeName = nil
 	}
 
-	err = srv.Repository.GetUsersRepository().UpdatePasswordAndChallengeName(user, user)
+	err = srv.UserService.UpdatePasswordAndChallengeName(user)
 	if nil != err {
 		logger.Error("failed to update user", "error", err)
 		// Returns a "400 StatusBadRequest" response
@@ -481,6 +571,12 @@ func (srv *AuthService) SetPasswordHandler(ctx *gin.Context) {
 		return
 	}
 
+	if err := srv.UserService.ValidatePassword(user, setPasswordForm.ProposedPassword, "proposedPassword"); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		srv.ResponseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
 	hash, err := srv.PasswordService.UserHashPassword(setPasswordForm.ProposedPassword)
 	if err != nil {
 		logger.Error("failed to create password hash", "error", err)
@@ -495,7 +591,7 @@ func (srv *AuthService) SetPasswordHandler(ctx *gin.Context) {
 		user.ChallengeName = nil
 	}
 
-	err = srv.Repository.GetUsersRepository().UpdatePasswordAndChallengeName(user, user)
+	err = srv.UserService.UpdatePasswordAndChallengeName(user)
 	if nil != err {
 		logger.Error("failed to update user", "error", err)
 		// Returns a "400 StatusBadRequest" response
@@ -552,7 +648,7 @@ func (srv *AuthService) IssueTokensForUserByUID(ctx *gin.Context) {
 		return
 	}
 
-	res, err := srv.tokenService.IssueTokens(user, nil)
+	res, err := srv.tokenService.IssueTokens(user, getTokenOptions(ctx))
 	if err != nil {
 		logger.Error("can't issue tokens", err)
 		ctx.AbortWithStatus(http.StatusInternalServerError)
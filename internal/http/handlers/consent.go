@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Confialink/wallet-pkg-errors"
+	"github.com/gin-gonic/gin"
+	"github.com/inconshreveable/log15"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	"github.com/Confialink/wallet-users/internal/services/gdpr"
+	"github.com/Confialink/wallet-users/internal/validators"
+)
+
+// ConsentHandler grants and withdraws consents of users to the terms, the privacy policy and marketing
+type ConsentHandler struct {
+	consents        *gdpr.Consents
+	responseService responses.ResponseHandler
+	logger          log15.Logger
+}
+
+func NewConsentHandler(
+	consents *gdpr.Consents,
+	responseService responses.ResponseHandler,
+	logger log15.Logger,
+) *ConsentHandler {
+	return &ConsentHandler{
+		consents,
+		responseService,
+		logger.New("handler", "ConsentHandler"),
+	}
+}
+
+// ListHandler returns the consent ledger of the requested user
+func (h *ConsentHandler) ListHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "ListHandler")
+	user := GetRequestedUser(ctx)
+
+	consents, err := h.consents.List(user)
+	if err != nil {
+		logger.Error("failed to find consents", "error", err, "uid", user.UID)
+		h.responseService.Error(ctx, responses.CannotProcessConsent, "Can't find consents.")
+		return
+	}
+
+	// Returns a "200 OK" response
+	h.responseService.OkResponse(ctx, consents)
+}
+
+// GrantHandler records consent of the current user to a document,
+// the consent challenge is cleared when every published document is accepted in its current version
+func (h *ConsentHandler) GrantHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "GrantHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	form := &validators.GrantConsentValidator{}
+	if err := form.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		h.responseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	consent, err := h.consents.Grant(user, form.DocumentType, form.Version, ctx.ClientIP())
+	if err != nil {
+		logger.Error("failed to grant consent", "error", err, "uid", user.UID, "document", form.DocumentType)
+		h.responseService.Error(ctx, responses.CannotProcessConsent, "Can't grant consent.")
+		return
+	}
+
+	// Returns a "201 StatusCreated" response
+	h.responseService.SuccessResponse(ctx, http.StatusCreated, consent)
+}
+
+// WithdrawHandler withdraws consent of the current user to the document
+func (h *ConsentHandler) WithdrawHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "WithdrawHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+	documentType := ctx.Param("documentType")
+
+	if err := h.consents.Withdraw(user, documentType); err != nil {
+		if typedErr, ok := err.(errors.TypedError); ok {
+			errors.AddErrors(ctx, typedErr)
+			return
+		}
+		logger.Error("failed to withdraw consent", "error", err, "uid", user.UID, "document", documentType)
+		h.responseService.Error(ctx, responses.CannotProcessConsent, "Can't withdraw consent.")
+		return
+	}
+
+	// Returns a "204 StatusNoContent" response
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Confialink/wallet-pkg-errors"
+	"github.com/gin-gonic/gin"
+	"github.com/inconshreveable/log15"
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	"github.com/Confialink/wallet-users/internal/services/gdpr"
+)
+
+// DataExportHandler exports personal data held about the requested user
+type DataExportHandler struct {
+	export          *gdpr.Export
+	responseService responses.ResponseHandler
+	logger          log15.Logger
+}
+
+func NewDataExportHandler(
+	export *gdpr.Export,
+	responseService responses.ResponseHandler,
+	logger log15.Logger,
+) *DataExportHandler {
+	return &DataExportHandler{
+		export,
+		responseService,
+		logger.New("handler", "DataExportHandler"),
+	}
+}
+
+// LatestHandler returns the last requested export of the requested user
+func (h *DataExportHandler) LatestHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "LatestHandler")
+	user := GetRequestedUser(ctx)
+
+	export, err := h.export.Latest(user)
+	if err == gorm.ErrRecordNotFound {
+		// Returns a "404 StatusNotFound" response
+		h.responseService.Error(ctx, responses.DataExportNotFound, "There is no export of personal data.")
+		return
+	}
+	if err != nil {
+		logger.Error("failed to find data export", "error", err, "uid", user.UID)
+		h.responseService.Error(ctx, responses.CannotExportPersonalData, "Can't find export of personal data.")
+		return
+	}
+
+	// Returns a "200 OK" response
+	h.responseService.OkResponse(ctx, export)
+}
+
+// RequestHandler queues an export of personal data of the requested user,
+// the user is notified when the bundle is uploaded
+func (h *DataExportHandler) RequestHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "RequestHandler")
+	user := GetRequestedUser(ctx)
+	currentUser := GetCurrentUser(ctx)
+
+	export, err := h.export.Request(user, currentUser.UID)
+	if err != nil {
+		if typedErr, ok := err.(errors.TypedError); ok {
+			errors.AddErrors(ctx, typedErr)
+			return
+		}
+		logger.Error("failed to request data export", "error", err, "uid", user.UID)
+		h.responseService.Error(ctx, responses.CannotExportPersonalData, "Can't export personal data.")
+		return
+	}
+
+	// Returns a "202 StatusAccepted" response
+	h.responseService.SuccessResponse(ctx, http.StatusAccepted, export)
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Confialink/wallet-pkg-errors"
+	"github.com/gin-gonic/gin"
+	"github.com/inconshreveable/log15"
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	"github.com/Confialink/wallet-users/internal/services"
+	"github.com/Confialink/wallet-users/internal/services/auth"
+	"github.com/Confialink/wallet-users/internal/services/users"
+	"github.com/Confialink/wallet-users/internal/validators"
+)
+
+// EmailChangeHandler changes emails of users after the new address is confirmed
+type EmailChangeHandler struct {
+	emailChange     *users.EmailChange
+	passwordService *services.Password
+	tokenService    *auth.TokenService
+	securityEvents  *auth.SecurityEvents
+	responseService responses.ResponseHandler
+	logger          log15.Logger
+}
+
+func NewEmailChangeHandler(
+	emailChange *users.EmailChange,
+	passwordService *services.Password,
+	tokenService *auth.TokenService,
+	securityEvents *auth.SecurityEvents,
+	responseService responses.ResponseHandler,
+	logger log15.Logger,
+) *EmailChangeHandler {
+	return &EmailChangeHandler{
+		emailChange,
+		passwordService,
+		tokenService,
+		securityEvents,
+		responseService,
+		logger.New("handler", "EmailChangeHandler"),
+	}
+}
+
+// PendingHandler returns the change of the email of the current user which waits for confirmation
+func (h *EmailChangeHandler) PendingHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "PendingHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	change, err := h.emailChange.Pending(user)
+	if err == gorm.ErrRecordNotFound {
+		// Returns a "404 StatusNotFound" response
+		h.responseService.Error(ctx, responses.EmailChangeNotFound, "There is no email change to confirm.")
+		return
+	}
+	if err != nil {
+		logger.Error("failed to find email change", "error", err, "uid", user.UID)
+		h.responseService.Error(ctx, responses.CannotChangeEmail, "Can't find email change.")
+		return
+	}
+
+	// Returns a "200 OK" response
+	h.responseService.OkResponse(ctx, change)
+}
+
+// RequestHandler sends a code to the new address of the current user and alerts the current address
+func (h *EmailChangeHandler) RequestHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "RequestHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	form := &validators.RequestEmailChangeValidator{}
+	if err := form.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		h.responseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	if err := h.passwordService.UserCheckPassword(form.Password, user.Password); err != nil {
+		h.responseService.Error(ctx, responses.CodeInvalidPassword, "Invalid password.")
+		return
+	}
+
+	change, err := h.emailChange.Request(user, form.Email)
+	if err != nil {
+		if typedErr, ok := err.(errors.TypedError); ok {
+			errors.AddErrors(ctx, typedErr)
+			return
+		}
+		logger.Error("failed to request email change", "error", err, "uid", user.UID)
+		h.responseService.Error(ctx, responses.CannotChangeEmail, "Can't change email.")
+		return
+	}
+
+	h.securityEvents.Record(user.UID, models.SecurityEventEmailChangeRequested, getTokenOptions(ctx).Device, map[string]interface{}{
+		"oldEmail": user.Email,
+		"newEmail": form.Email,
+	})
+
+	// Returns a "200 OK" response
+	h.responseService.OkResponse(ctx, change)
+}
+
+// ConfirmHandler replaces the email of the current user by the pending address confirmed by the code
+func (h *EmailChangeHandler) ConfirmHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "ConfirmHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	form := &validators.EmailChangeCodeValidator{}
+	if err := form.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		h.responseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	if err := h.emailChange.Confirm(user, form.Code); err != nil {
+		if typedErr, ok := err.(errors.TypedError); ok {
+			errors.AddErrors(ctx, typedErr)
+			return
+		}
+		logger.Error("failed to confirm email change", "error", err, "uid", user.UID)
+		h.responseService.Error(ctx, responses.CannotChangeEmail, "Can't change email.")
+		return
+	}
+
+	// Returns a "204 StatusNoContent" response
+	ctx.Status(http.StatusNoContent)
+}
+
+// CancelHandler cancels the change of the email by the code sent to the old address,
+// sessions are revoked if the change was already confirmed
+func (h *EmailChangeHandler) CancelHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "CancelHandler")
+
+	form := &validators.EmailChangeCodeValidator{}
+	if err := form.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		h.responseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	user, reverted, err := h.emailChange.Cancel(form.Code)
+	if err != nil {
+		if typedErr, ok := err.(errors.TypedError); ok {
+			errors.AddErrors(ctx, typedErr)
+			return
+		}
+		logger.Error("failed to cancel email change", "error", err)
+		h.responseService.Error(ctx, responses.CannotChangeEmail, "Can't cancel email change.")
+		return
+	}
+
+	h.securityEvents.Record(user.UID, models.SecurityEventEmailChangeCancelled, getTokenOptions(ctx).Device, map[string]interface{}{
+		"reverted": reverted,
+	})
+	if reverted {
+		// whoever confirmed the change must not keep access to the account
+		if err := h.tokenService.RevokeUserTokens(user); err != nil {
+			logger.Error("failed to revoke tokens", "error", err, "uid", user.UID)
+		}
+	}
+
+	// Returns a "204 StatusNoContent" response
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Confialink/wallet-pkg-errors"
+	"github.com/gin-gonic/gin"
+	"github.com/inconshreveable/log15"
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	"github.com/Confialink/wallet-users/internal/services"
+	"github.com/Confialink/wallet-users/internal/services/auth"
+	"github.com/Confialink/wallet-users/internal/services/gdpr"
+	"github.com/Confialink/wallet-users/internal/validators"
+)
+
+// ErasureHandler handles requests of users to erase their personal data and their review by admins
+type ErasureHandler struct {
+	erasure         *gdpr.Erasure
+	repository      *repositories.ErasureRequestRepository
+	passwordService *services.Password
+	securityEvents  *auth.SecurityEvents
+	params          *HandlerParams
+	responseService responses.ResponseHandler
+	logger          log15.Logger
+}
+
+func NewErasureHandler(
+	erasure *gdpr.Erasure,
+	repository *repositories.ErasureRequestRepository,
+	passwordService *services.Password,
+	securityEvents *auth.SecurityEvents,
+	params *HandlerParams,
+	responseService responses.ResponseHandler,
+	logger log15.Logger,
+) *ErasureHandler {
+	return &ErasureHandler{
+		erasure,
+		repository,
+		passwordService,
+		securityEvents,
+		params,
+		responseService,
+		logger.New("handler", "ErasureHandler"),
+	}
+}
+
+// CurrentHandler returns the erasure request of the current user which is not reviewed yet or waits for erasure
+func (h *ErasureHandler) CurrentHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "CurrentHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	request, err := h.erasure.Current(user)
+	if err == gorm.ErrRecordNotFound {
+		// Returns a "404 StatusNotFound" response
+		h.responseService.Error(ctx, responses.ErasureRequestNotFound, "There is no erasure request.")
+		return
+	}
+	if err != nil {
+		logger.Error("failed to find erasure request", "error", err, "uid", user.UID)
+		h.responseService.Error(ctx, responses.CannotProcessErasureRequest, "Can't find erasure request.")
+		return
+	}
+
+	// Returns a "200 OK" response
+	h.responseService.OkResponse(ctx, request)
+}
+
+// RequestHandler asks to erase personal data of the current user
+func (h *ErasureHandler) RequestHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "RequestHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	form := &validators.RequestErasureValidator{}
+	if err := form.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		h.responseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	if err := h.passwordService.UserCheckPassword(form.Password, user.Password); err != nil {
+		h.responseService.Error(ctx, responses.CodeInvalidPassword, "Invalid password.")
+		return
+	}
+
+	request, err := h.erasure.Request(user)
+	if err != nil {
+		h.handleError(ctx, logger, err, "failed to request erasure", user.UID)
+		return
+	}
+
+	h.securityEvents.Record(user.UID, models.SecurityEventErasureRequested, getTokenOptions(ctx).Device, map[string]interface{}{
+		"requestId": request.ID,
+	})
+
+	// Returns a "201 StatusCreated" response
+	h.responseService.SuccessResponse(ctx, http.StatusCreated, request)
+}
+
+// CancelHandler withdraws the erasure request of the current user
+func (h *ErasureHandler) CancelHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "CancelHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	if _, err := h.erasure.Cancel(user); err != nil {
+		h.handleError(ctx, logger, err, "failed to cancel erasure request", user.UID)
+		return
+	}
+
+	// Returns a "204 StatusNoContent" response
+	ctx.Status(http.StatusNoContent)
+}
+
+// ListHandler returns erasure requests of all users
+func (h *ErasureHandler) ListHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "ListHandler")
+
+	params := h.params.erasureRequests(ctx.Request.URL.RawQuery)
+	if ok, errorsList := params.Validate(); !ok {
+		var errs []*responses.Error
+		for _, err := range errorsList {
+			errs = append(errs, responses.NewCommonError().
+				ApplyCode(responses.CannotGetErasureRequests).
+				SetDetails(err.Error()))
+		}
+		h.responseService.Errors(ctx, http.StatusBadRequest, errs)
+		return
+	}
+
+	requests, err := h.repository.GetList(params)
+	if err != nil {
+		logger.Error("can't load erasure requests", "error", err)
+		h.responseService.Error(ctx, responses.CannotGetErasureRequests, "Can't load erasure requests")
+		return
+	}
+
+	// Returns a "200 OK" response
+	h.responseService.OkResponse(ctx, requests)
+}
+
+// ApproveHandler starts the grace period of the erasure request after which personal data is erased
+func (h *ErasureHandler) ApproveHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "ApproveHandler")
+	currentUser := GetCurrentUser(ctx)
+
+	id, err := getUint64Param(ctx, "id")
+	if err != nil {
+		h.responseService.Error(ctx, responses.ErasureRequestNotFound, err.Error())
+		return
+	}
+
+	request, err := h.erasure.Approve(id, currentUser.UID)
+	if err != nil {
+		h.handleError(ctx, logger, err, "failed to approve erasure request", currentUser.UID)
+		return
+	}
+
+	// Returns a "200 OK" response
+	h.responseService.OkResponse(ctx, request)
+}
+
+// RejectHandler closes the erasure request without erasure
+func (h *ErasureHandler) RejectHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "RejectHandler")
+	currentUser := GetCurrentUser(ctx)
+
+	id, err := getUint64Param(ctx, "id")
+	if err != nil {
+		h.responseService.Error(ctx, responses.ErasureRequestNotFound, err.Error())
+		return
+	}
+
+	form := &validators.RejectErasureValidator{}
+	if err := form.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		h.responseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	request, err := h.erasure.Reject(id, currentUser.UID, form.Reason)
+	if err != nil {
+		h.handleError(ctx, logger, err, "failed to reject erasure request", currentUser.UID)
+		return
+	}
+
+	// Returns a "200 OK" response
+	h.responseService.OkResponse(ctx, request)
+}
+
+func (h *ErasureHandler) handleError(ctx *gin.Context, logger log15.Logger, err error, message, uid string) {
+	if typedErr, ok := err.(errors.TypedError); ok {
+		errors.AddErrors(ctx, typedErr)
+		return
+	}
+	logger.Error(message, "error", err, "uid", uid)
+	h.responseService.Error(ctx, responses.CannotProcessErasureRequest, "Can't process erasure request.")
+}
@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	"github.com/Confialink/wallet-users/internal/http/serializers"
+	"github.com/Confialink/wallet-users/internal/services/auth"
+	"github.com/Confialink/wallet-users/internal/validators"
+)
+
+// FederatedProvidersHandler returns identity providers users may sign in with
+func (srv *AuthService) FederatedProvidersHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "FederatedProvidersHandler")
+
+	providers, err := srv.federated.ActiveProviders()
+	if err != nil {
+		logger.Error("failed to retrieve identity providers", "error", err)
+		srv.ResponseService.Error(ctx, responses.CannotRetrieveCollection, "Can't retrieve identity providers.")
+		return
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.OkResponse(ctx, serializers.NewPublicIdentityProviders(providers))
+}
+
+// FederatedSignInBeginHandler returns the url of the identity provider the user must be redirected to
+func (srv *AuthService) FederatedSignInBeginHandler(ctx *gin.Context) {
+	srv.federatedBegin(ctx, nil, "FederatedSignInBeginHandler")
+}
+
+// FederatedSignInHandler signs in the user authenticated by the identity provider,
+// the user is created on the first sign in if the provider allows it
+func (srv *AuthService) FederatedSignInHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "FederatedSignInHandler")
+	var ip = ctx.ClientIP()
+
+	validator := validators.FederatedCallbackValidator{}
+	if err := validator.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		srv.ResponseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	// the user is not known until the identity provider is asked, so only IP is checked beforehand
+	srv.AuthBlocker.LoadSettings()
+	if err := srv.AuthBlocker.CheckIP(ip); err != nil {
+		r := responses.NewResponse().SetStatus(http.StatusForbidden).AddError(responses.NewCommonError().ApplyCode(responses.CodeIpIsBlocked))
+		ctx.AbortWithStatusJSON(r.Status, r)
+		return
+	}
+
+	user, provisioned, err := srv.federated.SignIn(validator.State, validator.Code)
+	if err != nil {
+		if err == auth.ErrFederatedInvalidState || err == auth.ErrFederatedAuthentication {
+			srv.AuthBlocker.AddIPFailAttempt(ip)
+		}
+		if errResp := auth.FederatedErrorToResponse(err); errResp != nil {
+			srv.ResponseService.SetError(ctx, errResp)
+			return
+		}
+		logger.Error("failed to sign in with identity provider", "error", err)
+		srv.ResponseService.Error(ctx, responses.InternalError, "")
+		return
+	}
+
+	if provisioned {
+		if err := srv.accountsService.GenerateAccount(user); err != nil {
+			logger.Error("cannot generate account", "error", err, "uid", user.UID)
+			// do not return the error in response
+		}
+	}
+
+	if e := srv.BeforeSignIn(ctx, user); e != nil {
+		r := responses.NewResponse().SetStatus(http.StatusForbidden).AddError(e)
+		ctx.AbortWithStatusJSON(r.Status, r)
+		return
+	}
+
+	res, errResp := srv.authService.LoginFederated(user, getTokenOptions(ctx))
+	if errResp != nil {
+		srv.ResponseService.SetError(ctx, errResp)
+		return
+	}
+
+	// sign in is not completed until the second factor is passed, see MfaVerifyHandler
+	if res.MfaToken != nil {
+		srv.ResponseService.SuccessResponse(ctx, http.StatusOK, res)
+		return
+	}
+
+	if e := srv.AfterSignIn(ctx, user); e != nil {
+		r := responses.NewResponse().SetStatus(http.StatusUnauthorized).AddError(e)
+		ctx.AbortWithStatusJSON(r.Status, r)
+		return
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.SuccessResponse(ctx, http.StatusOK, res)
+}
+
+// FederatedIdentitiesHandler returns identities linked to the current user
+func (srv *AuthService) FederatedIdentitiesHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "FederatedIdentitiesHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	identities, err := srv.federated.Identities(user)
+	if err != nil {
+		logger.Error("failed to retrieve identities", "error", err)
+		srv.ResponseService.Error(ctx, responses.InternalError, "")
+		return
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.OkResponse(ctx, serializers.NewUserIdentities(identities))
+}
+
+// FederatedLinkBeginHandler returns the url of the identity provider in order to link the identity to the current user
+func (srv *AuthService) FederatedLinkBeginHandler(ctx *gin.Context) {
+	user := ctx.MustGet("_current_user").(*models.User)
+	srv.federatedBegin(ctx, user, "FederatedLinkBeginHandler")
+}
+
+// FederatedLinkHandler links the identity the user is authenticated with by the identity provider to the current user
+func (srv *AuthService) FederatedLinkHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "FederatedLinkHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	validator := validators.FederatedCallbackValidator{}
+	if err := validator.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		srv.ResponseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	identity, err := srv.federated.Link(user, validator.State, validator.Code)
+	if err != nil {
+		if errResp := auth.FederatedErrorToResponse(err); errResp != nil {
+			srv.ResponseService.SetError(ctx, errResp)
+			return
+		}
+		logger.Error("failed to link identity", "error", err)
+		srv.ResponseService.Error(ctx, responses.InternalError, "")
+		return
+	}
+
+	// Returns a "201 StatusCreated" response
+	srv.ResponseService.SuccessResponse(ctx, http.StatusCreated, serializers.NewUserIdentity(identity))
+}
+
+// FederatedUnlinkHandler removes an identity of the current user
+func (srv *AuthService) FederatedUnlinkHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "FederatedUnlinkHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	id, err := getUint64Param(ctx, "id")
+	if err != nil {
+		srv.ResponseService.Error(ctx, responses.UserIdentityNotFound, err.Error())
+		return
+	}
+
+	if err := srv.federated.Unlink(user, id); err != nil {
+		if errResp := auth.FederatedErrorToResponse(err); errResp != nil {
+			srv.ResponseService.SetError(ctx, errResp)
+			return
+		}
+		logger.Error("failed to unlink identity", "error", err, "id", id)
+		srv.ResponseService.Error(ctx, responses.InternalError, "")
+		return
+	}
+
+	// Returns a "204 StatusNoContent" response
+	ctx.Status(http.StatusNoContent)
+}
+
+// ListIdentityProvidersHandler returns all identity providers
+func (srv *AuthService) ListIdentityProvidersHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "ListIdentityProvidersHandler")
+
+	providers, err := srv.federated.Providers()
+	if err != nil {
+		logger.Error("failed to retrieve identity providers", "error", err)
+		srv.ResponseService.Error(ctx, responses.CannotRetrieveCollection, "Can't retrieve identity providers.")
+		return
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.OkResponse(ctx, serializers.NewIdentityProviders(providers))
+}
+
+// CreateIdentityProviderHandler adds an identity provider
+func (srv *AuthService) CreateIdentityProviderHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "CreateIdentityProviderHandler")
+
+	validator := validators.IdentityProviderValidator{}
+	if err := validator.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		srv.ResponseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	provider, err := srv.federated.CreateProvider(identityProviderParams(&validator))
+	if err != nil {
+		if errResp := auth.FederatedErrorToResponse(err); errResp != nil {
+			srv.ResponseService.SetError(ctx, errResp)
+			return
+		}
+		logger.Error("failed to create identity provider", "error", err)
+		srv.ResponseService.Error(ctx, responses.InternalError, "")
+		return
+	}
+
+	// Returns a "201 StatusCreated" response
+	srv.ResponseService.SuccessResponse(ctx, http.StatusCreated, serializers.NewIdentityProvider(provider))
+}
+
+// UpdateIdentityProviderHandler changes an identity provider, the client secret is kept if it is not passed
+func (srv *AuthService) UpdateIdentityProviderHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "UpdateIdentityProviderHandler")
+
+	id, err := getUint64Param(ctx, "id")
+	if err != nil {
+		srv.ResponseService.Error(ctx, responses.IdentityProviderNotFound, err.Error())
+		return
+	}
+
+	validator := validators.IdentityProviderValidator{}
+	if err := validator.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		srv.ResponseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	provider, err := srv.federated.UpdateProvider(id, identityProviderParams(&validator))
+	if err != nil {
+		if errResp := auth.FederatedErrorToResponse(err); errResp != nil {
+			srv.ResponseService.SetError(ctx, errResp)
+			return
+		}
+		logger.Error("failed to update identity provider", "error", err, "id", id)
+		srv.ResponseService.Error(ctx, responses.InternalError, "")
+		return
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.OkResponse(ctx, serializers.NewIdentityProvider(provider))
+}
+
+// DeleteIdentityProviderHandler removes an identity provider along with identities linked to users
+func (srv *AuthService) DeleteIdentityProviderHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "DeleteIdentityProviderHandler")
+
+	id, err := getUint64Param(ctx, "id")
+	if err != nil {
+		srv.ResponseService.Error(ctx, responses.IdentityProviderNotFound, err.Error())
+		return
+	}
+
+	if err := srv.federated.DeleteProvider(id); err != nil {
+		if errResp := auth.FederatedErrorToResponse(err); errResp != nil {
+			srv.ResponseService.SetError(ctx, errResp)
+			return
+		}
+		logger.Error("failed to delete identity provider", "error", err, "id", id)
+		srv.ResponseService.Error(ctx, responses.InternalError, "")
+		return
+	}
+
+	// Returns a "204 StatusNoContent" response
+	ctx.Status(http.StatusNoContent)
+}
+
+// federatedLoginRequired refuses password sign in if the email belongs to a company which signs in with its identity provider
+func (srv *AuthService) federatedLoginRequired(ctx *gin.Context, email string) bool {
+	provider, err := srv.federated.PasswordLoginProvider(email)
+	if err != nil {
+		srv.Logger.New("action", "federatedLoginRequired").Error("failed to retrieve identity providers", "error", err)
+		return false
+	}
+	if provider == nil {
+		return false
+	}
+
+	e := responses.NewCommonErrorByCode(responses.CodeFederatedLoginRequired, "Sign in with "+provider.Name+".")
+	e.Meta = &serializers.PublicIdentityProvider{Slug: provider.Slug, Name: provider.Name}
+	// Returns a "403 StatusForbidden" response
+	srv.ResponseService.SetError(ctx, e)
+	return true
+}
+
+func (srv *AuthService) federatedBegin(ctx *gin.Context, user *models.User, action string) {
+	logger := srv.Logger.New("action", action)
+
+	redirect, err := srv.federated.Begin(ctx.Param("slug"), user)
+	if err != nil {
+		if errResp := auth.FederatedErrorToResponse(err); errResp != nil {
+			srv.ResponseService.SetError(ctx, errResp)
+			return
+		}
+		logger.Error("failed to begin federated sign in", "error", err, "provider", ctx.Param("slug"))
+		// the provider may be unavailable
+		srv.ResponseService.Error(ctx, responses.InternalError, "")
+		return
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.OkResponse(ctx, gin.H{"authorizationUrl": redirect})
+}
+
+func identityProviderParams(validator *validators.IdentityProviderValidator) *auth.IdentityProviderParams {
+	isActive := true
+	if validator.IsActive != nil {
+		isActive = *validator.IsActive
+	}
+	return &auth.IdentityProviderParams{
+		Slug:                  validator.Slug,
+		Name:                  validator.Name,
+		Issuer:                validator.Issuer,
+		ClientID:              validator.ClientID,
+		ClientSecret:          validator.ClientSecret,
+		Scopes:                validator.Scopes,
+		Domains:               validator.Domains,
+		RoleClaim:             validator.RoleClaim,
+		RoleMapping:           validator.RoleMapping,
+		DefaultRole:           validator.DefaultRole,
+		GroupClaim:            validator.GroupClaim,
+		GroupMapping:          validator.GroupMapping,
+		JitEnabled:            validator.JitEnabled,
+		PasswordLoginDisabled: validator.PasswordLoginDisabled,
+		IsActive:              isActive,
+	}
+}
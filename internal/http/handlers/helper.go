@@ -5,6 +5,8 @@ import (
 	"strconv"
 
 	"github.com/Confialink/wallet-users/internal/db/models"
+	httpAuth "github.com/Confialink/wallet-users/internal/http/services/auth"
+	"github.com/Confialink/wallet-users/internal/services/auth"
 	userpb "github.com/Confialink/wallet-users/rpc/proto/users"
 	"github.com/gin-gonic/gin"
 )
@@ -54,3 +56,21 @@ func getUint64Param(ctx *gin.Context, name string) (uint64, error) {
 
 	return res, nil
 }
+
+// getTokenOptions returns token options which describe the device the request is sent from
+func getTokenOptions(ctx *gin.Context) *auth.TokenOptions {
+	return &auth.TokenOptions{
+		Device: &auth.DeviceInfo{
+			DeviceID:  truncate(ctx.GetHeader(httpAuth.DeviceIDHeader), 255),
+			UserAgent: truncate(ctx.Request.UserAgent(), 512),
+			IP:        ctx.ClientIP(),
+		},
+	}
+}
+
+func truncate(s string, max int) string {
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
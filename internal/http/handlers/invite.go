@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 
+	errors "github.com/Confialink/wallet-pkg-errors"
 	"github.com/gin-gonic/gin"
 	"github.com/inconshreveable/log15"
 
@@ -98,6 +99,11 @@ func (h *InvitesHandler) CreateHandler(ctx *gin.Context) {
 	createdUser, err := h.userCreator.Create(&validator.UserModel, true, false, tx)
 	if err != nil {
 		tx.Rollback()
+		if _, ok := err.(errors.TypedError); ok {
+			// Returns a "422 StatusUnprocessableEntity" response if the password violates the password policy
+			h.responseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+			return
+		}
 		h.logger.Error("failed to create a user", "error", err)
 		h.responseService.Error(ctx, responses.CanNotCreateUser, "Can't create a user")
 		return
@@ -111,9 +117,7 @@ func (h *InvitesHandler) CreateHandler(ctx *gin.Context) {
 		return
 	}
 
-	currentUser.Status = models.StatusActive
-
-	err = txUserRepo.UpdateStatusInfo(currentUser)
+	err = h.userCreator.SetStatus(currentUser, models.StatusActive, tx)
 	if err != nil {
 		tx.Rollback()
 		h.logger.Error("failed to approve a user", "error", err)
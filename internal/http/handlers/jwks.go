@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Confialink/wallet-users/internal/jwt"
+	"github.com/Confialink/wallet-users/pkg/jwk"
+)
+
+// jwksMaxAge tells clients how long the keys may be cached
+const jwksMaxAge = "public, max-age=300"
+
+type JwksHandler struct {
+	keySet jwt.KeySet
+}
+
+func NewJwksHandler(keySet jwt.KeySet) *JwksHandler {
+	return &JwksHandler{keySet}
+}
+
+// KeysHandler publishes public keys of access tokens as JSON Web Key Set.
+// The set is not wrapped into the common response since clients expect the format of RFC 7517.
+func (h *JwksHandler) KeysHandler(ctx *gin.Context) {
+	ctx.Header("Cache-Control", jwksMaxAge)
+	// Returns a "200 OK" response
+	ctx.JSON(http.StatusOK, jwk.Set{Keys: h.keySet.PublicKeys()})
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Confialink/wallet-pkg-list_params"
+	"github.com/Confialink/wallet-pkg-utils/csv"
+	"github.com/gin-gonic/gin"
+	"github.com/inconshreveable/log15"
+
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	csvServices "github.com/Confialink/wallet-users/internal/services/csv"
+)
+
+// LoginHistoryHandler shows sign ins and failed attempts to sign in of the requested user
+type LoginHistoryHandler struct {
+	repository      *repositories.LoginHistoryRepository
+	csvService      *csvServices.LoginHistory
+	params          *HandlerParams
+	responseService responses.ResponseHandler
+	logger          log15.Logger
+}
+
+func NewLoginHistoryHandler(
+	repository *repositories.LoginHistoryRepository,
+	csvService *csvServices.LoginHistory,
+	params *HandlerParams,
+	responseService responses.ResponseHandler,
+	logger log15.Logger,
+) *LoginHistoryHandler {
+	return &LoginHistoryHandler{
+		repository,
+		csvService,
+		params,
+		responseService,
+		logger.New("handler", "LoginHistoryHandler"),
+	}
+}
+
+// ListHandler returns the login history of the requested user
+func (h *LoginHistoryHandler) ListHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "ListHandler")
+	user := GetRequestedUser(ctx)
+
+	params := h.params.loginHistory(ctx.Request.URL.RawQuery)
+	if !h.validate(ctx, params, responses.CannotGetLoginHistory) {
+		return
+	}
+	params.AddFilter("uid", []string{user.UID})
+
+	entries, err := h.repository.GetList(params)
+	if err != nil {
+		logger.Error("can't load login history", "error", err, "uid", user.UID)
+		h.responseService.Error(ctx, responses.CannotGetLoginHistory, "Can't load login history")
+		return
+	}
+
+	// Returns a "200 OK" response
+	h.responseService.OkResponse(ctx, entries)
+}
+
+// ExportHandler is handler to download csv file with the login history of the requested user
+func (h *LoginHistoryHandler) ExportHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "ExportHandler")
+	user := GetRequestedUser(ctx)
+
+	params := h.params.loginHistoryCsv(ctx.Request.URL.RawQuery)
+	if !h.validate(ctx, params, responses.CannotGetLoginHistoryAsCsv) {
+		return
+	}
+	params.AddFilter("uid", []string{user.UID})
+
+	file, err := h.csvService.GetFile(user, params)
+	if err != nil {
+		logger.Error("Can not get csv file", "error", err, "uid", user.UID)
+		h.responseService.Error(ctx, responses.CannotGetLoginHistoryAsCsv, "Can't get csv file")
+		return
+	}
+
+	if err := csv.Send(file, ctx.Writer); err != nil {
+		logger.Error("Can not send csv file", "error", err, "uid", user.UID)
+		h.responseService.Error(ctx, responses.CannotSendUserProfilesAsCsv, "Can't send csv file")
+		return
+	}
+}
+
+func (h *LoginHistoryHandler) validate(ctx *gin.Context, params *list_params.ListParams, code string) bool {
+	ok, errorsList := params.Validate()
+	if ok {
+		return true
+	}
+
+	var errs []*responses.Error
+	for _, err := range errorsList {
+		e := responses.NewCommonError().
+			ApplyCode(code).
+			SetDetails(err.Error())
+		errs = append(errs, e)
+	}
+	h.responseService.Errors(ctx, http.StatusBadRequest, errs)
+	return false
+}
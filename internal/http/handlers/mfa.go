@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	"github.com/Confialink/wallet-users/internal/services/auth"
+	"github.com/Confialink/wallet-users/internal/validators"
+)
+
+// MfaStatusHandler returns two-factor authentication status of the current user
+func (srv *AuthService) MfaStatusHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "MfaStatusHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	enabled, err := srv.mfa.IsEnabled(user)
+	if err != nil {
+		logger.Error("failed to check mfa status", "error", err)
+		srv.ResponseService.Error(ctx, responses.InternalError, "")
+		return
+	}
+
+	remaining := 0
+	if enabled {
+		remaining, err = srv.mfa.RemainingRecoveryCodes(user)
+		if err != nil {
+			logger.Error("failed to count recovery codes", "error", err)
+			srv.ResponseService.Error(ctx, responses.InternalError, "")
+			return
+		}
+	}
+
+	credentials, err := srv.webauthn.Credentials(user)
+	if err != nil {
+		logger.Error("failed to retrieve webauthn credentials", "error", err)
+		srv.ResponseService.Error(ctx, responses.InternalError, "")
+		return
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.OkResponse(ctx, gin.H{
+		"enabled":                enabled,
+		"required":               srv.mfa.IsRequired(user),
+		"remainingRecoveryCodes": remaining,
+		"webauthnCredentials":    len(credentials),
+	})
+}
+
+// TotpEnrollHandler generates a new authenticator secret for the current user
+func (srv *AuthService) TotpEnrollHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "TotpEnrollHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	enrollment, err := srv.mfa.Enroll(user)
+	if err != nil {
+		if errResp := auth.MfaErrorToResponse(err); errResp != nil {
+			srv.ResponseService.SetError(ctx, errResp)
+			return
+		}
+		logger.Error("failed to enroll authenticator", "error", err)
+		// Returns a "500 StatusInternalServerError" response
+		srv.ResponseService.Error(ctx, responses.CannotEnrollMfa, "Can't enroll authenticator.")
+		return
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.OkResponse(ctx, enrollment)
+}
+
+// TotpConfirmHandler activates enrolled authenticator by the first code and returns recovery codes
+func (srv *AuthService) TotpConfirmHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "TotpConfirmHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	validator := validators.MfaCodeValidator{}
+	if err := validator.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		srv.ResponseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	recoveryCodes, err := srv.mfa.Confirm(user, validator.Code)
+	if err != nil {
+		if errResp := auth.MfaErrorToResponse(err); errResp != nil {
+			srv.ResponseService.SetError(ctx, errResp)
+			return
+		}
+		logger.Error("failed to confirm authenticator", "error", err)
+		srv.ResponseService.Error(ctx, responses.CannotEnrollMfa, "Can't enroll authenticator.")
+		return
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.OkResponse(ctx, gin.H{"recoveryCodes": recoveryCodes})
+}
+
+// RecoveryCodesHandler replaces recovery codes of the current user
+func (srv *AuthService) RecoveryCodesHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "RecoveryCodesHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	validator := validators.MfaCodeValidator{}
+	if err := validator.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		srv.ResponseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	recoveryCodes, err := srv.mfa.RegenerateRecoveryCodes(user, validator.Code)
+	if err != nil {
+		if errResp := auth.MfaErrorToResponse(err); errResp != nil {
+			srv.ResponseService.SetError(ctx, errResp)
+			return
+		}
+		logger.Error("failed to regenerate recovery codes", "error", err)
+		srv.ResponseService.Error(ctx, responses.InternalError, "")
+		return
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.OkResponse(ctx, gin.H{"recoveryCodes": recoveryCodes})
+}
+
+// TotpDisableHandler removes the authenticator of the current user
+func (srv *AuthService) TotpDisableHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "TotpDisableHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	validator := validators.MfaCodeValidator{}
+	if err := validator.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		srv.ResponseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	if err := srv.mfa.Disable(user, validator.Code); err != nil {
+		if errResp := auth.MfaErrorToResponse(err); errResp != nil {
+			srv.ResponseService.SetError(ctx, errResp)
+			return
+		}
+		logger.Error("failed to disable authenticator", "error", err)
+		srv.ResponseService.Error(ctx, responses.InternalError, "")
+		return
+	}
+
+	// Returns a "204 StatusNoContent" response
+	ctx.Status(http.StatusNoContent)
+}
+
+// ResetUserMfaHandler removes the authenticator of the requested user, e.g. if the user lost the device
+// and all recovery codes. The user has to enroll an authenticator again on the next sign in if it is mandatory.
+func (srv *AuthService) ResetUserMfaHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "ResetUserMfaHandler")
+	user := GetRequestedUser(ctx)
+
+	if err := srv.mfa.Reset(user); err != nil {
+		logger.Error("failed to reset authenticator", "error", err, "uid", user.UID)
+		srv.ResponseService.Error(ctx, responses.InternalError, "")
+		return
+	}
+
+	if err := srv.tokenService.RevokeUserTokens(user); err != nil {
+		logger.Error("failed to revoke user tokens", "error", err, "uid", user.UID)
+	}
+
+	// Returns a "204 StatusNoContent" response
+	ctx.Status(http.StatusNoContent)
+}
+
+// MfaVerifyHandler completes sign in using the mfa token and the second factor code
+func (srv *AuthService) MfaVerifyHandler(ctx *gin.Context) {
+	var ip = ctx.ClientIP()
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	validator := validators.MfaCodeValidator{}
+	if err := validator.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		srv.ResponseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	if e := srv.BeforeSignIn(ctx, user); e != nil {
+		r := responses.NewResponse().SetStatus(http.StatusForbidden).AddError(e)
+		ctx.AbortWithStatusJSON(r.Status, r)
+		return
+	}
+
+	res, errResp := srv.authService.VerifyMfa(user, validator.Code, getTokenOptions(ctx), ip)
+	if errResp != nil {
+		srv.ResponseService.SetError(ctx, errResp)
+		return
+	}
+
+	if e := srv.AfterSignIn(ctx, user); e != nil {
+		r := responses.NewResponse().SetStatus(http.StatusUnauthorized).AddError(e)
+		ctx.AbortWithStatusJSON(r.Status, r)
+		return
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.SuccessResponse(ctx, http.StatusOK, res)
+}
+
+// StepUpVerifyHandler completes a risky sign in using the mfa token and the code sent to the email or the phone
+func (srv *AuthService) StepUpVerifyHandler(ctx *gin.Context) {
+	var ip = ctx.ClientIP()
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	validator := validators.MfaCodeValidator{}
+	if err := validator.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		srv.ResponseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	if e := srv.BeforeSignIn(ctx, user); e != nil {
+		r := responses.NewResponse().SetStatus(http.StatusForbidden).AddError(e)
+		ctx.AbortWithStatusJSON(r.Status, r)
+		return
+	}
+
+	res, errResp := srv.authService.VerifyStepUp(user, validator.Code, getTokenOptions(ctx), ip)
+	if errResp != nil {
+		srv.ResponseService.SetError(ctx, errResp)
+		return
+	}
+
+	if e := srv.AfterSignIn(ctx, user); e != nil {
+		r := responses.NewResponse().SetStatus(http.StatusUnauthorized).AddError(e)
+		ctx.AbortWithStatusJSON(r.Status, r)
+		return
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.SuccessResponse(ctx, http.StatusOK, res)
+}
+
+// ConsentAcceptHandler completes sign in which returned the consent challenge using the mfa token,
+// the user accepts current versions of the terms and the privacy policy
+func (srv *AuthService) ConsentAcceptHandler(ctx *gin.Context) {
+	var ip = ctx.ClientIP()
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	if e := srv.BeforeSignIn(ctx, user); e != nil {
+		r := responses.NewResponse().SetStatus(http.StatusForbidden).AddError(e)
+		ctx.AbortWithStatusJSON(r.Status, r)
+		return
+	}
+
+	res, errResp := srv.authService.AcceptConsents(user, getTokenOptions(ctx), ip)
+	if errResp != nil {
+		srv.ResponseService.SetError(ctx, errResp)
+		return
+	}
+
+	if e := srv.AfterSignIn(ctx, user); e != nil {
+		r := responses.NewResponse().SetStatus(http.StatusUnauthorized).AddError(e)
+		ctx.AbortWithStatusJSON(r.Status, r)
+		return
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.SuccessResponse(ctx, http.StatusOK, res)
+}
+
+// MfaSetupConfirmHandler completes mandatory authenticator setup on sign in and returns tokens with recovery codes
+func (srv *AuthService) MfaSetupConfirmHandler(ctx *gin.Context) {
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	validator := validators.MfaCodeValidator{}
+	if err := validator.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		srv.ResponseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	res, errResp := srv.authService.ConfirmMfaSetup(user, validator.Code, getTokenOptions(ctx))
+	if errResp != nil {
+		srv.ResponseService.SetError(ctx, errResp)
+		return
+	}
+
+	if e := srv.AfterSignIn(ctx, user); e != nil {
+		r := responses.NewResponse().SetStatus(http.StatusUnauthorized).AddError(e)
+		ctx.AbortWithStatusJSON(r.Status, r)
+		return
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.SuccessResponse(ctx, http.StatusOK, res)
+}
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/inconshreveable/log15"
+
+	"github.com/Confialink/wallet-users/internal/authentication"
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	"github.com/Confialink/wallet-users/internal/http/serializers"
+	"github.com/Confialink/wallet-users/internal/services/auth"
+	"github.com/Confialink/wallet-users/internal/validators"
+	"github.com/Confialink/wallet-users/pkg/oauth"
+)
+
+// discoveryMaxAge tells clients how long the discovery document may be cached
+const discoveryMaxAge = "public, max-age=300"
+
+// OidcHandler serves OAuth 2.0 and OpenID Connect endpoints. Responses of protocol endpoints
+// are not wrapped into the common response since clients expect the format of RFC 6749.
+type OidcHandler struct {
+	oidc            *auth.Oidc
+	responseService responses.ResponseHandler
+	logger          log15.Logger
+}
+
+func NewOidcHandler(oidc *auth.Oidc, responseService responses.ResponseHandler, logger log15.Logger) *OidcHandler {
+	return &OidcHandler{oidc, responseService, logger.New("handler", "OidcHandler")}
+}
+
+// DiscoveryHandler publishes the OpenID Provider Metadata
+func (h *OidcHandler) DiscoveryHandler(ctx *gin.Context) {
+	ctx.Header("Cache-Control", discoveryMaxAge)
+	// Returns a "200 OK" response
+	ctx.JSON(http.StatusOK, h.oidc.Metadata())
+}
+
+// AuthorizeHandler validates the authorization request of a client and sends the user to the login page
+func (h *OidcHandler) AuthorizeHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "AuthorizeHandler")
+
+	req := &auth.AuthorizationRequest{}
+	if err := ctx.ShouldBindQuery(req); err != nil {
+		// Returns a "400 StatusBadRequest" response
+		ctx.JSON(http.StatusBadRequest, oauth.NewError(oauth.ErrorInvalidRequest, ""))
+		return
+	}
+
+	redirectable, e := h.oidc.ValidateAuthorizationRequest(req)
+	if e != nil && !redirectable {
+		// the client is unknown, so the user must not be redirected to the given uri
+		// Returns a "400 StatusBadRequest" response
+		ctx.JSON(http.StatusBadRequest, e)
+		return
+	}
+
+	var location string
+	var err error
+	if e != nil {
+		location, err = h.oidc.ErrorRedirect(req, e)
+	} else {
+		location, err = h.oidc.LoginRedirect(req)
+	}
+	if err != nil {
+		logger.Error("failed to build redirect url", "error", err)
+		ctx.JSON(http.StatusInternalServerError, oauth.NewError(oauth.ErrorServerError, ""))
+		return
+	}
+
+	// Returns a "302 StatusFound" response
+	ctx.Redirect(http.StatusFound, location)
+}
+
+// ConfirmAuthorizationHandler is called by the login page after the user has signed in,
+// it returns the redirect uri of the client with the authorization code
+func (h *OidcHandler) ConfirmAuthorizationHandler(ctx *gin.Context) {
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	req := &auth.AuthorizationRequest{}
+	if err := ctx.ShouldBindJSON(req); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		h.responseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	redirect, e := h.oidc.Authorize(user, ctx.GetString("AccessToken"), req)
+	if e != nil {
+		if e.Code == oauth.ErrorAccessDenied {
+			// Returns a "403 StatusForbidden" response
+			h.responseService.ErrorResponse(ctx, http.StatusForbidden, e.Error())
+			return
+		}
+		// Returns a "400 StatusBadRequest" response
+		h.responseService.ErrorResponse(ctx, http.StatusBadRequest, e.Error())
+		return
+	}
+
+	// Returns a "200 OK" response
+	h.responseService.OkResponse(ctx, gin.H{"redirectUri": redirect})
+}
+
+// TokenHandler exchanges an authorization code or a refresh token for tokens
+func (h *OidcHandler) TokenHandler(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Pragma", "no-cache")
+
+	req := &auth.TokenRequest{}
+	if err := ctx.ShouldBind(req); err != nil {
+		// Returns a "400 StatusBadRequest" response
+		ctx.JSON(http.StatusBadRequest, oauth.NewError(oauth.ErrorInvalidRequest, ""))
+		return
+	}
+
+	// client credentials of the basic scheme are form encoded
+	clientID, secret, basic := ctx.Request.BasicAuth()
+	if basic {
+		var err error
+		if req.ClientID, err = url.QueryUnescape(clientID); err == nil {
+			req.ClientSecret, err = url.QueryUnescape(secret)
+		}
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, oauth.NewError(oauth.ErrorInvalidRequest, "authorization header is invalid"))
+			return
+		}
+	}
+
+	res, e := h.oidc.Exchange(req, getTokenOptions(ctx).Device)
+	if e != nil {
+		switch e.Code {
+		case oauth.ErrorInvalidClient:
+			if basic {
+				ctx.Header("WWW-Authenticate", `Basic realm="oauth"`)
+			}
+			// Returns a "401 StatusUnauthorized" response
+			ctx.JSON(http.StatusUnauthorized, e)
+		case oauth.ErrorServerError:
+			// Returns a "500 StatusInternalServerError" response
+			ctx.JSON(http.StatusInternalServerError, e)
+		default:
+			// Returns a "400 StatusBadRequest" response
+			ctx.JSON(http.StatusBadRequest, e)
+		}
+		return
+	}
+
+	// Returns a "200 OK" response
+	ctx.JSON(http.StatusOK, res)
+}
+
+// UserInfoHandler returns claims of the user the bearer token was issued for
+func (h *OidcHandler) UserInfoHandler(ctx *gin.Context) {
+	accessToken, ok := authentication.ExtractToken(ctx)
+	if !ok {
+		ctx.Header("WWW-Authenticate", `Bearer realm="userinfo"`)
+		// Returns a "401 StatusUnauthorized" response
+		ctx.Status(http.StatusUnauthorized)
+		return
+	}
+
+	info, e := h.oidc.UserInfo(accessToken)
+	if e != nil {
+		ctx.Header("WWW-Authenticate", `Bearer realm="userinfo", error="`+e.Code+`"`)
+		// Returns a "401 StatusUnauthorized" response
+		ctx.JSON(http.StatusUnauthorized, e)
+		return
+	}
+
+	// Returns a "200 OK" response
+	ctx.JSON(http.StatusOK, info)
+}
+
+// ListClientsHandler returns registered clients
+func (h *OidcHandler) ListClientsHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "ListClientsHandler")
+
+	clients, err := h.oidc.Clients()
+	if err != nil {
+		logger.Error("failed to retrieve oauth clients", "error", err)
+		h.responseService.Error(ctx, responses.CannotRetrieveCollection, "Can't retrieve clients.")
+		return
+	}
+
+	// Returns a "200 OK" response
+	h.responseService.OkResponse(ctx, serializers.NewOAuthClients(clients))
+}
+
+// CreateClientHandler registers a client, the secret of a confidential client is returned only once
+func (h *OidcHandler) CreateClientHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "CreateClientHandler")
+
+	validator := validators.OAuthClientValidator{}
+	if err := validator.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		h.responseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	client, secret, err := h.oidc.CreateClient(clientParams(&validator), validator.Confidential)
+	if err != nil {
+		if h.clientError(ctx, err) {
+			return
+		}
+		logger.Error("failed to create oauth client", "error", err)
+		h.responseService.Error(ctx, responses.InternalError, "")
+		return
+	}
+
+	res := serializers.NewOAuthClient(client)
+	res.ClientSecret = secret
+
+	// Returns a "201 StatusCreated" response
+	h.responseService.SuccessResponse(ctx, http.StatusCreated, res)
+}
+
+// UpdateClientHandler changes the client, tokens are revoked if the client is deactivated
+func (h *OidcHandler) UpdateClientHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "UpdateClientHandler")
+
+	id, err := getUint64Param(ctx, "id")
+	if err != nil {
+		h.responseService.Error(ctx, responses.OAuthClientNotFound, err.Error())
+		return
+	}
+
+	validator := validators.OAuthClientValidator{}
+	if err := validator.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		h.responseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	client, err := h.oidc.UpdateClient(id, clientParams(&validator))
+	if err != nil {
+		if h.clientError(ctx, err) {
+			return
+		}
+		logger.Error("failed to update oauth client", "error", err, "id", id)
+		h.responseService.Error(ctx, responses.InternalError, "")
+		return
+	}
+
+	// Returns a "200 OK" response
+	h.responseService.OkResponse(ctx, serializers.NewOAuthClient(client))
+}
+
+// DeleteClientHandler removes the client and revokes its tokens
+func (h *OidcHandler) DeleteClientHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "DeleteClientHandler")
+
+	id, err := getUint64Param(ctx, "id")
+	if err != nil {
+		h.responseService.Error(ctx, responses.OAuthClientNotFound, err.Error())
+		return
+	}
+
+	if err := h.oidc.DeleteClient(id); err != nil {
+		if h.clientError(ctx, err) {
+			return
+		}
+		logger.Error("failed to delete oauth client", "error", err, "id", id)
+		h.responseService.Error(ctx, responses.InternalError, "")
+		return
+	}
+
+	// Returns a "204 StatusNoContent" response
+	ctx.Status(http.StatusNoContent)
+}
+
+// clientError responds with the known error of client management, it returns false for unexpected errors
+func (h *OidcHandler) clientError(ctx *gin.Context, err error) bool {
+	switch err {
+	case auth.ErrOAuthClientNotFound:
+		// Returns a "404 StatusNotFound" response
+		h.responseService.Error(ctx, responses.OAuthClientNotFound, "Client is not found.")
+	case auth.ErrOAuthInvalidRedirectURI, auth.ErrOAuthInvalidScope:
+		// Returns a "422 StatusUnprocessableEntity" response
+		h.responseService.Error(ctx, responses.UnprocessableEntity, err.Error())
+	default:
+		return false
+	}
+	return true
+}
+
+func clientParams(validator *validators.OAuthClientValidator) *auth.ClientParams {
+	isActive := true
+	if validator.IsActive != nil {
+		isActive = *validator.IsActive
+	}
+	return &auth.ClientParams{
+		Name:         validator.Name,
+		RedirectURIs: validator.RedirectURIs,
+		Scopes:       validator.Scopes,
+		IsActive:     isActive,
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"fmt"
+	"net/url"
 	"strings"
 
 	"github.com/Confialink/wallet-pkg-list_params"
@@ -93,3 +94,52 @@ func userFilter(inputValues []string, params *list_params.ListParams) (string, i
 	value := `%` + inputValues[0] + `%`
 	return "(users.email LIKE ?)", []string{value}
 }
+
+// loginHistoryDefaultSorting shows the newest sign ins first if the client does not ask for another order
+const loginHistoryDefaultSorting = "sort=-created_at"
+
+func (p *HandlerParams) loginHistory(query string) *list_params.ListParams {
+	params := list_params.NewListParamsFromQuery(loginHistoryQuery(query), models.LoginHistoryEntry{})
+	loginHistoryFilters(params)
+	loginHistorySortings(params)
+	return params
+}
+
+func (p *HandlerParams) loginHistoryCsv(query string) *list_params.ListParams {
+	params := p.loginHistory(query)
+	params.Pagination.PageSize = 0
+	return params
+}
+
+func loginHistoryQuery(query string) string {
+	values, _ := url.ParseQuery(query)
+	if values.Get("sort") != "" {
+		return query
+	}
+	if query == "" {
+		return loginHistoryDefaultSorting
+	}
+	return loginHistoryDefaultSorting + "&" + query
+}
+
+func loginHistoryFilters(params *list_params.ListParams) {
+	params.AllowFilters([]string{
+		"ip",
+		"status",
+		"date_from",
+		"date_to",
+	})
+	params.AddCustomFilter("date_from", list_params.DateFromFilter("created_at"))
+	params.AddCustomFilter("date_to", list_params.DateToFilter("created_at"))
+}
+
+func loginHistorySortings(params *list_params.ListParams) {
+	params.AllowSortings([]string{"created_at", "ip", "status"})
+}
+
+func (p *HandlerParams) erasureRequests(query string) *list_params.ListParams {
+	params := list_params.NewListParamsFromQuery(query, models.ErasureRequest{})
+	params.AllowFilters([]string{"uid", "status"})
+	params.AllowSortings([]string{"created_at", "erase_after"})
+	return params
+}
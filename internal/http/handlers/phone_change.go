@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Confialink/wallet-pkg-errors"
+	"github.com/gin-gonic/gin"
+	"github.com/inconshreveable/log15"
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	"github.com/Confialink/wallet-users/internal/services"
+	"github.com/Confialink/wallet-users/internal/services/auth"
+	"github.com/Confialink/wallet-users/internal/services/users"
+	"github.com/Confialink/wallet-users/internal/validators"
+)
+
+// PhoneChangeHandler changes phone numbers of users after the new number is verified by SMS
+type PhoneChangeHandler struct {
+	phoneChange     *users.PhoneChange
+	passwordService *services.Password
+	securityEvents  *auth.SecurityEvents
+	responseService responses.ResponseHandler
+	logger          log15.Logger
+}
+
+func NewPhoneChangeHandler(
+	phoneChange *users.PhoneChange,
+	passwordService *services.Password,
+	securityEvents *auth.SecurityEvents,
+	responseService responses.ResponseHandler,
+	logger log15.Logger,
+) *PhoneChangeHandler {
+	return &PhoneChangeHandler{
+		phoneChange,
+		passwordService,
+		securityEvents,
+		responseService,
+		logger.New("handler", "PhoneChangeHandler"),
+	}
+}
+
+// PendingHandler returns the change of a phone number of the current user which waits for confirmation
+func (h *PhoneChangeHandler) PendingHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "PendingHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	change, err := h.phoneChange.Pending(user)
+	if err == gorm.ErrRecordNotFound {
+		// Returns a "404 StatusNotFound" response
+		h.responseService.Error(ctx, responses.PhoneChangeNotFound, "There is no phone number change to confirm.")
+		return
+	}
+	if err != nil {
+		logger.Error("failed to find phone change", "error", err, "uid", user.UID)
+		h.responseService.Error(ctx, responses.CannotChangePhone, "Can't find phone number change.")
+		return
+	}
+
+	// Returns a "200 OK" response
+	h.responseService.OkResponse(ctx, change)
+}
+
+// RequestHandler sends a code by SMS to the new phone number of the current user and alerts the current number
+func (h *PhoneChangeHandler) RequestHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "RequestHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	form := &validators.RequestPhoneChangeValidator{}
+	if err := form.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		h.responseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	if err := h.passwordService.UserCheckPassword(form.Password, user.Password); err != nil {
+		h.responseService.Error(ctx, responses.CodeInvalidPassword, "Invalid password.")
+		return
+	}
+
+	change, err := h.phoneChange.Request(user, form.Field, form.PhoneNumber)
+	if err != nil {
+		logger.Error("failed to request phone change", "error", err, "uid", user.UID)
+		h.responseService.Error(ctx, responses.CannotChangePhone, "Can't change phone number.")
+		return
+	}
+
+	h.securityEvents.Record(user.UID, models.SecurityEventPhoneChangeRequested, getTokenOptions(ctx).Device, map[string]interface{}{
+		"field":          change.Field,
+		"oldPhoneNumber": change.OldPhoneNumber,
+		"newPhoneNumber": change.NewPhoneNumber,
+	})
+
+	// Returns a "200 OK" response
+	h.responseService.OkResponse(ctx, change)
+}
+
+// ConfirmHandler replaces the phone number of the current user by the pending one verified by the code
+func (h *PhoneChangeHandler) ConfirmHandler(ctx *gin.Context) {
+	logger := h.logger.New("action", "ConfirmHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	form := &validators.PhoneChangeCodeValidator{}
+	if err := form.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		h.responseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	if err := h.phoneChange.Confirm(user, form.Code); err != nil {
+		if typedErr, ok := err.(errors.TypedError); ok {
+			errors.AddErrors(ctx, typedErr)
+			return
+		}
+		logger.Error("failed to confirm phone change", "error", err, "uid", user.UID)
+		h.responseService.Error(ctx, responses.CannotChangePhone, "Can't change phone number.")
+		return
+	}
+
+	// Returns a "204 StatusNoContent" response
+	ctx.Status(http.StatusNoContent)
+}
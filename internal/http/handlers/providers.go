@@ -12,5 +12,13 @@ func Providers() []interface{} {
 		NewVerificationHandler,
 		NewStaffsService,
 		NewInvitesHandler,
+		NewJwksHandler,
+		NewOidcHandler,
+		NewLoginHistoryHandler,
+		NewEmailChangeHandler,
+		NewPhoneChangeHandler,
+		NewErasureHandler,
+		NewDataExportHandler,
+		NewConsentHandler,
 	}
 }
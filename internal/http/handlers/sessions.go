@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	"github.com/Confialink/wallet-users/internal/http/serializers"
+)
+
+// ListSessionsHandler returns active sessions of the current user
+func (srv *AuthService) ListSessionsHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "ListSessionsHandler")
+	currentUser := GetCurrentUser(ctx)
+
+	sessions, err := srv.tokenService.ListSessions(currentUser.UID)
+	if err != nil {
+		logger.Error("failed to retrieve sessions", "error", err)
+		// Returns a "400 StatusBadRequest" response
+		srv.ResponseService.Error(ctx, responses.CannotRetrieveCollection, "Can't retrieve list of sessions.")
+		return
+	}
+
+	var currentID uint64
+	if accessToken, ok := ctx.Get("AccessToken"); ok {
+		currentID, _ = srv.tokenService.SessionIDByAccessToken(accessToken.(string))
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.OkResponse(ctx, serializers.NewSessions(sessions, currentID))
+}
+
+// RevokeSessionHandler revokes a session of the current user
+func (srv *AuthService) RevokeSessionHandler(ctx *gin.Context) {
+	srv.revokeSession(ctx, GetCurrentUser(ctx).UID, "RevokeSessionHandler")
+}
+
+// UserSessionsHandler returns active sessions of the requested user
+func (srv *AuthService) UserSessionsHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "UserSessionsHandler")
+	user := GetRequestedUser(ctx)
+
+	sessions, err := srv.tokenService.ListSessions(user.UID)
+	if err != nil {
+		logger.Error("failed to retrieve sessions", "error", err, "uid", user.UID)
+		// Returns a "400 StatusBadRequest" response
+		srv.ResponseService.Error(ctx, responses.CannotRetrieveCollection, "Can't retrieve list of sessions.")
+		return
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.OkResponse(ctx, serializers.NewSessions(sessions, 0))
+}
+
+// RevokeUserSessionHandler revokes a session of the requested user
+func (srv *AuthService) RevokeUserSessionHandler(ctx *gin.Context) {
+	srv.revokeSession(ctx, GetRequestedUser(ctx).UID, "RevokeUserSessionHandler")
+}
+
+// RevokeUserSessionsHandler revokes all sessions of the requested user
+func (srv *AuthService) RevokeUserSessionsHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "RevokeUserSessionsHandler")
+	user := GetRequestedUser(ctx)
+
+	if err := srv.tokenService.RevokeUserTokens(user); err != nil {
+		logger.Error("failed to revoke user tokens", "error", err, "uid", user.UID)
+		// Returns a "500 StatusInternalServerError" response
+		srv.ResponseService.Error(ctx, responses.CannotRevokeSession, "Can't revoke sessions.")
+		return
+	}
+
+	// Returns a "204 StatusNoContent" response
+	ctx.Status(http.StatusNoContent)
+}
+
+func (srv *AuthService) revokeSession(ctx *gin.Context, uid string, action string) {
+	logger := srv.Logger.New("action", action)
+
+	id, err := getUint64Param(ctx, "id")
+	if err != nil {
+		srv.ResponseService.Error(ctx, responses.SessionNotFound, err.Error())
+		return
+	}
+
+	if err := srv.tokenService.RevokeSession(uid, id); err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			// Returns a "404 StatusNotFound" response
+			srv.ResponseService.Error(ctx, responses.SessionNotFound, "Session not found.")
+			return
+		}
+		logger.Error("failed to revoke session", "error", err, "uid", uid, "id", id)
+		// Returns a "500 StatusInternalServerError" response
+		srv.ResponseService.Error(ctx, responses.CannotRevokeSession, "Can't revoke session.")
+		return
+	}
+
+	// Returns a "204 StatusNoContent" response
+	ctx.Status(http.StatusNoContent)
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strings"
 
+	errors "github.com/Confialink/wallet-pkg-errors"
 	"github.com/gin-gonic/gin"
 	"github.com/inconshreveable/log15"
 
@@ -164,6 +165,11 @@ func (srv *StaffsService) CreateHandler(ctx *gin.Context) {
 	createdUser, err := srv.userCreator.Create(&validator.UserModel, true, false, tx)
 	if err != nil {
 		tx.Rollback()
+		if _, ok := err.(errors.TypedError); ok {
+			// Returns a "422 StatusUnprocessableEntity" response if the password violates the password policy
+			srv.ResponseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+			return
+		}
 		logger.Error("failed to create a user", "error", err)
 		srv.ResponseService.Error(ctx, responses.CanNotCreateUser, "Can't create a user")
 		return
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"time"
 
+	errors "github.com/Confialink/wallet-pkg-errors"
 	"github.com/Confialink/wallet-pkg-list_params"
 	"github.com/Confialink/wallet-pkg-utils/csv"
 	"github.com/gin-gonic/gin"
@@ -307,6 +308,11 @@ func (srv *UsersService) CreateHandler(ctx *gin.Context) {
 	// Create new user
 	createdUser, err := srv.userCreator.Create(&validator.UserModel, true, false, nil)
 	if err != nil {
+		if _, ok := err.(errors.TypedError); ok {
+			// Returns a "422 StatusUnprocessableEntity" response if the password violates the password policy
+			srv.ResponseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+			return
+		}
 		logger.Error("сan't create a user", "error", err)
 		// Returns a "500 StatusInternalServerError" response
 		srv.ResponseService.Error(ctx, responses.CanNotCreateUser, "Can't create a user")
@@ -375,6 +381,23 @@ func (srv *UsersService) UpdateHandler(ctx *gin.Context) {
 			return
 		}
 
+		if typedErr := ownerEmailChangeError(currentUser.UID, old, user); typedErr != nil {
+			errors.AddErrors(ctx, typedErr)
+			return
+		}
+
+		// users replace their phone numbers only by the phone change confirmed from the new number
+		if currentUser.UID == user.UID {
+			if user.PhoneNumber != old.PhoneNumber {
+				errors.AddErrors(ctx, phoneChangeRequired("phoneNumber"))
+				return
+			}
+			if smsPhoneNumberValue(user) != smsPhoneNumberValue(old) {
+				errors.AddErrors(ctx, phoneChangeRequired("smsPhoneNumber"))
+				return
+			}
+		}
+
 		tx := srv.Repository.GetUsersRepository().DB.Begin()
 		err = srv.userCreator.Update(user, tx)
 		if err != nil {
@@ -436,7 +459,7 @@ func (srv *UsersService) PatchHandler(ctx *gin.Context) {
 			return
 		}
 
-		_, err = repo.Update(user)
+		_, err = srv.userCreator.Patch(user)
 		if err != nil {
 			logger.Error("cannot update user", "err", err)
 			srv.ResponseService.Error(ctx, responses.CanNotUpdateUser, "Can't update a user")
@@ -477,6 +500,11 @@ func (srv *UsersService) ResetPasswordHandler(ctx *gin.Context) {
 
 		err := srv.userCreator.SetPassword(user, formResetPassword.NewPassword)
 		if err != nil {
+			if _, ok := err.(errors.TypedError); ok {
+				// Returns a "422 StatusUnprocessableEntity" response if the password violates the password policy
+				srv.ResponseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+				return
+			}
 			// Returns a "400 StatusBadRequest" response
 			srv.ResponseService.Error(ctx, responses.CanNotUpdateUser, "Can't update a user")
 			return
@@ -536,6 +564,33 @@ func (srv *UsersService) ExportHandler(ctx *gin.Context) {
 	ctx.Writer.Write(b.Bytes())
 }
 
+// PasswordSchemesReportHandler counts users by schemes their passwords are hashed by,
+// legacy hashes are upgraded to argon2id when their users sign in
+func (srv *UsersService) PasswordSchemesReportHandler(ctx *gin.Context) {
+	logger := srv.logger.New("action", "PasswordSchemesReportHandler")
+
+	counts, err := srv.Repository.GetUsersRepository().CountByPasswordScheme()
+	if err != nil {
+		logger.Error("failed to count users by password schemes", "error", err)
+		srv.ResponseService.Error(ctx, responses.InternalError, "")
+		return
+	}
+
+	report := struct {
+		Total   uint64                              `json:"total"`
+		Legacy  uint64                              `json:"legacy"`
+		Schemes []*repositories.PasswordSchemeCount `json:"schemes"`
+	}{Schemes: counts}
+	for _, count := range counts {
+		report.Total += count.Count
+		if count.Scheme != models.PasswordSchemeArgon2id {
+			report.Legacy += count.Count
+		}
+	}
+
+	srv.ResponseService.OkResponse(ctx, report)
+}
+
 // ImportHandler import users from csv
 func (srv *UsersService) ImportHandler(ctx *gin.Context) {
 	logger := srv.logger.New("action", "ImportHandler")
@@ -722,3 +777,32 @@ func (srv *UsersService) unblockUser(uid string) error {
 
 	return nil
 }
+
+// ownerEmailChangeError rejects a change of the email made by the user itself whether the email is confirmed or not,
+// users replace their email only by the email change confirmed from the new address.
+// Admins set emails directly, such an email is not confirmed.
+func ownerEmailChangeError(currentUID string, old, user *models.User) *errors.ValidationErrors {
+	if currentUID != user.UID || user.Email == old.Email {
+		return nil
+	}
+	return &errors.ValidationErrors{Errors: []errors.ValidationError{{
+		Title:  "Email must be changed by the email change confirmed by a code.",
+		Source: "email",
+		Code:   responses.EmailChangeRequired,
+	}}}
+}
+
+func smsPhoneNumberValue(user *models.User) string {
+	if user.SmsPhoneNumber == nil {
+		return ""
+	}
+	return *user.SmsPhoneNumber
+}
+
+func phoneChangeRequired(source string) *errors.ValidationErrors {
+	return &errors.ValidationErrors{Errors: []errors.ValidationError{{
+		Title:  "Phone number must be changed by the phone change confirmed by a code.",
+		Source: source,
+		Code:   responses.PhoneChangeRequired,
+	}}}
+}
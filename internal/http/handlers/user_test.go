@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/http/responses"
+)
+
+func TestOwnerEmailChangeError(t *testing.T) {
+	tests := []struct {
+		name       string
+		currentUID string
+		old        *models.User
+		user       *models.User
+		rejected   bool
+	}{
+		{
+			name:       "owner changes confirmed email",
+			currentUID: "uid",
+			old:        &models.User{UID: "uid", Email: "old@example.com", IsEmailConfirmed: true},
+			user:       &models.User{UID: "uid", Email: "new@example.com", IsEmailConfirmed: true},
+			rejected:   true,
+		},
+		{
+			name:       "owner changes not confirmed email",
+			currentUID: "uid",
+			old:        &models.User{UID: "uid", Email: "old@example.com"},
+			user:       &models.User{UID: "uid", Email: "new@example.com"},
+			rejected:   true,
+		},
+		{
+			name:       "owner keeps email",
+			currentUID: "uid",
+			old:        &models.User{UID: "uid", Email: "old@example.com"},
+			user:       &models.User{UID: "uid", Email: "old@example.com"},
+		},
+		{
+			name:       "admin changes confirmed email",
+			currentUID: "admin-uid",
+			old:        &models.User{UID: "uid", Email: "old@example.com", IsEmailConfirmed: true},
+			user:       &models.User{UID: "uid", Email: "new@example.com", IsEmailConfirmed: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ownerEmailChangeError(tt.currentUID, tt.old, tt.user)
+			if !tt.rejected {
+				assert.Nil(t, err)
+				return
+			}
+			if assert.NotNil(t, err) {
+				assert.Equal(t, responses.EmailChangeRequired, err.Errors[0].Code)
+				assert.Equal(t, "email", err.Errors[0].Source)
+			}
+		})
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/Confialink/wallet-users/internal/db/models"
 	"github.com/Confialink/wallet-users/internal/db/repositories"
 	"github.com/Confialink/wallet-users/internal/http/responses"
+	"github.com/Confialink/wallet-users/internal/services/events"
 	verificationStates "github.com/Confialink/wallet-users/internal/services/verification_states"
 )
 
@@ -21,6 +22,7 @@ type VerificationHandler struct {
 	responseService responses.ResponseHandler
 	creator         *verification.Creator
 	validator       *verification.Validator
+	outbox          *events.Outbox
 	logger          log15.Logger
 }
 
@@ -29,6 +31,7 @@ func NewVerificationHandler(
 	responseService responses.ResponseHandler,
 	creator *verification.Creator,
 	validator *verification.Validator,
+	outbox *events.Outbox,
 	logger log15.Logger,
 ) *VerificationHandler {
 	return &VerificationHandler{
@@ -36,6 +39,7 @@ func NewVerificationHandler(
 		responseService,
 		creator,
 		validator,
+		outbox,
 		logger,
 	}
 }
@@ -92,12 +96,13 @@ func (h *VerificationHandler) RequestHandler(ctx *gin.Context) {
 		return
 	}
 
+	previousStatus := verification.Status
 	if err := verificationStates.NewVerificationState(verification).HandleVerificationRequest(); err != nil {
 		h.responseService.Error(ctx, responses.CanNotCreateVerificationRequest, err.Error())
 		return
 	}
 
-	err = h.repository.GetVerificationRepository().Save(verification)
+	err = h.save(verification, previousStatus)
 	if err != nil {
 		h.logger.Error("failed to update verification", "error", err)
 		h.responseService.Error(ctx, responses.CanNotUpdateVerification, "Can't update a verification")
@@ -115,12 +120,13 @@ func (h *VerificationHandler) ApproveHandler(ctx *gin.Context) {
 		return
 	}
 
+	previousStatus := verification.Status
 	if err := verificationStates.NewVerificationState(verification).HandleAdminApprove(); err != nil {
 		h.responseService.Error(ctx, responses.CanNotApproveVerificationRequest, err.Error())
 		return
 	}
 
-	err = h.repository.GetVerificationRepository().Save(verification)
+	err = h.save(verification, previousStatus)
 	if err != nil {
 		h.logger.Error("failed to update verification", "error", err)
 		h.responseService.Error(ctx, responses.CanNotUpdateVerification, "Can't update a verification")
@@ -138,12 +144,13 @@ func (h *VerificationHandler) CancelHandler(ctx *gin.Context) {
 		return
 	}
 
+	previousStatus := verification.Status
 	if err := verificationStates.NewVerificationState(verification).HandleAdminCancellation(); err != nil {
 		h.responseService.Error(ctx, responses.CanNotCancelVerificationRequest, err.Error())
 		return
 	}
 
-	err = h.repository.GetVerificationRepository().Save(verification)
+	err = h.save(verification, previousStatus)
 	if err != nil {
 		h.logger.Error("failed to update verification", "error", err)
 		h.responseService.Error(ctx, responses.CanNotUpdateVerification, "Can't update a verification")
@@ -190,6 +197,30 @@ func (h *VerificationHandler) CreateHandler(ctx *gin.Context) {
 	return
 }
 
+// save stores the verification and records the status change in the same transaction
+func (h *VerificationHandler) save(verification *models.Verification, previousStatus string) error {
+	tx := h.repository.GetUsersRepository().DB.Begin()
+	if err := h.repository.GetVerificationRepository().WrapContext(tx).Save(verification); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if verification.Status != previousStatus {
+		if err := h.outbox.Record(tx, events.VerificationStatusChanged, &events.VerificationStatusChangedData{
+			ID:             verification.ID,
+			UserUID:        verification.UserUID,
+			Type:           verification.Type,
+			PreviousStatus: previousStatus,
+			Status:         verification.Status,
+		}); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
 func (h *VerificationHandler) getVerification(ctx *gin.Context) (*models.Verification, error) {
 	id, err := getUint64Param(ctx, "id")
 	if err != nil {
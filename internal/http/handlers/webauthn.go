@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	"github.com/Confialink/wallet-users/internal/services/auth"
+	"github.com/Confialink/wallet-users/internal/validators"
+)
+
+// WebauthnCredentialsHandler returns security keys and passkeys registered by the current user
+func (srv *AuthService) WebauthnCredentialsHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "WebauthnCredentialsHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	credentials, err := srv.webauthn.Credentials(user)
+	if err != nil {
+		logger.Error("failed to retrieve webauthn credentials", "error", err)
+		srv.ResponseService.Error(ctx, responses.InternalError, "")
+		return
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.OkResponse(ctx, credentials)
+}
+
+// WebauthnRegisterBeginHandler returns options for navigator.credentials.create()
+func (srv *AuthService) WebauthnRegisterBeginHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "WebauthnRegisterBeginHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	options, err := srv.webauthn.BeginRegistration(user)
+	if err != nil {
+		logger.Error("failed to begin webauthn registration", "error", err)
+		// Returns a "500 StatusInternalServerError" response
+		srv.ResponseService.Error(ctx, responses.CannotEnrollMfa, "Can't register security key.")
+		return
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.OkResponse(ctx, gin.H{"publicKey": options})
+}
+
+// WebauthnRegisterFinishHandler verifies and stores the credential created by the authenticator
+func (srv *AuthService) WebauthnRegisterFinishHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "WebauthnRegisterFinishHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	validator := validators.WebauthnRegistrationValidator{}
+	if err := validator.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		srv.ResponseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	credential, err := srv.webauthn.FinishRegistration(user, validator.Name, &validator.Credential)
+	if err != nil {
+		if errResp := auth.WebauthnErrorToResponse(err); errResp != nil {
+			srv.ResponseService.SetError(ctx, errResp)
+			return
+		}
+		logger.Error("failed to finish webauthn registration", "error", err)
+		srv.ResponseService.Error(ctx, responses.CannotEnrollMfa, "Can't register security key.")
+		return
+	}
+
+	// Returns a "201 StatusCreated" response
+	srv.ResponseService.SuccessResponse(ctx, http.StatusCreated, credential)
+}
+
+// WebauthnCredentialDeleteHandler removes a credential of the current user
+func (srv *AuthService) WebauthnCredentialDeleteHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "WebauthnCredentialDeleteHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	id, err := getUint64Param(ctx, "id")
+	if err != nil {
+		srv.ResponseService.Error(ctx, responses.WebauthnCredentialNotFound, err.Error())
+		return
+	}
+
+	if err := srv.webauthn.RemoveCredential(user, id); err != nil {
+		if errResp := auth.WebauthnErrorToResponse(err); errResp != nil {
+			srv.ResponseService.SetError(ctx, errResp)
+			return
+		}
+		logger.Error("failed to remove webauthn credential", "error", err, "id", id)
+		srv.ResponseService.Error(ctx, responses.InternalError, "")
+		return
+	}
+
+	// Returns a "204 StatusNoContent" response
+	ctx.Status(http.StatusNoContent)
+}
+
+// WebauthnMfaBeginHandler returns options for navigator.credentials.get() to pass the second factor on sign in
+func (srv *AuthService) WebauthnMfaBeginHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "WebauthnMfaBeginHandler")
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	options, err := srv.webauthn.BeginMfa(user)
+	if err != nil {
+		if errResp := auth.WebauthnErrorToResponse(err); errResp != nil {
+			srv.ResponseService.SetError(ctx, errResp)
+			return
+		}
+		logger.Error("failed to begin webauthn authentication", "error", err)
+		srv.ResponseService.Error(ctx, responses.InternalError, "")
+		return
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.OkResponse(ctx, gin.H{"publicKey": options})
+}
+
+// WebauthnMfaVerifyHandler completes sign in using the mfa token and an assertion of a security key
+func (srv *AuthService) WebauthnMfaVerifyHandler(ctx *gin.Context) {
+	var ip = ctx.ClientIP()
+	user := ctx.MustGet("_current_user").(*models.User)
+
+	validator := validators.WebauthnAssertionValidator{}
+	if err := validator.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		srv.ResponseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	if e := srv.BeforeSignIn(ctx, user); e != nil {
+		r := responses.NewResponse().SetStatus(http.StatusForbidden).AddError(e)
+		ctx.AbortWithStatusJSON(r.Status, r)
+		return
+	}
+
+	res, errResp := srv.authService.VerifyWebauthnMfa(user, &validator.Credential, getTokenOptions(ctx), ip)
+	if errResp != nil {
+		srv.ResponseService.SetError(ctx, errResp)
+		return
+	}
+
+	if e := srv.AfterSignIn(ctx, user); e != nil {
+		r := responses.NewResponse().SetStatus(http.StatusUnauthorized).AddError(e)
+		ctx.AbortWithStatusJSON(r.Status, r)
+		return
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.SuccessResponse(ctx, http.StatusOK, res)
+}
+
+// WebauthnSignInBeginHandler returns options for passwordless sign in with a passkey
+func (srv *AuthService) WebauthnSignInBeginHandler(ctx *gin.Context) {
+	logger := srv.Logger.New("action", "WebauthnSignInBeginHandler")
+
+	options, err := srv.webauthn.BeginLogin()
+	if err != nil {
+		logger.Error("failed to begin webauthn sign in", "error", err)
+		srv.ResponseService.Error(ctx, responses.InternalError, "")
+		return
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.OkResponse(ctx, gin.H{"publicKey": options})
+}
+
+// WebauthnSignInHandler signs in a user by a passkey without the password
+func (srv *AuthService) WebauthnSignInHandler(ctx *gin.Context) {
+	var ip = ctx.ClientIP()
+
+	validator := validators.WebauthnAssertionValidator{}
+	if err := validator.BindJSON(ctx); err != nil {
+		// Returns a "422 StatusUnprocessableEntity" response
+		srv.ResponseService.ValidatorErrorResponse(ctx, responses.UnprocessableEntity, err)
+		return
+	}
+
+	// the user is not known until the assertion is verified, so only IP is checked beforehand
+	srv.AuthBlocker.LoadSettings()
+	if err := srv.AuthBlocker.CheckIP(ip); err != nil {
+		r := responses.NewResponse().SetStatus(http.StatusForbidden).AddError(responses.NewCommonError().ApplyCode(responses.CodeIpIsBlocked))
+		ctx.AbortWithStatusJSON(r.Status, r)
+		return
+	}
+
+	user, errResp := srv.authService.FindWebauthnUser(&validator.Credential, ip)
+	if errResp != nil {
+		srv.ResponseService.SetError(ctx, errResp)
+		return
+	}
+
+	if e := srv.BeforeSignIn(ctx, user); e != nil {
+		r := responses.NewResponse().SetStatus(http.StatusForbidden).AddError(e)
+		ctx.AbortWithStatusJSON(r.Status, r)
+		return
+	}
+
+	res, errResp := srv.authService.LoginWithWebauthn(user, getTokenOptions(ctx))
+	if errResp != nil {
+		srv.ResponseService.SetError(ctx, errResp)
+		return
+	}
+
+	if e := srv.AfterSignIn(ctx, user); e != nil {
+		r := responses.NewResponse().SetStatus(http.StatusUnauthorized).AddError(e)
+		ctx.AbortWithStatusJSON(r.Status, r)
+		return
+	}
+
+	// Returns a "200 OK" response
+	srv.ResponseService.SuccessResponse(ctx, http.StatusOK, res)
+}
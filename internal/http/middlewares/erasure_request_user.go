@@ -0,0 +1,42 @@
+package middlewares
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/internal/http/responses"
+)
+
+// ErasureRequestUser sets the user of the erasure request given by the id param as the requested user,
+// so permissions to the profile of the user can be checked
+func ErasureRequestUser(
+	erasureRepo *repositories.ErasureRequestRepository,
+	usersRepo *repositories.UsersRepository,
+	responseService responses.ResponseHandler,
+) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.ParseUint(ctx.Params.ByName("id"), 10, 64)
+		if err != nil {
+			responseService.NotFound(ctx)
+			ctx.Abort()
+			return
+		}
+
+		request, err := erasureRepo.FindByID(id)
+		if err != nil {
+			responseService.NotFound(ctx)
+			ctx.Abort()
+			return
+		}
+
+		foundUser, err := usersRepo.FindByUID(request.UserUID)
+		if err != nil {
+			responseService.NotFound(ctx)
+			ctx.Abort()
+			return
+		}
+		ctx.Set("_requested_user", foundUser)
+	}
+}
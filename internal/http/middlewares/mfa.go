@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/inconshreveable/log15"
+
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	httpAuth "github.com/Confialink/wallet-users/internal/http/services/auth"
+	"github.com/Confialink/wallet-users/internal/services/auth"
+)
+
+// UserFromMfaToken retrieves user from the mfa token issued on sign in and set it to context,
+// the token must be issued for the given challenge
+func UserFromMfaToken(
+	responseService responses.ResponseHandler,
+	mfaTokens *auth.MfaTokens,
+	usersRepository *repositories.UsersRepository,
+	challengeName string,
+	logger log15.Logger,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		signedToken := c.Request.Header.Get(httpAuth.MfaTokenHeader)
+		if signedToken == "" {
+			// Returns a "401 StatusUnauthorized" response
+			responseService.Error(c, responses.CodeInvalidMfaToken, "Mfa token not found.")
+			return
+		}
+
+		uid, tokenChallengeName, err := mfaTokens.Verify(signedToken)
+		if err != nil || tokenChallengeName != challengeName {
+			logger.Error("mfa token verification failed", "error", err, "challenge", tokenChallengeName)
+			// Returns a "401 StatusUnauthorized" response
+			responseService.Error(c, responses.CodeInvalidMfaToken, "Mfa token is invalid or expired.")
+			return
+		}
+
+		user, err := usersRepository.FindByUID(uid)
+		if err != nil {
+			logger.Error("failed to retrieve user", "error", err)
+			// Returns a "401 StatusUnauthorized" response
+			responseService.Error(c, responses.CodeInvalidMfaToken, "Mfa token is invalid or expired.")
+			return
+		}
+
+		c.Set("_current_user", user)
+	}
+}
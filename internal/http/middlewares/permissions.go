@@ -97,6 +97,23 @@ func (m *PermissionsMiddleware) CanUpdateProfile() gin.HandlerFunc {
 	}
 }
 
+// CanUpdateClientProfile checks the permission to update profiles of users when there is no requested user,
+// e.g. for lists of all users
+func (m *PermissionsMiddleware) CanUpdateClientProfile() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		currentUser := handlers.GetCurrentUser(ctx)
+		if currentUser == nil {
+			m.responseService.Forbidden(ctx)
+			return
+		}
+
+		if hasPerm := m.permissionsService.CanUpdateUserProfile(currentUser.UID); !hasPerm {
+			m.responseService.Forbidden(ctx)
+			return
+		}
+	}
+}
+
 func (m *PermissionsMiddleware) CanViewSettings() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		currentUser := handlers.GetCurrentUser(ctx)
@@ -0,0 +1,149 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/inconshreveable/log15"
+
+	"github.com/Confialink/wallet-users/internal/config"
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	"github.com/Confialink/wallet-users/pkg/ratelimit"
+)
+
+// maxIdentifierBodySize limits the body read to find the identifier of a request
+const maxIdentifierBodySize = 1 << 16
+
+// errBodyTooLarge is returned by identifiers if the body is over maxIdentifierBodySize,
+// such requests are rejected, so the identifier bucket can not be skipped by padding the body
+var errBodyTooLarge = errors.New("request body is too large")
+
+// rateLimitBucket is a key of a bucket and the limit of the bucket
+type rateLimitBucket struct {
+	key   string
+	limit ratelimit.Limit
+}
+
+// Identifier returns what the request is made for (an email, a phone number, a confirmation code, a user),
+// an empty string means the request is throttled by the client IP only
+type Identifier func(ctx *gin.Context) (string, error)
+
+// IdentifierFromJSON returns the first not empty string found in the JSON body by the given paths,
+// nested fields are separated by dots, e.g. "data.email". The body is kept for handlers.
+func IdentifierFromJSON(paths ...string) Identifier {
+	return func(ctx *gin.Context) (string, error) {
+		if ctx.Request.Body == nil {
+			return "", nil
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(ctx.Request.Body, maxIdentifierBodySize+1))
+		ctx.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return "", nil
+		}
+		if len(body) > maxIdentifierBodySize {
+			return "", errBodyTooLarge
+		}
+
+		var data interface{}
+		if err := json.Unmarshal(body, &data); err != nil {
+			return "", nil
+		}
+		for _, path := range paths {
+			value := data
+			for _, field := range strings.Split(path, ".") {
+				object, ok := value.(map[string]interface{})
+				if !ok {
+					value = nil
+					break
+				}
+				value = object[field]
+			}
+			if str, ok := value.(string); ok && strings.TrimSpace(str) != "" {
+				return str, nil
+			}
+		}
+		return "", nil
+	}
+}
+
+// IdentifierFromParam returns the value of the route parameter
+func IdentifierFromParam(name string) Identifier {
+	return func(ctx *gin.Context) (string, error) {
+		return ctx.Param(name), nil
+	}
+}
+
+// IdentifierFromCurrentUser returns the uid of the current user, e.g. the user of the mfa token,
+// so second factor codes of the user can not be guessed from many addresses
+func IdentifierFromCurrentUser() Identifier {
+	return func(ctx *gin.Context) (string, error) {
+		if user, ok := ctx.Get("_current_user"); ok {
+			if currentUser, ok := user.(*models.User); ok {
+				return currentUser.UID, nil
+			}
+		}
+		return "", nil
+	}
+}
+
+// RateLimit throttles requests to the route by token buckets of the client IP and of the request identifier.
+// Requests over the limit get 429 with the Retry-After header. Requests are let through if the store fails,
+// failed sign in attempts are still counted by auth.Blocker.
+func RateLimit(
+	store ratelimit.Store,
+	route string,
+	rule config.RateLimitRule,
+	identify Identifier,
+	responseService responses.ResponseHandler,
+	logger log15.Logger,
+) gin.HandlerFunc {
+	logger = logger.New("middleware", "RateLimit", "route", route)
+
+	return func(ctx *gin.Context) {
+		buckets := []rateLimitBucket{{route + ":ip:" + ctx.ClientIP(), rule.IP}}
+
+		if rule.Identifier.Enabled() && identify != nil {
+			identifier, err := identify(ctx)
+			if err == errBodyTooLarge {
+				responseService.Error(ctx, responses.RequestEntityTooLarge, "Request body is too large.")
+				return
+			}
+			if identifier != "" {
+				// identifiers are hashed, so emails and codes are not kept by the store as they are
+				sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(identifier))))
+				buckets = append(buckets, rateLimitBucket{route + ":id:" + hex.EncodeToString(sum[:]), rule.Identifier})
+			}
+		}
+
+		for _, bucket := range buckets {
+			if !bucket.limit.Enabled() {
+				continue
+			}
+
+			allowed, retryAfter, err := store.Take(bucket.key, bucket.limit)
+			if err != nil {
+				logger.Error("failed to take a token, the request is let through", "error", err)
+				return
+			}
+			if !allowed {
+				seconds := int(math.Ceil(retryAfter.Seconds()))
+				if seconds < 1 {
+					seconds = 1
+				}
+				ctx.Header("Retry-After", strconv.Itoa(seconds))
+				responseService.Error(ctx, responses.TooManyRequests, "Too many requests, try again later.")
+				return
+			}
+		}
+	}
+}
@@ -19,6 +19,9 @@ const (
 	CodeResetPasswordIsNotAllowed           = "RESET_PASSWORD_IS_NOT_ALLOWED"
 	CodeConfirmRegistrationLink             = "USERS_CONFIRM_REGISTRATION_LINK"
 	CodeInvalidUsernamePassword             = "USERS_INVALID_USERNAME_OR_PASSWORD"
+	CodeCaptchaRequired                     = "USERS_CAPTCHA_REQUIRED"
+	CodeInvalidCaptcha                      = "USERS_INVALID_CAPTCHA"
+	CodeInvalidLoginVerificationCode        = "USERS_INVALID_LOGIN_VERIFICATION_CODE"
 	CodeInvalidPin                          = "INVALID_PIN"
 	CodeInvalidPassword                     = "USERS_INVALID_PASSWORD"
 	BadCollectionParams                     = "BAD_COLLECTION_PARAMS"
@@ -66,6 +69,43 @@ const (
 	CanNotGeneratePhoneVerificationCode     = "CANNOT_GENERATE_PHONE_VERIFICATION_CODE"
 	CanNotGenerateEmailVerificationCode     = "CANNOT_GENERATE_EMAIL_VERIFICATION_CODE"
 	PhoneNumberIsNotConfirmed               = "PHONE_NUMBER_IS_NOT_CONFIRMED"
+	CodeInvalidMfaCode                      = "USERS_INVALID_MFA_CODE"
+	CodeInvalidMfaToken                     = "USERS_INVALID_MFA_TOKEN"
+	CodeMfaIsNotEnrolled                    = "USERS_MFA_IS_NOT_ENROLLED"
+	CodeMfaIsAlreadyEnabled                 = "USERS_MFA_IS_ALREADY_ENABLED"
+	CodeMfaIsMandatory                      = "USERS_MFA_IS_MANDATORY"
+	CannotEnrollMfa                         = "CANNOT_ENROLL_MFA"
+	CodeInvalidWebauthnResponse             = "USERS_INVALID_WEBAUTHN_RESPONSE"
+	CodeWebauthnCredentialExists            = "USERS_WEBAUTHN_CREDENTIAL_EXISTS"
+	WebauthnCredentialNotFound              = "WEBAUTHN_CREDENTIAL_NOT_FOUND"
+	SessionNotFound                         = "SESSION_NOT_FOUND"
+	CannotRevokeSession                     = "CANNOT_REVOKE_SESSION"
+	OAuthClientNotFound                     = "OAUTH_CLIENT_NOT_FOUND"
+	CodeFederatedLoginRequired              = "USERS_FEDERATED_LOGIN_REQUIRED"
+	CodeFederatedLoginFailed                = "USERS_FEDERATED_LOGIN_FAILED"
+	CodeFederatedAccountNotLinked           = "USERS_FEDERATED_ACCOUNT_NOT_LINKED"
+	CodeFederatedEmailExists                = "USERS_FEDERATED_EMAIL_EXISTS"
+	CodeUserIdentityExists                  = "USERS_IDENTITY_EXISTS"
+	UserIdentityNotFound                    = "USER_IDENTITY_NOT_FOUND"
+	IdentityProviderNotFound                = "IDENTITY_PROVIDER_NOT_FOUND"
+	CannotGetLoginHistory                   = "CANNOT_GET_LOGIN_HISTORY"
+	CannotGetLoginHistoryAsCsv              = "CANNOT_GET_LOGIN_HISTORY_AS_CSV"
+	EmailChangeNotFound                     = "EMAIL_CHANGE_NOT_FOUND"
+	InvalidEmailChangeCode                  = "INVALID_EMAIL_CHANGE_CODE"
+	CannotChangeEmail                       = "CANNOT_CHANGE_EMAIL"
+	PhoneChangeNotFound                     = "PHONE_CHANGE_NOT_FOUND"
+	CannotChangePhone                       = "CANNOT_CHANGE_PHONE"
+	ErasureRequestNotFound                  = "ERASURE_REQUEST_NOT_FOUND"
+	ErasureRequestAlreadyExists             = "ERASURE_REQUEST_ALREADY_EXISTS"
+	ErasureRequestIsReviewed                = "ERASURE_REQUEST_IS_REVIEWED"
+	UserHasCardsOrAccounts                  = "USER_HAS_CARDS_OR_ACCOUNTS"
+	CannotProcessErasureRequest             = "CANNOT_PROCESS_ERASURE_REQUEST"
+	CannotGetErasureRequests                = "CANNOT_GET_ERASURE_REQUESTS"
+	DataExportNotFound                      = "DATA_EXPORT_NOT_FOUND"
+	DataExportAlreadyRequested              = "DATA_EXPORT_ALREADY_REQUESTED"
+	CannotExportPersonalData                = "CANNOT_EXPORT_PERSONAL_DATA"
+	ConsentNotFound                         = "CONSENT_NOT_FOUND"
+	CannotProcessConsent                    = "CANNOT_PROCESS_CONSENT"
 
 	UnprocessableEntity       = "UNPROCESSABLE_ENTITY"
 	DocumentTypeOneOf         = "DOCUMENT_TYPE_ONE_OF"
@@ -81,9 +121,18 @@ const (
 	NumberRequired            = "NUMBER_REQUIRED"
 	UppercaseLetterRequired   = "UPPERCASE_LETTER_REQUIRED"
 	LowercaseLetterRequired   = "LOWERCASE_LETTER_REQUIRED"
+	PasswordTooShort          = "PASSWORD_TOO_SHORT"
+	PasswordContainsUserInfo  = "PASSWORD_CONTAINS_USER_INFO"
+	PasswordRecentlyUsed      = "PASSWORD_RECENTLY_USED"
+	PasswordCompromised       = "PASSWORD_COMPROMISED"
 	UnknownEmailOrPhoneNumber = "UNKNOWN_EMAIL_OR_PHONE_NUMBER"
+	EmailChangeRequired       = "EMAIL_CHANGE_REQUIRED"
+	EmailNotChanged           = "EMAIL_NOT_CHANGED"
+	PhoneChangeRequired       = "PHONE_CHANGE_REQUIRED"
 
-	MaintenanceMode = "MAINTENANCE_MODE"
+	MaintenanceMode       = "MAINTENANCE_MODE"
+	TooManyRequests       = "TOO_MANY_REQUESTS"
+	RequestEntityTooLarge = "REQUEST_ENTITY_TOO_LARGE"
 )
 
 var statusCodes = map[string]int{
@@ -103,6 +152,9 @@ var statusCodes = map[string]int{
 	CodeUserIsDormant:                       http.StatusUnauthorized,
 	CodeConfirmRegistrationLink:             http.StatusUnauthorized,
 	CodeInvalidUsernamePassword:             http.StatusUnauthorized,
+	CodeCaptchaRequired:                     http.StatusUnauthorized,
+	CodeInvalidCaptcha:                      http.StatusUnauthorized,
+	CodeInvalidLoginVerificationCode:        http.StatusUnauthorized,
 	CodeInvalidPin:                          http.StatusUnauthorized,
 	CodeInvalidPassword:                     http.StatusUnprocessableEntity,
 	BadCollectionParams:                     http.StatusBadRequest,
@@ -146,6 +198,43 @@ var statusCodes = map[string]int{
 	VerificationNotFound:                    http.StatusNotFound,
 	MaxVerificationFiles:                    http.StatusBadRequest,
 	PhoneNumberIsNotConfirmed:               http.StatusForbidden,
+	CodeInvalidMfaCode:                      http.StatusUnauthorized,
+	CodeInvalidMfaToken:                     http.StatusUnauthorized,
+	CodeMfaIsNotEnrolled:                    http.StatusBadRequest,
+	CodeMfaIsAlreadyEnabled:                 http.StatusConflict,
+	CodeMfaIsMandatory:                      http.StatusForbidden,
+	CannotEnrollMfa:                         http.StatusInternalServerError,
+	CodeInvalidWebauthnResponse:             http.StatusUnauthorized,
+	CodeWebauthnCredentialExists:            http.StatusConflict,
+	WebauthnCredentialNotFound:              http.StatusNotFound,
+	SessionNotFound:                         http.StatusNotFound,
+	CannotRevokeSession:                     http.StatusInternalServerError,
+	OAuthClientNotFound:                     http.StatusNotFound,
+	CodeFederatedLoginRequired:              http.StatusForbidden,
+	CodeFederatedLoginFailed:                http.StatusUnauthorized,
+	CodeFederatedAccountNotLinked:           http.StatusForbidden,
+	CodeFederatedEmailExists:                http.StatusConflict,
+	CodeUserIdentityExists:                  http.StatusConflict,
+	UserIdentityNotFound:                    http.StatusNotFound,
+	IdentityProviderNotFound:                http.StatusNotFound,
+	CannotGetLoginHistory:                   http.StatusBadRequest,
+	CannotGetLoginHistoryAsCsv:              http.StatusBadRequest,
+	EmailChangeNotFound:                     http.StatusNotFound,
+	InvalidEmailChangeCode:                  http.StatusBadRequest,
+	CannotChangeEmail:                       http.StatusInternalServerError,
+	PhoneChangeNotFound:                     http.StatusNotFound,
+	CannotChangePhone:                       http.StatusInternalServerError,
+	ErasureRequestNotFound:                  http.StatusNotFound,
+	ErasureRequestAlreadyExists:             http.StatusConflict,
+	ErasureRequestIsReviewed:                http.StatusConflict,
+	UserHasCardsOrAccounts:                  http.StatusConflict,
+	CannotProcessErasureRequest:             http.StatusInternalServerError,
+	CannotGetErasureRequests:                http.StatusBadRequest,
+	DataExportNotFound:                      http.StatusNotFound,
+	DataExportAlreadyRequested:              http.StatusConflict,
+	CannotExportPersonalData:                http.StatusInternalServerError,
+	ConsentNotFound:                         http.StatusNotFound,
+	CannotProcessConsent:                    http.StatusInternalServerError,
 
 	UnprocessableEntity:      http.StatusUnprocessableEntity,
 	DocumentTypeOneOf:        http.StatusUnprocessableEntity,
@@ -158,6 +247,15 @@ var statusCodes = map[string]int{
 	NumberRequired:           http.StatusUnprocessableEntity,
 	UppercaseLetterRequired:  http.StatusUnprocessableEntity,
 	LowercaseLetterRequired:  http.StatusUnprocessableEntity,
+	PasswordTooShort:         http.StatusUnprocessableEntity,
+	PasswordContainsUserInfo: http.StatusUnprocessableEntity,
+	PasswordRecentlyUsed:     http.StatusUnprocessableEntity,
+	PasswordCompromised:      http.StatusUnprocessableEntity,
+	EmailChangeRequired:      http.StatusUnprocessableEntity,
+	EmailNotChanged:          http.StatusUnprocessableEntity,
+	PhoneChangeRequired:      http.StatusUnprocessableEntity,
 
-	MaintenanceMode: http.StatusForbidden,
+	MaintenanceMode:       http.StatusForbidden,
+	TooManyRequests:       http.StatusTooManyRequests,
+	RequestEntityTooLarge: http.StatusRequestEntityTooLarge,
 }
@@ -1,6 +1,9 @@
 package routes
 
 import (
+	"expvar"
+	"net/http"
+
 	"github.com/Confialink/wallet-users/internal/authentication"
 	"github.com/Confialink/wallet-users/internal/config"
 	"github.com/Confialink/wallet-users/internal/db/models"
@@ -13,12 +16,12 @@ import (
 	"github.com/Confialink/wallet-users/internal/services/syssettings"
 	"github.com/Confialink/wallet-users/internal/services/users"
 	"github.com/Confialink/wallet-users/internal/version"
+	"github.com/Confialink/wallet-users/pkg/ratelimit"
 
 	"github.com/Confialink/wallet-pkg-env_mods"
 	errorsPkg "github.com/Confialink/wallet-pkg-errors"
 	"github.com/gin-gonic/gin"
 	"github.com/inconshreveable/log15"
-	"net/http"
 )
 
 func Api(
@@ -34,13 +37,24 @@ func Api(
 	blockedIpsHandler *handlers.BlockedIpsService,
 	verificationsHandler *handlers.VerificationHandler,
 	invitesHandler *handlers.InvitesHandler,
+	jwksHandler *handlers.JwksHandler,
+	oidcHandler *handlers.OidcHandler,
+	loginHistoryHandler *handlers.LoginHistoryHandler,
+	emailChangeHandler *handlers.EmailChangeHandler,
+	phoneChangeHandler *handlers.PhoneChangeHandler,
+	erasureHandler *handlers.ErasureHandler,
+	dataExportHandler *handlers.DataExportHandler,
+	consentHandler *handlers.ConsentHandler,
 
 	responseService responses.ResponseHandler,
 	usersRepository *repositories.UsersRepository,
+	erasureRequestRepository *repositories.ErasureRequestRepository,
 	tmpTokens *auth.TemporaryTokens,
+	mfaTokens *auth.MfaTokens,
 	sysSettings *syssettings.SysSettings,
 	confirmationCodeService *users.ConfirmationCode,
 	permissionsService *permissions.Permissions,
+	rateLimitStore ratelimit.Store,
 ) *gin.Engine {
 	// Retrieve config options.
 	ginMode := env_mods.GetMode(cfg.GetServer().GetEnv())
@@ -78,6 +92,7 @@ func Api(
 	mwOwnerOrAdminOrRoot := middlewares.OwnerOrAdminOrRoot()
 	mwRequestedUser := middlewares.RequestedUser(usersRepository, responseService)
 	mwPermissionsService := middlewares.NewPermissionsMiddleware(permissionsService, responseService)
+	mwErasureRequestUser := middlewares.ErasureRequestUser(erasureRequestRepository, usersRepository, responseService)
 
 	/*
 	 |---------------------------------------------------
@@ -90,6 +105,10 @@ func Api(
 		{
 			// POST /users/private/v1/list-contacts
 			v1Group.POST("/list-contacts", usersHandler.ListContacts)
+			// GET /users/private/v1/metrics
+			// metrics of background jobs (e.g. rows deleted by the retention purge) published by expvar,
+			// expvar also exposes the command line and memory stats so only staff may see them
+			v1Group.GET("/metrics", mwAdminOrRoot, gin.WrapH(expvar.Handler()))
 
 			usersGroup := v1Group.Group("/users")
 			{
@@ -106,6 +125,24 @@ func Api(
 				usersGroup.PUT("/:uid/reset-password", mwOwnerOrAdminOrRoot, mwRequestedUser, mwPermissionsService.CanUpdateProfile(), usersHandler.ResetPasswordHandler)
 				// POST /users/private/v1/users/unblock
 				usersGroup.POST("/unblock", mwAdminOrRoot, usersHandler.UnblockHandler)
+				// GET /users/private/v1/users/:uid/sessions
+				usersGroup.GET("/:uid/sessions", mwAdminOrRoot, mwRequestedUser, mwPermissionsService.CanViewProfile(), authHandler.UserSessionsHandler)
+				// DELETE /users/private/v1/users/:uid/sessions
+				usersGroup.DELETE("/:uid/sessions", mwAdminOrRoot, mwRequestedUser, mwPermissionsService.CanUpdateProfile(), authHandler.RevokeUserSessionsHandler)
+				// DELETE /users/private/v1/users/:uid/sessions/:id
+				usersGroup.DELETE("/:uid/sessions/:id", mwAdminOrRoot, mwRequestedUser, mwPermissionsService.CanUpdateProfile(), authHandler.RevokeUserSessionHandler)
+				// DELETE /users/private/v1/users/:uid/mfa
+				usersGroup.DELETE("/:uid/mfa", mwAdminOrRoot, mwRequestedUser, mwPermissionsService.CanUpdateProfile(), authHandler.ResetUserMfaHandler)
+				// GET /users/private/v1/users/:uid/login-history
+				usersGroup.GET("/:uid/login-history", mwAdminOrRoot, mwRequestedUser, mwPermissionsService.CanViewProfile(), loginHistoryHandler.ListHandler)
+				// GET /users/private/v1/users/:uid/login-history/export
+				usersGroup.GET("/:uid/login-history/export", mwAdminOrRoot, mwRequestedUser, mwPermissionsService.CanViewProfile(), loginHistoryHandler.ExportHandler)
+				// GET /users/private/v1/users/:uid/data-export
+				usersGroup.GET("/:uid/data-export", mwAdminOrRoot, mwRequestedUser, mwPermissionsService.CanViewProfile(), dataExportHandler.LatestHandler)
+				// POST /users/private/v1/users/:uid/data-export
+				usersGroup.POST("/:uid/data-export", mwAdminOrRoot, mwRequestedUser, mwPermissionsService.CanViewProfile(), dataExportHandler.RequestHandler)
+				// GET /users/private/v1/users/:uid/consents
+				usersGroup.GET("/:uid/consents", mwAdminOrRoot, mwRequestedUser, mwPermissionsService.CanViewProfile(), consentHandler.ListHandler)
 			}
 
 			staffsGroup := v1Group.Group("/staffs")
@@ -132,6 +169,12 @@ func Api(
 				exportGroup.GET("/admin-profiles", mwAdminOrRoot, mwPermissionsService.CanViewAdminProfile(), usersHandler.GetAdminProfilesCsvHandler)
 			}
 
+			reportsGroup := v1Group.Group("/reports", mwAdminOrRoot)
+			{
+				// GET /users/private/v1/reports/password-schemes
+				reportsGroup.GET("/password-schemes", mwPermissionsService.CanViewSettings(), usersHandler.PasswordSchemesReportHandler)
+			}
+
 			authGroup := v1Group.Group("auth")
 			{
 				// GET /users/private/v1/auth/me
@@ -142,6 +185,10 @@ func Api(
 				authGroup.POST("/logout-device", authHandler.SignOutDeviceHandler)
 				// POST /users/private/v1/auth/change_password
 				authGroup.POST("/change_password", mwUserFromAccessToken, authHandler.ChangePasswordHandler)
+				// GET /users/private/v1/auth/sessions
+				authGroup.GET("/sessions", authHandler.ListSessionsHandler)
+				// DELETE /users/private/v1/auth/sessions/:id
+				authGroup.DELETE("/sessions/:id", authHandler.RevokeSessionHandler)
 				// POST /users/private/v1/auth/root/issue-tokens-for-user-by-uid/:uid
 				authGroup.POST("/root/issue-tokens-for-user-by-uid/:uid", authHandler.IssueTokensForUserByUID)
 
@@ -154,6 +201,94 @@ func Api(
 				authGroup.POST("/generate-new-email-code", mwCurrentUserAsRequestedUser, usersHandler.GenerateNewEmailCode)
 				// PUT /users/private/v1/auth/check-email-code
 				authGroup.PUT("/check-email-code", mwCurrentUserAsRequestedUser, usersHandler.CheckEmailCode)
+				// GET /users/private/v1/auth/login-history
+				authGroup.GET("/login-history", mwCurrentUserAsRequestedUser, loginHistoryHandler.ListHandler)
+				// GET /users/private/v1/auth/login-history/export
+				authGroup.GET("/login-history/export", mwCurrentUserAsRequestedUser, loginHistoryHandler.ExportHandler)
+				// GET /users/private/v1/auth/data-export
+				authGroup.GET("/data-export", mwCurrentUserAsRequestedUser, dataExportHandler.LatestHandler)
+				// POST /users/private/v1/auth/data-export
+				authGroup.POST("/data-export", mwCurrentUserAsRequestedUser, dataExportHandler.RequestHandler)
+				// GET /users/private/v1/auth/consents
+				authGroup.GET("/consents", mwCurrentUserAsRequestedUser, consentHandler.ListHandler)
+				// POST /users/private/v1/auth/consents
+				authGroup.POST("/consents", mwUserFromAccessToken, consentHandler.GrantHandler)
+				// DELETE /users/private/v1/auth/consents/:documentType
+				authGroup.DELETE("/consents/:documentType", mwUserFromAccessToken, consentHandler.WithdrawHandler)
+
+				emailChangeGroup := authGroup.Group("/email-change", mwUserFromAccessToken)
+				{
+					// GET /users/private/v1/auth/email-change
+					emailChangeGroup.GET("", emailChangeHandler.PendingHandler)
+					// POST /users/private/v1/auth/email-change
+					emailChangeGroup.POST("", emailChangeHandler.RequestHandler)
+					// POST /users/private/v1/auth/email-change/confirm
+					emailChangeGroup.POST("/confirm", emailChangeHandler.ConfirmHandler)
+				}
+
+				phoneChangeGroup := authGroup.Group("/phone-change", mwUserFromAccessToken)
+				{
+					// GET /users/private/v1/auth/phone-change
+					phoneChangeGroup.GET("", phoneChangeHandler.PendingHandler)
+					// POST /users/private/v1/auth/phone-change
+					phoneChangeGroup.POST("", phoneChangeHandler.RequestHandler)
+					// POST /users/private/v1/auth/phone-change/confirm
+					phoneChangeGroup.POST("/confirm", phoneChangeHandler.ConfirmHandler)
+				}
+
+				erasureRequestGroup := authGroup.Group("/erasure-request", mwUserFromAccessToken)
+				{
+					// GET /users/private/v1/auth/erasure-request
+					erasureRequestGroup.GET("", erasureHandler.CurrentHandler)
+					// POST /users/private/v1/auth/erasure-request
+					erasureRequestGroup.POST("", erasureHandler.RequestHandler)
+					// DELETE /users/private/v1/auth/erasure-request
+					erasureRequestGroup.DELETE("", erasureHandler.CancelHandler)
+				}
+
+				mfaGroup := authGroup.Group("/mfa", mwUserFromAccessToken)
+				{
+					// GET /users/private/v1/auth/mfa
+					mfaGroup.GET("", authHandler.MfaStatusHandler)
+					// POST /users/private/v1/auth/mfa/totp/enroll
+					mfaGroup.POST("/totp/enroll", authHandler.TotpEnrollHandler)
+					// POST /users/private/v1/auth/mfa/totp/confirm
+					mfaGroup.POST("/totp/confirm", authHandler.TotpConfirmHandler)
+					// POST /users/private/v1/auth/mfa/totp/disable
+					mfaGroup.POST("/totp/disable", authHandler.TotpDisableHandler)
+					// POST /users/private/v1/auth/mfa/recovery-codes
+					mfaGroup.POST("/recovery-codes", authHandler.RecoveryCodesHandler)
+					// GET /users/private/v1/auth/mfa/webauthn/credentials
+					mfaGroup.GET("/webauthn/credentials", authHandler.WebauthnCredentialsHandler)
+					// DELETE /users/private/v1/auth/mfa/webauthn/credentials/:id
+					mfaGroup.DELETE("/webauthn/credentials/:id", authHandler.WebauthnCredentialDeleteHandler)
+					// POST /users/private/v1/auth/mfa/webauthn/register/begin
+					mfaGroup.POST("/webauthn/register/begin", authHandler.WebauthnRegisterBeginHandler)
+					// POST /users/private/v1/auth/mfa/webauthn/register/finish
+					mfaGroup.POST("/webauthn/register/finish", authHandler.WebauthnRegisterFinishHandler)
+				}
+
+				federatedGroup := authGroup.Group("/federated", mwUserFromAccessToken)
+				{
+					// GET /users/private/v1/auth/federated/identities
+					federatedGroup.GET("/identities", authHandler.FederatedIdentitiesHandler)
+					// POST /users/private/v1/auth/federated/identities
+					federatedGroup.POST("/identities", authHandler.FederatedLinkHandler)
+					// DELETE /users/private/v1/auth/federated/identities/:id
+					federatedGroup.DELETE("/identities/:id", authHandler.FederatedUnlinkHandler)
+					// POST /users/private/v1/auth/federated/providers/:slug/link
+					federatedGroup.POST("/providers/:slug/link", authHandler.FederatedLinkBeginHandler)
+				}
+			}
+
+			erasureRequestsGroup := v1Group.Group("/erasure-requests", mwAdminOrRoot)
+			{
+				// GET /users/private/v1/erasure-requests
+				erasureRequestsGroup.GET("", mwPermissionsService.CanUpdateClientProfile(), erasureHandler.ListHandler)
+				// POST /users/private/v1/erasure-requests/:id/approve
+				erasureRequestsGroup.POST("/:id/approve", mwErasureRequestUser, mwPermissionsService.CanUpdateProfile(), erasureHandler.ApproveHandler)
+				// POST /users/private/v1/erasure-requests/:id/reject
+				erasureRequestsGroup.POST("/:id/reject", mwErasureRequestUser, mwPermissionsService.CanUpdateProfile(), erasureHandler.RejectHandler)
 			}
 
 			userGroupsGroup := v1Group.Group("/user-groups", mwAdminOrRoot)
@@ -201,6 +336,36 @@ func Api(
 				invitesGroup.GET("/count", invitesHandler.CountHandler)
 				invitesGroup.POST("", invitesHandler.CreateHandler)
 			}
+
+			identityProvidersGroup := v1Group.Group("/identity-providers", mwAdminOrRoot)
+			{
+				// GET /users/private/v1/identity-providers
+				identityProvidersGroup.GET("", authHandler.ListIdentityProvidersHandler)
+				// POST /users/private/v1/identity-providers
+				identityProvidersGroup.POST("", mwPermissionsService.CanCreateSettings(), authHandler.CreateIdentityProviderHandler)
+				// PUT /users/private/v1/identity-providers/:id
+				identityProvidersGroup.PUT("/:id", mwPermissionsService.CanModifySettings(), authHandler.UpdateIdentityProviderHandler)
+				// DELETE /users/private/v1/identity-providers/:id
+				identityProvidersGroup.DELETE("/:id", mwPermissionsService.CanRemoveSettings(), authHandler.DeleteIdentityProviderHandler)
+			}
+
+			oauthGroup := v1Group.Group("/oauth")
+			{
+				// POST /users/private/v1/oauth/authorize
+				oauthGroup.POST("/authorize", mwUserFromAccessToken, oidcHandler.ConfirmAuthorizationHandler)
+
+				clientsGroup := oauthGroup.Group("/clients", mwAdminOrRoot)
+				{
+					// GET /users/private/v1/oauth/clients
+					clientsGroup.GET("", oidcHandler.ListClientsHandler)
+					// POST /users/private/v1/oauth/clients
+					clientsGroup.POST("", mwPermissionsService.CanCreateSettings(), oidcHandler.CreateClientHandler)
+					// PUT /users/private/v1/oauth/clients/:id
+					clientsGroup.PUT("/:id", mwPermissionsService.CanModifySettings(), oidcHandler.UpdateClientHandler)
+					// DELETE /users/private/v1/oauth/clients/:id
+					clientsGroup.DELETE("/:id", mwPermissionsService.CanRemoveSettings(), oidcHandler.DeleteClientHandler)
+				}
+			}
 		}
 
 		// limited routes may be accessed using temporary jwt tokens
@@ -247,16 +412,136 @@ func Api(
 	{
 		v1Group := publicGroup.Group("/v1")
 		{
+			// GET /users/public/v1/.well-known/jwks.json
+			v1Group.GET("/.well-known/jwks.json", jwksHandler.KeysHandler)
+			// GET /users/public/v1/.well-known/openid-configuration
+			v1Group.GET("/.well-known/openid-configuration", oidcHandler.DiscoveryHandler)
+
+			oauthGroup := v1Group.Group("oauth")
+			{
+				// GET /users/public/v1/oauth/authorize
+				oauthGroup.GET("/authorize", mwMaintenance, oidcHandler.AuthorizeHandler)
+				// POST /users/public/v1/oauth/token
+				oauthGroup.POST("/token", mwMaintenance, oidcHandler.TokenHandler)
+				// GET /users/public/v1/oauth/userinfo
+				oauthGroup.GET("/userinfo", oidcHandler.UserInfoHandler)
+				// POST /users/public/v1/oauth/userinfo
+				oauthGroup.POST("/userinfo", oidcHandler.UserInfoHandler)
+			}
+
+			// mwRateLimit throttles requests to the route by the client IP and the given identifier of the request
+			mwRateLimit := func(route string, identify middlewares.Identifier) gin.HandlerFunc {
+				return middlewares.RateLimit(
+					rateLimitStore,
+					route,
+					cfg.RateLimit.Routes[route],
+					identify,
+					responseService,
+					logger,
+				)
+			}
+
 			authGroup := v1Group.Group("auth")
 			{
 				// POST /users/public/v1/auth/signup
-				authGroup.POST("/signup", authHandler.SimpleSignUpHandler)
+				authGroup.POST(
+					"/signup",
+					mwRateLimit(config.RateLimitRouteSignUp, middlewares.IdentifierFromJSON("email", "phoneNumber")),
+					authHandler.SimpleSignUpHandler,
+				)
 				// POST /users/public/v1/auth/signin
-				authGroup.POST("/signin", authHandler.SignInHandler)
+				authGroup.POST(
+					"/signin",
+					mwRateLimit(config.RateLimitRouteSignIn, middlewares.IdentifierFromJSON("data.email")),
+					authHandler.SignInHandler,
+				)
+
+				// mwMfaRequired gives access to the given route using mfa token issued on sign in
+				mwMfaRequired := middlewares.UserFromMfaToken(
+					responseService,
+					mfaTokens,
+					usersRepository,
+					models.ChallengeNameMfaRequired,
+					logger.New("middleware", "UserFromMfaToken"),
+				)
+				// mwSecondFactorRateLimit throttles attempts to pass the second factor by the user of the mfa token,
+				// so codes can not be guessed from many addresses
+				mwSecondFactorRateLimit := mwRateLimit(config.RateLimitRouteSecondFactor, middlewares.IdentifierFromCurrentUser())
+				// POST /users/public/v1/auth/mfa/verify
+				authGroup.POST("/mfa/verify", mwMfaRequired, mwSecondFactorRateLimit, authHandler.MfaVerifyHandler)
+				// POST /users/public/v1/auth/mfa/webauthn/begin
+				authGroup.POST("/mfa/webauthn/begin", mwMfaRequired, authHandler.WebauthnMfaBeginHandler)
+				// POST /users/public/v1/auth/mfa/webauthn/verify
+				authGroup.POST("/mfa/webauthn/verify", mwMfaRequired, mwSecondFactorRateLimit, authHandler.WebauthnMfaVerifyHandler)
+				// POST /users/public/v1/auth/webauthn/signin/begin
+				authGroup.POST("/webauthn/signin/begin", authHandler.WebauthnSignInBeginHandler)
+				// POST /users/public/v1/auth/webauthn/signin
+				authGroup.POST(
+					"/webauthn/signin",
+					mwRateLimit(config.RateLimitRouteWebauthnSignIn, middlewares.IdentifierFromJSON("credential.id")),
+					authHandler.WebauthnSignInHandler,
+				)
+				// GET /users/public/v1/auth/federated/providers
+				authGroup.GET("/federated/providers", authHandler.FederatedProvidersHandler)
+				// POST /users/public/v1/auth/federated/providers/:slug/begin
+				authGroup.POST("/federated/providers/:slug/begin", authHandler.FederatedSignInBeginHandler)
+				// POST /users/public/v1/auth/federated/signin
+				authGroup.POST("/federated/signin", authHandler.FederatedSignInHandler)
+
+				// mwMfaSetupRequired gives access to the given route if a user must enroll an authenticator on sign in
+				mwMfaSetupRequired := middlewares.UserFromMfaToken(
+					responseService,
+					mfaTokens,
+					usersRepository,
+					models.ChallengeNameMfaSetupRequired,
+					logger.New("middleware", "UserFromMfaToken"),
+				)
+				// POST /users/public/v1/auth/mfa/setup/enroll
+				authGroup.POST("/mfa/setup/enroll", mwMfaSetupRequired, authHandler.TotpEnrollHandler)
+				// POST /users/public/v1/auth/mfa/setup/confirm
+				authGroup.POST("/mfa/setup/confirm", mwMfaSetupRequired, authHandler.MfaSetupConfirmHandler)
+
+				// mwStepUpRequired gives access to the given route if a risky sign in must be confirmed by a code
+				mwStepUpRequired := middlewares.UserFromMfaToken(
+					responseService,
+					mfaTokens,
+					usersRepository,
+					models.ChallengeNameStepUpRequired,
+					logger.New("middleware", "UserFromMfaToken"),
+				)
+				// POST /users/public/v1/auth/step-up/verify
+				authGroup.POST("/step-up/verify", mwStepUpRequired, mwSecondFactorRateLimit, authHandler.StepUpVerifyHandler)
+
+				// mwConsentRequired gives access to the given route if new versions of documents must be accepted on sign in
+				mwConsentRequired := middlewares.UserFromMfaToken(
+					responseService,
+					mfaTokens,
+					usersRepository,
+					models.ChallengeNameConsentRequired,
+					logger.New("middleware", "UserFromMfaToken"),
+				)
+				// POST /users/public/v1/auth/consents/accept
+				authGroup.POST("/consents/accept", mwConsentRequired, authHandler.ConsentAcceptHandler)
 				// POST /users/public/v1/auth/forgot-password
-				authGroup.POST("/forgot-password", mwMaintenance, authHandler.ForgotPassword)
+				authGroup.POST(
+					"/forgot-password",
+					mwRateLimit(config.RateLimitRouteForgotPassword, middlewares.IdentifierFromJSON("email")),
+					mwMaintenance,
+					authHandler.ForgotPassword,
+				)
 				// POST /users/public/v1/auth/reset-password
-				authGroup.POST("/reset-password", mwMaintenance, authHandler.ResetPassword)
+				authGroup.POST(
+					"/reset-password",
+					mwRateLimit(config.RateLimitRouteResetPassword, middlewares.IdentifierFromJSON("confirmationCode")),
+					mwMaintenance,
+					authHandler.ResetPassword,
+				)
+				// POST /users/public/v1/auth/email-change/cancel
+				authGroup.POST(
+					"/email-change/cancel",
+					mwRateLimit(config.RateLimitRouteConfirmationCode, middlewares.IdentifierFromJSON("code")),
+					emailChangeHandler.CancelHandler,
+				)
 				// GET /users/public/v1/auth/refresh
 				authGroup.GET("/refresh", mwMaintenance, authHandler.RefreshHandler)
 
@@ -280,7 +565,13 @@ func Api(
 					logger.New("middleware", "UserFromConfirmationCode"),
 				)
 				// GET /users/public/v1/auth/confirmation-code/:code
-				authGroup.GET("/confirmation-code/:code", mwMaintenance, mwUserFromCode, authHandler.GetConfirmationCodeHandler)
+				authGroup.GET(
+					"/confirmation-code/:code",
+					mwRateLimit(config.RateLimitRouteConfirmationCode, middlewares.IdentifierFromParam("code")),
+					mwMaintenance,
+					mwUserFromCode,
+					authHandler.GetConfirmationCodeHandler,
+				)
 			}
 
 			securityQuestionsGroup := v1Group.Group("security-questions", mwMaintenance)
@@ -0,0 +1,110 @@
+package serializers
+
+import (
+	"time"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+// IdentityProvider is a representation of an identity provider for administrators, the client secret is never returned
+type IdentityProvider struct {
+	ID                    uint64            `json:"id"`
+	Slug                  string            `json:"slug"`
+	Name                  string            `json:"name"`
+	Issuer                string            `json:"issuer"`
+	ClientID              string            `json:"clientId"`
+	HasClientSecret       bool              `json:"hasClientSecret"`
+	Scopes                []string          `json:"scopes"`
+	Domains               []string          `json:"domains"`
+	RoleClaim             string            `json:"roleClaim"`
+	RoleMapping           map[string]string `json:"roleMapping"`
+	DefaultRole           string            `json:"defaultRole"`
+	GroupClaim            string            `json:"groupClaim"`
+	GroupMapping          map[string]uint64 `json:"groupMapping"`
+	JitEnabled            bool              `json:"jitEnabled"`
+	PasswordLoginDisabled bool              `json:"passwordLoginDisabled"`
+	IsActive              bool              `json:"isActive"`
+	CreatedAt             time.Time         `json:"createdAt"`
+	UpdatedAt             time.Time         `json:"updatedAt"`
+}
+
+func NewIdentityProvider(provider *models.IdentityProvider) *IdentityProvider {
+	// mappings are validated before they are stored
+	roleMapping, _ := provider.RoleMap()
+	groupMapping, _ := provider.GroupMap()
+
+	return &IdentityProvider{
+		ID:                    provider.ID,
+		Slug:                  provider.Slug,
+		Name:                  provider.Name,
+		Issuer:                provider.Issuer,
+		ClientID:              provider.ClientID,
+		HasClientSecret:       provider.ClientSecret != "",
+		Scopes:                provider.ScopeList(),
+		Domains:               provider.DomainList(),
+		RoleClaim:             provider.RoleClaim,
+		RoleMapping:           roleMapping,
+		DefaultRole:           provider.DefaultRole,
+		GroupClaim:            provider.GroupClaim,
+		GroupMapping:          groupMapping,
+		JitEnabled:            provider.JitEnabled,
+		PasswordLoginDisabled: provider.PasswordLoginDisabled,
+		IsActive:              provider.IsActive,
+		CreatedAt:             provider.CreatedAt,
+		UpdatedAt:             provider.UpdatedAt,
+	}
+}
+
+func NewIdentityProviders(providers []*models.IdentityProvider) []*IdentityProvider {
+	list := make([]*IdentityProvider, 0, len(providers))
+	for _, provider := range providers {
+		list = append(list, NewIdentityProvider(provider))
+	}
+	return list
+}
+
+// PublicIdentityProvider is shown on the sign in page
+type PublicIdentityProvider struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+func NewPublicIdentityProviders(providers []*models.IdentityProvider) []*PublicIdentityProvider {
+	list := make([]*PublicIdentityProvider, 0, len(providers))
+	for _, provider := range providers {
+		list = append(list, &PublicIdentityProvider{Slug: provider.Slug, Name: provider.Name})
+	}
+	return list
+}
+
+// UserIdentity is an identity of a provider linked to the user
+type UserIdentity struct {
+	ID           uint64     `json:"id"`
+	ProviderSlug string     `json:"providerSlug"`
+	ProviderName string     `json:"providerName"`
+	Email        string     `json:"email"`
+	LastLoginAt  *time.Time `json:"lastLoginAt"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+func NewUserIdentity(identity *models.UserIdentity) *UserIdentity {
+	res := &UserIdentity{
+		ID:          identity.ID,
+		Email:       identity.Email,
+		LastLoginAt: identity.LastLoginAt,
+		CreatedAt:   identity.CreatedAt,
+	}
+	if identity.Provider != nil {
+		res.ProviderSlug = identity.Provider.Slug
+		res.ProviderName = identity.Provider.Name
+	}
+	return res
+}
+
+func NewUserIdentities(identities []*models.UserIdentity) []*UserIdentity {
+	list := make([]*UserIdentity, 0, len(identities))
+	for _, identity := range identities {
+		list = append(list, NewUserIdentity(identity))
+	}
+	return list
+}
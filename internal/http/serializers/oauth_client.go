@@ -0,0 +1,44 @@
+package serializers
+
+import (
+	"time"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+// OAuthClient is a public representation of a registered client
+type OAuthClient struct {
+	ID           uint64    `json:"id"`
+	ClientID     string    `json:"clientId"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirectUris"`
+	Scopes       []string  `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+	IsActive     bool      `json:"isActive"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+	// ClientSecret is set only in the response to registration of a confidential client
+	ClientSecret string `json:"clientSecret,omitempty"`
+}
+
+func NewOAuthClient(client *models.OAuthClient) *OAuthClient {
+	return &OAuthClient{
+		ID:           client.ID,
+		ClientID:     client.ClientID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectURIList(),
+		Scopes:       client.ScopeList(),
+		Confidential: client.IsConfidential(),
+		IsActive:     client.IsActive,
+		CreatedAt:    client.CreatedAt,
+		UpdatedAt:    client.UpdatedAt,
+	}
+}
+
+func NewOAuthClients(clients []*models.OAuthClient) []*OAuthClient {
+	list := make([]*OAuthClient, 0, len(clients))
+	for _, client := range clients {
+		list = append(list, NewOAuthClient(client))
+	}
+	return list
+}
@@ -0,0 +1,38 @@
+package serializers
+
+import (
+	"time"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/services/auth"
+)
+
+// Session is a public representation of a refresh token
+type Session struct {
+	ID          uint64     `json:"id"`
+	DeviceID    string     `json:"deviceId"`
+	UserAgent   string     `json:"userAgent"`
+	OsType      string     `json:"osType"`
+	IP          string     `json:"ip"`
+	FirstSeenAt *time.Time `json:"firstSeenAt"`
+	LastUsedAt  *time.Time `json:"lastUsedAt"`
+	Current     bool       `json:"current"`
+}
+
+// NewSessions serializes refresh tokens, currentID marks the session of the current request
+func NewSessions(tokens []*models.Token, currentID uint64) []*Session {
+	sessions := make([]*Session, 0, len(tokens))
+	for _, token := range tokens {
+		sessions = append(sessions, &Session{
+			ID:          token.ID,
+			DeviceID:    token.DeviceID,
+			UserAgent:   token.UserAgent,
+			OsType:      auth.DeviceOsType(token.UserAgent),
+			IP:          token.IP,
+			FirstSeenAt: token.FirstSeenAt,
+			LastUsedAt:  token.LastUsedAt,
+			Current:     currentID != 0 && token.ID == currentID,
+		})
+	}
+	return sessions
+}
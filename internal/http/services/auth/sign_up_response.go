@@ -9,7 +9,13 @@ import (
 	"github.com/Confialink/wallet-users/internal/services/auth"
 )
 
-const TmpAuthHeader = "X-Tmp-Auth"
+const (
+	TmpAuthHeader = "X-Tmp-Auth"
+	// MfaTokenHeader is used to pass the token which is returned on sign in with a pending mfa challenge
+	MfaTokenHeader = "X-Mfa-Token"
+	// DeviceIDHeader may be sent by clients on sign in and refresh in order to identify the session device
+	DeviceIDHeader = "X-Device-Id"
+)
 
 type ResponseDto struct {
 	Status  int
@@ -2,6 +2,8 @@ package jwt
 
 import (
 	base "github.com/dgrijalva/jwt-go"
+
+	"github.com/Confialink/wallet-users/pkg/jwk"
 )
 
 const DefaultSecretKeyPath = "./jwt.pem"
@@ -24,3 +26,8 @@ type Service interface {
 	Signer
 	Parser
 }
+
+// KeySet is implemented by services which are able to publish keys for verification of issued tokens
+type KeySet interface {
+	PublicKeys() []jwk.Key
+}
@@ -0,0 +1,203 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	base "github.com/dgrijalva/jwt-go"
+)
+
+// minReloadInterval protects the key source from being flooded by tokens with unknown key id
+const minReloadInterval = 10 * time.Second
+
+var (
+	ErrNoCurrentKey = errors.New("key ring has no current key")
+	ErrUnknownKey   = errors.New("unknown signing key")
+)
+
+// Key is a member of the key ring.
+// SignKey and VerifyKey are *ecdsa.PrivateKey and *ecdsa.PublicKey for ECDSA or the secret bytes for HMAC.
+type Key struct {
+	Kid       string
+	SignKey   interface{}
+	VerifyKey interface{}
+	// Current key is used to sign new tokens, other keys are only used to verify tokens signed before rotation
+	Current bool
+}
+
+// KeyLoader provides not retired keys of the ring, exactly one of them must be current
+type KeyLoader interface {
+	LoadKeys() ([]*Key, error)
+}
+
+// KeyRing keeps signing keys and reloads them periodically, so rotated keys are picked up without restart
+type KeyRing struct {
+	loader         KeyLoader
+	reloadInterval time.Duration
+
+	mu          sync.RWMutex
+	keys        map[string]*Key
+	current     *Key
+	loadedAt    time.Time
+	attemptedAt time.Time
+}
+
+// NewKeyRing creates a ring and loads keys, zero reload interval disables reloading
+func NewKeyRing(loader KeyLoader, reloadInterval time.Duration) (*KeyRing, error) {
+	r := &KeyRing{loader: loader, reloadInterval: reloadInterval}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// NewStaticKeyRing creates a ring of the single key which is never reloaded
+func NewStaticKeyRing(key *Key) *KeyRing {
+	key.Current = true
+	return &KeyRing{
+		keys:     map[string]*Key{key.Kid: key},
+		current:  key,
+		loadedAt: time.Now(),
+	}
+}
+
+// Current returns the key new tokens are signed with
+func (r *KeyRing) Current() *Key {
+	r.reloadIfStale()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+// Get returns the key by id, the ring is reloaded if the key is unknown since it might be added by another instance
+func (r *KeyRing) Get(kid string) (*Key, error) {
+	r.reloadIfStale()
+	if key := r.get(kid); key != nil {
+		return key, nil
+	}
+
+	if r.loader != nil {
+		r.mu.RLock()
+		throttled := time.Since(r.attemptedAt) < minReloadInterval
+		r.mu.RUnlock()
+		if !throttled {
+			_ = r.reload()
+		}
+	}
+	if key := r.get(kid); key != nil {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// All returns all not retired keys
+func (r *KeyRing) All() []*Key {
+	r.reloadIfStale()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	keys := make([]*Key, 0, len(r.keys))
+	// the current key goes first as the most probable one
+	keys = append(keys, r.current)
+	for _, key := range r.keys {
+		if key != r.current {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (r *KeyRing) get(kid string) *Key {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.keys[kid]
+}
+
+// reloadIfStale reloads keys once the reload interval is passed, the loaded keys are kept on failure
+func (r *KeyRing) reloadIfStale() {
+	if r.loader == nil || r.reloadInterval == 0 {
+		return
+	}
+
+	r.mu.RLock()
+	stale := time.Since(r.loadedAt) > r.reloadInterval && time.Since(r.attemptedAt) > minReloadInterval
+	r.mu.RUnlock()
+	if stale {
+		_ = r.reload()
+	}
+}
+
+func (r *KeyRing) reload() error {
+	r.mu.Lock()
+	r.attemptedAt = time.Now()
+	r.mu.Unlock()
+
+	loaded, err := r.loader.LoadKeys()
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*Key, len(loaded))
+	var current *Key
+	for _, key := range loaded {
+		keys[key.Kid] = key
+		if key.Current {
+			current = key
+		}
+	}
+	if current == nil {
+		return ErrNoCurrentKey
+	}
+
+	r.mu.Lock()
+	r.keys = keys
+	r.current = current
+	r.loadedAt = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+// parse verifies the token with the key referenced by kid header.
+// Tokens issued before the ring was introduced have no kid, so every key of the ring is tried.
+func (r *KeyRing) parse(str string, accepts func(method base.SigningMethod) bool) (*base.Token, error) {
+	keyFunc := func(key *Key) base.Keyfunc {
+		return func(token *base.Token) (interface{}, error) {
+			if !accepts(token.Method) {
+				return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+			}
+			if key != nil {
+				return key.VerifyKey, nil
+			}
+			kid, _ := token.Header["kid"].(string)
+			found, err := r.Get(kid)
+			if err != nil {
+				return nil, err
+			}
+			return found.VerifyKey, nil
+		}
+	}
+
+	unverified, _, err := new(base.Parser).ParseUnverified(str, base.MapClaims{})
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := unverified.Header["kid"]; ok {
+		return base.Parse(str, keyFunc(nil))
+	}
+
+	var token *base.Token
+	for _, key := range r.All() {
+		token, err = base.Parse(str, keyFunc(key))
+		if err == nil {
+			return token, nil
+		}
+		// the signature is valid, but the claims are not, so other keys make no sense
+		if validationErr, ok := err.(*base.ValidationError); ok && validationErr.Errors&base.ValidationErrorSignatureInvalid == 0 {
+			return token, err
+		}
+	}
+	return token, err
+}
@@ -0,0 +1,108 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	base "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticLoader struct {
+	keys []*Key
+}
+
+func (l *staticLoader) LoadKeys() ([]*Key, error) {
+	return l.keys, nil
+}
+
+func newTestECDSAKey(t *testing.T, kid string) *Key {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return &Key{Kid: kid, SignKey: privateKey, VerifyKey: &privateKey.PublicKey}
+}
+
+func claims() base.MapClaims {
+	return base.MapClaims{"sub": "access", "exp": time.Now().Add(time.Minute).Unix()}
+}
+
+func TestWithECDSAKeyRingRotation(t *testing.T) {
+	first := newTestECDSAKey(t, "first")
+	first.Current = true
+	loader := &staticLoader{keys: []*Key{first}}
+	ring, err := NewKeyRing(loader, 0)
+	require.NoError(t, err)
+	service := NewWithECDSAKeyRing(base.SigningMethodES256, ring)
+
+	token := service.Issue(claims())
+	assert.Equal(t, "first", token.Header["kid"])
+	signedByFirst, err := service.Sign(token)
+	require.NoError(t, err)
+
+	// rotation by another instance: the first key becomes previous
+	second := newTestECDSAKey(t, "second")
+	second.Current = true
+	first.Current = false
+	loader.keys = []*Key{second, first}
+	otherInstance := NewWithECDSAKeyRing(base.SigningMethodES256, NewStaticKeyRing(second))
+	signedBySecond, err := otherInstance.Sign(otherInstance.Issue(claims()))
+	require.NoError(t, err)
+
+	// unknown kid forces reloading once the throttling interval is passed
+	ring.attemptedAt = time.Now().Add(-minReloadInterval)
+	parsed, err := service.Parse(signedBySecond)
+	require.NoError(t, err)
+	assert.True(t, parsed.Valid)
+	_, err = service.Parse(signedByFirst)
+	assert.NoError(t, err)
+	assert.Equal(t, "second", service.Issue(claims()).Header["kid"])
+	assert.Len(t, service.(KeySet).PublicKeys(), 2)
+
+	// retirement
+	loader.keys = []*Key{second}
+	require.NoError(t, ring.reload())
+	_, err = service.Parse(signedByFirst)
+	assert.Error(t, err)
+}
+
+func TestWithECDSAKeyRingLegacyTokens(t *testing.T) {
+	key := newTestECDSAKey(t, "kid")
+	service := NewWithECDSAKeyRing(base.SigningMethodES256, NewStaticKeyRing(key))
+
+	// tokens issued before the ring have no kid header
+	legacy, err := base.NewWithClaims(base.SigningMethodES256, claims()).SignedString(key.SignKey)
+	require.NoError(t, err)
+	_, err = service.Parse(legacy)
+	assert.NoError(t, err)
+
+	other := newTestECDSAKey(t, "other")
+	forged, err := base.NewWithClaims(base.SigningMethodES256, claims()).SignedString(other.SignKey)
+	require.NoError(t, err)
+	_, err = service.Parse(forged)
+	assert.Error(t, err)
+}
+
+func TestWithHMACKeyRing(t *testing.T) {
+	previous := NewHMACKey("previous-secret")
+	current := NewHMACKey("current-secret")
+	current.Current = true
+	ring, err := NewKeyRing(&staticLoader{keys: []*Key{current, previous}}, 0)
+	require.NoError(t, err)
+	service := NewWithHMACKeyRing(base.SigningMethodHS256, ring)
+
+	signedByPrevious, err := NewWithHMAC("previous-secret", base.SigningMethodHS256).
+		Sign(base.NewWithClaims(base.SigningMethodHS256, claims()))
+	require.NoError(t, err)
+	_, err = service.Parse(signedByPrevious)
+	assert.NoError(t, err)
+
+	token := service.Issue(claims())
+	assert.Equal(t, current.Kid, token.Header["kid"])
+
+	_, err = NewWithHMAC("unknown-secret", base.SigningMethodHS256).Parse(signedByPrevious)
+	assert.Error(t, err)
+}
@@ -4,12 +4,13 @@ import (
 	"crypto/ecdsa"
 	base "github.com/dgrijalva/jwt-go"
 	"io/ioutil"
+
+	"github.com/Confialink/wallet-users/pkg/jwk"
 )
 
 type WithECDSA struct {
 	signingMethod *base.SigningMethodECDSA
-	secretKey     *ecdsa.PrivateKey
-	publicKey     *ecdsa.PublicKey
+	ring          *KeyRing
 }
 
 type Params struct {
@@ -45,34 +46,78 @@ func NewWithECDSA(params *Params) (service Service, err error) {
 		params.PublicKey = publicBytes
 	}
 
-	secretKey, err := base.ParseECPrivateKeyFromPEM(params.SecretKey)
+	if params.SigningMethod == nil {
+		params.SigningMethod = base.SigningMethodES512
+	}
+
+	key, err := NewECDSAKey(params.SecretKey, params.PublicKey)
 	if nil != err {
 		return
 	}
 
-	publicKey, err := base.ParseECPublicKeyFromPEM(params.PublicKey)
+	service = NewWithECDSAKeyRing(params.SigningMethod, NewStaticKeyRing(key))
+	return
+}
+
+// NewWithECDSAKeyRing creates the service which signs tokens with the current key of the ring
+func NewWithECDSAKeyRing(signingMethod *base.SigningMethodECDSA, ring *KeyRing) Service {
+	return &WithECDSA{signingMethod: signingMethod, ring: ring}
+}
+
+// NewECDSAKey parses PEM encoded key pair, the key id is the RFC 7638 thumbprint of the public key
+func NewECDSAKey(secretKey, publicKey []byte) (*Key, error) {
+	signKey, err := base.ParseECPrivateKeyFromPEM(secretKey)
 	if nil != err {
-		return
+		return nil, err
 	}
 
-	if params.SigningMethod == nil {
-		params.SigningMethod = base.SigningMethodES512
+	verifyKey, err := base.ParseECPublicKeyFromPEM(publicKey)
+	if nil != err {
+		return nil, err
 	}
 
-	service = &WithECDSA{signingMethod: params.SigningMethod, secretKey: secretKey, publicKey: publicKey}
-	return
+	key, err := jwk.FromECDSA(verifyKey, "")
+	if nil != err {
+		return nil, err
+	}
+
+	return &Key{Kid: key.Kid, SignKey: signKey, VerifyKey: verifyKey}, nil
 }
 
+// Issue creates a new token which header contains id of the current key,
+// so the token can be verified by public keys published as JWKS
 func (s *WithECDSA) Issue(claims base.Claims) *base.Token {
-	return base.NewWithClaims(s.signingMethod, claims)
+	token := base.NewWithClaims(s.signingMethod, claims)
+	token.Header["kid"] = s.ring.Current().Kid
+	return token
 }
 
 func (s *WithECDSA) Sign(t *base.Token, secret ...[]byte) (string, error) {
-	return t.SignedString(s.secretKey)
+	key := s.ring.Current()
+	if kid, ok := t.Header["kid"].(string); ok && kid != key.Kid {
+		// the ring is reloaded between issuing and signing
+		t.Header["kid"] = key.Kid
+	}
+	return t.SignedString(key.SignKey)
 }
 
 func (s *WithECDSA) Parse(str string, secret ...[]byte) (*base.Token, error) {
-	return base.Parse(str, func(token *base.Token) (interface{}, error) {
-		return s.publicKey, nil
+	return s.ring.parse(str, func(method base.SigningMethod) bool {
+		_, ok := method.(*base.SigningMethodECDSA)
+		return ok
 	})
 }
+
+// PublicKeys returns public keys of the ring which are used to verify issued tokens
+func (s *WithECDSA) PublicKeys() []jwk.Key {
+	keys := make([]jwk.Key, 0)
+	for _, key := range s.ring.All() {
+		publicKey, err := jwk.FromECDSA(key.VerifyKey.(*ecdsa.PublicKey), s.signingMethod.Alg())
+		if err != nil {
+			continue
+		}
+		publicKey.Kid = key.Kid
+		keys = append(keys, publicKey)
+	}
+	return keys
+}
@@ -1,37 +1,73 @@
 package jwt
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+
 	base "github.com/dgrijalva/jwt-go"
+
+	"github.com/Confialink/wallet-users/pkg/jwk"
 )
 
 type WithHMAC struct {
 	signingMethod *base.SigningMethodHMAC
-	secret        string
+	ring          *KeyRing
 }
 
 func NewWithHMAC(secret string, signingMethod *base.SigningMethodHMAC) (service Service) {
-	service = &WithHMAC{secret: secret, signingMethod: signingMethod}
+	service = NewWithHMACKeyRing(signingMethod, NewStaticKeyRing(NewHMACKey(secret)))
 	return
 }
 
+// NewWithHMACKeyRing creates the service which signs tokens with the current secret of the ring
+func NewWithHMACKeyRing(signingMethod *base.SigningMethodHMAC, ring *KeyRing) Service {
+	return &WithHMAC{signingMethod: signingMethod, ring: ring}
+}
+
+// NewHMACKey creates a key of the secret, the key id is derived from the secret hash so it does not reveal the secret
+func NewHMACKey(secret string) *Key {
+	sum := sha256.Sum256([]byte("kid:" + secret))
+	return &Key{
+		Kid:       base64.RawURLEncoding.EncodeToString(sum[:12]),
+		SignKey:   []byte(secret),
+		VerifyKey: []byte(secret),
+	}
+}
+
 func (s *WithHMAC) Issue(claims base.Claims) *base.Token {
-	return base.NewWithClaims(s.signingMethod, claims)
+	token := base.NewWithClaims(s.signingMethod, claims)
+	token.Header["kid"] = s.ring.Current().Kid
+	return token
 }
 
 func (s *WithHMAC) Sign(t *base.Token, secret ...[]byte) (string, error) {
-	key := []byte(s.secret)
 	if len(secret) > 0 {
-		key = secret[0]
+		delete(t.Header, "kid")
+		return t.SignedString(secret[0])
+	}
+
+	key := s.ring.Current()
+	if kid, ok := t.Header["kid"].(string); ok && kid != key.Kid {
+		// the ring is reloaded between issuing and signing
+		t.Header["kid"] = key.Kid
 	}
-	return t.SignedString(key)
+	return t.SignedString(key.SignKey)
 }
 
 func (s *WithHMAC) Parse(str string, secret ...[]byte) (*base.Token, error) {
-	key := []byte(s.secret)
 	if len(secret) > 0 {
-		key = secret[0]
+		return base.Parse(str, func(token *base.Token) (interface{}, error) {
+			return secret[0], nil
+		})
 	}
-	return base.Parse(str, func(token *base.Token) (interface{}, error) {
-		return key, nil
+
+	return s.ring.parse(str, func(method base.SigningMethod) bool {
+		_, ok := method.(*base.SigningMethodHMAC)
+		return ok
 	})
 }
+
+// PublicKeys returns no keys, HMAC secret must never be published
+func (s *WithHMAC) PublicKeys() []jwk.Key {
+	return []jwk.Key{}
+}
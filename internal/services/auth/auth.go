@@ -3,22 +3,43 @@ package auth
 import (
 	"fmt"
 
+	"github.com/Confialink/wallet-pkg-utils/pointer"
 	"github.com/inconshreveable/log15"
 
 	"github.com/Confialink/wallet-users/internal/db/models"
 	"github.com/Confialink/wallet-users/internal/db/repositories"
 	"github.com/Confialink/wallet-users/internal/http/responses"
 	"github.com/Confialink/wallet-users/internal/services"
+	"github.com/Confialink/wallet-users/internal/services/gdpr"
+	"github.com/Confialink/wallet-users/internal/services/notifications"
+	"github.com/Confialink/wallet-users/internal/services/passwordpolicy"
 	"github.com/Confialink/wallet-users/internal/services/syssettings"
+	"github.com/Confialink/wallet-users/internal/services/users"
+	"github.com/Confialink/wallet-users/pkg/webauthn"
 )
 
 type Auth struct {
-	userRepo        *repositories.UsersRepository
-	authBlocker     *Blocker
-	sysSettings     *syssettings.SysSettings
-	passwordService *services.Password
-	tokenService    *TokenService
-	logger          log15.Logger
+	userRepo             *repositories.UsersRepository
+	authBlocker          *Blocker
+	sysSettings          *syssettings.SysSettings
+	passwordService      *services.Password
+	tokenService         *TokenService
+	mfa                  *Mfa
+	mfaTokens            *MfaTokens
+	webauthn             *Webauthn
+	passwordPolicy       *passwordpolicy.Policy
+	loginRisk            *LoginRisk
+	confirmationCodes    *users.ConfirmationCode
+	notificationsService *notifications.Notifications
+	securityEvents       *SecurityEvents
+	consents             *gdpr.Consents
+	logger               log15.Logger
+}
+
+// MfaSetupResponse is returned when a user completes mandatory authenticator setup during sign in
+type MfaSetupResponse struct {
+	ExtendedTokensResponse
+	RecoveryCodes []string `json:"recoveryCodes"`
 }
 
 func NewAuth(
@@ -27,6 +48,15 @@ func NewAuth(
 	sysSettings *syssettings.SysSettings,
 	passwordService *services.Password,
 	tokenService *TokenService,
+	mfa *Mfa,
+	mfaTokens *MfaTokens,
+	webauthn *Webauthn,
+	passwordPolicy *passwordpolicy.Policy,
+	loginRisk *LoginRisk,
+	confirmationCodes *users.ConfirmationCode,
+	notificationsService *notifications.Notifications,
+	securityEvents *SecurityEvents,
+	consents *gdpr.Consents,
 	logger log15.Logger,
 ) *Auth {
 	return &Auth{
@@ -35,19 +65,51 @@ func NewAuth(
 		sysSettings,
 		passwordService,
 		tokenService,
+		mfa,
+		mfaTokens,
+		webauthn,
+		passwordPolicy,
+		loginRisk,
+		confirmationCodes,
+		notificationsService,
+		securityEvents,
+		consents,
 		logger,
 	}
 }
 
 // it returns tokens by username and password
 // we apply blocking user policy
+// if the user has to pass the second factor, to confirm a risky sign in or to accept new versions of documents
+// a challenge is returned instead of tokens
 func (s *Auth) LoginUser(user *models.User, userModel models.User, tokenOptions *TokenOptions, ip string) (*ExtendedTokensResponse, *responses.Error) {
 	if err := s.passwordService.UserCheckPassword(userModel.Password, user.Password); err != nil {
 		s.authBlocker.AddUserFailAttempt(userModel.Email, ip)
 		return nil, responses.NewCommonErrorByCode(responses.CodeInvalidUsernamePassword, "Invalid username or password.")
 	}
+	s.upgradePasswordHash(user, userModel.Password)
+
+	if errResp := s.requirePasswordChangeIfExpired(user); errResp != nil {
+		return nil, errResp
+	}
+
+	challengeName, err := s.mfa.Challenge(user)
+	if err != nil {
+		logger := s.logger.New("method", "LoginUser")
+		logger.Error("failed to resolve mfa challenge", "error", err)
+		return nil, responses.NewCommonErrorByCode(responses.InternalError, "")
+	}
+	if challengeName != "" {
+		return s.issueMfaChallenge(user, challengeName)
+	}
+
+	// the second factor is stronger than a code sent to the email or the phone, so only others are asked for it
+	device := tokenOptions.deviceInfo()
+	if assessment := s.loginRisk.Assess(user, device); s.loginRisk.StepUpRequired(assessment) {
+		return s.issueStepUpChallenge(user, assessment, device)
+	}
 
-	tokens, errResp := s.issueTokens(user, tokenOptions)
+	tokens, errResp := s.completeSignIn(user, tokenOptions)
 	if errResp != nil {
 		return nil, errResp
 	}
@@ -55,6 +117,246 @@ func (s *Auth) LoginUser(user *models.User, userModel models.User, tokenOptions
 	return tokens, nil
 }
 
+// VerifyStepUp completes a risky sign in by the code sent to the email or the phone of the user
+func (s *Auth) VerifyStepUp(user *models.User, code string, tokenOptions *TokenOptions, ip string) (*ExtendedTokensResponse, *responses.Error) {
+	codeModel, err := s.confirmationCodes.FindActiveByCodeSubjectAndUser(code, models.ConfirmationCodeSubjectLoginVerification, user)
+	if err != nil {
+		s.authBlocker.AddUserFailAttempt(user.Email, ip)
+		return nil, responses.NewCommonErrorByCode(responses.CodeInvalidLoginVerificationCode, "Invalid verification code.")
+	}
+
+	if err := s.confirmationCodes.DeleteConfirmationCode(codeModel); err != nil {
+		s.logger.New("method", "VerifyStepUp").Error("failed to delete verification code", "error", err, "uid", user.UID)
+	}
+
+	return s.completeSignIn(user, tokenOptions)
+}
+
+// VerifyMfa completes sign in by the second factor code (TOTP or recovery code)
+func (s *Auth) VerifyMfa(user *models.User, code string, tokenOptions *TokenOptions, ip string) (*ExtendedTokensResponse, *responses.Error) {
+	if err := s.mfa.Verify(user, code); err != nil {
+		if err == ErrMfaInvalidCode {
+			s.authBlocker.AddUserFailAttempt(user.Email, ip)
+		}
+		return nil, s.mfaError(err, "VerifyMfa")
+	}
+
+	return s.completeSignIn(user, tokenOptions)
+}
+
+// VerifyWebauthnMfa completes sign in by an assertion of a WebAuthn credential of the user
+func (s *Auth) VerifyWebauthnMfa(user *models.User, response *webauthn.AssertionResponse, tokenOptions *TokenOptions, ip string) (*ExtendedTokensResponse, *responses.Error) {
+	if err := s.webauthn.VerifyMfa(user, response); err != nil {
+		if err == ErrWebauthnInvalidResponse {
+			s.authBlocker.AddUserFailAttempt(user.Email, ip)
+		}
+		return nil, s.webauthnError(err, "VerifyWebauthnMfa")
+	}
+
+	return s.completeSignIn(user, tokenOptions)
+}
+
+// FindWebauthnUser verifies an assertion of a discoverable credential and returns its owner.
+// The user must pass sign in checks before tokens are issued by LoginWithWebauthn.
+func (s *Auth) FindWebauthnUser(response *webauthn.AssertionResponse, ip string) (*models.User, *responses.Error) {
+	credential, err := s.webauthn.VerifyLogin(response)
+	if err != nil {
+		if err == ErrWebauthnInvalidResponse {
+			s.authBlocker.AddIPFailAttempt(ip)
+		}
+		return nil, s.webauthnError(err, "FindWebauthnUser")
+	}
+
+	user, err := s.userRepo.FindByUID(credential.UserUID)
+	if err != nil {
+		s.logger.New("method", "FindWebauthnUser").Error("failed to retrieve user", "error", err)
+		return nil, responses.NewCommonErrorByCode(responses.CodeInvalidWebauthnResponse, "Invalid security key response.")
+	}
+	return user, nil
+}
+
+// LoginWithWebauthn issues tokens to the user authenticated by a discoverable credential with user verification,
+// such a credential is phishing-resistant and multi-factor by itself so no further challenge is issued
+func (s *Auth) LoginWithWebauthn(user *models.User, tokenOptions *TokenOptions) (*ExtendedTokensResponse, *responses.Error) {
+	return s.completeSignIn(user, tokenOptions)
+}
+
+// LoginFederated issues tokens to the user authenticated by an external identity provider,
+// the second factor is required the same way as after the password
+func (s *Auth) LoginFederated(user *models.User, tokenOptions *TokenOptions) (*ExtendedTokensResponse, *responses.Error) {
+	challengeName, err := s.mfa.Challenge(user)
+	if err != nil {
+		logger := s.logger.New("method", "LoginFederated")
+		logger.Error("failed to resolve mfa challenge", "error", err)
+		return nil, responses.NewCommonErrorByCode(responses.InternalError, "")
+	}
+	if challengeName != "" {
+		return s.issueMfaChallenge(user, challengeName)
+	}
+
+	return s.completeSignIn(user, tokenOptions)
+}
+
+// FederatedErrorToResponse converts known errors of federated sign in to a response error, it returns nil for unknown errors
+func FederatedErrorToResponse(err error) *responses.Error {
+	switch err {
+	case ErrFederatedInvalidState, ErrFederatedAuthentication:
+		return responses.NewCommonErrorByCode(responses.CodeFederatedLoginFailed, "Sign in with the identity provider failed.")
+	case ErrFederatedEmailNotVerified, ErrFederatedDomainNotAllowed, ErrFederatedNotLinked:
+		return responses.NewCommonErrorByCode(responses.CodeFederatedAccountNotLinked, "Account is not linked to the identity provider.")
+	case ErrFederatedEmailExists:
+		return responses.NewCommonErrorByCode(responses.CodeFederatedEmailExists, "Sign in with the password and link the identity provider to the account.")
+	case ErrUserIdentityExists:
+		return responses.NewCommonErrorByCode(responses.CodeUserIdentityExists, "Identity is already linked.")
+	case ErrUserIdentityNotFound:
+		return responses.NewCommonErrorByCode(responses.UserIdentityNotFound, "Identity is not found.")
+	case ErrIdentityProviderNotFound:
+		return responses.NewCommonErrorByCode(responses.IdentityProviderNotFound, "Identity provider is not found.")
+	case ErrIdentityProviderExists, ErrIdentityProviderInvalidSlug, ErrIdentityProviderInvalidURL,
+		ErrIdentityProviderInvalidRole, ErrIdentityProviderInvalidGroup:
+		return responses.NewCommonErrorByCode(responses.UnprocessableEntity, err.Error())
+	}
+	return nil
+}
+
+func (s *Auth) webauthnError(err error, method string) *responses.Error {
+	if errResp := WebauthnErrorToResponse(err); errResp != nil {
+		return errResp
+	}
+	s.logger.New("method", method).Error("webauthn check failed", "error", err)
+	return responses.NewCommonErrorByCode(responses.InternalError, "")
+}
+
+// WebauthnErrorToResponse converts known WebAuthn errors to a response error, it returns nil for unknown errors
+func WebauthnErrorToResponse(err error) *responses.Error {
+	switch err {
+	case ErrWebauthnInvalidResponse:
+		return responses.NewCommonErrorByCode(responses.CodeInvalidWebauthnResponse, "Invalid security key response.")
+	case ErrWebauthnNoCredentials:
+		return responses.NewCommonErrorByCode(responses.CodeMfaIsNotEnrolled, "Security key is not registered.")
+	case ErrWebauthnCredentialNotFound:
+		return responses.NewCommonErrorByCode(responses.WebauthnCredentialNotFound, "Security key is not found.")
+	case ErrWebauthnCredentialExists:
+		return responses.NewCommonErrorByCode(responses.CodeWebauthnCredentialExists, "Security key is already registered.")
+	}
+	return nil
+}
+
+// ConfirmMfaSetup completes mandatory authenticator setup during sign in, it returns tokens and recovery codes
+func (s *Auth) ConfirmMfaSetup(user *models.User, code string, tokenOptions *TokenOptions) (*MfaSetupResponse, *responses.Error) {
+	recoveryCodes, err := s.mfa.Confirm(user, code)
+	if err != nil {
+		return nil, s.mfaError(err, "ConfirmMfaSetup")
+	}
+
+	tokens, errResp := s.completeSignIn(user, tokenOptions)
+	if errResp != nil {
+		return nil, errResp
+	}
+
+	return &MfaSetupResponse{
+		ExtendedTokensResponse: *tokens,
+		RecoveryCodes:          recoveryCodes,
+	}, nil
+}
+
+func (s *Auth) issueMfaChallenge(user *models.User, challengeName string) (*ExtendedTokensResponse, *responses.Error) {
+	if errResp := s.checkMaintenanceMode(user); errResp != nil {
+		return nil, errResp
+	}
+
+	if errResp := s.checkUserStatus(user); errResp != nil {
+		return nil, errResp
+	}
+
+	mfaToken, err := s.mfaTokens.Issue(user, challengeName)
+	if err != nil {
+		logger := s.logger.New("method", "issueMfaChallenge")
+		logger.Error("failed to generate mfa token", "error", err)
+		return nil, responses.NewCommonErrorByCode(responses.Unauthorized, "")
+	}
+
+	return &ExtendedTokensResponse{
+		ChallengeName: &challengeName,
+		MfaToken:      &mfaToken,
+	}, nil
+}
+
+// issueStepUpChallenge sends a verification code to the email (or to the phone if there is no email) of the user
+func (s *Auth) issueStepUpChallenge(user *models.User, assessment *LoginRiskAssessment, device *DeviceInfo) (*ExtendedTokensResponse, *responses.Error) {
+	logger := s.logger.New("method", "issueStepUpChallenge")
+
+	res, errResp := s.issueMfaChallenge(user, models.ChallengeNameStepUpRequired)
+	if errResp != nil {
+		return nil, errResp
+	}
+
+	s.securityEvents.Record(user.UID, models.SecurityEventRiskySignIn, device, map[string]interface{}{
+		"score":   assessment.Score,
+		"factors": assessment.Factors,
+	})
+
+	codeModel, err := s.confirmationCodes.CreateNewVerificationCode(user, models.ConfirmationCodeSubjectLoginVerification)
+	if err != nil {
+		logger.Error("failed to create verification code", "error", err, "uid", user.UID)
+		return nil, responses.NewCommonErrorByCode(responses.InternalError, "")
+	}
+
+	methods := []string{"email"}
+	if user.Email == "" {
+		methods = []string{"sms"}
+	}
+	if _, err := s.notificationsService.LoginVerification(user.UID, codeModel.Code, methods); err != nil {
+		logger.Error("failed to send verification code", "error", err, "uid", user.UID)
+		return nil, responses.NewCommonErrorByCode(responses.InternalError, "")
+	}
+
+	return res, nil
+}
+
+func (s *Auth) mfaError(err error, method string) *responses.Error {
+	if errResp := MfaErrorToResponse(err); errResp != nil {
+		return errResp
+	}
+	s.logger.New("method", method).Error("mfa check failed", "error", err)
+	return responses.NewCommonErrorByCode(responses.InternalError, "")
+}
+
+// MfaErrorToResponse converts known mfa errors to a response error, it returns nil for unknown errors
+func MfaErrorToResponse(err error) *responses.Error {
+	switch err {
+	case ErrMfaInvalidCode:
+		return responses.NewCommonErrorByCode(responses.CodeInvalidMfaCode, "Invalid authentication code.")
+	case ErrMfaNotEnrolled:
+		return responses.NewCommonErrorByCode(responses.CodeMfaIsNotEnrolled, "Authenticator is not enrolled.")
+	case ErrMfaAlreadyEnabled:
+		return responses.NewCommonErrorByCode(responses.CodeMfaIsAlreadyEnabled, "Authenticator is already enabled.")
+	case ErrMfaIsMandatory:
+		return responses.NewCommonErrorByCode(responses.CodeMfaIsMandatory, "Two-factor authentication cannot be disabled.")
+	}
+	return nil
+}
+
+// AcceptConsents completes sign in which returned the consent challenge,
+// current versions of outdated documents are accepted with the IP of the request.
+// If the documents can not be accepted, e.g. the customization service is down, tokens are issued anyway
+// and the challenge is kept for the next sign in.
+func (s *Auth) AcceptConsents(user *models.User, tokenOptions *TokenOptions, ip string) (*ExtendedTokensResponse, *responses.Error) {
+	if err := s.consents.AcceptOutdated(user, ip); err != nil {
+		s.logger.New("method", "AcceptConsents").Error("failed to accept consents", "error", err, "uid", user.UID)
+	}
+	return s.issueTokens(user, tokenOptions)
+}
+
+// completeSignIn issues tokens to the authenticated user unless the user has the consent challenge,
+// which is set when a document the user accepted gets a new version. Then the challenge is returned without tokens.
+func (s *Auth) completeSignIn(user *models.User, tokenOptions *TokenOptions) (*ExtendedTokensResponse, *responses.Error) {
+	if user.ChallengeName != nil && *user.ChallengeName == models.ChallengeNameConsentRequired {
+		return s.issueMfaChallenge(user, models.ChallengeNameConsentRequired)
+	}
+	return s.issueTokens(user, tokenOptions)
+}
+
 func (s *Auth) issueTokens(user *models.User, tokenOptions *TokenOptions) (*ExtendedTokensResponse, *responses.Error) {
 	if errResp := s.checkMaintenanceMode(user); errResp != nil {
 		return nil, errResp
@@ -77,6 +379,52 @@ func (s *Auth) issueTokens(user *models.User, tokenOptions *TokenOptions) (*Exte
 	}, nil
 }
 
+// requirePasswordChangeIfExpired sets the new password challenge if the password of the user is expired,
+// the user signs in but has to change the password. The challenge is stored, so it is returned
+// after the second factor as well and is cleared by the password change.
+func (s *Auth) requirePasswordChangeIfExpired(user *models.User) *responses.Error {
+	if user.ChallengeName != nil {
+		return nil
+	}
+
+	logger := s.logger.New("method", "requirePasswordChangeIfExpired")
+	expired, err := s.passwordPolicy.Expired(user)
+	if err != nil {
+		logger.Error("failed to check password age", "error", err)
+		return responses.NewCommonErrorByCode(responses.InternalError, "")
+	}
+	if !expired {
+		return nil
+	}
+
+	user.ChallengeName = pointer.ToString(models.ChallengeNameNewPasswordRequired)
+	if err := s.userRepo.UpdateChallengeName(user); err != nil {
+		logger.Error("failed to set new password challenge", "error", err)
+		return responses.NewCommonErrorByCode(responses.InternalError, "")
+	}
+	return nil
+}
+
+// upgradePasswordHash replaces a legacy hash or a hash with outdated parameters while the plain password is known.
+// Sign in goes on if it fails, the old hash stays valid.
+func (s *Auth) upgradePasswordHash(user *models.User, password string) {
+	if !s.passwordService.NeedsRehash(user.Password) {
+		return
+	}
+
+	logger := s.logger.New("method", "upgradePasswordHash")
+	hash, err := s.passwordService.UserHashPassword(password)
+	if err != nil {
+		logger.Error("failed to hash password", "error", err, "uid", user.UID)
+		return
+	}
+	if err := s.userRepo.UpdatePasswordHash(user, hash); err != nil {
+		logger.Error("failed to upgrade password hash", "error", err, "uid", user.UID)
+		return
+	}
+	user.Password = hash
+}
+
 func (s *Auth) checkMaintenanceMode(user *models.User) *responses.Error {
 	if !user.IsRoot() {
 		maintenanceModeSettings, err := s.sysSettings.GetMaintenanceModeSettings()
@@ -12,6 +12,7 @@ import (
 	"github.com/Confialink/wallet-users/internal/http/responses"
 	"github.com/Confialink/wallet-users/internal/services/notifications"
 	"github.com/Confialink/wallet-users/internal/services/syssettings"
+	"github.com/Confialink/wallet-users/pkg/captcha"
 )
 
 type Blocker struct {
@@ -22,11 +23,15 @@ type Blocker struct {
 	loginSettings             *syssettings.LoginSecuritySettings
 	notificationsService      *notifications.Notifications
 	sysSettings               *syssettings.SysSettings
+	captchaVerifier           captcha.Verifier
 }
 
 const (
 	ipBlockingDurationMin   = 10
 	userBlockingDurationMin = 30
+
+	// maxFailedLoginDelay limits the progressive delay between failed attempts
+	maxFailedLoginDelay = 10 * time.Second
 )
 
 func (b *Blocker) CheckIP(ip string) error {
@@ -116,16 +121,80 @@ func (b *Blocker) AddUserFailAttempt(emailOrUsername, ip string) bool {
 	return false
 }
 
+// CheckCaptcha requires a valid CAPTCHA token when there were too many recent failed attempts
+// to sign in as the user or from the IP, user is nil if it is not found
+func (b *Blocker) CheckCaptcha(user *models.User, ip, token string) *responses.Error {
+	settings := b.loginSecuritySettings()
+	if b.captchaVerifier == nil || settings.FailedLoginCaptchaAfter == 0 {
+		return nil
+	}
+
+	if b.recentFailures(user, ip) < settings.FailedLoginCaptchaAfter {
+		return nil
+	}
+
+	return b.verifyCaptcha(token, ip)
+}
+
+// FailedLoginRetryAfter returns how long the client has to wait before the next attempt to sign in as the user
+// or from the IP, the delay after the latest failure doubles with every recent failure. Responses are not held,
+// attempts made too early are rejected and are not counted as failed. User is nil if it is not found.
+func (b *Blocker) FailedLoginRetryAfter(user *models.User, ip string) time.Duration {
+	settings := b.loginSecuritySettings()
+	if settings.FailedLoginDelay <= 0 {
+		return 0
+	}
+
+	latest := b.latestFailure(user, ip)
+	if latest == nil {
+		return 0
+	}
+	return retryAfterFailure(FailedLoginDelay(settings.FailedLoginDelay, b.recentFailures(user, ip)), *latest, time.Now())
+}
+
+// retryAfterFailure returns the rest of the delay which started at the failure
+func retryAfterFailure(delay time.Duration, failedAt, now time.Time) time.Duration {
+	if wait := failedAt.Add(delay).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// FailedLoginDelay returns the delay before the next attempt after the given number of failed attempts
+func FailedLoginDelay(base time.Duration, failures uint64) time.Duration {
+	if base <= 0 || failures == 0 {
+		return 0
+	}
+
+	delay := base
+	for i := uint64(1); i < failures; i++ {
+		delay *= 2
+		if delay >= maxFailedLoginDelay {
+			return maxFailedLoginDelay
+		}
+	}
+
+	if delay > maxFailedLoginDelay {
+		return maxFailedLoginDelay
+	}
+	return delay
+}
+
 func (b *Blocker) ClearAllOldFailAttempts(user *models.User) {
 	b.deleteOldAttempts(user.UID)
 }
 
 func (b *Blocker) LoadSettings() {
-	var err error
-	b.loginSettings, err = b.sysSettings.GetLoginSecuritySettings()
+	settings, err := b.sysSettings.GetLoginSecuritySettings()
 	if err != nil {
 		b.logger.Error("failed fetch login security settings", "error", err)
+		// previously loaded settings are kept, blocking is disabled if settings were never loaded
+		if b.loginSettings == nil {
+			b.loginSettings = &syssettings.LoginSecuritySettings{}
+		}
+		return
 	}
+	b.loginSettings = settings
 }
 
 // return true if block IP
@@ -204,6 +273,65 @@ func (b *Blocker) blockUserIfNeed(user *models.User) bool {
 	return false
 }
 
+func (b *Blocker) verifyCaptcha(token, ip string) *responses.Error {
+	if token == "" {
+		e := responses.NewCommonError().ApplyCode(responses.CodeCaptchaRequired)
+		e.Meta = struct {
+			ChallengeName string `json:"challengeName"`
+		}{models.ChallengeNameCaptchaRequired}
+		e.Details = "Too many failed attempts, please solve the CAPTCHA."
+		return e
+	}
+
+	ok, err := b.captchaVerifier.Verify(token, ip)
+	if err != nil {
+		b.logger.Error("failed to verify captcha", "error", err)
+		return responses.NewCommonErrorByCode(responses.InternalError, "Can not verify CAPTCHA.")
+	}
+
+	if !ok {
+		return responses.NewCommonErrorByCode(responses.CodeInvalidCaptcha, "CAPTCHA is not solved.")
+	}
+
+	return nil
+}
+
+// recentFailures returns the greatest number of failed attempts of the user and from the IP
+// within the windows used for blocking
+func (b *Blocker) recentFailures(user *models.User, ip string) uint64 {
+	var failures uint32
+	if user != nil {
+		failures = b.failAuthAttemptRepository.GetCountByUID(user.UID, b.createUntilTime(-userBlockingDurationMin))
+	}
+
+	if len(ip) > 1 {
+		if byIP := b.failAuthAttemptRepository.GetCountByIp(ip, b.createUntilTime(-ipBlockingDurationMin)); byIP > failures {
+			failures = byIP
+		}
+	}
+
+	return uint64(failures)
+}
+
+// latestFailure returns the time of the latest failed attempt of the user or from the IP
+// within the windows used for blocking
+func (b *Blocker) latestFailure(user *models.User, ip string) *time.Time {
+	var latest *time.Time
+	if user != nil {
+		latest = b.failAuthAttemptRepository.GetLatestByUID(user.UID, b.createUntilTime(-userBlockingDurationMin))
+	}
+
+	if len(ip) > 1 {
+		if byIP := b.failAuthAttemptRepository.GetLatestByIp(ip, b.createUntilTime(-ipBlockingDurationMin)); byIP != nil {
+			if latest == nil || byIP.After(*latest) {
+				latest = byIP
+			}
+		}
+	}
+
+	return latest
+}
+
 func (b Blocker) loginSecuritySettings() *syssettings.LoginSecuritySettings {
 	return b.loginSettings
 }
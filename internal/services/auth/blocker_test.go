@@ -2,6 +2,14 @@ package auth
 
 import (
 	"testing"
+	"time"
+
+	"github.com/inconshreveable/log15"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	"github.com/Confialink/wallet-users/internal/services/syssettings"
+	"github.com/Confialink/wallet-users/pkg/captcha"
 )
 
 func TestCheckIp(t *testing.T) {
@@ -124,3 +132,61 @@ func TestAddUserFailAttempt(t *testing.T) {
 	// createDbSelectQueryMockUser(user)
 	// assert.False(t, service.AddUserFailAttempt(user.Email, ""), "UserFailAttempt must not be added")
 }
+
+func TestFailedLoginDelay(t *testing.T) {
+	base := 250 * time.Millisecond
+
+	assert.Equal(t, time.Duration(0), FailedLoginDelay(base, 0), "there is no delay without failures")
+	assert.Equal(t, time.Duration(0), FailedLoginDelay(0, 5), "delay is disabled")
+	assert.Equal(t, base, FailedLoginDelay(base, 1))
+	assert.Equal(t, 500*time.Millisecond, FailedLoginDelay(base, 2))
+	assert.Equal(t, time.Second, FailedLoginDelay(base, 3))
+	assert.Equal(t, maxFailedLoginDelay, FailedLoginDelay(base, 10), "delay is limited")
+	assert.Equal(t, maxFailedLoginDelay, FailedLoginDelay(base, 1000), "delay must not overflow")
+	assert.Equal(t, maxFailedLoginDelay, FailedLoginDelay(time.Minute, 1), "delay is limited")
+}
+
+func TestRetryAfterFailure(t *testing.T) {
+	failedAt := time.Now()
+
+	assert.Equal(t, time.Second, retryAfterFailure(2*time.Second, failedAt, failedAt.Add(time.Second)))
+	assert.Equal(t, time.Duration(0), retryAfterFailure(2*time.Second, failedAt, failedAt.Add(2*time.Second)), "delay is over")
+	assert.Equal(t, time.Duration(0), retryAfterFailure(0, failedAt, failedAt), "delay is disabled")
+}
+
+func TestCheckCaptcha(t *testing.T) {
+	service := &Blocker{
+		logger:          log15.New(),
+		loginSettings:   &syssettings.LoginSecuritySettings{},
+		captchaVerifier: captcha.NewStub("solved"),
+	}
+
+	/* CAPTCHA is disabled by settings */
+	assert.Nil(t, service.CheckCaptcha(nil, "", ""), "CAPTCHA must not be required")
+
+	/* CAPTCHA is not configured */
+	service.loginSettings.FailedLoginCaptchaAfter = 3
+	service.captchaVerifier = nil
+	assert.Nil(t, service.CheckCaptcha(nil, "", ""), "CAPTCHA must not be required")
+}
+
+func TestVerifyCaptcha(t *testing.T) {
+	service := &Blocker{
+		logger:          log15.New(),
+		loginSettings:   &syssettings.LoginSecuritySettings{FailedLoginCaptchaAfter: 3},
+		captchaVerifier: captcha.NewStub("solved"),
+	}
+
+	e := service.verifyCaptcha("", "172.16.12.13")
+	if assert.NotNil(t, e, "token is required") {
+		assert.Equal(t, responses.CodeCaptchaRequired, e.Code)
+		assert.NotNil(t, e.Meta, "challenge name must be returned")
+	}
+
+	e = service.verifyCaptcha("random", "172.16.12.13")
+	if assert.NotNil(t, e, "token must be rejected") {
+		assert.Equal(t, responses.CodeInvalidCaptcha, e.Code)
+	}
+
+	assert.Nil(t, service.verifyCaptcha("solved", "172.16.12.13"), "token must be accepted")
+}
@@ -1,6 +1,9 @@
 package auth
 
 import (
+	"fmt"
+	"time"
+
 	base "github.com/dgrijalva/jwt-go"
 	"github.com/inconshreveable/log15"
 
@@ -9,67 +12,145 @@ import (
 	"github.com/Confialink/wallet-users/internal/jwt"
 	"github.com/Confialink/wallet-users/internal/services/notifications"
 	"github.com/Confialink/wallet-users/internal/services/syssettings"
+	"github.com/Confialink/wallet-users/internal/services/users"
+	"github.com/Confialink/wallet-users/pkg/captcha"
+	"github.com/Confialink/wallet-users/pkg/geoip"
+	"github.com/Confialink/wallet-users/pkg/webauthn"
 )
 
+// keyRingReloadInterval defines how soon a rotated key is picked up by all instances of the service
+const keyRingReloadInterval = time.Minute
+
 func TokenServiceFactory(
-	configuration *config.Configuration,
+	jwtService jwt.Service,
 	repository *repositories.TokenRepository,
 	resolver TokenTTLResolver,
+	notificationsService *notifications.Notifications,
+	securityEvents *SecurityEvents,
+	revocations *Revocations,
 	logger log15.Logger,
 ) *TokenService {
-	var (
-		jwtService jwt.Service
-		err        error
+	return NewTokenService(
+		jwtService,
+		repository,
+		resolver,
+		notificationsService,
+		securityEvents,
+		revocations,
+		logger,
 	)
-	cfg := configuration.JWT
+}
 
-	switch signingMethod := cfg.SigningMethod.(type) {
-	case *base.SigningMethodHMAC:
-		jwtService = jwt.NewWithHMAC(cfg.Secret, signingMethod)
-	case *base.SigningMethodECDSA:
-		params := &jwt.Params{
-			SigningMethod: signingMethod,
-			SecretKeyPath: cfg.SecretKeyPath,
-			PublicKeyPath: cfg.PublicKeyPath,
-		}
-		jwtService, err = jwt.NewWithECDSA(params)
-	}
-	if err != nil {
-		logger.
-			New("instance", "container", "method", "TemporaryTokensService").
-			Crit("failed to create tokens service", "error", err)
-		panic(err)
-	}
+func TemporaryTokensFactory(jwtService jwt.Service) *TemporaryTokens {
+	return NewTemporaryTokens(jwtService)
+}
 
-	return NewTokenService(jwtService, repository, resolver)
+func MfaTokensFactory(jwtService jwt.Service) *MfaTokens {
+	return NewMfaTokens(jwtService)
 }
 
-func TemporaryTokensFactory(configuration *config.Configuration, logger log15.Logger) *TemporaryTokens {
-	var (
-		jwtService jwt.Service
-		err        error
+func MfaFactory(
+	configuration *config.Configuration,
+	totpRepository *repositories.UserTotpRepository,
+	recoveryCodeRepository *repositories.MfaRecoveryCodeRepository,
+	totp *Totp,
+	webauthn *Webauthn,
+) *Mfa {
+	return NewMfa(totpRepository, recoveryCodeRepository, totp, webauthn, configuration.Mfa.TotpIssuer)
+}
+
+func WebauthnFactory(
+	configuration *config.Configuration,
+	credentialRepository *repositories.WebauthnCredentialRepository,
+	challengeRepository *repositories.WebauthnChallengeRepository,
+	logger log15.Logger,
+) *Webauthn {
+	rp := webauthn.NewRelyingParty(
+		configuration.Mfa.WebauthnRPID,
+		configuration.Mfa.WebauthnRPName,
+		configuration.Mfa.WebauthnOrigins,
 	)
-	cfg := configuration.JWT
+	return NewWebauthn(rp, credentialRepository, challengeRepository, logger)
+}
 
-	switch signingMethod := cfg.SigningMethod.(type) {
-	case *base.SigningMethodHMAC:
-		jwtService = jwt.NewWithHMAC(cfg.Secret, signingMethod)
-	case *base.SigningMethodECDSA:
-		params := &jwt.Params{
-			SigningMethod: signingMethod,
-			SecretKeyPath: cfg.SecretKeyPath,
-			PublicKeyPath: cfg.PublicKeyPath,
-		}
-		jwtService, err = jwt.NewWithECDSA(params)
+func OidcFactory(
+	configuration *config.Configuration,
+	clientRepository *repositories.OAuthClientRepository,
+	codeRepository *repositories.OAuthAuthorizationCodeRepository,
+	tokenRepository *repositories.TokenRepository,
+	usersRepository *repositories.UsersRepository,
+	tokenService *TokenService,
+	jwtService jwt.Service,
+	logger log15.Logger,
+) *Oidc {
+	return NewOidc(
+		configuration.Oidc.Issuer,
+		configuration.Oidc.LoginURL,
+		configuration.JWT.SigningMethod.Alg(),
+		clientRepository,
+		codeRepository,
+		tokenRepository,
+		usersRepository,
+		tokenService,
+		jwtService,
+		logger,
+	)
+}
+
+func FederatedFactory(
+	configuration *config.Configuration,
+	providerRepository *repositories.IdentityProviderRepository,
+	identityRepository *repositories.UserIdentityRepository,
+	stateRepository *repositories.FederatedLoginStateRepository,
+	usersRepository *repositories.UsersRepository,
+	groupsRepository *repositories.UserGroupsRepository,
+	userService *users.UserService,
+	sysSettings *syssettings.SysSettings,
+	logger log15.Logger,
+) *Federated {
+	return NewFederated(
+		configuration.Oidc.FederatedRedirectURL,
+		providerRepository,
+		identityRepository,
+		stateRepository,
+		usersRepository,
+		groupsRepository,
+		userService,
+		sysSettings,
+		logger,
+	)
+}
+
+// KeySetFactory provides public keys of access tokens in order to publish them as JWKS
+func KeySetFactory(jwtService jwt.Service) jwt.KeySet {
+	return jwtService.(jwt.KeySet)
+}
+
+// JwtServiceFactory creates jwt service which uses the key ring of the configured signing method, it panics on failure
+func JwtServiceFactory(configuration *config.Configuration, signingKeys *SigningKeys, logger log15.Logger) jwt.Service {
+	logger = logger.New("instance", "container", "method", "JwtServiceFactory")
+
+	if err := signingKeys.Bootstrap(); err != nil {
+		logger.Crit("failed to import configured signing key", "error", err)
+		panic(err)
 	}
+
+	ring, err := jwt.NewKeyRing(signingKeys, keyRingReloadInterval)
 	if err != nil {
-		logger.
-			New("instance", "container", "method", "TemporaryTokensService").
-			Crit("failed to create tokens service", "error", err)
+		logger.Crit("failed to load signing keys", "error", err)
 		panic(err)
 	}
 
-	return NewTemporaryTokens(jwtService)
+	switch signingMethod := configuration.JWT.SigningMethod.(type) {
+	case *base.SigningMethodHMAC:
+		return jwt.NewWithHMACKeyRing(signingMethod, ring)
+	case *base.SigningMethodECDSA:
+		return jwt.NewWithECDSAKeyRing(signingMethod, ring)
+	}
+
+	err = fmt.Errorf("unsupported signing method %s", configuration.JWT.SigningMethod.Alg())
+	logger.Crit("failed to create jwt service", "error", err)
+	panic(err)
 }
 
 func BlockerFactory(
@@ -79,6 +160,7 @@ func BlockerFactory(
 	logger log15.Logger,
 	notificationsService *notifications.Notifications,
 	sysSettings *syssettings.SysSettings,
+	captchaVerifier captcha.Verifier,
 ) *Blocker {
 	return &Blocker{
 		blockedIpRepository,
@@ -88,5 +170,41 @@ func BlockerFactory(
 		&syssettings.LoginSecuritySettings{},
 		notificationsService,
 		sysSettings,
+		captchaVerifier,
+	}
+}
+
+// CaptchaVerifierFactory creates the verifier of CAPTCHA tokens which are required after failed sign in attempts,
+// it returns nil if CAPTCHA is not configured
+func CaptchaVerifierFactory(configuration *config.Configuration, logger log15.Logger) captcha.Verifier {
+	if configuration.Captcha.Secret != "" {
+		return captcha.NewSiteVerify(configuration.Captcha.VerifyURL, configuration.Captcha.Secret)
+	}
+
+	if configuration.Captcha.StubToken != "" {
+		logger.Warn("CAPTCHA is verified by the stub, do not use it in production")
+		return captcha.NewStub(configuration.Captcha.StubToken)
 	}
+
+	return nil
+}
+
+// LoginRiskFactory creates the risk scoring service, it panics if the GeoIP database cannot be read
+func LoginRiskFactory(
+	configuration *config.Configuration,
+	accessLogRepository *repositories.AccesLogRepository,
+	logger log15.Logger,
+) *LoginRisk {
+	var locator Locator
+	if path := configuration.LoginRisk.GeoIPDatabase; path != "" {
+		db, err := geoip.Open(path)
+		if err != nil {
+			panic("cannot read GeoIP database: " + err.Error())
+		}
+		locator = db
+	} else {
+		logger.Info("GeoIP database is not configured, impossible travel is not detected")
+	}
+
+	return NewLoginRisk(accessLogRepository, locator, configuration.LoginRisk.StepUpScore, logger)
 }
@@ -0,0 +1,623 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inconshreveable/log15"
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/internal/services/syssettings"
+	"github.com/Confialink/wallet-users/internal/services/users"
+	"github.com/Confialink/wallet-users/pkg/oauth"
+	"github.com/Confialink/wallet-users/pkg/oidcclient"
+)
+
+const federatedStateTTL = 10 * time.Minute
+
+// FederatedRoles may be assigned by identity providers, the root role is never assigned
+var FederatedRoles = []string{models.RoleAdmin, models.RoleClient}
+
+var providerSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+var (
+	ErrIdentityProviderNotFound     = errors.New("identity provider is not found")
+	ErrIdentityProviderExists       = errors.New("identity provider with the slug already exists")
+	ErrIdentityProviderInvalidSlug  = errors.New("slug may contain lowercase letters, digits and hyphens only")
+	ErrIdentityProviderInvalidURL   = errors.New("issuer must be an absolute url")
+	ErrIdentityProviderInvalidRole  = errors.New("only admin and client roles may be mapped")
+	ErrIdentityProviderInvalidGroup = errors.New("user group is not found")
+	ErrFederatedInvalidState        = errors.New("federated sign in is expired or invalid")
+	ErrFederatedAuthentication      = errors.New("identity provider authentication failed")
+	ErrFederatedEmailNotVerified    = errors.New("email is not verified by the identity provider")
+	ErrFederatedDomainNotAllowed    = errors.New("email domain is not allowed for the identity provider")
+	ErrFederatedNotLinked           = errors.New("identity is not linked to a user")
+	ErrFederatedEmailExists         = errors.New("user with the email already exists")
+	ErrUserIdentityExists           = errors.New("identity is already linked")
+	ErrUserIdentityNotFound         = errors.New("identity is not found")
+)
+
+// IdentityProviderParams are attributes of an identity provider set by an administrator
+type IdentityProviderParams struct {
+	Slug     string
+	Name     string
+	Issuer   string
+	ClientID string
+	// ClientSecret is kept if it is empty on update
+	ClientSecret          string
+	Scopes                []string
+	Domains               []string
+	RoleClaim             string
+	RoleMapping           map[string]string
+	DefaultRole           string
+	GroupClaim            string
+	GroupMapping          map[string]uint64
+	JitEnabled            bool
+	PasswordLoginDisabled bool
+	IsActive              bool
+}
+
+// federatedClient is a client of the provider built from its attributes at the time of UpdatedAt
+type federatedClient struct {
+	client    *oidcclient.Client
+	updatedAt time.Time
+}
+
+// Federated signs in users with external OpenID Connect providers. An identity of a provider is linked to a user
+// by a signed in user or the user is created on the first sign in if the provider allows just-in-time provisioning.
+// Role and user group of the user are driven by claims of the id token if the provider maps them.
+type Federated struct {
+	redirectURL        string
+	providerRepository *repositories.IdentityProviderRepository
+	identityRepository *repositories.UserIdentityRepository
+	stateRepository    *repositories.FederatedLoginStateRepository
+	usersRepository    *repositories.UsersRepository
+	groupsRepository   *repositories.UserGroupsRepository
+	userService        *users.UserService
+	sysSettings        *syssettings.SysSettings
+	logger             log15.Logger
+
+	mu      sync.Mutex
+	clients map[uint64]*federatedClient
+}
+
+func NewFederated(
+	redirectURL string,
+	providerRepository *repositories.IdentityProviderRepository,
+	identityRepository *repositories.UserIdentityRepository,
+	stateRepository *repositories.FederatedLoginStateRepository,
+	usersRepository *repositories.UsersRepository,
+	groupsRepository *repositories.UserGroupsRepository,
+	userService *users.UserService,
+	sysSettings *syssettings.SysSettings,
+	logger log15.Logger,
+) *Federated {
+	return &Federated{
+		redirectURL:        redirectURL,
+		providerRepository: providerRepository,
+		identityRepository: identityRepository,
+		stateRepository:    stateRepository,
+		usersRepository:    usersRepository,
+		groupsRepository:   groupsRepository,
+		userService:        userService,
+		sysSettings:        sysSettings,
+		logger:             logger.New("Service", "Federated"),
+		clients:            make(map[uint64]*federatedClient),
+	}
+}
+
+// ActiveProviders returns providers users may sign in with
+func (f *Federated) ActiveProviders() ([]*models.IdentityProvider, error) {
+	return f.providerRepository.FindActive()
+}
+
+// PasswordLoginProvider returns the active provider the user of the email must sign in with,
+// it returns nil if password sign in is allowed
+func (f *Federated) PasswordLoginProvider(email string) (*models.IdentityProvider, error) {
+	if email == "" {
+		return nil, nil
+	}
+	providers, err := f.providerRepository.FindActive()
+	if err != nil {
+		return nil, err
+	}
+	for _, provider := range providers {
+		if provider.PasswordLoginDisabled && provider.HasDomain(email) {
+			return provider, nil
+		}
+	}
+	return nil, nil
+}
+
+// Begin starts sign in at the provider and returns the url the user must be redirected to.
+// If the user is passed the identity is linked to the user instead of sign in.
+func (f *Federated) Begin(slug string, user *models.User) (string, error) {
+	provider, err := f.findActiveProvider(slug)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := f.stateRepository.DeleteExpired(); err != nil {
+		f.logger.Error("failed to delete expired federated login states", "error", err)
+	}
+	loginState := &models.FederatedLoginState{
+		StateHash:    hashSecret(state),
+		ProviderID:   provider.ID,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(federatedStateTTL),
+	}
+	if user != nil {
+		loginState.UserUID = user.UID
+	}
+	if err := f.stateRepository.Create(loginState); err != nil {
+		return "", err
+	}
+
+	return f.client(provider).AuthCodeURL(state, nonce, oauth.ComputeCodeChallenge(verifier))
+}
+
+// SignIn completes sign in at the provider and returns the user of the identity.
+// The user is created if the identity is not linked and the provider allows just-in-time provisioning,
+// provisioned is true in this case.
+func (f *Federated) SignIn(state, code string) (user *models.User, provisioned bool, err error) {
+	loginState, provider, claims, err := f.complete(state, code)
+	if err != nil {
+		return nil, false, err
+	}
+	// the state of linking can't be used to sign in
+	if loginState.UserUID != "" {
+		return nil, false, ErrFederatedInvalidState
+	}
+
+	identity, err := f.identityRepository.FindByProviderAndSubject(provider.ID, claims.Subject())
+	if err != nil {
+		if !gorm.IsRecordNotFoundError(err) {
+			return nil, false, err
+		}
+		user, err := f.provision(provider, claims)
+		if err != nil {
+			return nil, false, err
+		}
+		return user, true, nil
+	}
+
+	user, err = f.usersRepository.FindByUID(identity.UserUID)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := f.syncUser(provider, user, claims); err != nil {
+		return nil, false, err
+	}
+	if err := f.identityRepository.UpdateLastLogin(identity, claims.Email(), time.Now()); err != nil {
+		f.logger.Error("failed to update last login of identity", "error", err, "identityId", identity.ID)
+	}
+	return user, false, nil
+}
+
+// Link completes sign in at the provider started by the user and links the identity to the user
+func (f *Federated) Link(user *models.User, state, code string) (*models.UserIdentity, error) {
+	loginState, provider, claims, err := f.complete(state, code)
+	if err != nil {
+		return nil, err
+	}
+	if loginState.UserUID != user.UID {
+		return nil, ErrFederatedInvalidState
+	}
+
+	identity, err := f.identityRepository.FindByProviderAndSubject(provider.ID, claims.Subject())
+	if err == nil {
+		if identity.UserUID != user.UID {
+			return nil, ErrUserIdentityExists
+		}
+		identity.Provider = provider
+		return identity, nil
+	}
+	if !gorm.IsRecordNotFoundError(err) {
+		return nil, err
+	}
+
+	// a user may have one identity of every provider
+	if _, err := f.identityRepository.FindByUserAndProvider(user.UID, provider.ID); err == nil {
+		return nil, ErrUserIdentityExists
+	} else if !gorm.IsRecordNotFoundError(err) {
+		return nil, err
+	}
+
+	identity = &models.UserIdentity{
+		UserUID:    user.UID,
+		ProviderID: provider.ID,
+		Subject:    claims.Subject(),
+		Email:      claims.Email(),
+	}
+	if err := f.identityRepository.Create(identity); err != nil {
+		return nil, err
+	}
+	identity.Provider = provider
+	return identity, nil
+}
+
+// Identities returns identities linked to the user
+func (f *Federated) Identities(user *models.User) ([]*models.UserIdentity, error) {
+	return f.identityRepository.FindByUserUID(user.UID)
+}
+
+// Unlink removes the identity of the user
+func (f *Federated) Unlink(user *models.User, id uint64) error {
+	err := f.identityRepository.DeleteByIDAndUserUID(id, user.UID)
+	if gorm.IsRecordNotFoundError(err) {
+		return ErrUserIdentityNotFound
+	}
+	return err
+}
+
+// Providers returns all configured providers
+func (f *Federated) Providers() ([]*models.IdentityProvider, error) {
+	return f.providerRepository.FindAll()
+}
+
+// CreateProvider adds an identity provider
+func (f *Federated) CreateProvider(params *IdentityProviderParams) (*models.IdentityProvider, error) {
+	provider := &models.IdentityProvider{}
+	if err := f.applyProviderParams(provider, params); err != nil {
+		return nil, err
+	}
+
+	if err := f.providerRepository.Create(provider); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// UpdateProvider changes attributes of the provider
+func (f *Federated) UpdateProvider(id uint64, params *IdentityProviderParams) (*models.IdentityProvider, error) {
+	provider, err := f.findProvider(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.applyProviderParams(provider, params); err != nil {
+		return nil, err
+	}
+
+	if err := f.providerRepository.Update(provider); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+// DeleteProvider removes the provider, identities of the provider are removed along with it
+func (f *Federated) DeleteProvider(id uint64) error {
+	provider, err := f.findProvider(id)
+	if err != nil {
+		return err
+	}
+	if err := f.providerRepository.Delete(provider); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	delete(f.clients, provider.ID)
+	f.mu.Unlock()
+	return nil
+}
+
+// complete consumes the state and verifies the id token received for the code
+func (f *Federated) complete(state, code string) (*models.FederatedLoginState, *models.IdentityProvider, oidcclient.Claims, error) {
+	loginState, err := f.stateRepository.Consume(hashSecret(state))
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, nil, nil, ErrFederatedInvalidState
+		}
+		return nil, nil, nil, err
+	}
+
+	provider, err := f.findProvider(loginState.ProviderID)
+	if err != nil || !provider.IsActive {
+		return nil, nil, nil, ErrFederatedInvalidState
+	}
+
+	logger := f.logger.New("method", "complete", "provider", provider.Slug)
+	client := f.client(provider)
+	tokens, err := client.Exchange(code, loginState.CodeVerifier)
+	if err != nil {
+		logger.Warn("failed to exchange authorization code", "error", err)
+		return nil, nil, nil, ErrFederatedAuthentication
+	}
+	claims, err := client.VerifyIDToken(tokens.IDToken, loginState.Nonce)
+	if err != nil {
+		logger.Warn("failed to verify id token", "error", err)
+		return nil, nil, nil, ErrFederatedAuthentication
+	}
+	return loginState, provider, claims, nil
+}
+
+// provision creates the user of the identity. The email must be verified by the provider and must not be used
+// by another user, since otherwise the provider would be able to take over an existing account.
+func (f *Federated) provision(provider *models.IdentityProvider, claims oidcclient.Claims) (*models.User, error) {
+	if !provider.JitEnabled {
+		return nil, ErrFederatedNotLinked
+	}
+
+	email := claims.Email()
+	if email == "" || !claims.EmailVerified() {
+		return nil, ErrFederatedEmailNotVerified
+	}
+	if len(provider.DomainList()) > 0 && !provider.HasDomain(email) {
+		return nil, ErrFederatedDomainNotAllowed
+	}
+	if _, err := f.usersRepository.FindByEmail(email); err == nil {
+		return nil, ErrFederatedEmailExists
+	} else if !gorm.IsRecordNotFoundError(err) {
+		return nil, err
+	}
+
+	roleName, userGroupID, err := f.mapClaims(provider, claims)
+	if err != nil {
+		return nil, err
+	}
+	classID, err := f.sysSettings.GetDefaultUserClassByRole(roleName)
+	if err != nil {
+		return nil, err
+	}
+	// the user signs in with the provider, the password may be set later by the password recovery.
+	// The unknown password is stored hashed so the password policy is not applied to it
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	password, err := f.userService.HashPassword(token)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Email:            email,
+		Password:         password,
+		FirstName:        claims.String("given_name"),
+		LastName:         claims.String("family_name"),
+		RoleName:         roleName,
+		UserGroupId:      userGroupID,
+		Status:           models.StatusActive,
+		IsEmailConfirmed: true,
+	}
+	user.ClassId = json.Number(*classID)
+	user.LastActedAt = time.Now()
+
+	tx := f.usersRepository.DB.Begin()
+	user, err = f.userService.Create(user, true, false, tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = f.identityRepository.WrapContext(tx).Create(&models.UserIdentity{
+		UserUID:     user.UID,
+		ProviderID:  provider.ID,
+		Subject:     claims.Subject(),
+		Email:       email,
+		LastLoginAt: &user.LastActedAt,
+	})
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	f.logger.Info("user is provisioned by identity provider", "uid", user.UID, "provider", provider.Slug)
+	return user, nil
+}
+
+// syncUser applies role and user group mapped from claims, the root user is never changed by a provider
+func (f *Federated) syncUser(provider *models.IdentityProvider, user *models.User, claims oidcclient.Claims) error {
+	if user.IsRoot() || (provider.RoleClaim == "" && provider.GroupClaim == "") {
+		return nil
+	}
+
+	roleName, userGroupID, err := f.mapClaims(provider, claims)
+	if err != nil {
+		return err
+	}
+	if provider.RoleClaim == "" {
+		roleName = user.RoleName
+	}
+	if provider.GroupClaim == "" {
+		userGroupID = user.UserGroupId
+	}
+
+	tx := f.usersRepository.DB.Begin()
+	if err := f.userService.SetRoleAndGroup(user, roleName, userGroupID, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// mapClaims resolves role and user group by claims. The admin role wins if values of the claim
+// are mapped to several roles, the first mapped value of the group claim is used.
+func (f *Federated) mapClaims(provider *models.IdentityProvider, claims oidcclient.Claims) (string, *uint64, error) {
+	roles, err := provider.RoleMap()
+	if err != nil {
+		return "", nil, err
+	}
+	groups, err := provider.GroupMap()
+	if err != nil {
+		return "", nil, err
+	}
+
+	roleName := provider.DefaultRole
+	if roleName == "" {
+		roleName = models.RoleClient
+	}
+	if provider.RoleClaim != "" {
+		mapped := ""
+		for _, value := range claims.Values(provider.RoleClaim) {
+			if role, ok := roles[value]; ok && (mapped == "" || role == models.RoleAdmin) {
+				mapped = role
+			}
+		}
+		if mapped != "" {
+			roleName = mapped
+		}
+	}
+	if !isFederatedRole(roleName) {
+		return "", nil, ErrIdentityProviderInvalidRole
+	}
+
+	var userGroupID *uint64
+	if provider.GroupClaim != "" {
+		for _, value := range claims.Values(provider.GroupClaim) {
+			if id, ok := groups[value]; ok {
+				userGroupID = &id
+				break
+			}
+		}
+	}
+	return roleName, userGroupID, nil
+}
+
+// client returns the client of the provider, it is rebuilt once the provider is changed
+func (f *Federated) client(provider *models.IdentityProvider) *oidcclient.Client {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cached, ok := f.clients[provider.ID]
+	if !ok || !cached.updatedAt.Equal(provider.UpdatedAt) {
+		cached = &federatedClient{
+			client: oidcclient.New(
+				provider.Issuer,
+				provider.ClientID,
+				provider.ClientSecret,
+				f.redirectURL,
+				provider.ScopeList(),
+			),
+			updatedAt: provider.UpdatedAt,
+		}
+		f.clients[provider.ID] = cached
+	}
+	return cached.client
+}
+
+func (f *Federated) applyProviderParams(provider *models.IdentityProvider, params *IdentityProviderParams) error {
+	if !providerSlugPattern.MatchString(params.Slug) {
+		return ErrIdentityProviderInvalidSlug
+	}
+	if !oauth.IsValidRedirectURI(params.Issuer) {
+		return ErrIdentityProviderInvalidURL
+	}
+	if existing, err := f.providerRepository.FindBySlug(params.Slug); err == nil && existing.ID != provider.ID {
+		return ErrIdentityProviderExists
+	} else if err != nil && !gorm.IsRecordNotFoundError(err) {
+		return err
+	}
+
+	if params.DefaultRole != "" && !isFederatedRole(params.DefaultRole) {
+		return ErrIdentityProviderInvalidRole
+	}
+	for _, role := range params.RoleMapping {
+		if !isFederatedRole(role) {
+			return ErrIdentityProviderInvalidRole
+		}
+	}
+	for _, id := range params.GroupMapping {
+		if _, err := f.groupsRepository.FindById(id); err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				return ErrIdentityProviderInvalidGroup
+			}
+			return err
+		}
+	}
+
+	roleMapping, err := marshalMapping(len(params.RoleMapping), params.RoleMapping)
+	if err != nil {
+		return err
+	}
+	groupMapping, err := marshalMapping(len(params.GroupMapping), params.GroupMapping)
+	if err != nil {
+		return err
+	}
+
+	// the openid scope is required to get an id token
+	scopes := oauth.ParseScope(oauth.ScopeOpenID + " " + strings.Join(params.Scopes, " "))
+
+	provider.Slug = params.Slug
+	provider.Name = strings.TrimSpace(params.Name)
+	provider.Issuer = strings.TrimRight(params.Issuer, "/")
+	provider.ClientID = params.ClientID
+	if params.ClientSecret != "" {
+		provider.ClientSecret = params.ClientSecret
+	}
+	provider.Scopes = strings.Join(scopes, " ")
+	provider.Domains = strings.ToLower(strings.Join(params.Domains, " "))
+	provider.RoleClaim = params.RoleClaim
+	provider.RoleMapping = roleMapping
+	provider.DefaultRole = params.DefaultRole
+	provider.GroupClaim = params.GroupClaim
+	provider.GroupMapping = groupMapping
+	provider.JitEnabled = params.JitEnabled
+	provider.PasswordLoginDisabled = params.PasswordLoginDisabled
+	provider.IsActive = params.IsActive
+	return nil
+}
+
+func (f *Federated) findProvider(id uint64) (*models.IdentityProvider, error) {
+	provider, err := f.providerRepository.FindByID(id)
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, ErrIdentityProviderNotFound
+		}
+		return nil, err
+	}
+	return provider, nil
+}
+
+func (f *Federated) findActiveProvider(slug string) (*models.IdentityProvider, error) {
+	provider, err := f.providerRepository.FindBySlug(slug)
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, ErrIdentityProviderNotFound
+		}
+		return nil, err
+	}
+	if !provider.IsActive {
+		return nil, ErrIdentityProviderNotFound
+	}
+	return provider, nil
+}
+
+// marshalMapping stores an empty mapping as an empty string
+func marshalMapping(size int, mapping interface{}) (string, error) {
+	if size == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(mapping)
+	return string(b), err
+}
+
+func isFederatedRole(roleName string) bool {
+	for _, role := range FederatedRoles {
+		if role == roleName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/pkg/oidcclient"
+)
+
+func TestFederatedMapClaims(t *testing.T) {
+	provider := &models.IdentityProvider{
+		RoleClaim:    "realm_access.roles",
+		RoleMapping:  `{"wallet-admins":"admin","wallet-users":"client"}`,
+		GroupClaim:   "groups",
+		GroupMapping: `{"sales":2,"finance":3}`,
+	}
+	claims := oidcclient.Claims{
+		"realm_access": map[string]interface{}{"roles": []interface{}{"wallet-users", "wallet-admins"}},
+		"groups":       []interface{}{"engineering", "finance", "sales"},
+	}
+
+	roleName, groupID, err := (&Federated{}).mapClaims(provider, claims)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleAdmin, roleName, "admin role wins")
+	require.NotNil(t, groupID)
+	assert.Equal(t, uint64(3), *groupID, "the first mapped group is used")
+
+	roleName, groupID, err = (&Federated{}).mapClaims(provider, oidcclient.Claims{})
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleClient, roleName, "client is the default role")
+	assert.Nil(t, groupID)
+
+	provider.DefaultRole = models.RoleRoot
+	_, _, err = (&Federated{}).mapClaims(provider, oidcclient.Claims{})
+	assert.Equal(t, ErrIdentityProviderInvalidRole, err)
+}
+
+func TestIdentityProviderHasDomain(t *testing.T) {
+	provider := &models.IdentityProvider{Domains: "example.com corp.example.org"}
+
+	assert.True(t, provider.HasDomain("john@Example.com"))
+	assert.True(t, provider.HasDomain("jane@corp.example.org"))
+	assert.False(t, provider.HasDomain("john@sub.example.com"))
+	assert.False(t, provider.HasDomain("john@example.com.evil.io"))
+	assert.False(t, provider.HasDomain("example.com"))
+}
@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"time"
+
+	"github.com/inconshreveable/log15"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/pkg/geoip"
+)
+
+// Risk factors of a sign in
+const (
+	RiskFactorNewDevice        = "new_device"
+	RiskFactorNewSubnet        = "new_subnet"
+	RiskFactorUnusualHour      = "unusual_hour"
+	RiskFactorImpossibleTravel = "impossible_travel"
+)
+
+const (
+	// loginRiskHistorySize is the number of previous sign ins a new one is compared with
+	loginRiskHistorySize = 50
+
+	riskWeightNewDevice        = 40
+	riskWeightNewSubnet        = 20
+	riskWeightUnusualHour      = 10
+	riskWeightImpossibleTravel = 60
+	maxRiskScore               = 100
+
+	// minSignInsForUsualHours is the number of previous sign ins required to know usual hours of the user
+	minSignInsForUsualHours = 5
+	// impossibleTravelSpeedKmh is faster than an airliner
+	impossibleTravelSpeedKmh = 1000
+	// minTravelDistanceKm ignores inaccuracy of GeoIP data
+	minTravelDistanceKm = 500
+)
+
+// LoginRiskAssessment is the risk score of a sign in and the factors it consists of
+type LoginRiskAssessment struct {
+	Score   int
+	Factors []string
+	// FirstSignIn is set if the user never signed in before, there is nothing to compare with
+	FirstSignIn bool
+}
+
+// Has tells if the factor contributes to the score
+func (a *LoginRiskAssessment) Has(factor string) bool {
+	for _, f := range a.Factors {
+		if f == factor {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *LoginRiskAssessment) add(factor string, weight int) {
+	a.Factors = append(a.Factors, factor)
+	a.Score += weight
+	if a.Score > maxRiskScore {
+		a.Score = maxRiskScore
+	}
+}
+
+// Locator finds approximate locations of IP addresses
+type Locator interface {
+	Lookup(ip net.IP) (geoip.Location, bool)
+}
+
+// LoginRisk scores sign ins by comparing them with previous ones of the user from the access log
+type LoginRisk struct {
+	accessLogRepository *repositories.AccesLogRepository
+	// locator is nil if GeoIP database is not configured
+	locator     Locator
+	stepUpScore int
+	logger      log15.Logger
+}
+
+func NewLoginRisk(
+	accessLogRepository *repositories.AccesLogRepository,
+	locator Locator,
+	stepUpScore int,
+	logger log15.Logger,
+) *LoginRisk {
+	return &LoginRisk{
+		accessLogRepository,
+		locator,
+		stepUpScore,
+		logger.New("service", "LoginRisk"),
+	}
+}
+
+// Assess returns the risk score of sign in of the user from the device,
+// the score is 0 if previous sign ins cannot be loaded
+func (r *LoginRisk) Assess(user *models.User, device *DeviceInfo) *LoginRiskAssessment {
+	history, err := r.accessLogRepository.FindRecentByUID(user.UID, loginRiskHistorySize)
+	if err != nil {
+		r.logger.Error("failed to load access log", "error", err, "uid", user.UID)
+		return &LoginRiskAssessment{}
+	}
+
+	var ip string
+	if device != nil {
+		ip = device.IP
+	}
+	return assessLoginRisk(history, DeviceFingerprint(device), ip, time.Now(), r.locate)
+}
+
+// StepUpRequired tells if the sign in has to be confirmed by a code sent to the email or the phone
+func (r *LoginRisk) StepUpRequired(assessment *LoginRiskAssessment) bool {
+	return r.stepUpScore > 0 && assessment.Score >= r.stepUpScore
+}
+
+// Record writes the sign in to the access log
+func (r *LoginRisk) Record(user *models.User, device *DeviceInfo, assessment *LoginRiskAssessment) error {
+	accessLog := &models.AccessLog{
+		UID:               user.UID,
+		DeviceFingerprint: DeviceFingerprint(device),
+		RiskScore:         uint8(assessment.Score),
+	}
+	if device != nil {
+		accessLog.IP = device.IP
+		accessLog.UserAgent = device.UserAgent
+	}
+	return r.accessLogRepository.Create(accessLog)
+}
+
+func (r *LoginRisk) locate(ip string) (geoip.Location, bool) {
+	parsed := net.ParseIP(ip)
+	if r.locator == nil || parsed == nil {
+		return geoip.Location{}, false
+	}
+	return r.locator.Lookup(parsed)
+}
+
+// DeviceFingerprint identifies the device by the id sent by the client or by the user agent,
+// it is empty if neither is known
+func DeviceFingerprint(device *DeviceInfo) string {
+	if device == nil {
+		return ""
+	}
+
+	var source string
+	switch {
+	case device.DeviceID != "":
+		source = "id:" + device.DeviceID
+	case device.UserAgent != "":
+		source = "ua:" + device.UserAgent
+	default:
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:])
+}
+
+// assessLoginRisk compares the sign in with previous ones, history is sorted by time, the newest first
+func assessLoginRisk(
+	history []*models.AccessLog,
+	fingerprint, ip string,
+	at time.Time,
+	locate func(ip string) (geoip.Location, bool),
+) *LoginRiskAssessment {
+	assessment := &LoginRiskAssessment{}
+	if len(history) == 0 {
+		assessment.FirstSignIn = true
+		return assessment
+	}
+
+	if isNewDevice(history, fingerprint) {
+		assessment.add(RiskFactorNewDevice, riskWeightNewDevice)
+	}
+	if isNewSubnet(history, ip) {
+		assessment.add(RiskFactorNewSubnet, riskWeightNewSubnet)
+	}
+	if isUnusualHour(history, at) {
+		assessment.add(RiskFactorUnusualHour, riskWeightUnusualHour)
+	}
+	if isImpossibleTravel(history[0], ip, at, locate) {
+		assessment.add(RiskFactorImpossibleTravel, riskWeightImpossibleTravel)
+	}
+	return assessment
+}
+
+// isNewDevice is false if devices are unknown, e.g. the history was written before fingerprints were recorded
+func isNewDevice(history []*models.AccessLog, fingerprint string) bool {
+	if fingerprint == "" {
+		return false
+	}
+
+	known := false
+	for _, entry := range history {
+		if entry.DeviceFingerprint == fingerprint {
+			return false
+		}
+		known = known || entry.DeviceFingerprint != ""
+	}
+	return known
+}
+
+func isNewSubnet(history []*models.AccessLog, ip string) bool {
+	subnet := ipSubnet(ip)
+	if subnet == "" {
+		return false
+	}
+
+	for _, entry := range history {
+		if ipSubnet(entry.IP) == subnet {
+			return false
+		}
+	}
+	return true
+}
+
+// isUnusualHour is true if the user never signed in within an hour of the time of day (UTC)
+func isUnusualHour(history []*models.AccessLog, at time.Time) bool {
+	if len(history) < minSignInsForUsualHours {
+		return false
+	}
+
+	hour := at.UTC().Hour()
+	for _, entry := range history {
+		diff := hour - entry.CreatedAt.UTC().Hour()
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff <= 1 || diff >= 23 {
+			return false
+		}
+	}
+	return true
+}
+
+// isImpossibleTravel is true if the user could not get from the location of the previous sign in
+func isImpossibleTravel(previous *models.AccessLog, ip string, at time.Time, locate func(ip string) (geoip.Location, bool)) bool {
+	if locate == nil {
+		return false
+	}
+
+	from, ok := locate(previous.IP)
+	if !ok {
+		return false
+	}
+	to, ok := locate(ip)
+	if !ok {
+		return false
+	}
+
+	distance := geoip.Distance(from, to)
+	if distance < minTravelDistanceKm {
+		return false
+	}
+
+	hours := at.Sub(previous.CreatedAt).Hours()
+	return hours <= 0 || distance/hours > impossibleTravelSpeedKmh
+}
+
+// ipSubnet returns /24 network of IPv4 and /48 network of IPv6 addresses
+func ipSubnet(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String()
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/pkg/geoip"
+)
+
+var riskLocations = map[string]geoip.Location{
+	"81.2.69.142":   {Country: "GB", Latitude: 51.5142, Longitude: -0.0931},
+	"81.2.69.160":   {Country: "GB", Latitude: 51.5142, Longitude: -0.0931},
+	"216.160.83.56": {Country: "US", Latitude: 47.6339, Longitude: -122.3478},
+}
+
+func locateRiskIP(ip string) (geoip.Location, bool) {
+	location, ok := riskLocations[ip]
+	return location, ok
+}
+
+func riskHistory(ip, fingerprint string, times ...time.Time) []*models.AccessLog {
+	history := make([]*models.AccessLog, 0, len(times))
+	for _, at := range times {
+		history = append(history, &models.AccessLog{IP: ip, DeviceFingerprint: fingerprint, CreatedAt: at})
+	}
+	return history
+}
+
+func TestAssessLoginRiskFirstSignIn(t *testing.T) {
+	assessment := assessLoginRisk(nil, "device", "81.2.69.142", time.Now(), locateRiskIP)
+
+	assert.True(t, assessment.FirstSignIn)
+	assert.Equal(t, 0, assessment.Score)
+	assert.Empty(t, assessment.Factors)
+}
+
+func TestAssessLoginRiskKnownDevice(t *testing.T) {
+	now := time.Date(2021, 3, 15, 10, 0, 0, 0, time.UTC)
+	history := riskHistory("81.2.69.142", "device", now.Add(-24*time.Hour), now.Add(-48*time.Hour))
+
+	assessment := assessLoginRisk(history, "device", "81.2.69.160", now, locateRiskIP)
+
+	assert.False(t, assessment.FirstSignIn)
+	assert.Equal(t, 0, assessment.Score, "the same device from the same subnet is not risky")
+}
+
+func TestAssessLoginRiskNewDeviceAndSubnet(t *testing.T) {
+	now := time.Date(2021, 3, 15, 10, 0, 0, 0, time.UTC)
+	history := riskHistory("81.2.69.142", "device", now.Add(-24*time.Hour))
+
+	assessment := assessLoginRisk(history, "another device", "10.0.0.1", now, locateRiskIP)
+
+	assert.True(t, assessment.Has(RiskFactorNewDevice))
+	assert.True(t, assessment.Has(RiskFactorNewSubnet))
+	assert.False(t, assessment.Has(RiskFactorImpossibleTravel), "location of the IP is unknown")
+	assert.Equal(t, riskWeightNewDevice+riskWeightNewSubnet, assessment.Score)
+}
+
+func TestAssessLoginRiskUnknownDevices(t *testing.T) {
+	now := time.Date(2021, 3, 15, 10, 0, 0, 0, time.UTC)
+	history := riskHistory("81.2.69.142", "", now.Add(-24*time.Hour))
+
+	assessment := assessLoginRisk(history, "device", "81.2.69.142", now, locateRiskIP)
+	assert.False(t, assessment.Has(RiskFactorNewDevice), "history without fingerprints tells nothing about devices")
+
+	assessment = assessLoginRisk(riskHistory("81.2.69.142", "device", now.Add(-24*time.Hour)), "", "81.2.69.142", now, locateRiskIP)
+	assert.False(t, assessment.Has(RiskFactorNewDevice), "device of the sign in is unknown")
+}
+
+func TestAssessLoginRiskUnusualHour(t *testing.T) {
+	now := time.Date(2021, 3, 15, 10, 0, 0, 0, time.UTC)
+	var times []time.Time
+	for day := 1; day <= minSignInsForUsualHours; day++ {
+		times = append(times, now.Add(-time.Duration(day)*24*time.Hour))
+	}
+	history := riskHistory("81.2.69.142", "device", times...)
+
+	assessment := assessLoginRisk(history, "device", "81.2.69.142", now.Add(time.Hour), locateRiskIP)
+	assert.False(t, assessment.Has(RiskFactorUnusualHour), "an hour later is usual")
+
+	assessment = assessLoginRisk(history, "device", "81.2.69.142", now.Add(14*time.Hour), locateRiskIP)
+	assert.True(t, assessment.Has(RiskFactorUnusualHour))
+	assert.Equal(t, riskWeightUnusualHour, assessment.Score)
+
+	assessment = assessLoginRisk(history[:minSignInsForUsualHours-1], "device", "81.2.69.142", now.Add(14*time.Hour), locateRiskIP)
+	assert.False(t, assessment.Has(RiskFactorUnusualHour), "there are not enough sign ins to know usual hours")
+}
+
+func TestAssessLoginRiskUnusualHourAroundMidnight(t *testing.T) {
+	midnight := time.Date(2021, 3, 15, 0, 0, 0, 0, time.UTC)
+	var times []time.Time
+	for day := 1; day <= minSignInsForUsualHours; day++ {
+		times = append(times, midnight.Add(-time.Duration(day)*24*time.Hour))
+	}
+	history := riskHistory("81.2.69.142", "device", times...)
+
+	assessment := assessLoginRisk(history, "device", "81.2.69.142", midnight.Add(-time.Hour), locateRiskIP)
+	assert.False(t, assessment.Has(RiskFactorUnusualHour), "23:00 is close to 00:00")
+}
+
+func TestAssessLoginRiskImpossibleTravel(t *testing.T) {
+	now := time.Date(2021, 3, 15, 10, 0, 0, 0, time.UTC)
+
+	// London to Seattle is about 7700 km
+	history := riskHistory("81.2.69.142", "device", now.Add(-2*time.Hour))
+	assessment := assessLoginRisk(history, "device", "216.160.83.56", now, locateRiskIP)
+	assert.True(t, assessment.Has(RiskFactorImpossibleTravel))
+	assert.True(t, assessment.Has(RiskFactorNewSubnet))
+	assert.Equal(t, riskWeightImpossibleTravel+riskWeightNewSubnet, assessment.Score)
+
+	history = riskHistory("81.2.69.142", "device", now.Add(-12*time.Hour))
+	assessment = assessLoginRisk(history, "device", "216.160.83.56", now, locateRiskIP)
+	assert.False(t, assessment.Has(RiskFactorImpossibleTravel), "the flight is possible")
+
+	history = riskHistory("81.2.69.142", "device", now.Add(-2*time.Hour))
+	assessment = assessLoginRisk(history, "device", "216.160.83.56", now, nil)
+	assert.False(t, assessment.Has(RiskFactorImpossibleTravel), "GeoIP database is not configured")
+}
+
+func TestAssessLoginRiskScoreIsLimited(t *testing.T) {
+	now := time.Date(2021, 3, 15, 10, 0, 0, 0, time.UTC)
+	var times []time.Time
+	for day := 0; day < minSignInsForUsualHours; day++ {
+		times = append(times, now.Add(-3*time.Hour-time.Duration(day)*24*time.Hour))
+	}
+	history := riskHistory("81.2.69.142", "device", times...)
+
+	assessment := assessLoginRisk(history, "another device", "216.160.83.56", now, locateRiskIP)
+
+	assert.Len(t, assessment.Factors, 4)
+	assert.Equal(t, maxRiskScore, assessment.Score)
+}
+
+func TestStepUpRequired(t *testing.T) {
+	risk := &LoginRisk{stepUpScore: 60}
+	assert.True(t, risk.StepUpRequired(&LoginRiskAssessment{Score: 60}))
+	assert.False(t, risk.StepUpRequired(&LoginRiskAssessment{Score: 59}))
+
+	risk = &LoginRisk{stepUpScore: 0}
+	assert.False(t, risk.StepUpRequired(&LoginRiskAssessment{Score: 100}), "step up is disabled")
+}
+
+func TestDeviceFingerprint(t *testing.T) {
+	assert.Equal(t, "", DeviceFingerprint(nil))
+	assert.Equal(t, "", DeviceFingerprint(&DeviceInfo{IP: "81.2.69.142"}))
+
+	byID := DeviceFingerprint(&DeviceInfo{DeviceID: "device", UserAgent: "Mozilla/5.0"})
+	assert.Len(t, byID, 64)
+	assert.Equal(t, byID, DeviceFingerprint(&DeviceInfo{DeviceID: "device", UserAgent: "curl/7.68.0"}), "device id takes precedence")
+
+	byUserAgent := DeviceFingerprint(&DeviceInfo{UserAgent: "Mozilla/5.0"})
+	assert.NotEqual(t, byID, byUserAgent)
+	assert.NotEqual(t, byUserAgent, DeviceFingerprint(&DeviceInfo{UserAgent: "curl/7.68.0"}))
+}
+
+func TestIpSubnet(t *testing.T) {
+	assert.Equal(t, "81.2.69.0", ipSubnet("81.2.69.142"))
+	assert.Equal(t, "2a00:1450:4001::", ipSubnet("2a00:1450:4001:81c::200e"))
+	assert.Equal(t, "", ipSubnet("not an ip"))
+}
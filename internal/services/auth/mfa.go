@@ -0,0 +1,292 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+)
+
+const (
+	recoveryCodesCount = 10
+	// recoveryCodeBytes is a number of random bytes in a code, the code is shown as hex split into two groups
+	recoveryCodeBytes = 10
+)
+
+var (
+	ErrMfaAlreadyEnabled = errors.New("authenticator is already enabled")
+	ErrMfaNotEnrolled    = errors.New("authenticator is not enrolled")
+	ErrMfaInvalidCode    = errors.New("invalid authentication code")
+	ErrMfaIsMandatory    = errors.New("two-factor authentication is mandatory for the user role")
+)
+
+// TotpEnrollment contains data which is needed to set up an authenticator app
+type TotpEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// Mfa manages the second authentication factor of users
+type Mfa struct {
+	totpRepository         *repositories.UserTotpRepository
+	recoveryCodeRepository *repositories.MfaRecoveryCodeRepository
+	totp                   *Totp
+	webauthn               *Webauthn
+	issuer                 string
+}
+
+func NewMfa(
+	totpRepository *repositories.UserTotpRepository,
+	recoveryCodeRepository *repositories.MfaRecoveryCodeRepository,
+	totp *Totp,
+	webauthn *Webauthn,
+	issuer string,
+) *Mfa {
+	return &Mfa{
+		totpRepository,
+		recoveryCodeRepository,
+		totp,
+		webauthn,
+		issuer,
+	}
+}
+
+// IsRequired checks if the user must use the second factor. Compliance requires it for staff roles.
+func (m *Mfa) IsRequired(user *models.User) bool {
+	return user.IsAdmin() || user.IsRoot()
+}
+
+// IsEnabled checks if the user has a confirmed authenticator
+func (m *Mfa) IsEnabled(user *models.User) (bool, error) {
+	model, err := m.findTotp(user)
+	if err != nil {
+		return false, err
+	}
+	return model != nil && model.IsConfirmed, nil
+}
+
+// Challenge returns a challenge name which must be passed before tokens are issued
+// or an empty string if the user can get tokens right away.
+// Either an authenticator app or a WebAuthn credential may be used as the second factor.
+func (m *Mfa) Challenge(user *models.User) (string, error) {
+	enabled, err := m.IsEnabled(user)
+	if err != nil {
+		return "", err
+	}
+	if !enabled {
+		if enabled, err = m.webauthn.HasCredentials(user); err != nil {
+			return "", err
+		}
+	}
+	if enabled {
+		return models.ChallengeNameMfaRequired, nil
+	}
+	if m.IsRequired(user) {
+		return models.ChallengeNameMfaSetupRequired, nil
+	}
+	return "", nil
+}
+
+// Enroll generates a new secret for the user. The authenticator is not active until it is confirmed.
+func (m *Mfa) Enroll(user *models.User) (*TotpEnrollment, error) {
+	model, err := m.findTotp(user)
+	if err != nil {
+		return nil, err
+	}
+	if model != nil && model.IsConfirmed {
+		return nil, ErrMfaAlreadyEnabled
+	}
+	if model == nil {
+		model = &models.UserTotp{UserUID: user.UID}
+	}
+
+	secret, err := m.totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	model.Secret = secret
+	model.LastUsedStep = 0
+
+	if err := m.totpRepository.Save(model); err != nil {
+		return nil, err
+	}
+
+	return &TotpEnrollment{
+		Secret: secret,
+		URI:    m.totp.ProvisioningURI(m.issuer, accountName(user), secret),
+	}, nil
+}
+
+// Confirm activates enrolled authenticator using the first code and returns new recovery codes
+func (m *Mfa) Confirm(user *models.User, code string) ([]string, error) {
+	model, err := m.findTotp(user)
+	if err != nil {
+		return nil, err
+	}
+	if model == nil {
+		return nil, ErrMfaNotEnrolled
+	}
+	if model.IsConfirmed {
+		return nil, ErrMfaAlreadyEnabled
+	}
+
+	if err := m.checkTotp(model, code); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	model.IsConfirmed = true
+	model.ConfirmedAt = &now
+	if err := m.totpRepository.Save(model); err != nil {
+		return nil, err
+	}
+
+	return m.generateRecoveryCodes(user)
+}
+
+// Verify checks a TOTP code or a recovery code of the user. Every code can be used only once.
+// Recovery codes are accepted whenever any second factor is enrolled, so a user who has only
+// WebAuthn credentials can still get in after losing the security key.
+func (m *Mfa) Verify(user *models.User, code string) error {
+	model, err := m.findTotp(user)
+	if err != nil {
+		return err
+	}
+	totpEnabled := model != nil && model.IsConfirmed
+	if !totpEnabled {
+		hasCredentials, err := m.webauthn.HasCredentials(user)
+		if err != nil {
+			return err
+		}
+		if !hasCredentials {
+			return ErrMfaNotEnrolled
+		}
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totpDigits {
+		if !totpEnabled {
+			return ErrMfaInvalidCode
+		}
+		return m.checkTotp(model, code)
+	}
+
+	used, err := m.recoveryCodeRepository.UseCode(user.UID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrMfaInvalidCode
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes invalidates existing recovery codes and returns new ones
+func (m *Mfa) RegenerateRecoveryCodes(user *models.User, code string) ([]string, error) {
+	if err := m.Verify(user, code); err != nil {
+		return nil, err
+	}
+	return m.generateRecoveryCodes(user)
+}
+
+// RemainingRecoveryCodes returns a number of recovery codes which have not been used yet
+func (m *Mfa) RemainingRecoveryCodes(user *models.User) (int, error) {
+	return m.recoveryCodeRepository.CountUnused(user.UID)
+}
+
+// Disable removes the authenticator and recovery codes of the user
+func (m *Mfa) Disable(user *models.User, code string) error {
+	if m.IsRequired(user) {
+		return ErrMfaIsMandatory
+	}
+	if err := m.Verify(user, code); err != nil {
+		return err
+	}
+	return m.Reset(user)
+}
+
+// Reset removes the authenticator, WebAuthn credentials and recovery codes of the user without any checks
+func (m *Mfa) Reset(user *models.User) error {
+	if err := m.recoveryCodeRepository.DeleteByUserUID(user.UID); err != nil {
+		return err
+	}
+	if err := m.webauthn.Reset(user); err != nil {
+		return err
+	}
+	return m.totpRepository.DeleteByUserUID(user.UID)
+}
+
+func (m *Mfa) findTotp(user *models.User) (*models.UserTotp, error) {
+	model, err := m.totpRepository.FindByUserUID(user.UID)
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return model, nil
+}
+
+// checkTotp validates the code and makes sure that it was not used before
+func (m *Mfa) checkTotp(model *models.UserTotp, code string) error {
+	step, ok := m.totp.Validate(model.Secret, code)
+	if !ok || step <= model.LastUsedStep {
+		return ErrMfaInvalidCode
+	}
+
+	updated, err := m.totpRepository.UpdateLastUsedStep(model, step)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrMfaInvalidCode
+	}
+	model.LastUsedStep = step
+	return nil
+}
+
+func (m *Mfa) generateRecoveryCodes(user *models.User) ([]string, error) {
+	codes := make([]string, 0, recoveryCodesCount)
+	records := make([]*models.MfaRecoveryCode, 0, recoveryCodesCount)
+
+	for i := 0; i < recoveryCodesCount; i++ {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := hex.EncodeToString(b)
+		code := raw[:len(raw)/2] + "-" + raw[len(raw)/2:]
+
+		codes = append(codes, code)
+		records = append(records, &models.MfaRecoveryCode{
+			UserUID:  user.UID,
+			CodeHash: hashRecoveryCode(code),
+		})
+	}
+
+	if err := m.recoveryCodeRepository.ReplaceForUser(user.UID, records); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode normalizes the code and returns its hash, codes are random so a plain hash is enough
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.Replace(strings.TrimSpace(code), "-", "", -1))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func accountName(user *models.User) string {
+	if user.Email != "" {
+		return user.Email
+	}
+	return user.Username
+}
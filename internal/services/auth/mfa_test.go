@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/internal/tests/mocks/helpers"
+)
+
+func newTestMfa() *Mfa {
+	gormMock := helpers.DbMock.GetGormMock()
+	return NewMfa(
+		repositories.NewUserTotpRepository(gormMock),
+		repositories.NewMfaRecoveryCodeRepository(gormMock),
+		NewTotp(),
+		&Webauthn{credentialRepository: repositories.NewWebauthnCredentialRepository(gormMock)},
+		"Wallet",
+	)
+}
+
+func TestMfaVerifyAcceptsRecoveryCodeWithWebauthnOnly(t *testing.T) {
+	dbMock := helpers.DbMock.GetDbMock()
+	mfa := newTestMfa()
+	user := &models.User{UID: "user-uid"}
+
+	dbMock.ExpectQuery("SELECT (.+) FROM `users_totp` WHERE \\(user_uid = \\?\\)").
+		WithArgs("user-uid").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	dbMock.ExpectQuery("SELECT count\\(\\*\\) FROM `users_webauthn_credentials` WHERE \\(user_uid = \\?\\)").
+		WithArgs("user-uid").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("UPDATE `mfa_recovery_codes` SET `used_at` = \\?").
+		WithArgs(sqlmock.AnyArg(), "user-uid", hashRecoveryCode("0a1b2-c3d4e")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+
+	assert.NoError(t, mfa.Verify(user, "0a1b2-c3d4e"))
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestMfaVerifyRejectsTotpCodeWithWebauthnOnly(t *testing.T) {
+	dbMock := helpers.DbMock.GetDbMock()
+	mfa := newTestMfa()
+	user := &models.User{UID: "user-uid"}
+
+	dbMock.ExpectQuery("SELECT (.+) FROM `users_totp` WHERE \\(user_uid = \\?\\)").
+		WithArgs("user-uid").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	dbMock.ExpectQuery("SELECT count\\(\\*\\) FROM `users_webauthn_credentials` WHERE \\(user_uid = \\?\\)").
+		WithArgs("user-uid").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	assert.Equal(t, ErrMfaInvalidCode, mfa.Verify(user, "123456"))
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestMfaVerifyWithoutSecondFactor(t *testing.T) {
+	dbMock := helpers.DbMock.GetDbMock()
+	mfa := newTestMfa()
+	user := &models.User{UID: "user-uid"}
+
+	dbMock.ExpectQuery("SELECT (.+) FROM `users_totp` WHERE \\(user_uid = \\?\\)").
+		WithArgs("user-uid").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	dbMock.ExpectQuery("SELECT count\\(\\*\\) FROM `users_webauthn_credentials` WHERE \\(user_uid = \\?\\)").
+		WithArgs("user-uid").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	assert.Equal(t, ErrMfaNotEnrolled, mfa.Verify(user, "0a1b2-c3d4e"))
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
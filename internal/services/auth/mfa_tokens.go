@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	base "github.com/dgrijalva/jwt-go"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/jwt"
+)
+
+const (
+	claimMfaSub = "mfa"
+	claimMfaExp = 5 * time.Minute
+)
+
+// MfaTokens issues short-lived tokens which prove that the first factor (password) has been passed
+// and allow to complete the pending challenge
+type MfaTokens struct {
+	jwt jwt.Service
+}
+
+func NewMfaTokens(jwt jwt.Service) *MfaTokens {
+	return &MfaTokens{jwt: jwt}
+}
+
+func (t *MfaTokens) Issue(user *models.User, challengeName string) (string, error) {
+	claims := base.MapClaims{
+		"sub":       claimMfaSub,
+		"exp":       time.Now().Add(claimMfaExp).Unix(),
+		"uid":       user.UID,
+		"challenge": challengeName,
+	}
+
+	token := t.jwt.Issue(claims)
+	return t.jwt.Sign(token)
+}
+
+// Verify checks the token and returns user uid and challenge name from it
+func (t *MfaTokens) Verify(signedToken string) (uid string, challengeName string, err error) {
+	token, err := t.jwt.Parse(signedToken)
+	if err != nil {
+		return "", "", err
+	}
+
+	if !token.Valid {
+		return "", "", errors.New("invalid token")
+	}
+
+	claims := token.Claims.(base.MapClaims)
+
+	if claims["sub"] != claimMfaSub {
+		return "", "", errors.New("invalid token subject")
+	}
+
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return "", "", errors.New("token is expired")
+	}
+
+	uid, _ = claims["uid"].(string)
+	challengeName, _ = claims["challenge"].(string)
+	if uid == "" || challengeName == "" {
+		return "", "", errors.New("invalid token claims")
+	}
+
+	return uid, challengeName, nil
+}
@@ -0,0 +1,556 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	base "github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/inconshreveable/log15"
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/internal/jwt"
+	"github.com/Confialink/wallet-users/pkg/oauth"
+)
+
+const (
+	// ScopeWallet allows a client to call the wallet API on behalf of the user,
+	// tokens issued without it are accepted by the userinfo endpoint only
+	ScopeWallet = "wallet"
+
+	oauthCodeTTL = 5 * time.Minute
+	idTokenTTL   = time.Hour
+	// oauthRandomBytes is a size of authorization codes and client secrets
+	oauthRandomBytes = 32
+	// S256 code challenge is base64url encoded sha256 sum
+	codeChallengeLength = 43
+)
+
+// SupportedScopes may be allowed to OAuth clients
+var SupportedScopes = []string{oauth.ScopeOpenID, oauth.ScopeProfile, oauth.ScopeEmail, oauth.ScopePhone, ScopeWallet}
+
+var (
+	ErrOAuthClientNotFound     = errors.New("oauth client is not found")
+	ErrOAuthInvalidRedirectURI = errors.New("redirect uri must be an absolute url without a fragment")
+	ErrOAuthInvalidScope       = errors.New("scope is not supported")
+)
+
+// AuthorizationRequest is a request of the authorization code flow
+type AuthorizationRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type"`
+	ClientID            string `form:"client_id" json:"client_id"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	Nonce               string `form:"nonce" json:"nonce"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+}
+
+// query returns parameters of the request in order to pass them to the login page
+func (r *AuthorizationRequest) query() url.Values {
+	values := url.Values{}
+	params := map[string]string{
+		"response_type":         r.ResponseType,
+		"client_id":             r.ClientID,
+		"redirect_uri":          r.RedirectURI,
+		"scope":                 r.Scope,
+		"state":                 r.State,
+		"nonce":                 r.Nonce,
+		"code_challenge":        r.CodeChallenge,
+		"code_challenge_method": r.CodeChallengeMethod,
+	}
+	for key, value := range params {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	return values
+}
+
+// TokenRequest is a request of the token endpoint, client credentials may be sent
+// in the body or with the basic authorization header
+type TokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// ClientParams are attributes of a client set by an administrator
+type ClientParams struct {
+	Name         string
+	RedirectURIs []string
+	Scopes       []string
+	IsActive     bool
+}
+
+// Oidc is OAuth 2.0 authorization server with OpenID Connect. Users sign in on the login page of the web application
+// which confirms the authorization request with the access token of the user. Clients are registered by administrators,
+// so the user is not asked for consent. Tokens are issued by TokenService and id tokens are signed by jwt.Service.
+type Oidc struct {
+	issuer           string
+	loginURL         string
+	signingAlg       string
+	clientRepository *repositories.OAuthClientRepository
+	codeRepository   *repositories.OAuthAuthorizationCodeRepository
+	tokenRepository  *repositories.TokenRepository
+	usersRepository  *repositories.UsersRepository
+	tokenService     *TokenService
+	jwt              jwt.Service
+	logger           log15.Logger
+}
+
+func NewOidc(
+	issuer string,
+	loginURL string,
+	signingAlg string,
+	clientRepository *repositories.OAuthClientRepository,
+	codeRepository *repositories.OAuthAuthorizationCodeRepository,
+	tokenRepository *repositories.TokenRepository,
+	usersRepository *repositories.UsersRepository,
+	tokenService *TokenService,
+	jwt jwt.Service,
+	logger log15.Logger,
+) *Oidc {
+	return &Oidc{
+		issuer,
+		loginURL,
+		signingAlg,
+		clientRepository,
+		codeRepository,
+		tokenRepository,
+		usersRepository,
+		tokenService,
+		jwt,
+		logger.New("Service", "Oidc"),
+	}
+}
+
+// Metadata returns the discovery document
+func (o *Oidc) Metadata() *oauth.ProviderMetadata {
+	return &oauth.ProviderMetadata{
+		Issuer:                            o.issuer,
+		AuthorizationEndpoint:             o.issuer + "/oauth/authorize",
+		TokenEndpoint:                     o.issuer + "/oauth/token",
+		UserinfoEndpoint:                  o.issuer + "/oauth/userinfo",
+		JwksURI:                           o.issuer + "/.well-known/jwks.json",
+		ScopesSupported:                   SupportedScopes,
+		ResponseTypesSupported:            []string{oauth.ResponseTypeCode},
+		GrantTypesSupported:               []string{oauth.GrantTypeAuthorizationCode, oauth.GrantTypeRefreshToken},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{o.signingAlg},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{oauth.CodeChallengeMethodS256},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce",
+			"name", "given_name", "family_name", "preferred_username", "updated_at",
+			"email", "email_verified", "phone_number", "phone_number_verified",
+		},
+	}
+}
+
+// ValidateAuthorizationRequest checks the request before the user is sent to the login page.
+// If the client or the redirect uri are invalid the error must be shown to the user instead of the redirect,
+// it is reported by false value of redirectable.
+func (o *Oidc) ValidateAuthorizationRequest(req *AuthorizationRequest) (redirectable bool, e *oauth.Error) {
+	client, e := o.findActiveClient(req.ClientID)
+	if e != nil {
+		return false, oauth.NewError(oauth.ErrorInvalidRequest, "client_id is invalid")
+	}
+	if req.RedirectURI == "" || !client.HasRedirectURI(req.RedirectURI) {
+		return false, oauth.NewError(oauth.ErrorInvalidRequest, "redirect_uri is not registered for the client")
+	}
+
+	if req.ResponseType != oauth.ResponseTypeCode {
+		return true, oauth.NewError(oauth.ErrorUnsupportedResponseType, "only code response type is supported")
+	}
+	if req.CodeChallengeMethod != oauth.CodeChallengeMethodS256 {
+		return true, oauth.NewError(oauth.ErrorInvalidRequest, "code_challenge_method must be S256")
+	}
+	if len(req.CodeChallenge) != codeChallengeLength {
+		return true, oauth.NewError(oauth.ErrorInvalidRequest, "code_challenge is invalid")
+	}
+	if !oauth.IsSubset(oauth.ParseScope(req.Scope), client.ScopeList()) {
+		return true, oauth.NewError(oauth.ErrorInvalidScope, "scope is not allowed for the client")
+	}
+	return true, nil
+}
+
+// LoginRedirect returns the url of the login page which receives the authorization request
+func (o *Oidc) LoginRedirect(req *AuthorizationRequest) (string, error) {
+	return oauth.RedirectURL(o.loginURL, req.query())
+}
+
+// ErrorRedirect returns the url which reports the error to the client
+func (o *Oidc) ErrorRedirect(req *AuthorizationRequest, e *oauth.Error) (string, error) {
+	return oauth.RedirectURL(req.RedirectURI, url.Values{
+		"error":             {e.Code},
+		"error_description": {e.Description},
+		"state":             {req.State},
+	})
+}
+
+// Authorize issues the authorization code to the client on behalf of the signed in user
+// and returns the redirect uri of the client with the code
+func (o *Oidc) Authorize(user *models.User, accessToken string, req *AuthorizationRequest) (string, *oauth.Error) {
+	if _, e := o.ValidateAuthorizationRequest(req); e != nil {
+		return "", e
+	}
+
+	// only wallet sessions may approve requests, a client can't authorize another client
+	session, err := o.tokenRepository.FindTokenBySignedString(accessToken)
+	if err != nil || session.ClientID != "" {
+		return "", oauth.NewError(oauth.ErrorAccessDenied, "access token is not allowed to authorize clients")
+	}
+	authTime := time.Now()
+	if session.RefreshToken != nil && session.RefreshToken.FirstSeenAt != nil {
+		authTime = *session.RefreshToken.FirstSeenAt
+	}
+
+	code, err := randomToken()
+	if err != nil {
+		o.logger.Error("failed to generate authorization code", "error", err)
+		return "", oauth.NewError(oauth.ErrorServerError, "")
+	}
+
+	if err := o.codeRepository.DeleteExpired(); err != nil {
+		o.logger.Error("failed to delete expired authorization codes", "error", err)
+	}
+	err = o.codeRepository.Create(&models.OAuthAuthorizationCode{
+		CodeHash:            hashSecret(code),
+		ClientID:            req.ClientID,
+		UserUID:             user.UID,
+		RedirectURI:         req.RedirectURI,
+		Scope:               strings.Join(oauth.ParseScope(req.Scope), " "),
+		Nonce:               req.Nonce,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		AuthTime:            authTime,
+		ExpiresAt:           time.Now().Add(oauthCodeTTL),
+	})
+	if err != nil {
+		o.logger.Error("failed to store authorization code", "error", err)
+		return "", oauth.NewError(oauth.ErrorServerError, "")
+	}
+
+	redirect, err := oauth.RedirectURL(req.RedirectURI, url.Values{"code": {code}, "state": {req.State}})
+	if err != nil {
+		return "", oauth.NewError(oauth.ErrorInvalidRequest, "redirect_uri is invalid")
+	}
+	return redirect, nil
+}
+
+// Exchange handles a request of the token endpoint
+func (o *Oidc) Exchange(req *TokenRequest, device *DeviceInfo) (*oauth.TokenResponse, *oauth.Error) {
+	client, e := o.authenticateClient(req.ClientID, req.ClientSecret)
+	if e != nil {
+		return nil, e
+	}
+
+	switch req.GrantType {
+	case oauth.GrantTypeAuthorizationCode:
+		return o.exchangeCode(client, req, device)
+	case oauth.GrantTypeRefreshToken:
+		return o.exchangeRefreshToken(client, req, device)
+	}
+	return nil, oauth.NewError(oauth.ErrorUnsupportedGrantType, "")
+}
+
+func (o *Oidc) exchangeCode(client *models.OAuthClient, req *TokenRequest, device *DeviceInfo) (*oauth.TokenResponse, *oauth.Error) {
+	code, err := o.codeRepository.Consume(hashSecret(req.Code))
+	if err != nil {
+		if !gorm.IsRecordNotFoundError(err) {
+			o.logger.Error("failed to consume authorization code", "error", err)
+			return nil, oauth.NewError(oauth.ErrorServerError, "")
+		}
+		return nil, oauth.NewError(oauth.ErrorInvalidGrant, "code is invalid or expired")
+	}
+	if code.ClientID != client.ClientID || code.RedirectURI != req.RedirectURI {
+		return nil, oauth.NewError(oauth.ErrorInvalidGrant, "code was issued to another client or redirect_uri")
+	}
+	if !oauth.VerifyCodeChallenge(req.CodeVerifier, code.CodeChallenge, code.CodeChallengeMethod) {
+		return nil, oauth.NewError(oauth.ErrorInvalidGrant, "code_verifier is invalid")
+	}
+
+	user, err := o.usersRepository.FindByUID(code.UserUID)
+	if err != nil || !user.IsActive() {
+		return nil, oauth.NewError(oauth.ErrorInvalidGrant, "user is not active")
+	}
+
+	tokens, err := o.tokenService.IssueTokens(user, &TokenOptions{Device: device, ClientID: client.ClientID, Scope: code.Scope})
+	if err != nil {
+		o.logger.Error("failed to issue tokens", "error", err, "clientId", client.ClientID)
+		return nil, oauth.NewError(oauth.ErrorServerError, "")
+	}
+
+	response := o.tokenResponse(tokens, code.Scope)
+	if oauth.HasScope(code.Scope, oauth.ScopeOpenID) {
+		response.IDToken, err = o.issueIDToken(user, client, code)
+		if err != nil {
+			o.logger.Error("failed to issue id token", "error", err, "clientId", client.ClientID)
+			return nil, oauth.NewError(oauth.ErrorServerError, "")
+		}
+	}
+	return response, nil
+}
+
+func (o *Oidc) exchangeRefreshToken(client *models.OAuthClient, req *TokenRequest, device *DeviceInfo) (*oauth.TokenResponse, *oauth.Error) {
+	tokens, err := o.tokenService.RefreshSession(req.RefreshToken, client.ClientID, &TokenOptions{Device: device})
+	if err != nil {
+		return nil, oauth.NewError(oauth.ErrorInvalidGrant, "refresh_token is invalid")
+	}
+
+	claims, err := o.claims(tokens.Access)
+	if err != nil {
+		o.logger.Error("failed to parse issued access token", "error", err)
+		return nil, oauth.NewError(oauth.ErrorServerError, "")
+	}
+	scope, _ := claims["scope"].(string)
+	return o.tokenResponse(tokens, scope), nil
+}
+
+// UserInfo returns claims of the user the access token was issued for, the token must have openid scope
+func (o *Oidc) UserInfo(accessToken string) (map[string]interface{}, *oauth.Error) {
+	token, err := o.tokenService.VerifyToken(accessToken)
+	if err != nil {
+		return nil, oauth.NewError(oauth.ErrorInvalidToken, "access token is invalid")
+	}
+	claims := token.Claims.(base.MapClaims)
+	scope, _ := claims["scope"].(string)
+	if claims["sub"] != ClaimAccessSub || !oauth.HasScope(scope, oauth.ScopeOpenID) {
+		return nil, oauth.NewError(oauth.ErrorInvalidToken, "access token is not issued for openid scope")
+	}
+
+	uid, _ := claims["uid"].(string)
+	user, err := o.usersRepository.FindByUID(uid)
+	if err != nil {
+		return nil, oauth.NewError(oauth.ErrorInvalidToken, "user is not found")
+	}
+
+	info := userClaims(user, scope)
+	info["sub"] = user.UID
+	return info, nil
+}
+
+// Clients returns registered clients
+func (o *Oidc) Clients() ([]*models.OAuthClient, error) {
+	return o.clientRepository.FindAll()
+}
+
+// CreateClient registers a client, the secret is generated for confidential clients only
+// and it is returned once since it is stored as a hash
+func (o *Oidc) CreateClient(params *ClientParams, confidential bool) (*models.OAuthClient, string, error) {
+	client := &models.OAuthClient{ClientID: uuid.New().String()}
+	if err := applyClientParams(client, params); err != nil {
+		return nil, "", err
+	}
+
+	var secret string
+	if confidential {
+		var err error
+		if secret, err = randomToken(); err != nil {
+			return nil, "", err
+		}
+		client.SecretHash = hashSecret(secret)
+	}
+
+	if err := o.clientRepository.Create(client); err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+// UpdateClient changes attributes of the client, tokens are revoked if the client is deactivated
+func (o *Oidc) UpdateClient(id uint64, params *ClientParams) (*models.OAuthClient, error) {
+	client, err := o.findClient(id)
+	if err != nil {
+		return nil, err
+	}
+	wasActive := client.IsActive
+	if err := applyClientParams(client, params); err != nil {
+		return nil, err
+	}
+
+	if err := o.clientRepository.Update(client); err != nil {
+		return nil, err
+	}
+	if wasActive && !client.IsActive {
+		if err := o.revokeClient(client); err != nil {
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// DeleteClient removes the client along with its codes and tokens
+func (o *Oidc) DeleteClient(id uint64) error {
+	client, err := o.findClient(id)
+	if err != nil {
+		return err
+	}
+	if err := o.revokeClient(client); err != nil {
+		return err
+	}
+	return o.clientRepository.Delete(client)
+}
+
+func (o *Oidc) revokeClient(client *models.OAuthClient) error {
+	if err := o.codeRepository.DeleteByClientID(client.ClientID); err != nil {
+		return err
+	}
+	return o.tokenService.RevokeClientTokens(client.ClientID)
+}
+
+func (o *Oidc) findClient(id uint64) (*models.OAuthClient, error) {
+	client, err := o.clientRepository.FindByID(id)
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, ErrOAuthClientNotFound
+		}
+		return nil, err
+	}
+	return client, nil
+}
+
+func (o *Oidc) findActiveClient(clientID string) (*models.OAuthClient, *oauth.Error) {
+	if clientID == "" {
+		return nil, oauth.NewError(oauth.ErrorInvalidClient, "client_id is required")
+	}
+	client, err := o.clientRepository.FindByClientID(clientID)
+	if err != nil {
+		if !gorm.IsRecordNotFoundError(err) {
+			o.logger.Error("failed to find oauth client", "error", err, "clientId", clientID)
+		}
+		return nil, oauth.NewError(oauth.ErrorInvalidClient, "client is not found")
+	}
+	if !client.IsActive {
+		return nil, oauth.NewError(oauth.ErrorInvalidClient, "client is disabled")
+	}
+	return client, nil
+}
+
+// authenticateClient checks the secret of a confidential client, public clients are bound to the code by PKCE
+func (o *Oidc) authenticateClient(clientID, secret string) (*models.OAuthClient, *oauth.Error) {
+	client, e := o.findActiveClient(clientID)
+	if e != nil {
+		return nil, e
+	}
+	if client.IsConfidential() &&
+		subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(client.SecretHash)) != 1 {
+		return nil, oauth.NewError(oauth.ErrorInvalidClient, "client authentication failed")
+	}
+	return client, nil
+}
+
+func (o *Oidc) tokenResponse(tokens *TokensResponse, scope string) *oauth.TokenResponse {
+	response := &oauth.TokenResponse{
+		AccessToken:  tokens.Access,
+		TokenType:    "Bearer",
+		RefreshToken: tokens.Refresh,
+		Scope:        scope,
+	}
+	if claims, err := o.claims(tokens.Access); err == nil {
+		if exp, ok := claims["exp"].(float64); ok {
+			response.ExpiresIn = int64(exp) - time.Now().Unix()
+		}
+	}
+	return response
+}
+
+func (o *Oidc) claims(signed string) (base.MapClaims, error) {
+	token, err := o.jwt.Parse(signed)
+	if err != nil {
+		return nil, err
+	}
+	return token.Claims.(base.MapClaims), nil
+}
+
+func (o *Oidc) issueIDToken(user *models.User, client *models.OAuthClient, code *models.OAuthAuthorizationCode) (string, error) {
+	now := time.Now()
+	claims := base.MapClaims(userClaims(user, code.Scope))
+	claims["iss"] = o.issuer
+	claims["sub"] = user.UID
+	claims["aud"] = client.ClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(idTokenTTL).Unix()
+	claims["auth_time"] = code.AuthTime.Unix()
+	if code.Nonce != "" {
+		claims["nonce"] = code.Nonce
+	}
+	return o.jwt.Sign(o.jwt.Issue(claims))
+}
+
+// userClaims returns standard claims of the user allowed by the scope
+func userClaims(user *models.User, scope string) map[string]interface{} {
+	claims := map[string]interface{}{}
+	if oauth.HasScope(scope, oauth.ScopeProfile) {
+		claims["name"] = strings.TrimSpace(user.FirstName + " " + user.LastName)
+		claims["given_name"] = user.FirstName
+		claims["family_name"] = user.LastName
+		claims["preferred_username"] = user.Username
+		claims["updated_at"] = user.UpdatedAt.Unix()
+	}
+	if oauth.HasScope(scope, oauth.ScopeEmail) {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.IsEmailConfirmed
+	}
+	if oauth.HasScope(scope, oauth.ScopePhone) {
+		claims["phone_number"] = user.PhoneNumber
+		claims["phone_number_verified"] = user.IsPhoneConfirmed
+	}
+	return claims
+}
+
+// IsClientTokenAllowed checks if the access token may be used to call the wallet API,
+// tokens issued to OAuth clients must have the wallet scope
+func IsClientTokenAllowed(claims base.MapClaims) bool {
+	clientID, _ := claims["client_id"].(string)
+	if clientID == "" {
+		return true
+	}
+	scope, _ := claims["scope"].(string)
+	return oauth.HasScope(scope, ScopeWallet)
+}
+
+func applyClientParams(client *models.OAuthClient, params *ClientParams) error {
+	for _, uri := range params.RedirectURIs {
+		if !oauth.IsValidRedirectURI(uri) || strings.ContainsAny(uri, " \t\n") {
+			return ErrOAuthInvalidRedirectURI
+		}
+	}
+	if !oauth.IsSubset(params.Scopes, SupportedScopes) {
+		return ErrOAuthInvalidScope
+	}
+
+	client.Name = strings.TrimSpace(params.Name)
+	client.RedirectURIs = strings.Join(params.RedirectURIs, " ")
+	client.Scopes = strings.Join(oauth.ParseScope(strings.Join(params.Scopes, " ")), " ")
+	client.IsActive = params.IsActive
+	return nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, oauthRandomBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -3,10 +3,23 @@ package auth
 func Providers() []interface{} {
 	return []interface{}{
 		BlockerFactory,
+		CaptchaVerifierFactory,
 		TokenServiceFactory,
 		NewAutologoutTTLResolver,
 		NewFixedValueTTLResolver,
 		TemporaryTokensFactory,
+		MfaTokensFactory,
+		MfaFactory,
+		WebauthnFactory,
+		OidcFactory,
+		FederatedFactory,
+		NewTotp,
+		NewSecurityEvents,
+		LoginRiskFactory,
+		NewRevocations,
+		KeySetFactory,
+		JwtServiceFactory,
+		NewSigningKeys,
 		NewAuth,
 	}
 }
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/inconshreveable/log15"
+
+	messagebroker "github.com/Confialink/wallet-users/internal/services/message-broker"
+	"github.com/Confialink/wallet-users/pkg/tokenverifier"
+)
+
+// Revocations publishes revoked access tokens to services which validate tokens locally
+type Revocations struct {
+	broker           messagebroker.MessageBroker
+	tokenTTLResolver TokenTTLResolver
+	logger           log15.Logger
+
+	mu sync.Mutex
+	// revokedUsers holds the second of the latest revocation of a user, tokens issued within it are denied
+	revokedUsers map[string]int64
+}
+
+func NewRevocations(broker messagebroker.MessageBroker, tokenTTLResolver TokenTTLResolver, logger log15.Logger) *Revocations {
+	return &Revocations{
+		broker:           broker,
+		tokenTTLResolver: tokenTTLResolver,
+		logger:           logger.New("service", "Revocations"),
+		revokedUsers:     make(map[string]int64),
+	}
+}
+
+// RevokeSession denies all access tokens issued within the session
+func (r *Revocations) RevokeSession(sessionID string) {
+	r.publish(tokenverifier.Revocation{SessionID: sessionID})
+}
+
+// RevokeUser denies all access tokens of the user issued before the current second or within it
+func (r *Revocations) RevokeUser(uid string) {
+	revokedAt := time.Now().Unix()
+
+	r.mu.Lock()
+	for id, second := range r.revokedUsers {
+		if second < revokedAt {
+			delete(r.revokedUsers, id)
+		}
+	}
+	r.revokedUsers[uid] = revokedAt
+	r.mu.Unlock()
+
+	r.publish(tokenverifier.Revocation{UserUID: uid, IssuedBefore: revokedAt})
+}
+
+// WaitIssuable blocks until the second of the latest revocation of the user is over,
+// tokens have the precision of the iat claim, so a token issued within that second would be denied
+func (r *Revocations) WaitIssuable(uid string) {
+	r.mu.Lock()
+	revokedAt, ok := r.revokedUsers[uid]
+	r.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	if wait := time.Until(time.Unix(revokedAt+1, 0)); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// publish sends the revocation, errors are only logged since tokens are already removed from the database
+func (r *Revocations) publish(revocation tokenverifier.Revocation) {
+	revocation.ExpiresAt = time.Now().Add(r.accessTokenTTL()).Unix()
+	if err := r.broker.Publish(tokenverifier.RevocationsSubject, revocation); err != nil {
+		r.logger.Error("failed to publish revocation", "error", err, "revocation", revocation)
+	}
+}
+
+// accessTokenTTL returns for how long a revoked access token may remain valid
+func (r *Revocations) accessTokenTTL() time.Duration {
+	ttl, err := r.tokenTTLResolver.ResolveByTokenSubject(ClaimAccessSub)
+	if err != nil {
+		r.logger.Warn("failed to resolve access token ttl", "error", err)
+		return tokenverifier.MaxRevocationTTL
+	}
+	// the ttl may be changed since the token was issued
+	ttl += time.Minute
+	if ttl > tokenverifier.MaxRevocationTTL {
+		return tokenverifier.MaxRevocationTTL
+	}
+	return ttl
+}
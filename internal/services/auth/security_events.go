@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"encoding/json"
+
+	"github.com/inconshreveable/log15"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+)
+
+// SecurityEvents records suspicious activity related to user accounts
+type SecurityEvents struct {
+	repository *repositories.SecurityEventRepository
+	logger     log15.Logger
+}
+
+func NewSecurityEvents(repository *repositories.SecurityEventRepository, logger log15.Logger) *SecurityEvents {
+	return &SecurityEvents{
+		repository,
+		logger.New("service", "SecurityEvents"),
+	}
+}
+
+// Record stores the event, errors are only logged since the event must not break the caller flow
+func (s *SecurityEvents) Record(uid, eventType string, device *DeviceInfo, details map[string]interface{}) {
+	event := &models.SecurityEvent{
+		UserUID: uid,
+		Type:    eventType,
+		Details: "{}",
+	}
+	if device != nil {
+		event.IP = device.IP
+		event.UserAgent = device.UserAgent
+	}
+	if details != nil {
+		if data, err := json.Marshal(details); err == nil {
+			event.Details = string(data)
+		}
+	}
+
+	s.logger.Warn("security event", "uid", uid, "type", eventType, "details", event.Details)
+	if err := s.repository.Create(event); err != nil {
+		s.logger.Error("failed to record security event", "error", err, "uid", uid, "type", eventType)
+	}
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+// sessionTouchInterval limits how often last usage time of a session is written
+const sessionTouchInterval = 5 * time.Minute
+
+// DeviceInfo describes a client which tokens are issued for
+type DeviceInfo struct {
+	// DeviceID is supplied by the client, it is empty if the client does not send it
+	DeviceID  string
+	UserAgent string
+	IP        string
+}
+
+// newSession builds session metadata for a new refresh token.
+// A rotated refresh token keeps the family, the first seen time and the device of the previous one.
+func newSession(options *TokenOptions) *models.Token {
+	now := time.Now()
+	session := &models.Token{FamilyID: uuid.New().String(), FirstSeenAt: &now, LastUsedAt: &now}
+	if options == nil {
+		return session
+	}
+
+	if prev := options.previousSession; prev != nil {
+		if prev.FamilyID != "" {
+			session.FamilyID = prev.FamilyID
+		}
+		session.DeviceID = prev.DeviceID
+		session.UserAgent = prev.UserAgent
+		session.IP = prev.IP
+		if prev.FirstSeenAt != nil {
+			session.FirstSeenAt = prev.FirstSeenAt
+		}
+		session.ClientID = prev.ClientID
+		session.Scope = prev.Scope
+	}
+
+	if options.ClientID != "" {
+		session.ClientID = options.ClientID
+		session.Scope = options.Scope
+	}
+
+	if device := options.Device; device != nil {
+		if device.DeviceID != "" {
+			session.DeviceID = device.DeviceID
+		}
+		if device.UserAgent != "" {
+			session.UserAgent = device.UserAgent
+		}
+		if device.IP != "" {
+			session.IP = device.IP
+		}
+	}
+
+	return session
+}
+
+// ListSessions returns active sessions (not expired refresh tokens) of the user
+func (t *TokenService) ListSessions(uid string) ([]*models.Token, error) {
+	return t.tokenRepository.FindActiveSessionsByUID(uid, ClaimRefreshSub, time.Now())
+}
+
+// RevokeSession removes the refresh token and all access tokens issued with it
+func (t *TokenService) RevokeSession(uid string, sessionID uint64) error {
+	model, err := t.tokenRepository.FindByIDAndUIDAndSubject(sessionID, uid, ClaimRefreshSub)
+	if err != nil {
+		return err
+	}
+	// access tokens are removed by the foreign key cascade
+	if err := t.tokenRepository.Delete(model); err != nil {
+		return err
+	}
+	t.revokeSessionAccess(uid, model.FamilyID)
+	return nil
+}
+
+// RevokeDeviceSessions removes all tokens of the user issued for the given device
+func (t *TokenService) RevokeDeviceSessions(uid string, deviceID string) error {
+	if deviceID == "" {
+		return errors.New("device id is empty")
+	}
+
+	sessions, err := t.tokenRepository.FindSessionsByUID(uid, ClaimRefreshSub)
+	if err != nil {
+		return err
+	}
+	if err := t.tokenRepository.DeleteByUIDAndDeviceID(uid, deviceID); err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.DeviceID == deviceID {
+			t.revokeSessionAccess(uid, session.FamilyID)
+		}
+	}
+	return nil
+}
+
+// SessionIDByAccessToken returns id of the session which the access token belongs to
+func (t *TokenService) SessionIDByAccessToken(accessToken string) (uint64, error) {
+	model, err := t.tokenRepository.FindTokenBySignedStringAndSubject(accessToken, ClaimAccessSub)
+	if err != nil {
+		return 0, err
+	}
+	if model.RefreshTokenId == nil {
+		return 0, errors.New("access token is not bound to a session")
+	}
+	return *model.RefreshTokenId, nil
+}
+
+// touchSession updates last usage time of the session which the access token belongs to
+func (t *TokenService) touchSession(accessModel *models.Token) {
+	session := accessModel.RefreshToken
+	if accessModel.Subject != ClaimAccessSub || session == nil {
+		return
+	}
+
+	now := time.Now()
+	if session.LastUsedAt != nil && session.LastUsedAt.Add(sessionTouchInterval).After(now) {
+		return
+	}
+	// it is not critical, so the error is ignored
+	_ = t.tokenRepository.UpdateLastUsedAt(session.ID, now)
+}
+
+// DeviceOsType makes a rough guess of the client operating system by the user agent
+func DeviceOsType(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case strings.Contains(ua, "android"):
+		return "android"
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ios"), strings.Contains(ua, "cfnetwork"):
+		return "ios"
+	case strings.Contains(ua, "windows"):
+		return "windows"
+	case strings.Contains(ua, "mac os"), strings.Contains(ua, "macintosh"):
+		return "macos"
+	case strings.Contains(ua, "linux"):
+		return "linux"
+	}
+	return ""
+}
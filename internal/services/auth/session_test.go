@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+func TestNewSession(t *testing.T) {
+	session := newSession(nil)
+	assert.NotNil(t, session.FirstSeenAt)
+	assert.NotNil(t, session.LastUsedAt)
+	assert.Empty(t, session.DeviceID)
+
+	session = newSession(&TokenOptions{Device: &DeviceInfo{DeviceID: "device", UserAgent: "agent", IP: "127.0.0.1"}})
+	assert.Equal(t, "device", session.DeviceID)
+	assert.Equal(t, "agent", session.UserAgent)
+	assert.Equal(t, "127.0.0.1", session.IP)
+}
+
+func TestNewSessionKeepsPreviousSession(t *testing.T) {
+	firstSeenAt := time.Now().Add(-48 * time.Hour)
+	previous := &models.Token{
+		DeviceID:    "device",
+		UserAgent:   "agent",
+		IP:          "127.0.0.1",
+		FirstSeenAt: &firstSeenAt,
+	}
+
+	session := newSession(&TokenOptions{
+		Device:          &DeviceInfo{IP: "10.0.0.1"},
+		previousSession: previous,
+	})
+
+	assert.Equal(t, "device", session.DeviceID)
+	assert.Equal(t, "agent", session.UserAgent)
+	assert.Equal(t, "10.0.0.1", session.IP)
+	assert.Equal(t, firstSeenAt, *session.FirstSeenAt)
+	assert.True(t, session.LastUsedAt.After(firstSeenAt))
+}
+
+func TestDeviceOsType(t *testing.T) {
+	var testTable = []struct {
+		userAgent string
+		osType    string
+	}{
+		{"Mozilla/5.0 (Linux; Android 10; SM-G975F) AppleWebKit/537.36", "android"},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 14_2 like Mac OS X)", "ios"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64)", "windows"},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7)", "macos"},
+		{"Mozilla/5.0 (X11; Linux x86_64)", "linux"},
+		{"curl/7.64.1", ""},
+	}
+
+	for _, v := range testTable {
+		assert.Equal(t, v.osType, DeviceOsType(v.userAgent), v.userAgent)
+	}
+}
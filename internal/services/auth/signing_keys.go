@@ -0,0 +1,312 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	base "github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/inconshreveable/log15"
+
+	"github.com/Confialink/wallet-users/internal/config"
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/internal/jwt"
+)
+
+const (
+	// hmacSecretLength is the length of generated HMAC secrets in bytes
+	hmacSecretLength = 64
+	// encryptedKeyPrefix marks private keys encrypted with the keys encryption key,
+	// keys stored before the encryption was introduced have no prefix and are encrypted on load
+	encryptedKeyPrefix = "aes-gcm:"
+)
+
+// SigningKeys manages the ring of keys tokens are signed with.
+// Keys are stored in the database, so all instances of the service share the ring.
+// Private keys are encrypted by AES-256-GCM with the key from VELMIE_WALLET_USERS_JWT_KEYS_ENCRYPTION_KEY,
+// so a leaked database dump does not allow to sign tokens. Without the key private keys are stored in plain text.
+type SigningKeys struct {
+	repository *repositories.JwtKeyRepository
+	cfg        *config.JwtConfiguration
+	logger     log15.Logger
+}
+
+func NewSigningKeys(repository *repositories.JwtKeyRepository, configuration *config.Configuration, logger log15.Logger) *SigningKeys {
+	s := &SigningKeys{
+		repository,
+		configuration.JWT,
+		logger.New("service", "SigningKeys"),
+	}
+	if !s.encryptionEnabled() {
+		s.logger.Warn("VELMIE_WALLET_USERS_JWT_KEYS_ENCRYPTION_KEY is not set, signing keys are stored in plain text")
+	}
+	return s
+}
+
+// LoadKeys returns current and previous keys of the configured signing method
+func (s *SigningKeys) LoadKeys() ([]*jwt.Key, error) {
+	stored, err := s.repository.FindNotRetiredByAlgorithm(s.cfg.SigningMethod.Alg())
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*jwt.Key, 0, len(stored))
+	for _, model := range stored {
+		s.encryptLegacyKey(model)
+		key, err := s.toKey(model)
+		if err != nil {
+			s.logger.Error("failed to load signing key", "error", err, "kid", model.Kid)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Bootstrap imports the configured key into the ring if the ring is empty,
+// so tokens issued before the ring was introduced remain valid
+func (s *SigningKeys) Bootstrap() error {
+	keys, err := s.repository.FindNotRetiredByAlgorithm(s.cfg.SigningMethod.Alg())
+	if err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		return nil
+	}
+
+	model := &models.JwtKey{Algorithm: s.cfg.SigningMethod.Alg()}
+	switch s.cfg.SigningMethod.(type) {
+	case *base.SigningMethodHMAC:
+		model.PrivateKey = s.cfg.Secret
+		model.Kid = jwt.NewHMACKey(s.cfg.Secret).Kid
+	case *base.SigningMethodECDSA:
+		secretKey, err := ioutil.ReadFile(s.cfg.SecretKeyPath)
+		if err != nil {
+			return err
+		}
+		publicKey, err := ioutil.ReadFile(s.cfg.PublicKeyPath)
+		if err != nil {
+			return err
+		}
+		key, err := jwt.NewECDSAKey(secretKey, publicKey)
+		if err != nil {
+			return err
+		}
+		model.PrivateKey = string(secretKey)
+		model.PublicKey = string(publicKey)
+		model.Kid = key.Kid
+	default:
+		return fmt.Errorf("unsupported signing method %s", s.cfg.SigningMethod.Alg())
+	}
+	if model.PrivateKey, err = s.encrypt(model.PrivateKey, model.Kid); err != nil {
+		return err
+	}
+
+	if err := s.repository.Promote(model); err != nil {
+		// another instance might import the key at the same time
+		if keys, findErr := s.repository.FindNotRetiredByAlgorithm(model.Algorithm); findErr == nil && len(keys) > 0 {
+			return nil
+		}
+		return err
+	}
+	s.logger.Info("configured signing key is imported into the key ring", "kid", model.Kid)
+	return nil
+}
+
+// Rotate generates a new key and makes it current.
+// The previous key keeps verifying issued tokens until it is retired.
+func (s *SigningKeys) Rotate() (*models.JwtKey, error) {
+	model := &models.JwtKey{Algorithm: s.cfg.SigningMethod.Alg()}
+
+	switch method := s.cfg.SigningMethod.(type) {
+	case *base.SigningMethodHMAC:
+		secret := make([]byte, hmacSecretLength)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+		model.PrivateKey = base64.RawURLEncoding.EncodeToString(secret)
+		model.Kid = uuid.New().String()
+	case *base.SigningMethodECDSA:
+		curve, err := curveBySigningMethod(method)
+		if err != nil {
+			return nil, err
+		}
+		privateKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		privateBytes, err := x509.MarshalECPrivateKey(privateKey)
+		if err != nil {
+			return nil, err
+		}
+		publicBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		model.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: privateBytes}))
+		model.PublicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicBytes}))
+		key, err := jwt.NewECDSAKey([]byte(model.PrivateKey), []byte(model.PublicKey))
+		if err != nil {
+			return nil, err
+		}
+		model.Kid = key.Kid
+	default:
+		return nil, fmt.Errorf("unsupported signing method %s", s.cfg.SigningMethod.Alg())
+	}
+	privateKey, err := s.encrypt(model.PrivateKey, model.Kid)
+	if err != nil {
+		return nil, err
+	}
+	model.PrivateKey = privateKey
+
+	if err := s.repository.Promote(model); err != nil {
+		return nil, err
+	}
+	s.logger.Info("signing key is rotated", "kid", model.Kid)
+	return model, nil
+}
+
+// Retire stops accepting tokens signed with keys which were replaced earlier than the given duration ago.
+// The duration must not be less than the lifetime of refresh tokens, otherwise users are logged out.
+func (s *SigningKeys) Retire(replacedBefore time.Duration) ([]*models.JwtKey, error) {
+	keys, err := s.repository.FindPreviousDemotedBefore(s.cfg.SigningMethod.Alg(), time.Now().Add(-replacedBefore))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		if err := s.repository.Retire(key); err != nil {
+			return nil, err
+		}
+		s.logger.Info("signing key is retired", "kid", key.Kid)
+	}
+	return keys, nil
+}
+
+func (s *SigningKeys) toKey(model *models.JwtKey) (*jwt.Key, error) {
+	privateKey, err := s.decrypt(model.PrivateKey, model.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	var key *jwt.Key
+	switch s.cfg.SigningMethod.(type) {
+	case *base.SigningMethodHMAC:
+		key = jwt.NewHMACKey(privateKey)
+	case *base.SigningMethodECDSA:
+		key, err = jwt.NewECDSAKey([]byte(privateKey), []byte(model.PublicKey))
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported signing method %s", model.Algorithm)
+	}
+
+	// the stored id is kept since generated HMAC keys have random ids
+	key.Kid = model.Kid
+	key.Current = model.Status == models.JwtKeyStatusCurrent
+	return key, nil
+}
+
+// encryptLegacyKey encrypts the private key stored in plain text once the encryption key is set.
+// The key stays usable if it can not be saved, the next load tries again.
+func (s *SigningKeys) encryptLegacyKey(model *models.JwtKey) {
+	if !s.encryptionEnabled() || model.PrivateKey == "" || strings.HasPrefix(model.PrivateKey, encryptedKeyPrefix) {
+		return
+	}
+
+	encrypted, err := s.encrypt(model.PrivateKey, model.Kid)
+	if err == nil {
+		err = s.repository.UpdatePrivateKey(model, encrypted)
+	}
+	if err != nil {
+		s.logger.Error("failed to encrypt signing key", "error", err, "kid", model.Kid)
+		return
+	}
+	s.logger.Info("signing key is encrypted", "kid", model.Kid)
+}
+
+// encrypt seals the private key, the key id is authenticated so an encrypted key can not be moved to another row.
+// The key is returned as is if the encryption key is not set.
+func (s *SigningKeys) encrypt(privateKey, kid string) (string, error) {
+	if !s.encryptionEnabled() {
+		return privateKey, nil
+	}
+
+	aead, err := s.aead()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(privateKey), []byte(kid))
+	return encryptedKeyPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens the private key, keys stored in plain text are returned as is
+func (s *SigningKeys) decrypt(stored, kid string) (string, error) {
+	if !strings.HasPrefix(stored, encryptedKeyPrefix) {
+		return stored, nil
+	}
+	if !s.encryptionEnabled() {
+		return "", errors.New("key is encrypted, but VELMIE_WALLET_USERS_JWT_KEYS_ENCRYPTION_KEY is not set")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedKeyPrefix))
+	if err != nil {
+		return "", err
+	}
+	aead, err := s.aead()
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("encrypted key is too short")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(kid))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func (s *SigningKeys) encryptionEnabled() bool {
+	return len(s.cfg.KeysEncryptionKey) > 0
+}
+
+func (s *SigningKeys) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.cfg.KeysEncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func curveBySigningMethod(method *base.SigningMethodECDSA) (elliptic.Curve, error) {
+	switch method.CurveBits {
+	case 256:
+		return elliptic.P256(), nil
+	case 384:
+		return elliptic.P384(), nil
+	case 521:
+		return elliptic.P521(), nil
+	}
+	return nil, errors.New("unsupported curve")
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	base "github.com/dgrijalva/jwt-go"
+	"github.com/inconshreveable/log15"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Confialink/wallet-users/internal/config"
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+func newTestSigningKeys() *SigningKeys {
+	logger := log15.New()
+	logger.SetHandler(log15.DiscardHandler())
+	return &SigningKeys{
+		cfg: &config.JwtConfiguration{
+			SigningMethod:     base.SigningMethodHS256,
+			KeysEncryptionKey: []byte(strings.Repeat("k", 32)),
+		},
+		logger: logger,
+	}
+}
+
+func TestSigningKeysEncryptPrivateKey(t *testing.T) {
+	keys := newTestSigningKeys()
+
+	encrypted, err := keys.encrypt("secret", "kid")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encrypted, encryptedKeyPrefix))
+	assert.NotContains(t, encrypted, "secret")
+
+	key, err := keys.toKey(&models.JwtKey{Kid: "kid", PrivateKey: encrypted, Status: models.JwtKeyStatusCurrent})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret"), key.SignKey)
+	assert.True(t, key.Current)
+}
+
+func TestSigningKeysRejectKeyMovedToAnotherKid(t *testing.T) {
+	keys := newTestSigningKeys()
+
+	encrypted, err := keys.encrypt("secret", "kid")
+	require.NoError(t, err)
+
+	_, err = keys.toKey(&models.JwtKey{Kid: "other-kid", PrivateKey: encrypted})
+	assert.Error(t, err)
+}
+
+func TestSigningKeysRejectKeyEncryptedWithAnotherKey(t *testing.T) {
+	keys := newTestSigningKeys()
+	encrypted, err := keys.encrypt("secret", "kid")
+	require.NoError(t, err)
+
+	keys.cfg.KeysEncryptionKey = []byte(strings.Repeat("x", 32))
+	_, err = keys.toKey(&models.JwtKey{Kid: "kid", PrivateKey: encrypted})
+	assert.Error(t, err)
+}
+
+func TestSigningKeysAcceptLegacyPlainKey(t *testing.T) {
+	keys := newTestSigningKeys()
+
+	key, err := keys.toKey(&models.JwtKey{Kid: "kid", PrivateKey: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret"), key.SignKey)
+}
+
+func TestSigningKeysStorePlainKeyWithoutEncryptionKey(t *testing.T) {
+	keys := newTestSigningKeys()
+	keys.cfg.KeysEncryptionKey = nil
+
+	stored, err := keys.encrypt("secret", "kid")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", stored)
+
+	key, err := keys.toKey(&models.JwtKey{Kid: "kid", PrivateKey: stored})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret"), key.SignKey)
+}
+
+func TestSigningKeysRejectEncryptedKeyWithoutEncryptionKey(t *testing.T) {
+	keys := newTestSigningKeys()
+	encrypted, err := keys.encrypt("secret", "kid")
+	require.NoError(t, err)
+
+	keys.cfg.KeysEncryptionKey = nil
+	_, err = keys.toKey(&models.JwtKey{Kid: "kid", PrivateKey: encrypted})
+	assert.Error(t, err)
+}
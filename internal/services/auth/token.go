@@ -8,7 +8,10 @@ import (
 	"github.com/Confialink/wallet-users/internal/db/models"
 	"github.com/Confialink/wallet-users/internal/db/repositories"
 	"github.com/Confialink/wallet-users/internal/jwt"
+	"github.com/Confialink/wallet-users/internal/services/notifications"
 	base "github.com/dgrijalva/jwt-go"
+	"github.com/inconshreveable/log15"
+	"github.com/jinzhu/gorm"
 )
 
 const (
@@ -26,12 +29,25 @@ type TokensResponse struct {
 type ExtendedTokensResponse struct {
 	TokensResponse
 	ChallengeName *string `json:"challengeName"`
+	// MfaToken is set instead of access and refresh tokens if the second factor is required
+	MfaToken *string `json:"mfaToken,omitempty"`
 }
 
+var (
+	// ErrRefreshTokenReused is returned if a refresh token which has been already rotated is presented again
+	ErrRefreshTokenReused = errors.New("refresh token has been already used")
+	// ErrRefreshTokenClientMismatch is returned if a refresh token is presented by a client it was not issued to
+	ErrRefreshTokenClientMismatch = errors.New("refresh token was issued to another client")
+)
+
 type TokenService struct {
-	jwt              jwt.Service
-	tokenRepository  *repositories.TokenRepository
-	tokenTTLResolver TokenTTLResolver
+	jwt                  jwt.Service
+	tokenRepository      *repositories.TokenRepository
+	tokenTTLResolver     TokenTTLResolver
+	notificationsService *notifications.Notifications
+	securityEvents       *SecurityEvents
+	revocations          *Revocations
+	logger               log15.Logger
 }
 
 // TokenTTLResolver defines time to life for token
@@ -44,13 +60,41 @@ type TokenTTLResolver interface {
 type TokenOptions struct {
 	// TtlResolver overrides default token resolver if set
 	TtlResolver TokenTTLResolver
+	// Device is stored along with the refresh token in order to manage user sessions
+	Device *DeviceInfo
+	// ClientID and Scope are set if tokens are issued to an OAuth client, they are kept on rotation
+	ClientID string
+	Scope    string
+
+	// previousSession is the refresh token which is being rotated
+	previousSession *models.Token
+}
+
+// deviceInfo returns the device of the request, options may be omitted by callers
+func (o *TokenOptions) deviceInfo() *DeviceInfo {
+	if o == nil {
+		return nil
+	}
+	return o.Device
 }
 
-func NewTokenService(jwt jwt.Service, tokenRepository *repositories.TokenRepository, tokenTTLResolver TokenTTLResolver) *TokenService {
+func NewTokenService(
+	jwt jwt.Service,
+	tokenRepository *repositories.TokenRepository,
+	tokenTTLResolver TokenTTLResolver,
+	notificationsService *notifications.Notifications,
+	securityEvents *SecurityEvents,
+	revocations *Revocations,
+	logger log15.Logger,
+) *TokenService {
 	return &TokenService{
-		jwt:              jwt,
-		tokenRepository:  tokenRepository,
-		tokenTTLResolver: tokenTTLResolver,
+		jwt:                  jwt,
+		tokenRepository:      tokenRepository,
+		tokenTTLResolver:     tokenTTLResolver,
+		notificationsService: notificationsService,
+		securityEvents:       securityEvents,
+		revocations:          revocations,
+		logger:               logger.New("service", "TokenService"),
 	}
 }
 
@@ -82,7 +126,7 @@ func (t *TokenService) IssueAccessToken(user *models.User, refreshToken *models.
 	if err != nil {
 		return nil, err
 	}
-	model, err := t.issueToken(user, ClaimAccessSub, ttl, &refreshToken.ID)
+	model, err := t.issueToken(user, ClaimAccessSub, ttl, refreshToken, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -100,7 +144,7 @@ func (t *TokenService) IssueRefreshToken(user *models.User, options *TokenOption
 	if err != nil {
 		return nil, err
 	}
-	model, err := t.issueToken(user, ClaimRefreshSub, ttl, nil)
+	model, err := t.issueToken(user, ClaimRefreshSub, ttl, nil, newSession(options))
 	if err != nil {
 		return nil, err
 	}
@@ -113,6 +157,10 @@ func (t *TokenService) VerifyToken(signedToken string) (*base.Token, error) {
 		return nil, err
 	}
 
+	if model.RevokedAt != nil {
+		return nil, errors.New("token is revoked")
+	}
+
 	token, err := t.jwt.Parse(signedToken)
 	if err != nil {
 		return nil, err
@@ -132,40 +180,163 @@ func (t *TokenService) VerifyToken(signedToken string) (*base.Token, error) {
 		return nil, errors.New("token is expired")
 	}
 
+	t.touchSession(model)
+
 	return token, nil
 }
 
 func (t *TokenService) RefreshTokens(accessToken string, refreshToken string, options *TokenOptions) (*TokensResponse, error) {
-	refreshModel, err := t.tokenRepository.FindTokenBySignedStringAndSubject(refreshToken, ClaimRefreshSub)
+	refreshModel, err := t.findRefreshToken(refreshToken, options)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = t.VerifyToken(refreshToken)
+	accessModel, err := t.tokenRepository.FindTokenBySignedStringAndSubject(accessToken, ClaimAccessSub)
 	if err != nil {
+		_ = t.RevokeToken(refreshToken)
 		return nil, err
 	}
 
-	accessModel, err := t.tokenRepository.FindTokenBySignedStringAndSubject(accessToken, ClaimAccessSub)
+	if accessModel.RefreshTokenId == nil || *accessModel.RefreshTokenId != refreshModel.ID {
+		return nil, errors.New("tokens pair does not math")
+	}
+
+	return t.rotate(refreshModel, options)
+}
+
+// RefreshSession rotates the refresh token issued to the OAuth client without the access token,
+// clients of the token endpoint present the refresh token only
+func (t *TokenService) RefreshSession(refreshToken string, clientID string, options *TokenOptions) (*TokensResponse, error) {
+	refreshModel, err := t.findRefreshToken(refreshToken, options)
 	if err != nil {
-		_ = t.RevokeToken(refreshToken)
 		return nil, err
 	}
 
-	if *accessModel.RefreshTokenId != refreshModel.ID {
-		return nil, errors.New("tokens pair does not math")
+	if refreshModel.ClientID != clientID {
+		return nil, ErrRefreshTokenClientMismatch
 	}
 
+	return t.rotate(refreshModel, options)
+}
+
+// findRefreshToken returns the valid refresh token, the token family is revoked if the token has been already rotated
+func (t *TokenService) findRefreshToken(refreshToken string, options *TokenOptions) (*models.Token, error) {
+	refreshModel, err := t.tokenRepository.FindTokenBySignedStringAndSubject(refreshToken, ClaimRefreshSub)
+	if err != nil {
+		return nil, err
+	}
+
+	if refreshModel.RevokedAt != nil {
+		t.revokeFamily(refreshModel, options.deviceInfo())
+		return nil, ErrRefreshTokenReused
+	}
+
+	if _, err := t.VerifyToken(refreshToken); err != nil {
+		return nil, err
+	}
+	return refreshModel, nil
+}
+
+// rotate replaces the refresh token and its access tokens by a new pair of the same session.
+// The old token is revoked and the new pair is stored in one transaction, so the session is kept
+// if the new pair cannot be issued.
+func (t *TokenService) rotate(refreshModel *models.Token, options *TokenOptions) (*TokensResponse, error) {
 	user := refreshModel.User
 
-	_ = t.RevokeToken(accessToken)
-	_ = t.RevokeToken(refreshToken)
+	// the refresh token is kept as a revoked member of the family in order to detect its reuse.
+	// The token is revoked only if it is still active, so of concurrent requests with the same token
+	// only one rotates it and the others are treated as reuse.
+	tx := t.tokenRepository.DB.Begin()
+	service := t.WrapContext(tx)
+
+	revoked, err := service.tokenRepository.MarkRevoked(refreshModel.ID, time.Now())
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if revoked == 0 {
+		tx.Rollback()
+		t.revokeFamily(refreshModel, options.deviceInfo())
+		return nil, ErrRefreshTokenReused
+	}
+
+	if err := service.tokenRepository.DeleteByRefreshTokenID(refreshModel.ID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	rotateOptions := TokenOptions{}
+	if options != nil {
+		rotateOptions = *options
+	}
+	rotateOptions.previousSession = refreshModel
 
-	return t.IssueTokens(user, options)
+	tokens, err := service.IssueTokens(user, &rotateOptions)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// WrapContext returns a copy of the service which stores tokens with the given transaction
+func (t TokenService) WrapContext(tx *gorm.DB) *TokenService {
+	t.tokenRepository = t.tokenRepository.WrapContext(tx)
+	return &t
+}
+
+// revokeFamily removes all tokens which descend from the same sign in as the reused refresh token
+func (t *TokenService) revokeFamily(reused *models.Token, device *DeviceInfo) {
+	logger := t.logger.New("method", "revokeFamily")
+
+	var err error
+	if reused.FamilyID != "" {
+		err = t.tokenRepository.DeleteByFamilyID(reused.FamilyID)
+	} else {
+		err = t.tokenRepository.Delete(reused)
+	}
+	if err != nil {
+		logger.Error("failed to revoke refresh token family", "error", err, "familyId", reused.FamilyID)
+	}
+	t.revokeSessionAccess(reused.UserUID, reused.FamilyID)
+
+	t.securityEvents.Record(reused.UserUID, models.SecurityEventRefreshTokenReuse, device, map[string]interface{}{
+		"familyId":       reused.FamilyID,
+		"refreshTokenId": reused.ID,
+		"revokedAt":      reused.RevokedAt,
+	})
+
+	if _, err := t.notificationsService.SessionCompromised(reused.UserUID); err != nil {
+		logger.Error("failed to send session compromised notification", "error", err, "uid", reused.UserUID)
+	}
 }
 
 func (t *TokenService) RevokeUserTokens(user *models.User) error {
-	return t.tokenRepository.DeleteTokensByUID(user.UID)
+	if err := t.tokenRepository.DeleteTokensByUID(user.UID); err != nil {
+		return err
+	}
+	t.revocations.RevokeUser(user.UID)
+	return nil
+}
+
+// RevokeClientTokens removes all tokens issued to the OAuth client
+func (t *TokenService) RevokeClientTokens(clientID string) error {
+	sessions, err := t.tokenRepository.FindByClientIDAndSubject(clientID, ClaimRefreshSub)
+	if err != nil {
+		return err
+	}
+	if err := t.tokenRepository.DeleteByClientID(clientID); err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.RevokedAt == nil {
+			t.revokeSessionAccess(session.UserUID, session.FamilyID)
+		}
+	}
+	return nil
 }
 
 func (t *TokenService) RevokeToken(signedToken string) error {
@@ -174,21 +345,42 @@ func (t *TokenService) RevokeToken(signedToken string) error {
 		return err
 	}
 
+	familyID := model.FamilyID
 	if model.RefreshTokenId != nil {
+		if model.RefreshToken != nil {
+			familyID = model.RefreshToken.FamilyID
+		}
 		err = t.tokenRepository.DeleteTokenByID(*model.RefreshTokenId)
 		if err != nil {
 			return err
 		}
 	}
 
-	return t.tokenRepository.Delete(model)
+	if err := t.tokenRepository.Delete(model); err != nil {
+		return err
+	}
+	t.revokeSessionAccess(model.UserUID, familyID)
+	return nil
+}
+
+// revokeSessionAccess denies access tokens of the session for services which validate tokens locally.
+// Tokens issued before sessions got families are not bound to a session, so all user tokens are denied.
+func (t *TokenService) revokeSessionAccess(uid, familyID string) {
+	if familyID == "" {
+		t.revocations.RevokeUser(uid)
+		return
+	}
+	t.revocations.RevokeSession(familyID)
 }
 
-func (t *TokenService) issueToken(user *models.User, subject string, expire time.Duration, refreshId *uint64) (*models.Token, error) {
-	exp := time.Now().Add(expire)
+func (t *TokenService) issueToken(user *models.User, subject string, expire time.Duration, refreshToken *models.Token, session *models.Token) (*models.Token, error) {
+	t.revocations.WaitIssuable(user.UID)
+	now := time.Now()
+	exp := now.Add(expire)
 
 	claims := base.MapClaims{
 		"sub":       subject,
+		"iat":       now.Unix(),
 		"exp":       exp.Unix(),
 		"uid":       user.UID,
 		"roleName":  user.RoleName,
@@ -198,6 +390,20 @@ func (t *TokenService) issueToken(user *models.User, subject string, expire time
 		"lastName":  user.LastName,
 	}
 
+	var refreshId *uint64
+	if refreshToken != nil {
+		refreshId = &refreshToken.ID
+		// the session id allows to revoke access tokens of the session without a database lookup
+		if refreshToken.FamilyID != "" {
+			claims["sid"] = refreshToken.FamilyID
+		}
+		// the wallet API rejects tokens of OAuth clients unless the scope allows it
+		if refreshToken.ClientID != "" {
+			claims["client_id"] = refreshToken.ClientID
+			claims["scope"] = refreshToken.Scope
+		}
+	}
+
 	token := t.jwt.Issue(claims)
 	jwtSigned, err := t.jwt.Sign(token)
 	if err != nil {
@@ -209,6 +415,21 @@ func (t *TokenService) issueToken(user *models.User, subject string, expire time
 		SignedString:   jwtSigned,
 		UserUID:        user.UID,
 		RefreshTokenId: refreshId,
+		ExpiresAt:      &exp,
+	}
+	if refreshToken != nil {
+		model.ClientID = refreshToken.ClientID
+		model.Scope = refreshToken.Scope
+	}
+	if session != nil {
+		model.FamilyID = session.FamilyID
+		model.DeviceID = session.DeviceID
+		model.UserAgent = session.UserAgent
+		model.IP = session.IP
+		model.FirstSeenAt = session.FirstSeenAt
+		model.LastUsedAt = session.LastUsedAt
+		model.ClientID = session.ClientID
+		model.Scope = session.Scope
 	}
 
 	created, err := t.tokenRepository.Create(model)
@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/Confialink/wallet-notifications/rpc/proto/notifications"
+	"github.com/DATA-DOG/go-sqlmock"
+	base "github.com/dgrijalva/jwt-go"
+	"github.com/inconshreveable/log15"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	jwtMocks "github.com/Confialink/wallet-users/internal/jwt/mocks"
+	messageBrokerMocks "github.com/Confialink/wallet-users/internal/services/message-broker/mocks"
+	"github.com/Confialink/wallet-users/internal/services/notifications"
+	notificationsMocks "github.com/Confialink/wallet-users/internal/services/notifications/mocks"
+	"github.com/Confialink/wallet-users/internal/tests/mocks/helpers"
+	notificationsHandlerMock "github.com/Confialink/wallet-users/internal/tests/mocks/vendor-mocks/rpc/notifications"
+	"github.com/Confialink/wallet-users/pkg/tokenverifier"
+)
+
+func TestRefreshTokensRevokesFamilyOnReuse(t *testing.T) {
+	gormMock := helpers.DbMock.GetGormMock()
+	dbMock := helpers.DbMock.GetDbMock()
+
+	logger := log15.New()
+	logger.SetHandler(log15.DiscardHandler())
+
+	client := &notificationsHandlerMock.NotificationHandler{}
+	client.On("Dispatch", mock.Anything, mock.MatchedBy(func(req *pb.Request) bool {
+		return req.To == "user-uid" && req.EventName == eventNameSessionCompromised
+	})).Return(&pb.Response{}, nil).Once()
+	clientFactory := &notificationsMocks.ClientFactory{}
+	clientFactory.On("NewClient").Return(client, nil)
+
+	broker := &messageBrokerMocks.MessageBroker{}
+	broker.On("Publish", tokenverifier.RevocationsSubject, mock.MatchedBy(func(r tokenverifier.Revocation) bool {
+		return r.SessionID == "family-id" && r.ExpiresAt > time.Now().Unix()
+	})).Return(nil).Once()
+	ttlResolver := NewFixedValueTTLResolver(ClaimRefreshTokenExp, ClaimAccessTokenExp)
+
+	service := NewTokenService(
+		&jwtMocks.Service{},
+		repositories.NewTokenRepository(gormMock),
+		ttlResolver,
+		notifications.NewNotifications(clientFactory),
+		NewSecurityEvents(repositories.NewSecurityEventRepository(gormMock), logger),
+		NewRevocations(broker, ttlResolver, logger),
+		logger,
+	)
+
+	revokedAt := time.Now().Add(-time.Hour)
+	rows := sqlmock.NewRows([]string{"id", "subject", "signed_string", "user_uid", "family_id", "revoked_at"}).
+		AddRow(10, ClaimRefreshSub, "reused-refresh", "user-uid", "family-id", revokedAt)
+	dbMock.ExpectQuery("SELECT (.+) FROM `tokens` WHERE \\(signed_string = \\? AND subject = \\?\\)").
+		WithArgs("reused-refresh", ClaimRefreshSub).
+		WillReturnRows(rows)
+	dbMock.ExpectQuery("SELECT (.+) FROM `users`").
+		WillReturnRows(sqlmock.NewRows([]string{"uid"}).AddRow("user-uid"))
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("DELETE FROM `tokens` WHERE \\(family_id = \\?\\)").
+		WithArgs("family-id").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	dbMock.ExpectCommit()
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("INSERT INTO `security_events`").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	dbMock.ExpectCommit()
+
+	options := &TokenOptions{Device: &DeviceInfo{IP: "10.0.0.1", UserAgent: "agent"}}
+	tokens, err := service.RefreshTokens("access", "reused-refresh", options)
+
+	assert.Nil(t, tokens)
+	assert.Equal(t, ErrRefreshTokenReused, err)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+	client.AssertExpectations(t)
+	broker.AssertExpectations(t)
+}
+
+func TestRefreshSessionRevokesFamilyOnConcurrentRotation(t *testing.T) {
+	gormMock := helpers.DbMock.GetGormMock()
+	dbMock := helpers.DbMock.GetDbMock()
+
+	logger := log15.New()
+	logger.SetHandler(log15.DiscardHandler())
+
+	client := &notificationsHandlerMock.NotificationHandler{}
+	client.On("Dispatch", mock.Anything, mock.MatchedBy(func(req *pb.Request) bool {
+		return req.To == "user-uid" && req.EventName == eventNameSessionCompromised
+	})).Return(&pb.Response{}, nil).Once()
+	clientFactory := &notificationsMocks.ClientFactory{}
+	clientFactory.On("NewClient").Return(client, nil)
+
+	broker := &messageBrokerMocks.MessageBroker{}
+	broker.On("Publish", tokenverifier.RevocationsSubject, mock.Anything).Return(nil).Once()
+	ttlResolver := NewFixedValueTTLResolver(ClaimRefreshTokenExp, ClaimAccessTokenExp)
+
+	jwtService := &jwtMocks.Service{}
+	jwtService.On("Parse", "refresh").Return(&base.Token{
+		Valid:  true,
+		Claims: base.MapClaims{"sub": ClaimRefreshSub, "exp": float64(time.Now().Add(time.Hour).Unix())},
+	}, nil)
+
+	service := NewTokenService(
+		jwtService,
+		repositories.NewTokenRepository(gormMock),
+		ttlResolver,
+		notifications.NewNotifications(clientFactory),
+		NewSecurityEvents(repositories.NewSecurityEventRepository(gormMock), logger),
+		NewRevocations(broker, ttlResolver, logger),
+		logger,
+	)
+
+	tokenRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "subject", "signed_string", "user_uid", "family_id"}).
+			AddRow(10, ClaimRefreshSub, "refresh", "user-uid", "family-id")
+	}
+	dbMock.ExpectQuery("SELECT (.+) FROM `tokens` WHERE \\(signed_string = \\? AND subject = \\?\\)").
+		WithArgs("refresh", ClaimRefreshSub).
+		WillReturnRows(tokenRows())
+	dbMock.ExpectQuery("SELECT (.+) FROM `users`").
+		WillReturnRows(sqlmock.NewRows([]string{"uid"}).AddRow("user-uid"))
+	dbMock.ExpectQuery("SELECT (.+) FROM `tokens` WHERE \\(signed_string = \\?\\)").
+		WithArgs("refresh").
+		WillReturnRows(tokenRows())
+	dbMock.ExpectQuery("SELECT (.+) FROM `users`").
+		WillReturnRows(sqlmock.NewRows([]string{"uid"}).AddRow("user-uid"))
+
+	// another request has rotated the token after it was read
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("UPDATE `tokens` SET `revoked_at` = \\? WHERE \\(id = \\? AND revoked_at IS NULL\\)").
+		WithArgs(sqlmock.AnyArg(), 10).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	dbMock.ExpectRollback()
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("DELETE FROM `tokens` WHERE \\(family_id = \\?\\)").
+		WithArgs("family-id").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	dbMock.ExpectCommit()
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("INSERT INTO `security_events`").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	dbMock.ExpectCommit()
+
+	tokens, err := service.RefreshSession("refresh", "", nil)
+
+	assert.Nil(t, tokens)
+	assert.Equal(t, ErrRefreshTokenReused, err)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+	client.AssertExpectations(t)
+	broker.AssertExpectations(t)
+}
+
+func TestRefreshSessionKeepsTokenWhenNewPairIsNotIssued(t *testing.T) {
+	gormMock := helpers.DbMock.GetGormMock()
+	dbMock := helpers.DbMock.GetDbMock()
+
+	logger := log15.New()
+	logger.SetHandler(log15.DiscardHandler())
+	ttlResolver := NewFixedValueTTLResolver(ClaimRefreshTokenExp, ClaimAccessTokenExp)
+
+	jwtService := &jwtMocks.Service{}
+	jwtService.On("Parse", "refresh").Return(&base.Token{
+		Valid:  true,
+		Claims: base.MapClaims{"sub": ClaimRefreshSub, "exp": float64(time.Now().Add(time.Hour).Unix())},
+	}, nil)
+	jwtService.On("Issue", mock.Anything).Return(&base.Token{})
+	jwtService.On("Sign", mock.Anything).Return("", errors.New("signing key is not available"))
+
+	service := NewTokenService(
+		jwtService,
+		repositories.NewTokenRepository(gormMock),
+		ttlResolver,
+		notifications.NewNotifications(&notificationsMocks.ClientFactory{}),
+		NewSecurityEvents(repositories.NewSecurityEventRepository(gormMock), logger),
+		NewRevocations(&messageBrokerMocks.MessageBroker{}, ttlResolver, logger),
+		logger,
+	)
+
+	tokenRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "subject", "signed_string", "user_uid", "family_id"}).
+			AddRow(10, ClaimRefreshSub, "refresh", "user-uid", "family-id")
+	}
+	dbMock.ExpectQuery("SELECT (.+) FROM `tokens` WHERE \\(signed_string = \\? AND subject = \\?\\)").
+		WithArgs("refresh", ClaimRefreshSub).
+		WillReturnRows(tokenRows())
+	dbMock.ExpectQuery("SELECT (.+) FROM `users`").
+		WillReturnRows(sqlmock.NewRows([]string{"uid"}).AddRow("user-uid"))
+	dbMock.ExpectQuery("SELECT (.+) FROM `tokens` WHERE \\(signed_string = \\?\\)").
+		WithArgs("refresh").
+		WillReturnRows(tokenRows())
+	dbMock.ExpectQuery("SELECT (.+) FROM `users`").
+		WillReturnRows(sqlmock.NewRows([]string{"uid"}).AddRow("user-uid"))
+
+	// the revocation of the token is rolled back along with the failed issue of the new pair
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec("UPDATE `tokens` SET `revoked_at` = \\? WHERE \\(id = \\? AND revoked_at IS NULL\\)").
+		WithArgs(sqlmock.AnyArg(), 10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec("DELETE FROM `tokens` WHERE \\(refresh_token_id = \\?\\)").
+		WithArgs(10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectRollback()
+
+	tokens, err := service.RefreshSession("refresh", "", nil)
+
+	assert.Nil(t, tokens)
+	assert.EqualError(t, err, "signing key is not available")
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// base32 encoded "12345678901234567890", the secret from RFC 6238 test vectors
+const rfcTotpSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTotpCodeAt(t *testing.T) {
+	// the RFC provides 8 digit codes, we use last 6 of them
+	var testTable = []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	totp := NewTotp()
+	for _, v := range testTable {
+		code, err := totp.CodeAt(rfcTotpSecret, time.Unix(v.unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, v.code, code, "unix time %d", v.unix)
+	}
+}
+
+func TestTotpValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	totp := &Totp{now: func() time.Time { return now }}
+
+	step, ok := totp.Validate(rfcTotpSecret, "050471")
+	assert.True(t, ok)
+	assert.Equal(t, int64(1111111111/30), step)
+
+	previous, _ := totp.CodeAt(rfcTotpSecret, now.Add(-30*time.Second))
+	_, ok = totp.Validate(rfcTotpSecret, previous)
+	assert.True(t, ok, "previous step is accepted")
+
+	tooOld, _ := totp.CodeAt(rfcTotpSecret, now.Add(-90*time.Second))
+	_, ok = totp.Validate(rfcTotpSecret, tooOld)
+	assert.False(t, ok, "codes out of the allowed skew are rejected")
+
+	_, ok = totp.Validate(rfcTotpSecret, "12345")
+	assert.False(t, ok)
+
+	_, ok = totp.Validate("not base32!", "050471")
+	assert.False(t, ok)
+}
+
+func TestTotpGenerateSecretAndURI(t *testing.T) {
+	totp := NewTotp()
+
+	secret, err := totp.GenerateSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	uri := totp.ProvisioningURI("Velmie Wallet", "user@example.com", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Velmie%20Wallet:user@example.com?"))
+	assert.Contains(t, uri, "secret="+secret)
+	assert.Contains(t, uri, "issuer=Velmie+Wallet")
+}
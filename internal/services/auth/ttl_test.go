@@ -8,6 +8,7 @@ import (
 
 	"github.com/Confialink/wallet-pkg-utils"
 	pbSettings "github.com/Confialink/wallet-settings/rpc/proto/settings"
+	"github.com/inconshreveable/log15"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/Confialink/wallet-users/internal/services/syssettings"
@@ -110,7 +111,7 @@ func TestAutologoutResolveByTokenSubject(t *testing.T) {
 		client.On("List", context.Background(), req).Return(resp, testData.rpcClientError)
 		clientFactory := &mocks.ClientFactory{}
 		clientFactory.On("NewClient").Return(client, nil)
-		sysSettings := syssettings.NewSysSettings(clientFactory)
+		sysSettings := syssettings.NewSysSettings(clientFactory, log15.New())
 		resolver := NewAutologoutTTLResolver(sysSettings)
 		ttl, err := resolver.ResolveByTokenSubject(testData.claim)
 
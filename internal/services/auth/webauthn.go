@@ -0,0 +1,333 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/inconshreveable/log15"
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/pkg/webauthn"
+)
+
+const (
+	webauthnChallengeTTL = 5 * time.Minute
+	// webauthnCredentialName is used if a user does not name a credential
+	webauthnCredentialName = "Security key"
+)
+
+var (
+	ErrWebauthnInvalidResponse    = errors.New("invalid webauthn response")
+	ErrWebauthnNoCredentials      = errors.New("user has no webauthn credentials")
+	ErrWebauthnCredentialNotFound = errors.New("webauthn credential is not found")
+	ErrWebauthnCredentialExists   = errors.New("webauthn credential is already registered")
+)
+
+// Webauthn manages FIDO2 credentials (security keys and passkeys) of users. A credential may be used
+// as the second factor after the password or, if it is discoverable, instead of the password.
+type Webauthn struct {
+	rp                   *webauthn.RelyingParty
+	credentialRepository *repositories.WebauthnCredentialRepository
+	challengeRepository  *repositories.WebauthnChallengeRepository
+	logger               log15.Logger
+}
+
+func NewWebauthn(
+	rp *webauthn.RelyingParty,
+	credentialRepository *repositories.WebauthnCredentialRepository,
+	challengeRepository *repositories.WebauthnChallengeRepository,
+	logger log15.Logger,
+) *Webauthn {
+	return &Webauthn{
+		rp,
+		credentialRepository,
+		challengeRepository,
+		logger.New("Service", "Webauthn"),
+	}
+}
+
+// BeginRegistration returns options for navigator.credentials.create()
+func (w *Webauthn) BeginRegistration(user *models.User) (*webauthn.CreationOptions, error) {
+	credentials, err := w.credentialRepository.FindByUserUID(user.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := w.newChallenge(models.WebauthnCeremonyRegistration, user.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	entity := webauthn.UserEntity{
+		ID:          []byte(user.UID),
+		Name:        accountName(user),
+		DisplayName: strings.TrimSpace(user.FirstName + " " + user.LastName),
+	}
+	if entity.DisplayName == "" {
+		entity.DisplayName = entity.Name
+	}
+
+	return w.rp.CreationOptions(challenge, entity, descriptors(credentials), timeoutMs()), nil
+}
+
+// FinishRegistration verifies the created credential and stores it
+func (w *Webauthn) FinishRegistration(user *models.User, name string, response *webauthn.RegistrationResponse) (*models.WebauthnCredential, error) {
+	challenge, err := w.consumeChallenge(response.Response.ClientDataJSON, models.WebauthnCeremonyRegistration, user.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := w.rp.VerifyRegistration(challenge, response, false)
+	if err != nil {
+		w.logger.Warn("registration verification failed", "uid", user.UID, "error", err)
+		return nil, ErrWebauthnInvalidResponse
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(credential.ID)
+	if _, err := w.credentialRepository.FindByCredentialID(credentialID); err == nil {
+		return nil, ErrWebauthnCredentialExists
+	} else if !gorm.IsRecordNotFoundError(err) {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = webauthnCredentialName
+	}
+
+	model := &models.WebauthnCredential{
+		UserUID:      user.UID,
+		CredentialID: credentialID,
+		PublicKey:    credential.PublicKey,
+		SignCount:    credential.SignCount,
+		AAGUID:       formatAAGUID(credential.AAGUID),
+		Name:         name,
+		Transports:   strings.Join(response.Response.Transports, ","),
+		UserVerified: credential.UserVerified,
+	}
+	if err := w.credentialRepository.Create(model); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// BeginMfa returns options for navigator.credentials.get() limited to credentials of the user
+// which has passed the password check
+func (w *Webauthn) BeginMfa(user *models.User) (*webauthn.RequestOptions, error) {
+	credentials, err := w.credentialRepository.FindByUserUID(user.UID)
+	if err != nil {
+		return nil, err
+	}
+	if len(credentials) == 0 {
+		return nil, ErrWebauthnNoCredentials
+	}
+
+	challenge, err := w.newChallenge(models.WebauthnCeremonyMfa, user.UID)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.rp.RequestOptions(challenge, descriptors(credentials), webauthn.UserVerificationPreferred, timeoutMs()), nil
+}
+
+// VerifyMfa checks the assertion made by a credential of the user
+func (w *Webauthn) VerifyMfa(user *models.User, response *webauthn.AssertionResponse) error {
+	challenge, err := w.consumeChallenge(response.Response.ClientDataJSON, models.WebauthnCeremonyMfa, user.UID)
+	if err != nil {
+		return err
+	}
+
+	credential, err := w.findCredential(response)
+	if err != nil {
+		return err
+	}
+	if credential.UserUID != user.UID {
+		return ErrWebauthnInvalidResponse
+	}
+
+	return w.verifyAssertion(challenge, credential, response, false)
+}
+
+// BeginLogin returns options for navigator.credentials.get() for passwordless sign in,
+// the authenticator offers discoverable credentials (passkeys) stored for the relying party
+func (w *Webauthn) BeginLogin() (*webauthn.RequestOptions, error) {
+	challenge, err := w.newChallenge(models.WebauthnCeremonyLogin, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return w.rp.RequestOptions(challenge, nil, webauthn.UserVerificationRequired, timeoutMs()), nil
+}
+
+// VerifyLogin checks the assertion for passwordless sign in and returns the used credential.
+// User verification is required, so the credential replaces both the password and the second factor.
+func (w *Webauthn) VerifyLogin(response *webauthn.AssertionResponse) (*models.WebauthnCredential, error) {
+	challenge, err := w.consumeChallenge(response.Response.ClientDataJSON, models.WebauthnCeremonyLogin, "")
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := w.findCredential(response)
+	if err != nil {
+		return nil, err
+	}
+	// the user handle is the user uid set during registration
+	if len(response.Response.UserHandle) > 0 && string(response.Response.UserHandle) != credential.UserUID {
+		return nil, ErrWebauthnInvalidResponse
+	}
+
+	if err := w.verifyAssertion(challenge, credential, response, true); err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+// Credentials returns registered credentials of the user
+func (w *Webauthn) Credentials(user *models.User) ([]*models.WebauthnCredential, error) {
+	return w.credentialRepository.FindByUserUID(user.UID)
+}
+
+// HasCredentials checks if the user has at least one registered credential
+func (w *Webauthn) HasCredentials(user *models.User) (bool, error) {
+	count, err := w.credentialRepository.CountByUserUID(user.UID)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// RemoveCredential removes the credential of the user
+func (w *Webauthn) RemoveCredential(user *models.User, id uint64) error {
+	removed, err := w.credentialRepository.DeleteByIDAndUserUID(id, user.UID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrWebauthnCredentialNotFound
+	}
+	return nil
+}
+
+// Reset removes all credentials of the user
+func (w *Webauthn) Reset(user *models.User) error {
+	return w.credentialRepository.DeleteByUserUID(user.UID)
+}
+
+func (w *Webauthn) newChallenge(ceremony, uid string) ([]byte, error) {
+	if err := w.challengeRepository.DeleteExpired(); err != nil {
+		w.logger.Error("failed to delete expired challenges", "error", err)
+	}
+
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.challengeRepository.Create(&models.WebauthnChallenge{
+		ChallengeHash: hashChallenge(challenge),
+		Ceremony:      ceremony,
+		UserUID:       uid,
+		ExpiresAt:     time.Now().Add(webauthnChallengeTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// consumeChallenge finds the challenge signed by the authenticator among pending ceremonies and removes it
+func (w *Webauthn) consumeChallenge(clientDataJSON []byte, ceremony, uid string) ([]byte, error) {
+	challenge, err := webauthn.ChallengeFromClientData(clientDataJSON)
+	if err != nil {
+		return nil, ErrWebauthnInvalidResponse
+	}
+
+	consumed, err := w.challengeRepository.Consume(hashChallenge(challenge), ceremony, uid)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, ErrWebauthnInvalidResponse
+	}
+	return challenge, nil
+}
+
+func (w *Webauthn) findCredential(response *webauthn.AssertionResponse) (*models.WebauthnCredential, error) {
+	credential, err := w.credentialRepository.FindByCredentialID(base64.RawURLEncoding.EncodeToString(response.RawID))
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, ErrWebauthnInvalidResponse
+		}
+		return nil, err
+	}
+	return credential, nil
+}
+
+func (w *Webauthn) verifyAssertion(
+	challenge []byte,
+	credential *models.WebauthnCredential,
+	response *webauthn.AssertionResponse,
+	requireUserVerification bool,
+) error {
+	signCount, err := w.rp.VerifyAssertion(challenge, response, credential.PublicKey, credential.SignCount, requireUserVerification)
+	if err != nil {
+		if err == webauthn.ErrSignCountInvalid {
+			w.logger.Error("signature counter did not increase, the authenticator may be cloned",
+				"uid", credential.UserUID, "credentialId", credential.ID)
+		} else {
+			w.logger.Warn("assertion verification failed", "uid", credential.UserUID, "error", err)
+		}
+		return ErrWebauthnInvalidResponse
+	}
+
+	updated, err := w.credentialRepository.UpdateSignCount(credential, signCount)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		// the credential was used concurrently with the same counter value
+		return ErrWebauthnInvalidResponse
+	}
+	credential.SignCount = signCount
+	return nil
+}
+
+func descriptors(credentials []*models.WebauthnCredential) []webauthn.CredentialDescriptor {
+	list := make([]webauthn.CredentialDescriptor, 0, len(credentials))
+	for _, credential := range credentials {
+		id, err := base64.RawURLEncoding.DecodeString(credential.CredentialID)
+		if err != nil {
+			continue
+		}
+		var transports []string
+		if credential.Transports != "" {
+			transports = strings.Split(credential.Transports, ",")
+		}
+		list = append(list, webauthn.NewCredentialDescriptor(id, transports))
+	}
+	return list
+}
+
+func hashChallenge(challenge []byte) string {
+	sum := sha256.Sum256(challenge)
+	return hex.EncodeToString(sum[:])
+}
+
+func formatAAGUID(aaguid []byte) string {
+	if len(aaguid) != 16 {
+		return ""
+	}
+	h := hex.EncodeToString(aaguid)
+	return fmt.Sprintf("%s-%s-%s-%s-%s", h[0:8], h[8:12], h[12:16], h[16:20], h[20:32])
+}
+
+func timeoutMs() int64 {
+	return int64(webauthnChallengeTTL / time.Millisecond)
+}
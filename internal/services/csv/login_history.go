@@ -0,0 +1,67 @@
+package csv
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Confialink/wallet-pkg-list_params"
+	"github.com/Confialink/wallet-pkg-utils/csv"
+	"github.com/Confialink/wallet-pkg-utils/timefmt"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/internal/services/syssettings"
+)
+
+// LoginHistory service to generate csv file with the login history of a user
+type LoginHistory struct {
+	repository  *repositories.LoginHistoryRepository
+	sysSettings *syssettings.SysSettings
+}
+
+// NewLoginHistory returns new LoginHistory service
+func NewLoginHistory(repository *repositories.LoginHistoryRepository, sysSettings *syssettings.SysSettings) *LoginHistory {
+	return &LoginHistory{repository, sysSettings}
+}
+
+// GetFile returns generated csv file with the login history of the user
+func (s *LoginHistory) GetFile(user *models.User, params *list_params.ListParams) (*csv.File, error) {
+	entries, err := s.repository.GetList(params)
+	if err != nil {
+		return nil, err
+	}
+	currentTime := time.Now()
+	timeSettings, err := s.sysSettings.GetTimeSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	file := csv.NewFile()
+	formattedCurrentTime := timefmt.FormatFilenameWithTime(currentTime, timeSettings.Timezone)
+	file.Name = fmt.Sprintf("login-history-%s-%s.csv", user.UID, formattedCurrentTime)
+
+	file.WriteRow(loginHistoryHeader())
+
+	for _, v := range entries {
+		file.WriteRow([]string{
+			timefmt.Format(v.CreatedAt, timeSettings.DateTimeFormat, timeSettings.Timezone),
+			v.Status,
+			v.IP,
+			v.UserAgent,
+			strconv.Itoa(int(v.RiskScore)),
+		})
+	}
+
+	return file, nil
+}
+
+func loginHistoryHeader() []string {
+	return []string{
+		"Date",
+		"Status",
+		"IP",
+		"User Agent",
+		"Risk Score",
+	}
+}
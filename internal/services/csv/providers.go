@@ -4,5 +4,6 @@ func Providers() []interface{} {
 	return []interface{}{
 		NewAdminProfiles,
 		NewUsers,
+		NewLoginHistory,
 	}
 }
@@ -0,0 +1,87 @@
+package events
+
+import (
+	"time"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+)
+
+// Subjects of domain events published to the message broker
+const (
+	UserCreated               = "user.created"
+	UserUpdated               = "user.updated"
+	UserStatusChanged         = "user.status_changed"
+	UserPasswordChanged       = "user.password_changed"
+	UserLoggedIn              = "user.logged_in"
+	UserErased                = "user.erased"
+	VerificationStatusChanged = "verification.status_changed"
+)
+
+// Event is the envelope of a published domain event, consumers should use ID to skip duplicates
+type Event struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	OccurredAt time.Time   `json:"occurredAt"`
+	Data       interface{} `json:"data"`
+}
+
+// UserData describes the user in user.created and user.updated events
+type UserData struct {
+	UID         string    `json:"uid"`
+	Email       string    `json:"email"`
+	Username    string    `json:"username"`
+	FirstName   string    `json:"firstName"`
+	LastName    string    `json:"lastName"`
+	PhoneNumber string    `json:"phoneNumber"`
+	RoleName    string    `json:"roleName"`
+	Status      string    `json:"status"`
+	ParentID    string    `json:"parentId"`
+	UserGroupID *uint64   `json:"userGroupId"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+func NewUserData(user *models.User) *UserData {
+	return &UserData{
+		UID:         user.UID,
+		Email:       user.Email,
+		Username:    user.Username,
+		FirstName:   user.FirstName,
+		LastName:    user.LastName,
+		PhoneNumber: user.PhoneNumber,
+		RoleName:    user.RoleName,
+		Status:      user.Status,
+		ParentID:    user.ParentId,
+		UserGroupID: user.UserGroupId,
+		CreatedAt:   user.CreatedAt,
+	}
+}
+
+type UserStatusChangedData struct {
+	UID            string `json:"uid"`
+	PreviousStatus string `json:"previousStatus"`
+	Status         string `json:"status"`
+}
+
+type UserPasswordChangedData struct {
+	UID string `json:"uid"`
+}
+
+// UserErasedData tells other services to erase personal data they keep for the user, the uid remains valid
+type UserErasedData struct {
+	UID      string    `json:"uid"`
+	ErasedAt time.Time `json:"erasedAt"`
+}
+
+type UserLoggedInData struct {
+	UID string    `json:"uid"`
+	IP  string    `json:"ip"`
+	At  time.Time `json:"at"`
+}
+
+type VerificationStatusChangedData struct {
+	ID             uint32 `json:"id"`
+	UserUID        string `json:"uid"`
+	Type           string `json:"type"`
+	PreviousStatus string `json:"previousStatus"`
+	Status         string `json:"status"`
+}
@@ -0,0 +1,95 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/inconshreveable/log15"
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	messagebroker "github.com/Confialink/wallet-users/internal/services/message-broker"
+)
+
+// maxErrorLength fits the last_error column
+const maxErrorLength = 512
+
+// Outbox stores domain events along with the changes and relays them to the message broker.
+// An event is recorded in the transaction of the change, so it is published if and only if the change is committed.
+type Outbox struct {
+	repository *repositories.OutboxEventRepository
+	broker     messagebroker.MessageBroker
+	logger     log15.Logger
+}
+
+func NewOutbox(
+	repository *repositories.OutboxEventRepository,
+	broker messagebroker.MessageBroker,
+	logger log15.Logger,
+) *Outbox {
+	return &Outbox{
+		repository,
+		broker,
+		logger.New("service", "Outbox"),
+	}
+}
+
+// Record stores the event within the given transaction
+func (o *Outbox) Record(tx *gorm.DB, eventType string, data interface{}) error {
+	event := Event{
+		ID:         uuid.New().String(),
+		Type:       eventType,
+		OccurredAt: time.Now(),
+		Data:       data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return o.repository.WrapContext(tx).Create(&models.OutboxEvent{
+		EventID: event.ID,
+		Subject: eventType,
+		Payload: string(payload),
+	})
+}
+
+// Relay publishes not published events in the order they were recorded and returns the number of published events.
+// Publishing stops on the first failure in order to keep the order, the rest is published on the next run.
+func (o *Outbox) Relay(limit int) (int, error) {
+	tx := o.repository.DB.Begin()
+	repository := o.repository.WrapContext(tx)
+
+	records, err := repository.FindUnpublishedForUpdate(limit)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	published := 0
+	for _, record := range records {
+		if err := o.broker.Publish(record.Subject, json.RawMessage(record.Payload)); err != nil {
+			o.logger.Error("failed to publish event", "error", err, "eventId", record.EventID, "subject", record.Subject)
+			lastError := err.Error()
+			if len(lastError) > maxErrorLength {
+				lastError = lastError[:maxErrorLength]
+			}
+			if err := repository.MarkFailed(record.ID, lastError); err != nil {
+				tx.Rollback()
+				return 0, err
+			}
+			break
+		}
+
+		if err := repository.MarkPublished(record.ID, time.Now()); err != nil {
+			// the events are published again on the next run, consumers skip duplicates by id
+			tx.Rollback()
+			return 0, err
+		}
+		published++
+	}
+
+	return published, tx.Commit().Error
+}
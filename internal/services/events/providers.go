@@ -0,0 +1,7 @@
+package events
+
+func Providers() []interface{} {
+	return []interface{}{
+		NewOutbox,
+	}
+}
@@ -0,0 +1,262 @@
+package gdpr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Confialink/wallet-pkg-errors"
+	"github.com/inconshreveable/log15"
+	"github.com/jinzhu/gorm"
+
+	pb "github.com/Confialink/wallet-customization/rpc/proto"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	"github.com/Confialink/wallet-users/internal/services/customization"
+	messagebroker "github.com/Confialink/wallet-users/internal/services/message-broker"
+)
+
+const (
+	// CustomizationChangedSubject is the subject the customization service publishes to when a customization is changed,
+	// the message holds the key of the customization
+	CustomizationChangedSubject = "customization.changed"
+
+	// privacyPolicyKey is the customization holding the privacy policy, it is accepted at sign up when GDPR is enabled
+	privacyPolicyKey = "gdpr"
+	// termsKey is the customization holding the terms and conditions
+	termsKey = "terms"
+)
+
+// publishedDocument is a document which text is kept by the customization service
+type publishedDocument struct {
+	documentType string
+	key          string
+}
+
+// publishedDocuments must be accepted again when their texts are changed,
+// other documents (marketing) are versioned by clients
+var publishedDocuments = []publishedDocument{
+	{models.ConsentDocumentTerms, termsKey},
+	{models.ConsentDocumentPrivacyPolicy, privacyPolicyKey},
+}
+
+// Consents keeps the consent ledger: which version of which document each user accepted, when and from which IP.
+// When a published document is changed users who accepted an older version get the consent challenge
+// and have to accept the new version at the next sign in.
+type Consents struct {
+	consentRepository *repositories.ConsentRepository
+	usersRepository   *repositories.UsersRepository
+	logger            log15.Logger
+}
+
+func NewConsents(
+	consentRepository *repositories.ConsentRepository,
+	usersRepository *repositories.UsersRepository,
+	logger log15.Logger,
+) *Consents {
+	return &Consents{
+		consentRepository,
+		usersRepository,
+		logger.New("service", "Consents"),
+	}
+}
+
+// List returns the consent ledger of the user
+func (s *Consents) List(user *models.User) ([]*models.Consent, error) {
+	return s.consentRepository.FindByUID(user.UID)
+}
+
+// Grant records the consent of the user to the document. A published document is accepted in its current version,
+// the version is the hash of its text, the version of other documents is given by the client.
+// Consent to the version which is already accepted is not recorded twice.
+// The consent challenge of the user is cleared once every published document is accepted in its current version.
+func (s *Consents) Grant(user *models.User, documentType, version, ip string) (*models.Consent, error) {
+	if key, ok := publishedDocumentKey(documentType); ok {
+		current, err := currentVersion(key)
+		if err != nil {
+			return nil, err
+		}
+		version = current
+	}
+
+	consent, err := s.consentRepository.FindActive(user.UID, documentType)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	if err == gorm.ErrRecordNotFound || consent.Version != version {
+		if consent, err = s.Record(s.consentRepository.DB, user.UID, documentType, version, ip); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.clearChallengeIfConsented(user); err != nil {
+		return nil, err
+	}
+	return consent, nil
+}
+
+// AcceptOutdated grants consent to current versions of published documents the user accepted in older versions,
+// the consent challenge of the user is cleared
+func (s *Consents) AcceptOutdated(user *models.User, ip string) error {
+	outdated, err := s.Outdated(user)
+	if err != nil {
+		return err
+	}
+	for _, documentType := range outdated {
+		if _, err := s.Grant(user, documentType, "", ip); err != nil {
+			return err
+		}
+	}
+	return s.clearChallengeIfConsented(user)
+}
+
+// Record stores the consent within the transaction, it is used at sign up
+func (s *Consents) Record(tx *gorm.DB, uid, documentType, version, ip string) (*models.Consent, error) {
+	now := time.Now()
+	consent := &models.Consent{
+		UserUID:      uid,
+		DocumentType: documentType,
+		Version:      version,
+		IP:           ip,
+		AcceptedAt:   &now,
+	}
+	if err := s.consentRepository.WrapContext(tx).Create(consent); err != nil {
+		return nil, err
+	}
+	return consent, nil
+}
+
+// Withdraw sets the withdrawal time of consents of the user to the document, records are kept as proof
+func (s *Consents) Withdraw(user *models.User, documentType string) error {
+	withdrawn, err := s.consentRepository.Withdraw(user.UID, documentType, time.Now())
+	if err != nil {
+		return err
+	}
+	if withdrawn == 0 {
+		return &errors.PublicError{
+			Title:      "There is no consent to withdraw",
+			Code:       responses.ConsentNotFound,
+			HttpStatus: http.StatusNotFound,
+		}
+	}
+	return nil
+}
+
+// Outdated returns published documents the user accepted in a version which is not current anymore
+func (s *Consents) Outdated(user *models.User) ([]string, error) {
+	var outdated []string
+	for _, document := range publishedDocuments {
+		consent, err := s.consentRepository.FindActive(user.UID, document.documentType)
+		if err == gorm.ErrRecordNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		current, err := currentVersion(document.key)
+		if err != nil {
+			return nil, err
+		}
+		if consent.Version != current {
+			outdated = append(outdated, document.documentType)
+		}
+	}
+	return outdated, nil
+}
+
+// RequireReconsent sets the consent challenge of users who accepted an older version of the document
+// published under the key. Other customizations are ignored.
+func (s *Consents) RequireReconsent(key string) error {
+	documentType, ok := publishedDocumentType(key)
+	if !ok {
+		return nil
+	}
+
+	version, err := currentVersion(key)
+	if err != nil {
+		return err
+	}
+
+	updated, err := s.consentRepository.SetChallengeForOutdated(documentType, version, models.ChallengeNameConsentRequired)
+	if err != nil {
+		return err
+	}
+	s.logger.Info("consent to a new version is required", "document", documentType, "version", version, "users", updated)
+	return nil
+}
+
+// SubscribeToDocumentChanges requires consent to new versions of documents published by the customization service.
+// Users are updated in the database, so the subscription uses a queue group and only one instance handles a change.
+func (s *Consents) SubscribeToDocumentChanges(broker messagebroker.MessageBroker) error {
+	return broker.QueueSubscribe(CustomizationChangedSubject, func(jsonData string) {
+		logger := s.logger.New("subject", CustomizationChangedSubject)
+
+		var message struct {
+			Key string `json:"key"`
+		}
+		if err := json.Unmarshal([]byte(jsonData), &message); err != nil {
+			logger.Error("failed to parse message", "error", err, "data", jsonData)
+			return
+		}
+		if err := s.RequireReconsent(message.Key); err != nil {
+			logger.Error("failed to require consent to the new version", "error", err, "key", message.Key)
+		}
+	})
+}
+
+// clearChallengeIfConsented clears the consent challenge of the user if no accepted document is outdated
+func (s *Consents) clearChallengeIfConsented(user *models.User) error {
+	if user.ChallengeName == nil || *user.ChallengeName != models.ChallengeNameConsentRequired {
+		return nil
+	}
+
+	outdated, err := s.Outdated(user)
+	if err != nil {
+		return err
+	}
+	if len(outdated) > 0 {
+		return nil
+	}
+
+	user.ChallengeName = nil
+	return s.usersRepository.UpdateChallengeName(user)
+}
+
+// currentVersion returns the version of the text published under the key
+func currentVersion(key string) (string, error) {
+	document, err := customization.GetCustomizationByKey(key)
+	if err != nil {
+		return "", err
+	}
+	return documentVersion(document), nil
+}
+
+// documentVersion is the SHA-256 hash of the text of the document,
+// the customization service does not version texts so any change makes a new version
+func documentVersion(document *pb.Customization) string {
+	sum := sha256.Sum256([]byte(document.Value))
+	return hex.EncodeToString(sum[:])
+}
+
+func publishedDocumentKey(documentType string) (string, bool) {
+	for _, document := range publishedDocuments {
+		if document.documentType == documentType {
+			return document.key, true
+		}
+	}
+	return "", false
+}
+
+func publishedDocumentType(key string) (string, bool) {
+	for _, document := range publishedDocuments {
+		if document.key == key {
+			return document.documentType, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,260 @@
+package gdpr
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Confialink/wallet-pkg-errors"
+	"github.com/inconshreveable/log15"
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/config"
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	"github.com/Confialink/wallet-users/internal/services/accounts"
+	"github.com/Confialink/wallet-users/internal/services/events"
+)
+
+// erasureBlockedReason is stored in a request rejected at erasure time
+const erasureBlockedReason = "The user has cards or accounts"
+
+// Erasure handles requests of users to erase their personal data. A request is approved by an admin
+// and personal data is pseudonymized when the grace period is over. The uid of the user is kept,
+// other services are told to erase their data by the user.erased event.
+type Erasure struct {
+	db                     *gorm.DB
+	erasureRepository      *repositories.ErasureRequestRepository
+	personalDataRepository *repositories.PersonalDataRepository
+	usersRepository        *repositories.UsersRepository
+	accountsService        *accounts.AccountsService
+	outbox                 *events.Outbox
+	config                 *config.Configuration
+	logger                 log15.Logger
+}
+
+func NewErasure(
+	db *gorm.DB,
+	erasureRepository *repositories.ErasureRequestRepository,
+	personalDataRepository *repositories.PersonalDataRepository,
+	usersRepository *repositories.UsersRepository,
+	accountsService *accounts.AccountsService,
+	outbox *events.Outbox,
+	config *config.Configuration,
+	logger log15.Logger,
+) *Erasure {
+	return &Erasure{
+		db,
+		erasureRepository,
+		personalDataRepository,
+		usersRepository,
+		accountsService,
+		outbox,
+		config,
+		logger.New("service", "Erasure"),
+	}
+}
+
+// Current returns the request of the user which is not reviewed yet or waits for erasure
+func (s *Erasure) Current(user *models.User) (*models.ErasureRequest, error) {
+	return s.erasureRepository.FindOpenByUID(user.UID)
+}
+
+// Request creates an erasure request of the user which waits for a review by an admin
+func (s *Erasure) Request(user *models.User) (*models.ErasureRequest, error) {
+	_, err := s.erasureRepository.FindOpenByUID(user.UID)
+	if err == nil {
+		return nil, &errors.PublicError{
+			Title:      "Erasure is already requested",
+			Code:       responses.ErasureRequestAlreadyExists,
+			HttpStatus: http.StatusConflict,
+		}
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	request := &models.ErasureRequest{
+		UserUID: user.UID,
+		Status:  models.ErasureRequestStatusRequested,
+	}
+	if err := s.erasureRepository.Create(request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// Cancel withdraws the request of the user, it is possible until personal data is erased
+func (s *Erasure) Cancel(user *models.User) (*models.ErasureRequest, error) {
+	request, err := s.erasureRepository.FindOpenByUID(user.UID)
+	if err == gorm.ErrRecordNotFound {
+		return nil, erasureRequestNotFound()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	request.Status = models.ErasureRequestStatusCancelled
+	if err := s.erasureRepository.Save(request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// Approve starts the grace period of the request, the user must not have cards or accounts
+func (s *Erasure) Approve(id uint64, reviewerUID string) (*models.ErasureRequest, error) {
+	request, err := s.findRequested(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkCardsAndAccounts(request.UserUID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	eraseAfter := now.Add(s.config.Gdpr.ErasureGracePeriod)
+	request.Status = models.ErasureRequestStatusApproved
+	request.ReviewedBy = reviewerUID
+	request.ReviewedAt = &now
+	request.EraseAfter = &eraseAfter
+	if err := s.erasureRepository.Save(request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// Reject closes the request without erasure
+func (s *Erasure) Reject(id uint64, reviewerUID string, reason string) (*models.ErasureRequest, error) {
+	request, err := s.findRequested(id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	request.Status = models.ErasureRequestStatusRejected
+	request.ReviewedBy = reviewerUID
+	request.ReviewedAt = &now
+	request.Reason = reason
+	if err := s.erasureRepository.Save(request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// Due returns approved requests which grace period is over
+func (s *Erasure) Due(limit int) ([]*models.ErasureRequest, error) {
+	return s.erasureRepository.FindDue(time.Now(), limit)
+}
+
+// Erase pseudonymizes personal data of the user of the approved request and records the user.erased event.
+// The request is rejected if the user got cards or accounts during the grace period.
+// Sessions of the returned user must be revoked by the caller.
+func (s *Erasure) Erase(request *models.ErasureRequest) (*models.User, error) {
+	if err := s.checkCardsAndAccounts(request.UserUID); err != nil {
+		if _, ok := err.(*errors.PublicError); ok {
+			request.Status = models.ErasureRequestStatusRejected
+			request.Reason = erasureBlockedReason
+			if saveErr := s.erasureRepository.Save(request); saveErr != nil {
+				return nil, saveErr
+			}
+		}
+		return nil, err
+	}
+
+	user, err := s.usersRepository.FindByUID(request.UserUID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := s.db.Begin()
+	if err := s.pseudonymize(tx, user); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	now := time.Now()
+	request.Status = models.ErasureRequestStatusErased
+	request.ErasedAt = &now
+	if err := s.erasureRepository.WrapContext(tx).Save(request); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := s.outbox.Record(tx, events.UserErased, &events.UserErasedData{UID: user.UID, ErasedAt: now}); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *Erasure) pseudonymize(tx *gorm.DB, user *models.User) error {
+	repo := s.personalDataRepository.WrapContext(tx)
+	if user.CompanyID != nil {
+		if err := repo.PseudonymizeCompany(*user.CompanyID, user.UID); err != nil {
+			return err
+		}
+	}
+
+	steps := []func(uid string) error{
+		repo.PseudonymizeUser,
+		repo.DeleteAddresses,
+		repo.DeleteAttributeValues,
+		repo.DeleteSecurityQuestionsAnswers,
+		repo.PseudonymizeAccessLog,
+		repo.PseudonymizeSecurityEvents,
+		repo.PseudonymizeConsents,
+		repo.DetachVerificationFiles,
+		repo.DeleteContactChanges,
+	}
+	for _, step := range steps {
+		if err := step(user.UID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Erasure) findRequested(id uint64) (*models.ErasureRequest, error) {
+	request, err := s.erasureRepository.FindByID(id)
+	if err == gorm.ErrRecordNotFound {
+		return nil, erasureRequestNotFound()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != models.ErasureRequestStatusRequested {
+		return nil, &errors.PublicError{
+			Title:      "The erasure request is already reviewed",
+			Code:       responses.ErasureRequestIsReviewed,
+			HttpStatus: http.StatusConflict,
+		}
+	}
+	return request, nil
+}
+
+// checkCardsAndAccounts asks the accounts service whether personal data of the user may be erased,
+// erasure is not allowed if the service can not be reached
+func (s *Erasure) checkCardsAndAccounts(uid string) error {
+	resp, err := s.accountsService.UserHasCardsOrAccounts(uid)
+	if err != nil {
+		return err
+	}
+	if resp.HasCardsOrAccounts {
+		return &errors.PublicError{
+			Title:      erasureBlockedReason,
+			Code:       responses.UserHasCardsOrAccounts,
+			HttpStatus: http.StatusConflict,
+		}
+	}
+	return nil
+}
+
+func erasureRequestNotFound() error {
+	return &errors.PublicError{
+		Title:      "There is no erasure request",
+		Code:       responses.ErasureRequestNotFound,
+		HttpStatus: http.StatusNotFound,
+	}
+}
@@ -0,0 +1,313 @@
+package gdpr
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Confialink/wallet-pkg-errors"
+	"github.com/Confialink/wallet-pkg-list_params"
+	"github.com/inconshreveable/log15"
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	"github.com/Confialink/wallet-users/internal/services/files"
+	"github.com/Confialink/wallet-users/internal/services/notifications"
+	"github.com/Confialink/wallet-users/internal/services/pdf"
+)
+
+const (
+	// dataExportFileCategory is the category of bundles in the files service
+	dataExportFileCategory = "gdpr_export"
+	// maxExportErrorLength fits the error column
+	maxExportErrorLength = 255
+)
+
+// exportSection is a part of the bundle, it becomes <name>.json and a chapter of the PDF
+type exportSection struct {
+	name  string
+	title string
+	data  interface{}
+}
+
+// Export builds bundles of personal data held about users: a ZIP of JSON files and a readable PDF.
+// Exports are requested by users or admins and built by a worker, the bundle is uploaded to the files service.
+type Export struct {
+	exportRepository         *repositories.DataExportRepository
+	usersRepository          *repositories.UsersRepository
+	addressRepository        *repositories.AddressRepository
+	attributeValueRepository *repositories.UserAttributeValueRepository
+	companyRepository        *repositories.CompanyRepository
+	loginHistoryRepository   *repositories.LoginHistoryRepository
+	verificationRepository   *repositories.VerificationRepository
+	invitesRepository        *repositories.InvitesRepository
+	consentRepository        *repositories.ConsentRepository
+	filesService             *files.FilesService
+	notificationsService     *notifications.Notifications
+	logger                   log15.Logger
+}
+
+func NewExport(
+	exportRepository *repositories.DataExportRepository,
+	usersRepository *repositories.UsersRepository,
+	addressRepository *repositories.AddressRepository,
+	attributeValueRepository *repositories.UserAttributeValueRepository,
+	companyRepository *repositories.CompanyRepository,
+	loginHistoryRepository *repositories.LoginHistoryRepository,
+	verificationRepository *repositories.VerificationRepository,
+	invitesRepository *repositories.InvitesRepository,
+	consentRepository *repositories.ConsentRepository,
+	filesService *files.FilesService,
+	notificationsService *notifications.Notifications,
+	logger log15.Logger,
+) *Export {
+	return &Export{
+		exportRepository,
+		usersRepository,
+		addressRepository,
+		attributeValueRepository,
+		companyRepository,
+		loginHistoryRepository,
+		verificationRepository,
+		invitesRepository,
+		consentRepository,
+		filesService,
+		notificationsService,
+		logger.New("service", "Export"),
+	}
+}
+
+// Latest returns the last requested export of the user
+func (s *Export) Latest(user *models.User) (*models.DataExport, error) {
+	return s.exportRepository.FindLatestByUID(user.UID)
+}
+
+// Request queues an export of personal data of the user, requestedBy is the uid of the user or an admin
+func (s *Export) Request(user *models.User, requestedBy string) (*models.DataExport, error) {
+	latest, err := s.exportRepository.FindLatestByUID(user.UID)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	if err == nil && latest.IsOpen() {
+		return nil, &errors.PublicError{
+			Title:      "Export of personal data is already requested",
+			Code:       responses.DataExportAlreadyRequested,
+			HttpStatus: http.StatusConflict,
+		}
+	}
+
+	export := &models.DataExport{
+		UserUID:     user.UID,
+		RequestedBy: requestedBy,
+		Status:      models.DataExportStatusPending,
+	}
+	if err := s.exportRepository.Create(export); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// Pending returns exports waiting for the worker
+func (s *Export) Pending(limit int) ([]*models.DataExport, error) {
+	return s.exportRepository.FindPending(limit)
+}
+
+// Build collects personal data of the user of the export, uploads the bundle and notifies the user.
+// The export is skipped if another instance has claimed it, it is marked as failed on an error.
+func (s *Export) Build(export *models.DataExport) error {
+	claimed, err := s.exportRepository.Claim(export)
+	if err != nil || !claimed {
+		return err
+	}
+
+	if err := s.build(export); err != nil {
+		export.Status = models.DataExportStatusFailed
+		export.Error = err.Error()
+		if len(export.Error) > maxExportErrorLength {
+			export.Error = export.Error[:maxExportErrorLength]
+		}
+		if saveErr := s.exportRepository.Save(export); saveErr != nil {
+			s.logger.Error("failed to save failed export", "error", saveErr, "id", export.ID)
+		}
+		return err
+	}
+
+	if _, err := s.notificationsService.DataExportReady(export.UserUID); err != nil {
+		s.logger.Error("failed to notify about ready export", "error", err, "uid", export.UserUID)
+	}
+	return nil
+}
+
+func (s *Export) build(export *models.DataExport) error {
+	sections, err := s.collect(export.UserUID)
+	if err != nil {
+		return err
+	}
+
+	bundle, err := bundle(sections)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	fileName := fmt.Sprintf("personal-data-%s-%s.zip", export.UserUID, now.Format("20060102150405"))
+	if _, err := s.filesService.Upload(bundle, fileName, export.UserUID, false, true, dataExportFileCategory); err != nil {
+		return err
+	}
+
+	export.Status = models.DataExportStatusReady
+	export.FileName = fileName
+	export.CompletedAt = &now
+	return s.exportRepository.Save(export)
+}
+
+// collect reads everything held about the user
+func (s *Export) collect(uid string) ([]*exportSection, error) {
+	user, err := s.usersRepository.FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses, err := s.addressRepository.FindByUser(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	rawAttributes, err := s.attributeValueRepository.AllByUserId(uid)
+	if err != nil {
+		return nil, err
+	}
+	attributes := make(map[string]string, len(rawAttributes))
+	for _, attribute := range rawAttributes {
+		attributes[attribute.Slug] = attribute.Value
+	}
+
+	var company *models.Company
+	if user.CompanyID != nil {
+		if company, err = s.companyRepository.GetByID(*user.CompanyID); err != nil && err != gorm.ErrRecordNotFound {
+			return nil, err
+		}
+	}
+
+	params := list_params.NewListParamsFromQuery("", models.LoginHistoryEntry{})
+	params.AddFilter("uid", []string{uid})
+	params.Pagination.PageSize = 0
+	loginHistory, err := s.loginHistoryRepository.GetList(params)
+	if err != nil {
+		return nil, err
+	}
+
+	verifications, err := s.verificationRepository.FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	invites, err := s.invitesRepository.FindByUserUID(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	consents, err := s.consentRepository.FindByUID(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*exportSection{
+		{"profile", "Profile", user},
+		{"addresses", "Addresses", addresses},
+		{"attributes", "Attributes", attributes},
+		{"company", "Company", company},
+		{"login_history", "Login history", loginHistory},
+		{"verifications", "Verifications", verifications},
+		{"invites", "Invites sent", invites},
+		{"consents", "Consents", consents},
+	}, nil
+}
+
+// bundle writes a JSON file per section and the PDF of all sections into a ZIP archive
+func bundle(sections []*exportSection) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	archive := zip.NewWriter(buf)
+	body := &strings.Builder{}
+	body.WriteString("<!doctype html><html><head><meta charset=\"utf-8\"></head><body><h1>Personal data</h1>")
+
+	for _, section := range sections {
+		data, err := json.MarshalIndent(section.data, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		w, err := archive.Create(section.name + ".json")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+
+		// the data is decoded back in order to render it the same way as it is exported
+		var decoded interface{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return nil, err
+		}
+		body.WriteString("<h2>" + html.EscapeString(section.title) + "</h2>")
+		writeHtmlValue(body, decoded)
+	}
+	body.WriteString("<h4>" + time.Now().UTC().Format(time.RFC1123) + "</h4></body></html>")
+
+	document, err := pdf.HtmlToPdfBytes(body.String())
+	if err != nil {
+		return nil, err
+	}
+	w, err := archive.Create("personal-data.pdf")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(document); err != nil {
+		return nil, err
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeHtmlValue renders objects as tables of fields and lists as sequences of their items
+func writeHtmlValue(b *strings.Builder, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		b.WriteString("<table border=\"1\" cellpadding=\"4\" cellspacing=\"0\">")
+		for _, key := range keys {
+			b.WriteString("<tr><th align=\"left\">" + html.EscapeString(key) + "</th><td>")
+			writeHtmlValue(b, v[key])
+			b.WriteString("</td></tr>")
+		}
+		b.WriteString("</table>")
+	case []interface{}:
+		if len(v) == 0 {
+			b.WriteString("<p>None</p>")
+			return
+		}
+		for _, item := range v {
+			writeHtmlValue(b, item)
+			b.WriteString("<br>")
+		}
+	case nil:
+		b.WriteString("&mdash;")
+	default:
+		b.WriteString(html.EscapeString(fmt.Sprint(v)))
+	}
+}
@@ -20,21 +20,22 @@ func NewService(sysSettingsService *syssettings.SysSettings) *Service {
 	return &Service{sysSettingsService: sysSettingsService}
 }
 
-// GdprHtmlBytes requests a GDPR policy from the Customization service and generates a byte slice for a PDF file
-func (s *Service) GdprHtmlBytes() ([]byte, error) {
-	gdpr, err := customization.GetCustomizationByKey("gdpr")
+// GdprHtmlBytes requests a GDPR policy from the Customization service and generates a byte slice for a PDF file,
+// the version of the policy is returned to be stored in the consent ledger
+func (s *Service) GdprHtmlBytes() ([]byte, string, error) {
+	gdpr, err := customization.GetCustomizationByKey(privacyPolicyKey)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	settings, err := s.sysSettingsService.GetTimeSettings()
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	l, err := time.LoadLocation(settings.Timezone)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	_, o := time.Now().In(l).Zone()
 	h := o / 3600
@@ -45,6 +46,10 @@ func (s *Service) GdprHtmlBytes() ([]byte, error) {
 	z := fmt.Sprintf("UTC%s%d", sep, h)
 	t := fmt.Sprintf("%s (%s)", timefmt.Format(time.Now(), settings.DateTimeFormat, settings.Timezone), z)
 
-	return pdf.HtmlToPdfBytes(html.UnescapeString(`<!doctype html><html><body>` +
+	pdfBytes, err := pdf.HtmlToPdfBytes(html.UnescapeString(`<!doctype html><html><body>` +
 		`<h1>` + gdpr.Label + `</h1><br>` + gdpr.Value + `<br><h4>` + t + `</h4>` + `</body></html>`))
+	if err != nil {
+		return nil, "", err
+	}
+	return pdfBytes, documentVersion(gdpr), nil
 }
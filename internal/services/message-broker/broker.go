@@ -17,4 +17,8 @@ type MessageBroker interface {
 	// Other subscriptions receive messages independently of the queue groups, that is, a message is delivered to all
 	// subscriptions and one member of each queue group.
 	QueueSubscribe(subject string, handler MessageHandler) error
+
+	// Subscribe delivers every message published after the subscription to this instance of the service.
+	// Use this method if every instance must handle a message, e.g. to invalidate an in-process cache.
+	Subscribe(subject string, handler MessageHandler) error
 }
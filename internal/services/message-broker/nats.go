@@ -98,3 +98,13 @@ func (s *Nats) QueueSubscribe(subject string, handler MessageHandler) error {
 
 	return err
 }
+
+// Subscribe delivers every message published after the subscription to this instance of the service.
+// The subscription is not durable, messages published while the instance is offline are not delivered.
+func (s *Nats) Subscribe(subject string, handler MessageHandler) error {
+	_, err := s.connection.Subscribe(subject, func(m *stan.Msg) {
+		handler(string(m.Data))
+	})
+
+	return err
+}
@@ -7,13 +7,21 @@ import (
 )
 
 const (
-	eventNamePasswordRecovery    = "PasswordRecovery"
-	eventNameProfileCreate       = "ProfileCreate"
-	eventNameChangePassword      = "ChangePassword"
-	eventNamePhoneVerification   = "PhoneVerification"
-	eventNameEmailVerification   = "EmailVerification"
-	eventNameFailedLoginAttempts = "FailedLoginAttempts"
-	eventNameInviteCreate        = "InviteCreate"
+	eventNamePasswordRecovery        = "PasswordRecovery"
+	eventNameProfileCreate           = "ProfileCreate"
+	eventNameChangePassword          = "ChangePassword"
+	eventNamePhoneVerification       = "PhoneVerification"
+	eventNameEmailVerification       = "EmailVerification"
+	eventNameFailedLoginAttempts     = "FailedLoginAttempts"
+	eventNameInviteCreate            = "InviteCreate"
+	eventNameSessionCompromised      = "SessionCompromised"
+	eventNameLoginVerification       = "LoginVerification"
+	eventNameNewSignIn               = "NewSignIn"
+	eventNameEmailChangeConfirmation = "EmailChangeConfirmation"
+	eventNameEmailChangeRequested    = "EmailChangeRequested"
+	eventNamePhoneChangeConfirmation = "PhoneChangeConfirmation"
+	eventNamePhoneChangeRequested    = "PhoneChangeRequested"
+	eventNameDataExportReady         = "DataExportReady"
 )
 
 type Notifications struct {
@@ -131,3 +139,128 @@ func (s *Notifications) InviteCreated(userID, password string) (*pb.Response, er
 		},
 	})
 }
+
+// SessionCompromised sends a notification when reuse of a revoked refresh token was detected and all sessions of the family were revoked
+func (s *Notifications) SessionCompromised(userID string) (*pb.Response, error) {
+	client, err := s.clientFactory.NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Dispatch(context.Background(), &pb.Request{
+		To:        userID,
+		EventName: eventNameSessionCompromised,
+	})
+}
+
+// LoginVerification sends a confirmation code to confirm a risky sign in
+func (s *Notifications) LoginVerification(userID, confirmationCode string, methods []string) (*pb.Response, error) {
+	client, err := s.clientFactory.NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Dispatch(context.Background(), &pb.Request{
+		To:        userID,
+		EventName: eventNameLoginVerification,
+		TemplateData: &pb.TemplateData{
+			ConfirmationCode: confirmationCode,
+		},
+		Notifiers: methods,
+	})
+}
+
+// NewSignIn sends a notification when a user signed in from a new device
+func (s *Notifications) NewSignIn(userID string) (*pb.Response, error) {
+	client, err := s.clientFactory.NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Dispatch(context.Background(), &pb.Request{
+		To:        userID,
+		EventName: eventNameNewSignIn,
+	})
+}
+
+// EmailChangeConfirmation sends a confirmation code to the new email address of the user,
+// the address is not stored in the user yet, so it is passed instead of the user id
+func (s *Notifications) EmailChangeConfirmation(email, confirmationCode string) (*pb.Response, error) {
+	client, err := s.clientFactory.NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Dispatch(context.Background(), &pb.Request{
+		To:        email,
+		EventName: eventNameEmailChangeConfirmation,
+		TemplateData: &pb.TemplateData{
+			ConfirmationCode: confirmationCode,
+		},
+		Notifiers: []string{"email"},
+	})
+}
+
+// EmailChangeRequested alerts the user at the current email address about the requested change,
+// the code allows to cancel the change
+func (s *Notifications) EmailChangeRequested(userID, cancelCode string) (*pb.Response, error) {
+	client, err := s.clientFactory.NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Dispatch(context.Background(), &pb.Request{
+		To:        userID,
+		EventName: eventNameEmailChangeRequested,
+		TemplateData: &pb.TemplateData{
+			ConfirmationCode: cancelCode,
+		},
+		Notifiers: []string{"email"},
+	})
+}
+
+// PhoneChangeConfirmation sends a confirmation code by SMS to the new phone number of the user,
+// the number is not stored in the user yet, so it is passed instead of the user id
+func (s *Notifications) PhoneChangeConfirmation(phoneNumber, confirmationCode string) (*pb.Response, error) {
+	client, err := s.clientFactory.NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Dispatch(context.Background(), &pb.Request{
+		To:        phoneNumber,
+		EventName: eventNamePhoneChangeConfirmation,
+		TemplateData: &pb.TemplateData{
+			ConfirmationCode: confirmationCode,
+		},
+		Notifiers: []string{"sms"},
+	})
+}
+
+// PhoneChangeRequested alerts the user by SMS at the current phone number about the requested change
+func (s *Notifications) PhoneChangeRequested(phoneNumber string) (*pb.Response, error) {
+	client, err := s.clientFactory.NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Dispatch(context.Background(), &pb.Request{
+		To:        phoneNumber,
+		EventName: eventNamePhoneChangeRequested,
+		Notifiers: []string{"sms"},
+	})
+}
+
+// DataExportReady tells the user that the export of personal data is uploaded and can be downloaded
+func (s *Notifications) DataExportReady(userID string) (*pb.Response, error) {
+	client, err := s.clientFactory.NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Dispatch(context.Background(), &pb.Request{
+		To:        userID,
+		EventName: eventNameDataExportReady,
+		Notifiers: []string{"email"},
+	})
+}
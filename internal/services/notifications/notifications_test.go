@@ -245,4 +245,259 @@ var _ = Describe("notifications package", func() {
 			})
 		})
 	})
+
+	Context("SessionCompromised", func() {
+		When("client factory returns an error", func() {
+			It("should return an error", func() {
+				clientFactory.On("NewClient").Return(nil, errors.New("random err"))
+				service := NewNotifications(clientFactory)
+
+				_, err := service.SessionCompromised(userID)
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+
+		When("notification is successfully sent", func() {
+			It("should not return an error", func() {
+				req := &pb.Request{
+					To:        userID,
+					EventName: eventNameSessionCompromised,
+				}
+				resp := &pb.Response{}
+				client.On("Dispatch", context.Background(), req).Return(resp, nil)
+				clientFactory.On("NewClient").Return(client, nil)
+				service := NewNotifications(clientFactory)
+				res, err := service.SessionCompromised(userID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(res).Should(Equal(resp))
+			})
+		})
+	})
+
+	Context("LoginVerification", func() {
+		confirmationCode := "random-code"
+		notifyMethods := []string{"email"}
+
+		When("client factory returns an error", func() {
+			It("should return an error", func() {
+				clientFactory.On("NewClient").Return(nil, errors.New("random err"))
+				service := NewNotifications(clientFactory)
+
+				_, err := service.LoginVerification(userID, confirmationCode, notifyMethods)
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+
+		When("notification is successfully sent", func() {
+			It("should not return an error", func() {
+				req := &pb.Request{
+					To:        userID,
+					EventName: eventNameLoginVerification,
+					TemplateData: &pb.TemplateData{
+						ConfirmationCode: confirmationCode,
+					},
+					Notifiers: notifyMethods,
+				}
+				resp := &pb.Response{}
+				client.On("Dispatch", context.Background(), req).Return(resp, nil)
+				clientFactory.On("NewClient").Return(client, nil)
+				service := NewNotifications(clientFactory)
+				res, err := service.LoginVerification(userID, confirmationCode, notifyMethods)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(res).Should(Equal(resp))
+			})
+		})
+	})
+
+	Context("NewSignIn", func() {
+		When("client factory returns an error", func() {
+			It("should return an error", func() {
+				clientFactory.On("NewClient").Return(nil, errors.New("random err"))
+				service := NewNotifications(clientFactory)
+
+				_, err := service.NewSignIn(userID)
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+
+		When("notification is successfully sent", func() {
+			It("should not return an error", func() {
+				req := &pb.Request{
+					To:        userID,
+					EventName: eventNameNewSignIn,
+				}
+				resp := &pb.Response{}
+				client.On("Dispatch", context.Background(), req).Return(resp, nil)
+				clientFactory.On("NewClient").Return(client, nil)
+				service := NewNotifications(clientFactory)
+				res, err := service.NewSignIn(userID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(res).Should(Equal(resp))
+			})
+		})
+	})
+
+	Context("EmailChangeConfirmation", func() {
+		email := "new@example.com"
+		confirmationCode := "random-code"
+
+		When("client factory returns an error", func() {
+			It("should return an error", func() {
+				clientFactory.On("NewClient").Return(nil, errors.New("random err"))
+				service := NewNotifications(clientFactory)
+
+				_, err := service.EmailChangeConfirmation(email, confirmationCode)
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+
+		When("notification is successfully sent", func() {
+			It("should send the code to the new address", func() {
+				req := &pb.Request{
+					To:        email,
+					EventName: eventNameEmailChangeConfirmation,
+					TemplateData: &pb.TemplateData{
+						ConfirmationCode: confirmationCode,
+					},
+					Notifiers: []string{"email"},
+				}
+				resp := &pb.Response{}
+				client.On("Dispatch", context.Background(), req).Return(resp, nil)
+				clientFactory.On("NewClient").Return(client, nil)
+				service := NewNotifications(clientFactory)
+				res, err := service.EmailChangeConfirmation(email, confirmationCode)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(res).Should(Equal(resp))
+			})
+		})
+	})
+
+	Context("EmailChangeRequested", func() {
+		cancelCode := "random-cancel-code"
+
+		When("client factory returns an error", func() {
+			It("should return an error", func() {
+				clientFactory.On("NewClient").Return(nil, errors.New("random err"))
+				service := NewNotifications(clientFactory)
+
+				_, err := service.EmailChangeRequested(userID, cancelCode)
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+
+		When("notification is successfully sent", func() {
+			It("should not return an error", func() {
+				req := &pb.Request{
+					To:        userID,
+					EventName: eventNameEmailChangeRequested,
+					TemplateData: &pb.TemplateData{
+						ConfirmationCode: cancelCode,
+					},
+					Notifiers: []string{"email"},
+				}
+				resp := &pb.Response{}
+				client.On("Dispatch", context.Background(), req).Return(resp, nil)
+				clientFactory.On("NewClient").Return(client, nil)
+				service := NewNotifications(clientFactory)
+				res, err := service.EmailChangeRequested(userID, cancelCode)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(res).Should(Equal(resp))
+			})
+		})
+	})
+
+	Context("PhoneChangeConfirmation", func() {
+		phoneNumber := "+15550001111"
+		confirmationCode := "random-code"
+
+		When("client factory returns an error", func() {
+			It("should return an error", func() {
+				clientFactory.On("NewClient").Return(nil, errors.New("random err"))
+				service := NewNotifications(clientFactory)
+
+				_, err := service.PhoneChangeConfirmation(phoneNumber, confirmationCode)
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+
+		When("notification is successfully sent", func() {
+			It("should send the code to the new number", func() {
+				req := &pb.Request{
+					To:        phoneNumber,
+					EventName: eventNamePhoneChangeConfirmation,
+					TemplateData: &pb.TemplateData{
+						ConfirmationCode: confirmationCode,
+					},
+					Notifiers: []string{"sms"},
+				}
+				resp := &pb.Response{}
+				client.On("Dispatch", context.Background(), req).Return(resp, nil)
+				clientFactory.On("NewClient").Return(client, nil)
+				service := NewNotifications(clientFactory)
+				res, err := service.PhoneChangeConfirmation(phoneNumber, confirmationCode)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(res).Should(Equal(resp))
+			})
+		})
+	})
+
+	Context("PhoneChangeRequested", func() {
+		phoneNumber := "+15550002222"
+
+		When("client factory returns an error", func() {
+			It("should return an error", func() {
+				clientFactory.On("NewClient").Return(nil, errors.New("random err"))
+				service := NewNotifications(clientFactory)
+
+				_, err := service.PhoneChangeRequested(phoneNumber)
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+
+		When("notification is successfully sent", func() {
+			It("should alert the current number", func() {
+				req := &pb.Request{
+					To:        phoneNumber,
+					EventName: eventNamePhoneChangeRequested,
+					Notifiers: []string{"sms"},
+				}
+				resp := &pb.Response{}
+				client.On("Dispatch", context.Background(), req).Return(resp, nil)
+				clientFactory.On("NewClient").Return(client, nil)
+				service := NewNotifications(clientFactory)
+				res, err := service.PhoneChangeRequested(phoneNumber)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(res).Should(Equal(resp))
+			})
+		})
+	})
+
+	Context("DataExportReady", func() {
+		When("client factory returns an error", func() {
+			It("should return an error", func() {
+				clientFactory.On("NewClient").Return(nil, errors.New("random err"))
+				service := NewNotifications(clientFactory)
+
+				_, err := service.DataExportReady(userID)
+				Expect(err).Should(HaveOccurred())
+			})
+		})
+
+		When("notification is successfully sent", func() {
+			It("should not return an error", func() {
+				req := &pb.Request{
+					To:        userID,
+					EventName: eventNameDataExportReady,
+					Notifiers: []string{"email"},
+				}
+				resp := &pb.Response{}
+				client.On("Dispatch", context.Background(), req).Return(resp, nil)
+				clientFactory.On("NewClient").Return(client, nil)
+				service := NewNotifications(clientFactory)
+				res, err := service.DataExportReady(userID)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(res).Should(Equal(resp))
+			})
+		})
+	})
 })
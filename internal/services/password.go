@@ -5,13 +5,19 @@ import (
 	"crypto/md5"
 	"crypto/rand"
 	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"math"
 	"strings"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
+
+	"github.com/Confialink/wallet-users/internal/config"
+	"github.com/Confialink/wallet-users/internal/db/models"
 )
 
 const (
@@ -23,14 +29,49 @@ const (
 	maxHashCount = 30
 	// hashLength is the expected (and maximum) number of characters in a hashed password.
 	hashLength = 55
+	// maxPasswordLength is the maximum number of bytes of a password which is hashed,
+	// hashing large passwords is refused to prevent DoS attacks
+	maxPasswordLength = 512
 )
 
+// Argon2Params are parameters of argon2id hashing, they are stored in every hash
+type Argon2Params struct {
+	Time       uint32
+	Memory     uint32
+	Threads    uint8
+	KeyLength  uint32
+	SaltLength uint32
+}
+
+// DefaultArgon2Params returns parameters recommended by RFC 9106 for memory constrained environments
+func DefaultArgon2Params() Argon2Params {
+	return Argon2Params{Time: 1, Memory: 64 * 1024, Threads: 4, KeyLength: 32, SaltLength: 16}
+}
+
 // Password is secure password hashing for user authentication
-type Password struct{}
+type Password struct {
+	argon2 Argon2Params
+}
 
-// NewPassword creates new password service
+// NewPassword creates new password service with default argon2id parameters
 func NewPassword() *Password {
-	return &Password{}
+	return NewPasswordWithParams(DefaultArgon2Params())
+}
+
+// NewPasswordWithParams creates new password service with the given argon2id parameters
+func NewPasswordWithParams(params Argon2Params) *Password {
+	return &Password{argon2: params}
+}
+
+// PasswordFactory creates new password service with argon2id parameters from the config
+func PasswordFactory(config *config.Configuration) *Password {
+	return NewPasswordWithParams(Argon2Params{
+		Time:       config.Password.Argon2Time,
+		Memory:     config.Password.Argon2Memory,
+		Threads:    config.Password.Argon2Threads,
+		KeyLength:  config.Password.Argon2KeyLength,
+		SaltLength: config.Password.Argon2SaltLength,
+	})
 }
 
 // passwordItoa64 returns a string for mapping an int to the corresponding base 64 character.
@@ -38,24 +79,56 @@ func (p *Password) passwordItoa64() string {
 	return "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
 }
 
-// UserHashPassword hash a password using a secure hash.
+// UserHashPassword hash a password using argon2id.
+// The hash is encoded in the PHC string format: $argon2id$v=19$m=65536,t=1,p=4$<salt>$<hash>
 func (p *Password) UserHashPassword(password string) (string, error) {
-	salt := p.passwordGenerateSalt(hashCount)
+	if len(password) > maxPasswordLength {
+		return "", errors.New("password cannot be longer than 512")
+	}
+
+	salt := p.randomBytes(int(p.argon2.SaltLength))
+	key := argon2.IDKey([]byte(password), salt, p.argon2.Time, p.argon2.Memory, p.argon2.Threads, p.argon2.KeyLength)
+
+	return fmt.Sprintf(
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		models.PasswordPrefixArgon2id,
+		argon2.Version,
+		p.argon2.Memory,
+		p.argon2.Time,
+		p.argon2.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
 
-	hash, err := p.passwordCrypt("sha512", password, salt)
+// NeedsRehash tells if a hash was made by a legacy scheme or with other argon2id parameters than the current ones,
+// such hash should be replaced when the plain password is known, e.g. on sign in
+func (p *Password) NeedsRehash(hashedPassword string) bool {
+	params, _, _, err := p.decodeArgon2id(hashedPassword)
 	if err != nil {
-		return "", err
+		return true
 	}
-	return hash, nil
+	return params != p.argon2
 }
 
 // UserCheckPassword check whether a plain text password matches a stored hashed password
 func (p *Password) UserCheckPassword(password, hashedPassword string) error {
+	if len(hashedPassword) < 3 {
+		return errors.New("hashedPassword is too short")
+	}
+	if strings.HasPrefix(hashedPassword, models.PasswordPrefixArgon2id) {
+		return p.compareArgon2idHashAndPassword(password, hashedPassword)
+	}
+
 	var passwordType = hashedPassword[0:3]
 
 	if passwordType[0:2] == "U$" {
 		hashedPassword = hashedPassword[1:]
 		password = p.getMD5Hash(password)
+		if len(hashedPassword) < 3 {
+			return errors.New("hashedPassword is too short")
+		}
+		passwordType = hashedPassword[0:3]
 	}
 
 	switch passwordType {
@@ -72,6 +145,59 @@ func (p *Password) UserCheckPassword(password, hashedPassword string) error {
 	}
 }
 
+// compareArgon2idHashAndPassword compares an argon2id hash with the password,
+// the hash is computed with parameters stored in the hash
+func (p *Password) compareArgon2idHashAndPassword(password, hashedPassword string) error {
+	if len(password) > maxPasswordLength {
+		return errors.New("password cannot be longer than 512")
+	}
+
+	params, salt, key, err := p.decodeArgon2id(hashedPassword)
+	if err != nil {
+		return err
+	}
+
+	otherKey := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, params.KeyLength)
+	if subtle.ConstantTimeCompare(key, otherKey) != 1 {
+		return errors.New("hashedPassword is not the hash of the given password")
+	}
+	return nil
+}
+
+// decodeArgon2id parses parameters, the salt and the key of an argon2id hash
+func (p *Password) decodeArgon2id(hashedPassword string) (params Argon2Params, salt, key []byte, err error) {
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 || "$"+parts[1]+"$" != models.PasswordPrefixArgon2id {
+		return params, nil, nil, errors.New("password is not a valid argon2id hash")
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, errors.New("password is not a valid argon2id hash")
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, errors.New("password is not a valid argon2id hash")
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, errors.New("password is not a valid argon2id hash")
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return params, nil, nil, errors.New("password is not a valid argon2id hash")
+	}
+	if len(salt) == 0 || len(key) == 0 || params.Time == 0 || params.Threads == 0 {
+		return params, nil, nil, errors.New("password is not a valid argon2id hash")
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
+
 // compareHashAndPassword compares a hashed password with its possible hashed equivalent.
 func (p *Password) compareHashAndPasswordByAlgo(algo, password, hashedPassword string) error {
 	hash, err := p.passwordCrypt(algo, password, hashedPassword)
@@ -94,7 +220,7 @@ func (p *Password) compareHashAndPasswordByAlgo(algo, password, hashedPassword s
 func (p *Password) passwordCrypt(algo, password, setting string) (string, error) {
 	var output bytes.Buffer
 
-	if len(password) > 512 { // Prevent DoS attacks by refusing to hash large passwords.
+	if len(password) > maxPasswordLength { // Prevent DoS attacks by refusing to hash large passwords.
 		return "", errors.New("password cannot be longer than 512")
 	}
 	// The first 12 characters of an existing hash are its setting string.
@@ -136,7 +262,11 @@ func (p *Password) passwordCrypt(algo, password, setting string) (string, error)
 
 	outputStr := output.String()
 	if len(outputStr) == int(expected) {
-		return outputStr[0:hashLength], nil
+		// md5 hashes are shorter than hashLength and are kept whole
+		if len(outputStr) > hashLength {
+			outputStr = outputStr[0:hashLength]
+		}
+		return outputStr, nil
 	}
 
 	return "", errors.New("password is not valid")
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2Params keep tests fast, they are not meant for production
+var testArgon2Params = Argon2Params{Time: 1, Memory: 1024, Threads: 1, KeyLength: 32, SaltLength: 16}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	service := NewPasswordWithParams(testArgon2Params)
+
+	hash, err := service.UserHashPassword("Str0ng!Pass")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"), hash)
+	assert.NoError(t, service.UserCheckPassword("Str0ng!Pass", hash))
+	assert.Error(t, service.UserCheckPassword("Str0ng!Pas", hash))
+
+	other, err := service.UserHashPassword("Str0ng!Pass")
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, other, "every hash has its own salt")
+
+	_, err = service.UserHashPassword(strings.Repeat("a", 513))
+	assert.Error(t, err)
+}
+
+func TestCheckPasswordByLegacySchemes(t *testing.T) {
+	service := NewPasswordWithParams(testArgon2Params)
+
+	sha512, err := service.passwordCrypt("sha512", "secret", service.passwordGenerateSalt(minHashCount))
+	assert.NoError(t, err)
+	assert.NoError(t, service.UserCheckPassword("secret", sha512))
+	assert.Error(t, service.UserCheckPassword("Secret", sha512))
+
+	wrapped, err := service.passwordCrypt("md5", service.getMD5Hash("secret"), "$H$"+service.passwordGenerateSalt(minHashCount)[3:])
+	assert.NoError(t, err)
+	assert.NoError(t, service.UserCheckPassword("secret", "U"+wrapped))
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	assert.NoError(t, service.UserCheckPassword("secret", string(bcryptHash)))
+
+	assert.Error(t, service.UserCheckPassword("secret", ""))
+	assert.Error(t, service.UserCheckPassword("secret", "$argon2id$v=19$m=1024,t=1,p=1$broken"))
+}
+
+func TestNeedsRehash(t *testing.T) {
+	service := NewPasswordWithParams(testArgon2Params)
+
+	hash, err := service.UserHashPassword("secret")
+	assert.NoError(t, err)
+	assert.False(t, service.NeedsRehash(hash))
+
+	sha512, err := service.passwordCrypt("sha512", "secret", service.passwordGenerateSalt(minHashCount))
+	assert.NoError(t, err)
+	assert.True(t, service.NeedsRehash(sha512), "legacy hashes are upgraded")
+
+	tuned := testArgon2Params
+	tuned.Time = 2
+	assert.True(t, NewPasswordWithParams(tuned).NeedsRehash(hash), "hashes with outdated parameters are upgraded")
+	assert.NoError(t, NewPasswordWithParams(tuned).UserCheckPassword("secret", hash), "parameters are read from the hash")
+}
@@ -0,0 +1,36 @@
+package passwordpolicy
+
+import (
+	"github.com/inconshreveable/log15"
+
+	"github.com/Confialink/wallet-users/internal/config"
+	"github.com/Confialink/wallet-users/pkg/pwned"
+)
+
+// CompromisedChecker tells if a password is known from data breaches
+type CompromisedChecker interface {
+	IsCompromised(password string) (bool, error)
+}
+
+// disabledChecker is used when no breached passwords dataset is configured
+type disabledChecker struct{}
+
+func (disabledChecker) IsCompromised(string) (bool, error) {
+	return false, nil
+}
+
+// NewCompromisedChecker returns the checker of the local Pwned Passwords dataset from the config,
+// passwords are not checked if the dataset is not configured
+func NewCompromisedChecker(config *config.Configuration, logger log15.Logger) CompromisedChecker {
+	logger = logger.New("Service", "CompromisedChecker")
+	if config.Password.PwnedPasswordsDir == "" {
+		logger.Info("breached passwords dataset is not configured, passwords are not checked against breaches")
+		return disabledChecker{}
+	}
+
+	dataset, err := pwned.NewRangeDirectory(config.Password.PwnedPasswordsDir)
+	if err != nil {
+		panic("cannot open breached passwords dataset: " + err.Error())
+	}
+	return dataset
+}
@@ -0,0 +1,242 @@
+package passwordpolicy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Confialink/wallet-pkg-errors"
+	"github.com/inconshreveable/log15"
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	"github.com/Confialink/wallet-users/internal/services"
+	"github.com/Confialink/wallet-users/internal/services/syssettings"
+)
+
+// minUserInfoLength is the minimum length of a username or an email part which is looked for in a password,
+// shorter values match too many passwords by accident
+const minUserInfoLength = 3
+
+var (
+	// One of !"#$%&'\()*+,-./:;<=>?@[\]^_{|}~
+	// Reference: https://owasp.org/www-community/password-special-characters
+	specialCharacterPattern = regexp.MustCompile(`[!"#$%&'\\()*+,-./:;<=>?@[\]^_{|}~]`)
+	numberPattern           = regexp.MustCompile(`[0-9]`)
+	uppercaseLetterPattern  = regexp.MustCompile(`[A-Z]`)
+	lowercaseLetterPattern  = regexp.MustCompile(`[a-z]`)
+)
+
+// Policy checks passwords against the password policy configured in system settings,
+// keeps the history of passwords of users and tells if a password is expired
+type Policy struct {
+	sysSettings        *syssettings.SysSettings
+	historyRepository  *repositories.PasswordHistoryRepository
+	passwordService    *services.Password
+	compromisedChecker CompromisedChecker
+	logger             log15.Logger
+}
+
+func NewPolicy(
+	sysSettings *syssettings.SysSettings,
+	historyRepository *repositories.PasswordHistoryRepository,
+	passwordService *services.Password,
+	compromisedChecker CompromisedChecker,
+	logger log15.Logger,
+) *Policy {
+	return &Policy{
+		sysSettings,
+		historyRepository,
+		passwordService,
+		compromisedChecker,
+		logger.New("Service", "PasswordPolicy"),
+	}
+}
+
+// Settings returns the current password policy.
+// The default policy is returned if the settings can not be received, so passwords are never accepted unchecked.
+func (p *Policy) Settings() *syssettings.PasswordPolicySettings {
+	settings, err := p.sysSettings.GetPasswordPolicySettings()
+	if err != nil {
+		p.logger.Error("cannot get password policy settings, the default policy is used", "error", err)
+		return DefaultSettings()
+	}
+	return settings
+}
+
+// Validate checks the password of the given user, source is the name of the request field
+// the password is passed in. It returns *errors.ValidationErrors with an error per violated rule.
+// Passwords are also checked against breached passwords and, for existing users, against the last passwords of the history.
+func (p *Policy) Validate(password string, user *models.User, source string) error {
+	settings := p.Settings()
+	vErrs := Check(settings, password, user, source)
+	if len(vErrs) > 0 {
+		return &errors.ValidationErrors{Errors: vErrs}
+	}
+
+	if settings.DenyCompromised && p.isCompromised(password) {
+		return &errors.ValidationErrors{Errors: []errors.ValidationError{{
+			Title:  "Password is known from data breaches, choose another one",
+			Source: source,
+			Code:   responses.PasswordCompromised,
+		}}}
+	}
+
+	if user == nil || user.UID == "" || settings.HistoryDepth == 0 {
+		return nil
+	}
+	reused, err := p.isReused(password, user, settings.HistoryDepth)
+	if err != nil {
+		return err
+	}
+	if reused {
+		return &errors.ValidationErrors{Errors: []errors.ValidationError{{
+			Title:  fmt.Sprintf("Password must differ from the last %d passwords", settings.HistoryDepth),
+			Source: source,
+			Code:   responses.PasswordRecentlyUsed,
+		}}}
+	}
+	return nil
+}
+
+// Record adds the current password hash of the user to the history in the given transaction
+// and removes passwords which are not needed for the reuse check anymore
+func (p *Policy) Record(tx *gorm.DB, user *models.User) error {
+	repo := p.historyRepository.WrapContext(tx)
+	if err := repo.Create(&models.PasswordHistory{UserUID: user.UID, Password: user.Password}); err != nil {
+		return err
+	}
+
+	// the latest record is kept anyway because it tells the age of the current password
+	keep := p.Settings().HistoryDepth
+	if keep == 0 {
+		keep = 1
+	}
+	return repo.DeleteExceptLatest(user.UID, keep)
+}
+
+// Expired tells if the current password of the user is older than the max age of the policy
+func (p *Policy) Expired(user *models.User) (bool, error) {
+	maxAge := MaxAge(p.Settings(), user)
+	if maxAge == 0 {
+		return false, nil
+	}
+
+	// users created before the history was introduced have their passwords since creation
+	changedAt := user.CreatedAt
+	latest, err := p.historyRepository.FindLatestByUserUID(user.UID, 1)
+	if err != nil {
+		return false, err
+	}
+	if len(latest) > 0 {
+		changedAt = latest[0].CreatedAt
+	}
+	return time.Since(changedAt) > maxAge, nil
+}
+
+// isCompromised checks the password against breached passwords.
+// The password is accepted if the dataset can not be read, the other rules of the policy still apply.
+func (p *Policy) isCompromised(password string) bool {
+	compromised, err := p.compromisedChecker.IsCompromised(password)
+	if err != nil {
+		p.logger.Error("cannot check password against breached passwords", "error", err)
+		return false
+	}
+	return compromised
+}
+
+// isReused checks the password against the given number of the latest passwords of the user
+func (p *Policy) isReused(password string, user *models.User, depth uint64) (bool, error) {
+	history, err := p.historyRepository.FindLatestByUserUID(user.UID, depth)
+	if err != nil {
+		return false, err
+	}
+
+	hashes := make([]string, 0, len(history)+1)
+	for _, record := range history {
+		hashes = append(hashes, record.Password)
+	}
+	// the stored password may be missing in the history if it has been set before the history was introduced
+	if len(hashes) == 0 && user.Password != "" {
+		hashes = append(hashes, user.Password)
+	}
+
+	for _, hash := range hashes {
+		if hash != "" && p.passwordService.UserCheckPassword(password, hash) == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// MaxAge returns the max age of passwords of the user, back-office users may have a shorter one
+func MaxAge(settings *syssettings.PasswordPolicySettings, user *models.User) time.Duration {
+	if settings.StaffMaxAge > 0 && (user.IsAdmin() || user.IsRoot()) {
+		return settings.StaffMaxAge
+	}
+	return settings.MaxAge
+}
+
+// DefaultSettings returns the policy used when nothing is configured
+func DefaultSettings() *syssettings.PasswordPolicySettings {
+	return &syssettings.PasswordPolicySettings{
+		MinLength:               syssettings.DefaultPasswordMinLength,
+		RequireUppercase:        true,
+		RequireLowercase:        true,
+		RequireNumber:           true,
+		RequireSpecialCharacter: true,
+		DenyUserInfo:            true,
+		DenyCompromised:         true,
+	}
+}
+
+// Check returns violations of the policy by the password, the user may be nil if it is not known yet
+func Check(settings *syssettings.PasswordPolicySettings, password string, user *models.User, source string) []errors.ValidationError {
+	var vErrs []errors.ValidationError
+	violation := func(code, title string) {
+		vErrs = append(vErrs, errors.ValidationError{Title: title, Source: source, Code: code})
+	}
+
+	if utf8.RuneCountInString(password) < settings.MinLength {
+		violation(responses.PasswordTooShort, fmt.Sprintf("Password must be at least %d characters long", settings.MinLength))
+	}
+	if settings.RequireUppercase && !uppercaseLetterPattern.MatchString(password) {
+		violation(responses.UppercaseLetterRequired, "Password must contain at least one uppercase letter")
+	}
+	if settings.RequireLowercase && !lowercaseLetterPattern.MatchString(password) {
+		violation(responses.LowercaseLetterRequired, "Password must contain at least one lowercase letter")
+	}
+	if settings.RequireNumber && !numberPattern.MatchString(password) {
+		violation(responses.NumberRequired, "Password must contain at least one number")
+	}
+	if settings.RequireSpecialCharacter && !specialCharacterPattern.MatchString(password) {
+		violation(responses.SpecialCharacterRequired, "Password must contain at least one special character")
+	}
+	if settings.DenyUserInfo && user != nil && containsUserInfo(password, user) {
+		violation(responses.PasswordContainsUserInfo, "Password must not contain the username or the email")
+	}
+
+	return vErrs
+}
+
+// containsUserInfo checks case-insensitively if the password contains the username,
+// the email or the local part of the email
+func containsUserInfo(password string, user *models.User) bool {
+	password = strings.ToLower(password)
+	values := []string{user.Username, user.Email}
+	if at := strings.LastIndex(user.Email, "@"); at > 0 {
+		values = append(values, user.Email[:at])
+	}
+
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if utf8.RuneCountInString(value) >= minUserInfoLength && strings.Contains(password, value) {
+			return true
+		}
+	}
+	return false
+}
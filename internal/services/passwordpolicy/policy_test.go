@@ -0,0 +1,90 @@
+package passwordpolicy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/inconshreveable/log15"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	"github.com/Confialink/wallet-users/internal/services/syssettings"
+)
+
+func codes(t *testing.T, settings *syssettings.PasswordPolicySettings, password string, user *models.User) []string {
+	var list []string
+	for _, vErr := range Check(settings, password, user, "password") {
+		assert.Equal(t, "password", vErr.Source)
+		list = append(list, vErr.Code)
+	}
+	return list
+}
+
+func TestCheck(t *testing.T) {
+	user := &models.User{Username: "johnny", Email: "john.doe@example.com"}
+
+	assert.Empty(t, codes(t, DefaultSettings(), "Str0ng!Pass", user))
+	assert.Equal(t, []string{
+		responses.PasswordTooShort,
+		responses.UppercaseLetterRequired,
+		responses.NumberRequired,
+		responses.SpecialCharacterRequired,
+	}, codes(t, DefaultSettings(), "weak", user), "every violated rule is returned")
+
+	settings := &syssettings.PasswordPolicySettings{MinLength: 12}
+	assert.Equal(t, []string{responses.PasswordTooShort}, codes(t, settings, "lowercase", user))
+	assert.Empty(t, codes(t, settings, "только-кириллица", user), "length is counted in characters")
+}
+
+func TestCheckUserInfo(t *testing.T) {
+	user := &models.User{Username: "johnny", Email: "john.doe@example.com"}
+	settings := DefaultSettings()
+
+	assert.Equal(t, []string{responses.PasswordContainsUserInfo}, codes(t, settings, "1!JOHNNYpass", user))
+	assert.Equal(t, []string{responses.PasswordContainsUserInfo}, codes(t, settings, "1!John.Doe-pass", user))
+	assert.Equal(t, []string{responses.PasswordContainsUserInfo}, codes(t, settings, "P1!john.doe@example.com", user))
+	assert.Empty(t, codes(t, settings, "1!JohnPass", user))
+	assert.Empty(t, codes(t, settings, "1!JOHNNYpass", nil), "the user may be unknown")
+
+	settings.DenyUserInfo = false
+	assert.Empty(t, codes(t, settings, "1!JOHNNYpass", user))
+
+	shortName := &models.User{Username: "jo"}
+	assert.Empty(t, codes(t, DefaultSettings(), "1!JoJoPass", shortName), "short values are not looked for")
+}
+
+func TestMaxAge(t *testing.T) {
+	settings := &syssettings.PasswordPolicySettings{MaxAge: 180 * 24 * time.Hour}
+	client := &models.User{RoleName: models.RoleClient}
+	admin := &models.User{RoleName: models.RoleAdmin}
+
+	assert.Equal(t, 180*24*time.Hour, MaxAge(settings, client))
+	assert.Equal(t, 180*24*time.Hour, MaxAge(settings, admin), "max age is used if staff max age is not set")
+
+	settings.StaffMaxAge = 90 * 24 * time.Hour
+	assert.Equal(t, 180*24*time.Hour, MaxAge(settings, client))
+	assert.Equal(t, 90*24*time.Hour, MaxAge(settings, admin))
+	assert.Equal(t, 90*24*time.Hour, MaxAge(settings, &models.User{RoleName: models.RoleRoot}))
+}
+
+type stubChecker struct {
+	compromised bool
+	err         error
+}
+
+func (c stubChecker) IsCompromised(string) (bool, error) {
+	return c.compromised, c.err
+}
+
+func TestIsCompromised(t *testing.T) {
+	policy := &Policy{compromisedChecker: stubChecker{compromised: true}, logger: log15.New()}
+	assert.True(t, policy.isCompromised("password"))
+
+	policy.compromisedChecker = stubChecker{}
+	assert.False(t, policy.isCompromised("password"))
+
+	policy.compromisedChecker = stubChecker{compromised: true, err: errors.New("unreadable range file")}
+	assert.False(t, policy.isCompromised("password"), "passwords are accepted if the dataset can not be read")
+}
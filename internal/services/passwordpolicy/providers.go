@@ -0,0 +1,8 @@
+package passwordpolicy
+
+func Providers() []interface{} {
+	return []interface{}{
+		NewPolicy,
+		NewCompromisedChecker,
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/Confialink/wallet-users/internal/services/gdpr"
 	"github.com/Confialink/wallet-users/internal/services/notifications"
 	"github.com/Confialink/wallet-users/internal/services/permissions"
+	"github.com/Confialink/wallet-users/internal/services/retention"
 	"github.com/Confialink/wallet-users/internal/services/syssettings"
 	system_logs "github.com/Confialink/wallet-users/internal/services/system-logs"
 	"github.com/Confialink/wallet-users/internal/services/verification"
@@ -19,11 +20,15 @@ func Providers() []interface{} {
 		syssettings.NewSysSettings,
 		syssettings.NewRpcClientFactory,
 		system_logs.SystemLogsServiceFactory,
-		NewPassword,
+		PasswordFactory,
 		notifications.NewRpcClientFactory,
 		notifications.NewNotifications,
 		verification.NewCreator,
 		verification.NewValidator,
 		gdpr.NewService,
+		gdpr.NewErasure,
+		gdpr.NewExport,
+		gdpr.NewConsents,
+		retention.NewPurger,
 	}
 }
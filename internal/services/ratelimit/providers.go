@@ -0,0 +1,7 @@
+package ratelimit
+
+func Providers() []interface{} {
+	return []interface{}{
+		NewStore,
+	}
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/inconshreveable/log15"
+
+	"github.com/Confialink/wallet-users/internal/config"
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/pkg/ratelimit"
+)
+
+// purgeEvery is the number of takes between removals of expired buckets
+const purgeEvery = 1000
+
+// NewStore returns the store of buckets configured by VELMIE_WALLET_USERS_RATE_LIMIT_BACKEND
+func NewStore(cfg *config.Configuration, repository *repositories.RateLimitBucketRepository, logger log15.Logger) ratelimit.Store {
+	if cfg.RateLimit.Backend == config.RateLimitBackendDb {
+		return NewDbStore(repository, logger)
+	}
+	return ratelimit.NewMemoryStore()
+}
+
+// DbStore keeps buckets in the database, so all instances of the service share them
+type DbStore struct {
+	repository *repositories.RateLimitBucketRepository
+	takes      uint64
+	logger     log15.Logger
+}
+
+func NewDbStore(repository *repositories.RateLimitBucketRepository, logger log15.Logger) *DbStore {
+	return &DbStore{repository: repository, logger: logger.New("Service", "RateLimitDbStore")}
+}
+
+// Take takes a token from the bucket of the key, the bucket is locked while it is updated
+func (s *DbStore) Take(key string, limit ratelimit.Limit) (bool, time.Duration, error) {
+	if !limit.Enabled() {
+		return true, 0, nil
+	}
+
+	now := time.Now()
+	if atomic.AddUint64(&s.takes, 1)%purgeEvery == 0 {
+		if err := s.repository.DeleteExpired(now); err != nil {
+			s.logger.Error("failed to remove expired buckets", "error", err)
+		}
+	}
+
+	tx := s.repository.DB.Begin()
+	repository := s.repository.WrapContext(tx)
+
+	record, err := repository.FindOrCreateForUpdate(key, now)
+	if err != nil {
+		tx.Rollback()
+		return false, 0, err
+	}
+
+	bucket := &ratelimit.Bucket{Taken: record.Taken, UpdatedAt: record.TakenAt}
+	allowed, retryAfter := bucket.Take(limit, now)
+
+	if err := repository.Update(&models.RateLimitBucket{
+		Key:       record.Key,
+		Taken:     bucket.Taken,
+		TakenAt:   bucket.UpdatedAt,
+		ExpiresAt: bucket.FullAt(limit),
+	}); err != nil {
+		tx.Rollback()
+		return false, 0, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return false, 0, err
+	}
+	return allowed, retryAfter, nil
+}
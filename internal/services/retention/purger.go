@@ -0,0 +1,150 @@
+package retention
+
+import (
+	"expvar"
+	"time"
+
+	"github.com/inconshreveable/log15"
+
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/internal/services/syssettings"
+)
+
+const (
+	// batchSize is the number of rows deleted by a single statement, small batches keep locks short
+	batchSize = 1000
+	// maxBatches limits the rows deleted from a table by a single run, the rest is deleted by the next runs
+	maxBatches = 100
+)
+
+// Metrics of purges by table, they are published by expvar
+var (
+	purgedRows  = expvar.NewMap("retention_purged_rows")
+	lastPurgeAt = expvar.NewMap("retention_last_purge_at")
+	failures    = expvar.NewMap("retention_failures")
+)
+
+// policy describes which rows of a table are kept longer than the retention period.
+// The condition gets the cutoff time (now minus the period) as its parameter.
+type policy struct {
+	table     string
+	condition string
+	period    func(settings *syssettings.RetentionSettings) time.Duration
+}
+
+var policies = []policy{
+	{"confirmation_codes", "expires_at < ?", func(s *syssettings.RetentionSettings) time.Duration {
+		return s.ConfirmationCodes
+	}},
+	{"fail_auth_attempts", "created_at < ?", func(s *syssettings.RetentionSettings) time.Duration {
+		return s.FailAuthAttempts
+	}},
+	{"blocked_ips", "blocked_until < ?", func(s *syssettings.RetentionSettings) time.Duration {
+		return s.BlockedIps
+	}},
+	// invites without the expiry time never expire, a zero time may be stored for them
+	{"invites", "expires_at < ? AND expires_at > '1970-01-01 00:00:01'", func(s *syssettings.RetentionSettings) time.Duration {
+		return s.Invites
+	}},
+	{"users_accesslog", "created_at < ?", func(s *syssettings.RetentionSettings) time.Duration {
+		return s.AccessLog
+	}},
+}
+
+// Result is the outcome of the purge of a table
+type Result struct {
+	Table  string
+	Cutoff time.Time
+	// Rows is the number of deleted rows, or the number of rows to delete in dry-run mode
+	Rows     int64
+	Duration time.Duration
+	Err      error
+}
+
+// Purger deletes rows of tables which grow without bound when they are kept longer than
+// the retention periods in system settings
+type Purger struct {
+	repository  *repositories.RetentionRepository
+	sysSettings *syssettings.SysSettings
+	logger      log15.Logger
+}
+
+func NewPurger(
+	repository *repositories.RetentionRepository,
+	sysSettings *syssettings.SysSettings,
+	logger log15.Logger,
+) *Purger {
+	return &Purger{
+		repository,
+		sysSettings,
+		logger.New("service", "RetentionPurger"),
+	}
+}
+
+// Purge deletes rows of every table with a retention period in batches. In dry-run mode rows are only counted.
+// A failure of a table is reported in its result and does not stop the purge of other tables.
+func (p *Purger) Purge(dryRun bool) ([]*Result, error) {
+	settings, err := p.sysSettings.GetRetentionSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var results []*Result
+	for _, policy := range policies {
+		period := policy.period(settings)
+		if period == 0 {
+			continue
+		}
+
+		result := &Result{Table: policy.table, Cutoff: now.Add(-period)}
+		started := time.Now()
+		if dryRun {
+			result.Rows, result.Err = p.repository.Count(policy.table, policy.condition, result.Cutoff)
+		} else {
+			result.Rows, result.Err = p.deleteInBatches(policy, result.Cutoff)
+			p.report(result)
+		}
+		result.Duration = time.Since(started)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// deleteInBatches deletes rows until a batch is not full or the limit of batches is reached
+func (p *Purger) deleteInBatches(policy policy, cutoff time.Time) (int64, error) {
+	var total int64
+	for i := 0; i < maxBatches; i++ {
+		deleted, err := p.repository.DeleteBatch(policy.table, policy.condition, cutoff, batchSize)
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+		if deleted < batchSize {
+			break
+		}
+	}
+	return total, nil
+}
+
+// report updates metrics of the table and logs the result
+func (p *Purger) report(result *Result) {
+	purgedRows.Add(result.Table, result.Rows)
+	if result.Err != nil {
+		failures.Add(result.Table, 1)
+		p.logger.Error("failed to purge table", "table", result.Table, "deleted", result.Rows, "error", result.Err)
+		return
+	}
+
+	lastPurgeAt.Set(result.Table, expvarTime(time.Now()))
+	if result.Rows > 0 {
+		p.logger.Info("table is purged", "table", result.Table, "cutoff", result.Cutoff, "deleted", result.Rows)
+	}
+}
+
+// expvarTime publishes the time as Unix seconds
+func expvarTime(t time.Time) *expvar.Int {
+	v := new(expvar.Int)
+	v.Set(t.Unix())
+	return v
+}
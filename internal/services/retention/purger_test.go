@@ -0,0 +1,109 @@
+package retention
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	pbSettings "github.com/Confialink/wallet-settings/rpc/proto/settings"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/inconshreveable/log15"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/internal/services/syssettings"
+	"github.com/Confialink/wallet-users/internal/services/syssettings/mocks"
+	"github.com/Confialink/wallet-users/internal/tests/mocks/vendor-mocks/rpc/settings"
+)
+
+// newPurger returns a purger of the tables with retention periods in days, other tables are disabled
+func newPurger(t *testing.T, days map[string]string) (*Purger, sqlmock.Sqlmock) {
+	db, dbMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gormDb, err := gorm.Open("mysql", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var values []*pbSettings.Setting
+	for _, table := range []string{"confirmation_codes", "fail_auth_attempts", "blocked_ips", "invites", "accesslog"} {
+		value, ok := days[table]
+		if !ok {
+			value = "0"
+		}
+		values = append(values, &pbSettings.Setting{Path: "profile/data-retention/" + table, Value: value})
+	}
+	client := &settings.MockSettingsHandler{}
+	req := &pbSettings.Request{Path: "profile/data-retention/%"}
+	client.On("List", context.Background(), req).Return(&pbSettings.Response{Settings: values}, nil)
+	clientFactory := &mocks.ClientFactory{}
+	clientFactory.On("NewClient").Return(client, nil)
+
+	purger := NewPurger(
+		repositories.NewRetentionRepository(gormDb),
+		syssettings.NewSysSettings(clientFactory, log15.New()),
+		log15.New(),
+	)
+	return purger, dbMock
+}
+
+func TestPurgeDeletesInBatches(t *testing.T) {
+	purger, dbMock := newPurger(t, map[string]string{"fail_auth_attempts": "30"})
+	deleteQuery := regexp.QuoteMeta("DELETE FROM `fail_auth_attempts` WHERE created_at < ? LIMIT ?")
+	dbMock.ExpectExec(deleteQuery).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, batchSize))
+	dbMock.ExpectExec(deleteQuery).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 5))
+
+	started := time.Now()
+	results, err := purger.Purge(false)
+
+	assert.NoError(t, err)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "fail_auth_attempts", results[0].Table)
+		assert.Equal(t, int64(batchSize+5), results[0].Rows)
+		assert.NoError(t, results[0].Err)
+		assert.WithinDuration(t, started.Add(-30*24*time.Hour), results[0].Cutoff, time.Minute)
+	}
+}
+
+func TestPurgeDryRunCountsRows(t *testing.T) {
+	purger, dbMock := newPurger(t, map[string]string{"invites": "30"})
+	// the table is quoted by the dialect
+	dbMock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM") + ".invites. +" + regexp.QuoteMeta("WHERE (expires_at < ? AND expires_at > '1970-01-01 00:00:01')")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(42))
+
+	results, err := purger.Purge(true)
+
+	assert.NoError(t, err)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "invites", results[0].Table)
+		assert.Equal(t, int64(42), results[0].Rows)
+	}
+}
+
+func TestPurgeGoesOnAfterFailedTable(t *testing.T) {
+	purger, dbMock := newPurger(t, map[string]string{"confirmation_codes": "7", "blocked_ips": "7"})
+	dbMock.ExpectExec(regexp.QuoteMeta("DELETE FROM `confirmation_codes`")).
+		WillReturnError(assert.AnError)
+	dbMock.ExpectExec(regexp.QuoteMeta("DELETE FROM `blocked_ips` WHERE blocked_until < ? LIMIT ?")).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	results, err := purger.Purge(false)
+
+	assert.NoError(t, err)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+	if assert.Len(t, results, 2) {
+		assert.Error(t, results[0].Err)
+		assert.NoError(t, results[1].Err)
+		assert.Equal(t, int64(3), results[1].Rows)
+	}
+}
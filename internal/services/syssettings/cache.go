@@ -0,0 +1,119 @@
+package syssettings
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	pb "github.com/Confialink/wallet-settings/rpc/proto/settings"
+)
+
+// DefaultCacheTTL is the period during which fetched settings are used without calling the settings service
+const DefaultCacheTTL = time.Minute
+
+type cacheEntry struct {
+	response  *pb.Response
+	fetchedAt time.Time
+	// stale is set when settings were changed, the response is kept as the last known good value
+	stale bool
+}
+
+// cache keeps responses of the settings service by request key.
+// Expired and invalidated entries are not removed so they can be used if the settings service is unreachable.
+type cache struct {
+	ttl     time.Duration
+	mu      sync.RWMutex
+	entries map[string]*cacheEntry
+}
+
+func newCache(ttl time.Duration) *cache {
+	return &cache{ttl: ttl, entries: make(map[string]*cacheEntry)}
+}
+
+// fresh returns a cached response if it is neither expired nor invalidated
+func (c *cache) fresh(key string) (*pb.Response, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[key]
+	if !ok || entry.stale || time.Since(entry.fetchedAt) >= c.ttl {
+		return nil, false
+	}
+	return entry.response, true
+}
+
+// lastKnown returns a cached response regardless of its age
+func (c *cache) lastKnown(key string) (*pb.Response, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	return entry.response, true
+}
+
+func (c *cache) store(key string, response *pb.Response) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = &cacheEntry{response: response, fetchedAt: time.Now()}
+}
+
+// invalidate marks all entries as stale
+func (c *cache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, entry := range c.entries {
+		entry.stale = true
+	}
+}
+
+// list returns settings matching the path pattern
+func (s *SysSettings) list(path string) (*pb.Response, error) {
+	return s.fetch("list:"+path, func(client pb.SettingsHandler) (*pb.Response, error) {
+		return client.List(context.Background(), &pb.Request{Path: path})
+	})
+}
+
+// get returns a single setting by the path
+func (s *SysSettings) get(path string) (*pb.Response, error) {
+	return s.fetch("get:"+path, func(client pb.SettingsHandler) (*pb.Response, error) {
+		return client.Get(context.Background(), &pb.Request{Path: path})
+	})
+}
+
+// fetch returns a cached response or calls the settings service.
+// If the call fails the last known good response is returned.
+func (s *SysSettings) fetch(key string, call func(client pb.SettingsHandler) (*pb.Response, error)) (*pb.Response, error) {
+	if response, ok := s.cache.fresh(key); ok {
+		return response, nil
+	}
+
+	response, err := s.call(call)
+	if err != nil {
+		if lastKnown, ok := s.cache.lastKnown(key); ok {
+			s.logger.Warn("settings service is unavailable, last known settings are used", "key", key, "error", err)
+			return lastKnown, nil
+		}
+		return nil, err
+	}
+
+	s.cache.store(key, response)
+	return response, nil
+}
+
+func (s *SysSettings) call(call func(client pb.SettingsHandler) (*pb.Response, error)) (*pb.Response, error) {
+	client, err := s.clientFactory.NewClient()
+	if err != nil {
+		return nil, err
+	}
+	return call(client)
+}
+
+// Invalidate forces settings to be fetched from the settings service on the next call
+func (s *SysSettings) Invalidate() {
+	s.cache.invalidate()
+}
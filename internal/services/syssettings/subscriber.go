@@ -0,0 +1,17 @@
+package syssettings
+
+import (
+	messagebroker "github.com/Confialink/wallet-users/internal/services/message-broker"
+)
+
+// SettingsChangedSubject is the subject the settings service publishes to when settings are changed
+const SettingsChangedSubject = "settings.changed"
+
+// SubscribeToChanges invalidates cached settings when the settings service reports changes.
+// Every instance of the service keeps its own cache, so the subscription does not use a queue group.
+func (s *SysSettings) SubscribeToChanges(broker messagebroker.MessageBroker) error {
+	return broker.Subscribe(SettingsChangedSubject, func(_ string) {
+		s.logger.Info("settings are changed, cached settings are invalidated")
+		s.Invalidate()
+	})
+}
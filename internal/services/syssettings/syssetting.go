@@ -1,12 +1,12 @@
 package syssettings
 
 import (
-	"context"
 	"fmt"
 	"strconv"
 	"time"
 
 	pb "github.com/Confialink/wallet-settings/rpc/proto/settings"
+	"github.com/inconshreveable/log15"
 )
 
 const (
@@ -27,16 +27,47 @@ const (
 	loginUsernameUsePath     = "regional/login/failed_login_username_use"
 	loginUserUsePath         = "regional/login/failed_login_user_use"
 	loginUserWindowPath      = "regional/login/failed_login_user_window"
+	loginCaptchaAfterPath    = "regional/login/failed_login_captcha_after"
+	loginDelayPath           = "regional/login/failed_login_delay"
 
 	userOptionsDormantPath = "profile/user-options/dormant"
+
+	passwordMinLengthPath        = "profile/password-policy/min_length"
+	passwordUppercasePath        = "profile/password-policy/require_uppercase"
+	passwordLowercasePath        = "profile/password-policy/require_lowercase"
+	passwordNumberPath           = "profile/password-policy/require_number"
+	passwordSpecialCharacterPath = "profile/password-policy/require_special_character"
+	passwordDenyUserInfoPath     = "profile/password-policy/deny_user_info"
+	passwordMaxAgePath           = "profile/password-policy/max_age"
+	passwordStaffMaxAgePath      = "profile/password-policy/staff_max_age"
+	passwordHistoryDepthPath     = "profile/password-policy/history_depth"
+	passwordDenyCompromisedPath  = "profile/password-policy/deny_compromised"
+
+	retentionConfirmationCodesPath = "profile/data-retention/confirmation_codes"
+	retentionFailAuthAttemptsPath  = "profile/data-retention/fail_auth_attempts"
+	retentionBlockedIpsPath        = "profile/data-retention/blocked_ips"
+	retentionInvitesPath           = "profile/data-retention/invites"
+	retentionAccessLogPath         = "profile/data-retention/accesslog"
+
+	// DefaultPasswordMinLength is used if the minimum length is not set or is set lower
+	DefaultPasswordMinLength = 8
+
+	// Retention periods in days used if they are not set
+	DefaultRetentionConfirmationCodesDays = 7
+	DefaultRetentionFailAuthAttemptsDays  = 30
+	DefaultRetentionBlockedIpsDays        = 7
+	DefaultRetentionInvitesDays           = 30
+	DefaultRetentionAccessLogDays         = 365
 )
 
 type SysSettings struct {
 	clientFactory ClientFactory
+	cache         *cache
+	logger        log15.Logger
 }
 
-func NewSysSettings(clientFactory ClientFactory) *SysSettings {
-	return &SysSettings{clientFactory}
+func NewSysSettings(clientFactory ClientFactory, logger log15.Logger) *SysSettings {
+	return &SysSettings{clientFactory, newCache(DefaultCacheTTL), logger.New("Service", "SysSettings")}
 }
 
 // TimeSettings struct has timezone and date format
@@ -53,6 +84,10 @@ type LoginSecuritySettings struct {
 	FailedLoginUsernameUse     bool
 	FailedLoginUserUse         uint64
 	FailedLoginUserWindow      uint64
+	// FailedLoginCaptchaAfter is the number of recent failed attempts after which sign in requires CAPTCHA, 0 disables it
+	FailedLoginCaptchaAfter uint64
+	// FailedLoginDelay is the delay after the first failed attempt before the next one is accepted, it doubles with every next one
+	FailedLoginDelay time.Duration
 }
 
 type GDPRSettings struct {
@@ -63,6 +98,36 @@ type MaintenanceModeSettings struct {
 	Enabled bool
 }
 
+// PasswordPolicySettings describes requirements to passwords set by users.
+// Character classes, the user info and the compromised password checks are required unless they are explicitly set to "no".
+type PasswordPolicySettings struct {
+	MinLength               int
+	RequireUppercase        bool
+	RequireLowercase        bool
+	RequireNumber           bool
+	RequireSpecialCharacter bool
+	DenyUserInfo            bool
+	// DenyCompromised rejects passwords known from data breaches, it works if the breached passwords dataset is configured
+	DenyCompromised bool
+	// MaxAge is zero if passwords do not expire
+	MaxAge time.Duration
+	// StaffMaxAge overrides MaxAge for back-office users (admin and root) if it is not zero
+	StaffMaxAge time.Duration
+	// HistoryDepth is the number of previous passwords which cannot be reused
+	HistoryDepth uint64
+}
+
+// RetentionSettings describes how long rows of tables which grow without bound are kept.
+// Expiring rows are kept for the period after they expire, other rows after they are created.
+// Zero disables the purge of a table.
+type RetentionSettings struct {
+	ConfirmationCodes time.Duration
+	FailAuthAttempts  time.Duration
+	BlockedIps        time.Duration
+	Invites           time.Duration
+	AccessLog         time.Duration
+}
+
 type AutologoutSettings struct {
 	Enabled bool
 	Timeout time.Duration
@@ -71,12 +136,7 @@ type AutologoutSettings struct {
 
 // GetTimeSettings returns new TimeSettings from settings service or err if can not get it
 func (s *SysSettings) GetTimeSettings() (*TimeSettings, error) {
-	client, err := s.clientFactory.NewClient()
-	if err != nil {
-		return nil, err
-	}
-
-	response, err := client.List(context.Background(), &pb.Request{Path: "regional/general/%"})
+	response, err := s.list("regional/general/%")
 	if err != nil {
 		return nil, err
 	}
@@ -91,12 +151,7 @@ func (s *SysSettings) GetTimeSettings() (*TimeSettings, error) {
 
 func (s *SysSettings) GetAutologoutSettings() (*AutologoutSettings, error) {
 	autologoutSettings := &AutologoutSettings{}
-	client, err := s.clientFactory.NewClient()
-	if err != nil {
-		return nil, err
-	}
-
-	response, err := client.List(context.Background(), &pb.Request{Path: "profile/autologout/%"})
+	response, err := s.list("profile/autologout/%")
 	if err != nil {
 		return nil, err
 	}
@@ -121,12 +176,7 @@ func (s *SysSettings) GetAutologoutSettings() (*AutologoutSettings, error) {
 
 // GetGDPRSettings returns GDPR module settings from settings service or err if can not get it
 func (s *SysSettings) GetGDPRSettings() (*GDPRSettings, error) {
-	client, err := s.clientFactory.NewClient()
-	if err != nil {
-		return nil, err
-	}
-
-	response, err := client.Get(context.Background(), &pb.Request{Path: gdprSettingPath})
+	response, err := s.get(gdprSettingPath)
 	if err != nil {
 		return nil, err
 	}
@@ -139,32 +189,26 @@ func (s *SysSettings) GetGDPRSettings() (*GDPRSettings, error) {
 }
 
 func (s *SysSettings) GetLoginSecuritySettings() (*LoginSecuritySettings, error) {
-	client, err := s.clientFactory.NewClient()
+	response, err := s.list("regional/login/%")
 	if err != nil {
 		return nil, err
 	}
 	settings := LoginSecuritySettings{}
 
-	response, err := client.List(context.Background(), &pb.Request{Path: "regional/login/%"})
-	if err != nil {
-		return &settings, err
-	}
-
 	settings.FailedLoginUsernameCleanup, _ = strconv.ParseUint(getSettingValue(response.Settings, loginUsernameCleanupPath), 10, 16)
 	settings.FailedLoginUsernameLimit, _ = strconv.ParseUint(getSettingValue(response.Settings, loginUsernameLimitPath), 10, 16)
 	settings.FailedLoginUsernameUse = "yes" == getSettingValue(response.Settings, loginUsernameUsePath)
 	settings.FailedLoginUserUse, _ = strconv.ParseUint(getSettingValue(response.Settings, loginUserUsePath), 10, 16)
 	settings.FailedLoginUserWindow, _ = strconv.ParseUint(getSettingValue(response.Settings, loginUserWindowPath), 10, 16)
+	settings.FailedLoginCaptchaAfter, _ = strconv.ParseUint(getSettingValue(response.Settings, loginCaptchaAfterPath), 10, 16)
+	delayMs, _ := strconv.ParseUint(getSettingValue(response.Settings, loginDelayPath), 10, 16)
+	settings.FailedLoginDelay = time.Duration(delayMs) * time.Millisecond
 
 	return &settings, nil
 }
 
 func (s *SysSettings) GetDormantDuration() (time.Duration, error) {
-	client, err := s.clientFactory.NewClient()
-	if err != nil {
-		return time.Nanosecond, err
-	}
-	response, err := client.Get(context.Background(), &pb.Request{Path: userOptionsDormantPath})
+	response, err := s.get(userOptionsDormantPath)
 	if err != nil {
 		return time.Nanosecond, err
 	}
@@ -178,14 +222,55 @@ func (s *SysSettings) GetDormantDuration() (time.Duration, error) {
 	return time.ParseDuration(fmt.Sprintf("%dh", hoursCount))
 }
 
-// GetMaintenanceModeSettings returns maintenance mode settings from settings service or err if can not get it
-func (s *SysSettings) GetMaintenanceModeSettings() (*MaintenanceModeSettings, error) {
-	client, err := s.clientFactory.NewClient()
+// GetPasswordPolicySettings returns password policy settings from settings service or err if can not get it
+func (s *SysSettings) GetPasswordPolicySettings() (*PasswordPolicySettings, error) {
+	response, err := s.list("profile/password-policy/%")
+	if err != nil {
+		return nil, err
+	}
+
+	settings := PasswordPolicySettings{}
+	settings.MinLength, _ = strconv.Atoi(getSettingValue(response.Settings, passwordMinLengthPath))
+	if settings.MinLength < DefaultPasswordMinLength {
+		settings.MinLength = DefaultPasswordMinLength
+	}
+	settings.RequireUppercase = "no" != getSettingValue(response.Settings, passwordUppercasePath)
+	settings.RequireLowercase = "no" != getSettingValue(response.Settings, passwordLowercasePath)
+	settings.RequireNumber = "no" != getSettingValue(response.Settings, passwordNumberPath)
+	settings.RequireSpecialCharacter = "no" != getSettingValue(response.Settings, passwordSpecialCharacterPath)
+	settings.DenyUserInfo = "no" != getSettingValue(response.Settings, passwordDenyUserInfoPath)
+	settings.DenyCompromised = "no" != getSettingValue(response.Settings, passwordDenyCompromisedPath)
+
+	maxAgeDays, _ := strconv.ParseUint(getSettingValue(response.Settings, passwordMaxAgePath), 10, 16)
+	settings.MaxAge = time.Duration(maxAgeDays) * 24 * time.Hour
+	staffMaxAgeDays, _ := strconv.ParseUint(getSettingValue(response.Settings, passwordStaffMaxAgePath), 10, 16)
+	settings.StaffMaxAge = time.Duration(staffMaxAgeDays) * 24 * time.Hour
+	settings.HistoryDepth, _ = strconv.ParseUint(getSettingValue(response.Settings, passwordHistoryDepthPath), 10, 16)
+
+	return &settings, nil
+}
+
+// GetRetentionSettings returns data retention settings from settings service or err if can not get it,
+// a period which is not set or is invalid gets the default value
+func (s *SysSettings) GetRetentionSettings() (*RetentionSettings, error) {
+	response, err := s.list("profile/data-retention/%")
 	if err != nil {
 		return nil, err
 	}
 
-	response, err := client.Get(context.Background(), &pb.Request{Path: maintenancePath})
+	settings := RetentionSettings{}
+	settings.ConfirmationCodes = retentionPeriod(response.Settings, retentionConfirmationCodesPath, DefaultRetentionConfirmationCodesDays)
+	settings.FailAuthAttempts = retentionPeriod(response.Settings, retentionFailAuthAttemptsPath, DefaultRetentionFailAuthAttemptsDays)
+	settings.BlockedIps = retentionPeriod(response.Settings, retentionBlockedIpsPath, DefaultRetentionBlockedIpsDays)
+	settings.Invites = retentionPeriod(response.Settings, retentionInvitesPath, DefaultRetentionInvitesDays)
+	settings.AccessLog = retentionPeriod(response.Settings, retentionAccessLogPath, DefaultRetentionAccessLogDays)
+
+	return &settings, nil
+}
+
+// GetMaintenanceModeSettings returns maintenance mode settings from settings service or err if can not get it
+func (s *SysSettings) GetMaintenanceModeSettings() (*MaintenanceModeSettings, error) {
+	response, err := s.get(maintenancePath)
 	if err != nil {
 		return nil, err
 	}
@@ -200,16 +285,22 @@ func (s *SysSettings) GetMaintenanceModeSettings() (*MaintenanceModeSettings, er
 
 // GetDefaulUserClassByRole returns default user class settings from settings service or err if can not get it
 func (s *SysSettings) GetDefaultUserClassByRole(roleName string) (*string, error) {
-	client, err := s.clientFactory.NewClient()
+	path := fmt.Sprintf("%s/%s", "profile/default-user-classes", roleName)
+	response, err := s.get(path)
 	if err != nil {
 		return nil, err
 	}
-	path := fmt.Sprintf("%s/%s", "profile/default-user-classes", roleName)
-	response, err := client.Get(context.Background(), &pb.Request{Path: path})
+	// the response is cached, so the value is copied in order to not expose it to modifications
+	value := response.Setting.Value
+	return &value, nil
+}
+
+func retentionPeriod(settings []*pb.Setting, path string, defaultDays uint64) time.Duration {
+	days, err := strconv.ParseUint(getSettingValue(settings, path), 10, 16)
 	if err != nil {
-		return nil, err
+		days = defaultDays
 	}
-	return &response.Setting.Value, nil
+	return time.Duration(days) * 24 * time.Hour
 }
 
 func getSettingValue(settings []*pb.Setting, path string) string {
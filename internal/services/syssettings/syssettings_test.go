@@ -7,6 +7,7 @@ import (
 	"time"
 
 	pb "github.com/Confialink/wallet-settings/rpc/proto/settings"
+	"github.com/inconshreveable/log15"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -28,7 +29,7 @@ var _ = Describe("syssettings package", func() {
 		When("client factory returns an error", func() {
 			It("should return an error", func() {
 				clientFactory.On("NewClient").Return(nil, errors.New("random err"))
-				service := NewSysSettings(clientFactory)
+				service := NewSysSettings(clientFactory, log15.New())
 
 				res, err := service.GetTimeSettings()
 				Expect(err).Should(HaveOccurred())
@@ -42,7 +43,7 @@ var _ = Describe("syssettings package", func() {
 					req := &pb.Request{Path: "regional/general/%"}
 					client.On("List", context.Background(), req).Return(nil, errors.New("random err"))
 					clientFactory.On("NewClient").Return(client, nil)
-					service := NewSysSettings(clientFactory)
+					service := NewSysSettings(clientFactory, log15.New())
 					res, err := service.GetTimeSettings()
 					Expect(err).Should(HaveOccurred())
 					Expect(res).Should(BeNil())
@@ -65,7 +66,7 @@ var _ = Describe("syssettings package", func() {
 					}
 					client.On("List", context.Background(), req).Return(resp, nil)
 					clientFactory.On("NewClient").Return(client, nil)
-					service := NewSysSettings(clientFactory)
+					service := NewSysSettings(clientFactory, log15.New())
 					res, err := service.GetTimeSettings()
 					Expect(err).ShouldNot(HaveOccurred())
 					Expect(res.Timezone).Should(Equal(timeZone))
@@ -81,7 +82,7 @@ var _ = Describe("syssettings package", func() {
 		When("client factory returns an error", func() {
 			It("should return an error", func() {
 				clientFactory.On("NewClient").Return(nil, errors.New("random err"))
-				service := NewSysSettings(clientFactory)
+				service := NewSysSettings(clientFactory, log15.New())
 
 				res, err := service.GetAutologoutSettings()
 				Expect(err).Should(HaveOccurred())
@@ -95,7 +96,7 @@ var _ = Describe("syssettings package", func() {
 					req := &pb.Request{Path: "profile/autologout/%"}
 					client.On("List", context.Background(), req).Return(nil, errors.New("random err"))
 					clientFactory.On("NewClient").Return(client, nil)
-					service := NewSysSettings(clientFactory)
+					service := NewSysSettings(clientFactory, log15.New())
 					res, err := service.GetAutologoutSettings()
 					Expect(err).Should(HaveOccurred())
 					Expect(res).Should(BeNil())
@@ -115,7 +116,7 @@ var _ = Describe("syssettings package", func() {
 						}
 						client.On("List", context.Background(), req).Return(resp, nil)
 						clientFactory.On("NewClient").Return(client, nil)
-						service := NewSysSettings(clientFactory)
+						service := NewSysSettings(clientFactory, log15.New())
 						res, err := service.GetAutologoutSettings()
 						Expect(err).Should(HaveOccurred())
 						Expect(res).Should(BeNil())
@@ -133,7 +134,7 @@ var _ = Describe("syssettings package", func() {
 						}
 						client.On("List", context.Background(), req).Return(resp, nil)
 						clientFactory.On("NewClient").Return(client, nil)
-						service := NewSysSettings(clientFactory)
+						service := NewSysSettings(clientFactory, log15.New())
 						res, err := service.GetAutologoutSettings()
 						Expect(err).Should(HaveOccurred())
 						Expect(res).Should(BeNil())
@@ -152,7 +153,7 @@ var _ = Describe("syssettings package", func() {
 						}
 						client.On("List", context.Background(), req).Return(resp, nil)
 						clientFactory.On("NewClient").Return(client, nil)
-						service := NewSysSettings(clientFactory)
+						service := NewSysSettings(clientFactory, log15.New())
 						res, err := service.GetAutologoutSettings()
 						Expect(err).ShouldNot(HaveOccurred())
 						Expect(res.Enabled).Should(BeTrue())
@@ -169,7 +170,7 @@ var _ = Describe("syssettings package", func() {
 		When("client factory returns an error", func() {
 			It("should return an error", func() {
 				clientFactory.On("NewClient").Return(nil, errors.New("random err"))
-				service := NewSysSettings(clientFactory)
+				service := NewSysSettings(clientFactory, log15.New())
 
 				res, err := service.GetGDPRSettings()
 				Expect(err).Should(HaveOccurred())
@@ -183,7 +184,7 @@ var _ = Describe("syssettings package", func() {
 					req := &pb.Request{Path: gdprSettingPath}
 					client.On("Get", context.Background(), req).Return(nil, errors.New("random err"))
 					clientFactory.On("NewClient").Return(client, nil)
-					service := NewSysSettings(clientFactory)
+					service := NewSysSettings(clientFactory, log15.New())
 					res, err := service.GetGDPRSettings()
 					Expect(err).Should(HaveOccurred())
 					Expect(res).Should(BeNil())
@@ -200,7 +201,7 @@ var _ = Describe("syssettings package", func() {
 					}
 					client.On("Get", context.Background(), req).Return(resp, nil)
 					clientFactory.On("NewClient").Return(client, nil)
-					service := NewSysSettings(clientFactory)
+					service := NewSysSettings(clientFactory, log15.New())
 					res, err := service.GetGDPRSettings()
 					Expect(err).ShouldNot(HaveOccurred())
 					Expect(res.Enabled).Should(BeTrue())
@@ -213,7 +214,7 @@ var _ = Describe("syssettings package", func() {
 		When("client factory returns an error", func() {
 			It("should return an error", func() {
 				clientFactory.On("NewClient").Return(nil, errors.New("random err"))
-				service := NewSysSettings(clientFactory)
+				service := NewSysSettings(clientFactory, log15.New())
 
 				res, err := service.GetLoginSecuritySettings()
 				Expect(err).Should(HaveOccurred())
@@ -227,7 +228,7 @@ var _ = Describe("syssettings package", func() {
 					req := &pb.Request{Path: "regional/login/%"}
 					client.On("List", context.Background(), req).Return(nil, errors.New("random err"))
 					clientFactory.On("NewClient").Return(client, nil)
-					service := NewSysSettings(clientFactory)
+					service := NewSysSettings(clientFactory, log15.New())
 					_, err := service.GetLoginSecuritySettings()
 					Expect(err).Should(HaveOccurred())
 				})
@@ -243,11 +244,13 @@ var _ = Describe("syssettings package", func() {
 							{Path: loginUsernameUsePath, Value: "yes"},
 							{Path: loginUserUsePath, Value: "100"},
 							{Path: loginUserWindowPath, Value: "10"},
+							{Path: loginCaptchaAfterPath, Value: "3"},
+							{Path: loginDelayPath, Value: "250"},
 						},
 					}
 					client.On("List", context.Background(), req).Return(resp, nil)
 					clientFactory.On("NewClient").Return(client, nil)
-					service := NewSysSettings(clientFactory)
+					service := NewSysSettings(clientFactory, log15.New())
 					res, err := service.GetLoginSecuritySettings()
 					Expect(err).ShouldNot(HaveOccurred())
 					Expect(res.FailedLoginUsernameCleanup).Should(Equal(uint64(5)))
@@ -255,6 +258,8 @@ var _ = Describe("syssettings package", func() {
 					Expect(res.FailedLoginUsernameUse).Should(BeTrue())
 					Expect(res.FailedLoginUserUse).Should(Equal(uint64(100)))
 					Expect(res.FailedLoginUserWindow).Should(Equal(uint64(10)))
+					Expect(res.FailedLoginCaptchaAfter).Should(Equal(uint64(3)))
+					Expect(res.FailedLoginDelay).Should(Equal(250 * time.Millisecond))
 				})
 			})
 		})
@@ -264,7 +269,7 @@ var _ = Describe("syssettings package", func() {
 		When("client factory returns an error", func() {
 			It("should return an error", func() {
 				clientFactory.On("NewClient").Return(nil, errors.New("random err"))
-				service := NewSysSettings(clientFactory)
+				service := NewSysSettings(clientFactory, log15.New())
 
 				res, err := service.GetDormantDuration()
 				Expect(err).Should(HaveOccurred())
@@ -278,7 +283,7 @@ var _ = Describe("syssettings package", func() {
 					req := &pb.Request{Path: userOptionsDormantPath}
 					client.On("Get", context.Background(), req).Return(nil, errors.New("random err"))
 					clientFactory.On("NewClient").Return(client, nil)
-					service := NewSysSettings(clientFactory)
+					service := NewSysSettings(clientFactory, log15.New())
 					res, err := service.GetDormantDuration()
 					Expect(err).Should(HaveOccurred())
 					Expect(res).Should(Equal(time.Nanosecond))
@@ -296,7 +301,7 @@ var _ = Describe("syssettings package", func() {
 						}
 						client.On("Get", context.Background(), req).Return(resp, nil)
 						clientFactory.On("NewClient").Return(client, nil)
-						service := NewSysSettings(clientFactory)
+						service := NewSysSettings(clientFactory, log15.New())
 						res, err := service.GetDormantDuration()
 						Expect(err).Should(HaveOccurred())
 						Expect(res).Should(Equal(time.Nanosecond))
@@ -313,7 +318,7 @@ var _ = Describe("syssettings package", func() {
 						}
 						client.On("Get", context.Background(), req).Return(resp, nil)
 						clientFactory.On("NewClient").Return(client, nil)
-						service := NewSysSettings(clientFactory)
+						service := NewSysSettings(clientFactory, log15.New())
 						res, err := service.GetDormantDuration()
 						Expect(err).ShouldNot(HaveOccurred())
 
@@ -326,11 +331,149 @@ var _ = Describe("syssettings package", func() {
 		})
 	})
 
+	Context("GetPasswordPolicySettings", func() {
+		When("Settings service returns an error", func() {
+			It("should return an error", func() {
+				req := &pb.Request{Path: "profile/password-policy/%"}
+				client.On("List", context.Background(), req).Return(nil, errors.New("random err"))
+				clientFactory.On("NewClient").Return(client, nil)
+				service := NewSysSettings(clientFactory, log15.New())
+				res, err := service.GetPasswordPolicySettings()
+				Expect(err).Should(HaveOccurred())
+				Expect(res).Should(BeNil())
+			})
+		})
+
+		When("settings are not set", func() {
+			It("should return the default policy", func() {
+				req := &pb.Request{Path: "profile/password-policy/%"}
+				client.On("List", context.Background(), req).Return(&pb.Response{}, nil)
+				clientFactory.On("NewClient").Return(client, nil)
+				service := NewSysSettings(clientFactory, log15.New())
+				res, err := service.GetPasswordPolicySettings()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(res.MinLength).Should(Equal(DefaultPasswordMinLength))
+				Expect(res.RequireUppercase).Should(BeTrue())
+				Expect(res.RequireLowercase).Should(BeTrue())
+				Expect(res.RequireNumber).Should(BeTrue())
+				Expect(res.RequireSpecialCharacter).Should(BeTrue())
+				Expect(res.DenyUserInfo).Should(BeTrue())
+				Expect(res.DenyCompromised).Should(BeTrue())
+				Expect(res.MaxAge).Should(BeZero())
+				Expect(res.StaffMaxAge).Should(BeZero())
+				Expect(res.HistoryDepth).Should(BeZero())
+			})
+		})
+
+		When("everything is ok", func() {
+			It("should not return an error", func() {
+				req := &pb.Request{Path: "profile/password-policy/%"}
+				resp := &pb.Response{
+					Settings: []*pb.Setting{
+						{Path: passwordMinLengthPath, Value: "12"},
+						{Path: passwordUppercasePath, Value: "no"},
+						{Path: passwordLowercasePath, Value: "yes"},
+						{Path: passwordNumberPath, Value: "yes"},
+						{Path: passwordSpecialCharacterPath, Value: "no"},
+						{Path: passwordDenyUserInfoPath, Value: "no"},
+						{Path: passwordDenyCompromisedPath, Value: "no"},
+						{Path: passwordMaxAgePath, Value: "180"},
+						{Path: passwordStaffMaxAgePath, Value: "90"},
+						{Path: passwordHistoryDepthPath, Value: "5"},
+					},
+				}
+				client.On("List", context.Background(), req).Return(resp, nil)
+				clientFactory.On("NewClient").Return(client, nil)
+				service := NewSysSettings(clientFactory, log15.New())
+				res, err := service.GetPasswordPolicySettings()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(res.MinLength).Should(Equal(12))
+				Expect(res.RequireUppercase).Should(BeFalse())
+				Expect(res.RequireLowercase).Should(BeTrue())
+				Expect(res.RequireNumber).Should(BeTrue())
+				Expect(res.RequireSpecialCharacter).Should(BeFalse())
+				Expect(res.DenyUserInfo).Should(BeFalse())
+				Expect(res.DenyCompromised).Should(BeFalse())
+				Expect(res.MaxAge).Should(Equal(180 * 24 * time.Hour))
+				Expect(res.StaffMaxAge).Should(Equal(90 * 24 * time.Hour))
+				Expect(res.HistoryDepth).Should(Equal(uint64(5)))
+			})
+		})
+
+		When("the minimum length is lower than the default one", func() {
+			It("should return the default minimum length", func() {
+				req := &pb.Request{Path: "profile/password-policy/%"}
+				resp := &pb.Response{Settings: []*pb.Setting{{Path: passwordMinLengthPath, Value: "4"}}}
+				client.On("List", context.Background(), req).Return(resp, nil)
+				clientFactory.On("NewClient").Return(client, nil)
+				service := NewSysSettings(clientFactory, log15.New())
+				res, err := service.GetPasswordPolicySettings()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(res.MinLength).Should(Equal(DefaultPasswordMinLength))
+			})
+		})
+	})
+
+	Context("GetRetentionSettings", func() {
+		When("Settings service returns an error", func() {
+			It("should return an error", func() {
+				req := &pb.Request{Path: "profile/data-retention/%"}
+				client.On("List", context.Background(), req).Return(nil, errors.New("random err"))
+				clientFactory.On("NewClient").Return(client, nil)
+				service := NewSysSettings(clientFactory, log15.New())
+				res, err := service.GetRetentionSettings()
+				Expect(err).Should(HaveOccurred())
+				Expect(res).Should(BeNil())
+			})
+		})
+
+		When("settings are not set", func() {
+			It("should return the default periods", func() {
+				req := &pb.Request{Path: "profile/data-retention/%"}
+				client.On("List", context.Background(), req).Return(&pb.Response{}, nil)
+				clientFactory.On("NewClient").Return(client, nil)
+				service := NewSysSettings(clientFactory, log15.New())
+				res, err := service.GetRetentionSettings()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(res.ConfirmationCodes).Should(Equal(DefaultRetentionConfirmationCodesDays * 24 * time.Hour))
+				Expect(res.FailAuthAttempts).Should(Equal(DefaultRetentionFailAuthAttemptsDays * 24 * time.Hour))
+				Expect(res.BlockedIps).Should(Equal(DefaultRetentionBlockedIpsDays * 24 * time.Hour))
+				Expect(res.Invites).Should(Equal(DefaultRetentionInvitesDays * 24 * time.Hour))
+				Expect(res.AccessLog).Should(Equal(DefaultRetentionAccessLogDays * 24 * time.Hour))
+			})
+		})
+
+		When("everything is ok", func() {
+			It("should not return an error", func() {
+				req := &pb.Request{Path: "profile/data-retention/%"}
+				resp := &pb.Response{
+					Settings: []*pb.Setting{
+						{Path: retentionConfirmationCodesPath, Value: "1"},
+						{Path: retentionFailAuthAttemptsPath, Value: "0"},
+						{Path: retentionBlockedIpsPath, Value: "invalid"},
+						{Path: retentionInvitesPath, Value: "90"},
+						{Path: retentionAccessLogPath, Value: "730"},
+					},
+				}
+				client.On("List", context.Background(), req).Return(resp, nil)
+				clientFactory.On("NewClient").Return(client, nil)
+				service := NewSysSettings(clientFactory, log15.New())
+				res, err := service.GetRetentionSettings()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(res.ConfirmationCodes).Should(Equal(24 * time.Hour))
+				Expect(res.FailAuthAttempts).Should(BeZero())
+				Expect(res.BlockedIps).Should(Equal(DefaultRetentionBlockedIpsDays * 24 * time.Hour))
+				Expect(res.Invites).Should(Equal(90 * 24 * time.Hour))
+				Expect(res.AccessLog).Should(Equal(730 * 24 * time.Hour))
+			})
+		})
+	})
+
 	Context("GetMaintenanceModeSettings", func() {
 		When("client factory returns an error", func() {
 			It("should return an error", func() {
 				clientFactory.On("NewClient").Return(nil, errors.New("random err"))
-				service := NewSysSettings(clientFactory)
+				service := NewSysSettings(clientFactory, log15.New())
 
 				res, err := service.GetMaintenanceModeSettings()
 				Expect(err).Should(HaveOccurred())
@@ -344,7 +487,7 @@ var _ = Describe("syssettings package", func() {
 					req := &pb.Request{Path: maintenancePath}
 					client.On("Get", context.Background(), req).Return(nil, errors.New("random err"))
 					clientFactory.On("NewClient").Return(client, nil)
-					service := NewSysSettings(clientFactory)
+					service := NewSysSettings(clientFactory, log15.New())
 					res, err := service.GetMaintenanceModeSettings()
 					Expect(err).Should(HaveOccurred())
 					Expect(res).Should(BeNil())
@@ -362,7 +505,7 @@ var _ = Describe("syssettings package", func() {
 						}
 						client.On("Get", context.Background(), req).Return(resp, nil)
 						clientFactory.On("NewClient").Return(client, nil)
-						service := NewSysSettings(clientFactory)
+						service := NewSysSettings(clientFactory, log15.New())
 						res, err := service.GetMaintenanceModeSettings()
 						Expect(err).ShouldNot(HaveOccurred())
 						Expect(res.Enabled).Should(BeTrue())
@@ -378,7 +521,7 @@ var _ = Describe("syssettings package", func() {
 		When("client factory returns an error", func() {
 			It("should return an error", func() {
 				clientFactory.On("NewClient").Return(nil, errors.New("random err"))
-				service := NewSysSettings(clientFactory)
+				service := NewSysSettings(clientFactory, log15.New())
 
 				res, err := service.GetDefaultUserClassByRole(roleName)
 				Expect(err).Should(HaveOccurred())
@@ -392,7 +535,7 @@ var _ = Describe("syssettings package", func() {
 					req := &pb.Request{Path: settingPath}
 					client.On("Get", context.Background(), req).Return(nil, errors.New("random err"))
 					clientFactory.On("NewClient").Return(client, nil)
-					service := NewSysSettings(clientFactory)
+					service := NewSysSettings(clientFactory, log15.New())
 					res, err := service.GetDefaultUserClassByRole(roleName)
 					Expect(err).Should(HaveOccurred())
 					Expect(res).Should(BeNil())
@@ -411,7 +554,7 @@ var _ = Describe("syssettings package", func() {
 						}
 						client.On("Get", context.Background(), req).Return(resp, nil)
 						clientFactory.On("NewClient").Return(client, nil)
-						service := NewSysSettings(clientFactory)
+						service := NewSysSettings(clientFactory, log15.New())
 						res, err := service.GetDefaultUserClassByRole(roleName)
 						Expect(err).ShouldNot(HaveOccurred())
 						Expect(res).Should(Equal(&result))
@@ -420,4 +563,67 @@ var _ = Describe("syssettings package", func() {
 			})
 		})
 	})
+
+	Context("cache", func() {
+		var (
+			req  *pb.Request
+			resp *pb.Response
+		)
+		BeforeEach(func() {
+			req = &pb.Request{Path: maintenancePath}
+			resp = &pb.Response{Setting: &pb.Setting{Path: maintenancePath, Value: "enable"}}
+			clientFactory.On("NewClient").Return(client, nil)
+		})
+
+		When("settings were fetched recently", func() {
+			It("should not call the settings service", func() {
+				client.On("Get", context.Background(), req).Return(resp, nil).Once()
+				service := NewSysSettings(clientFactory, log15.New())
+
+				for i := 0; i < 3; i++ {
+					res, err := service.GetMaintenanceModeSettings()
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(res.Enabled).Should(BeTrue())
+				}
+				client.AssertNumberOfCalls(GinkgoT(), "Get", 1)
+			})
+		})
+
+		When("settings were invalidated", func() {
+			It("should fetch settings again", func() {
+				disabled := &pb.Response{Setting: &pb.Setting{Path: maintenancePath, Value: "disable"}}
+				client.On("Get", context.Background(), req).Return(resp, nil).Once()
+				client.On("Get", context.Background(), req).Return(disabled, nil).Once()
+				service := NewSysSettings(clientFactory, log15.New())
+
+				res, err := service.GetMaintenanceModeSettings()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(res.Enabled).Should(BeTrue())
+
+				service.Invalidate()
+
+				res, err = service.GetMaintenanceModeSettings()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(res.Enabled).Should(BeFalse())
+			})
+		})
+
+		When("settings service becomes unavailable", func() {
+			It("should return last known settings", func() {
+				client.On("Get", context.Background(), req).Return(resp, nil).Once()
+				client.On("Get", context.Background(), req).Return(nil, errors.New("random err")).Once()
+				service := NewSysSettings(clientFactory, log15.New())
+
+				_, err := service.GetMaintenanceModeSettings()
+				Expect(err).ShouldNot(HaveOccurred())
+
+				service.Invalidate()
+
+				res, err := service.GetMaintenanceModeSettings()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(res.Enabled).Should(BeTrue())
+				client.AssertNumberOfCalls(GinkgoT(), "Get", 2)
+			})
+		})
+	})
 })
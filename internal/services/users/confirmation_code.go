@@ -61,6 +61,10 @@ func (c *ConfirmationCode) FindByCodeSubjectAndEmailOrUsername(code string, subj
 	return c.confirmationCodeRepository.FindByCodeSubjectAndEmailOrUsername(code, subject, emailOrUsername)
 }
 
+func (c *ConfirmationCode) FindActiveByCodeSubjectAndUser(code string, subject string, user *models.User) (*models.ConfirmationCode, error) {
+	return c.confirmationCodeRepository.FindActiveByCodeSubjectAndUser(code, subject, user)
+}
+
 func (c *ConfirmationCode) DeleteConfirmationCode(code *models.ConfirmationCode) error {
 	return c.confirmationCodeRepository.Delete(code)
 }
@@ -90,7 +90,7 @@ var _ = Describe("users package", func() {
 			It("returns an error", func() {
 				errorText := "db error"
 				dbMock.ExpectQuery(selectQuery).
-					WithArgs(uid, models.ConfirmationCodeSubjectPhoneVerificationCode, code, AnyValue{}).
+					WithArgs(uid, models.ConfirmationCodeSubjectPhoneVerificationCode, code, AnyValue{}, models.PhoneChangeStatusPending).
 					WillReturnError(errors.New(errorText))
 
 				Expect(service.CheckPhoneCode(code, user)).Should(MatchError(errorText))
@@ -102,7 +102,7 @@ var _ = Describe("users package", func() {
 			It("returns nil", func() {
 				sqlRows := sqlmock.NewRows([]string{"id"}).AddRow("1")
 				dbMock.ExpectQuery(selectQuery).
-					WithArgs(uid, models.ConfirmationCodeSubjectPhoneVerificationCode, code, AnyValue{}).
+					WithArgs(uid, models.ConfirmationCodeSubjectPhoneVerificationCode, code, AnyValue{}, models.PhoneChangeStatusPending).
 					WillReturnRows(sqlRows)
 
 				Expect(service.CheckPhoneCode(code, user)).Should(BeNil())
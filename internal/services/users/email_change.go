@@ -0,0 +1,249 @@
+package users
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Confialink/wallet-pkg-errors"
+	"github.com/inconshreveable/log15"
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	"github.com/Confialink/wallet-users/internal/services/events"
+	"github.com/Confialink/wallet-users/internal/services/notifications"
+)
+
+const (
+	// emailChangeCancelCodeLength is the length of the code in the cancel link sent to the old address
+	emailChangeCancelCodeLength = 64
+	// emailChangeCancelPeriod is how long the change can be cancelled from the old address,
+	// it lasts after the change is confirmed, so the owner can take the account back
+	emailChangeCancelPeriod = 7 * 24 * time.Hour
+)
+
+// EmailChange replaces emails of users only after the new address is confirmed by a code,
+// the old address is alerted and gets a link to cancel the change
+type EmailChange struct {
+	db                      *gorm.DB
+	userRepository          *repositories.UsersRepository
+	emailChangeRepository   *repositories.EmailChangeRepository
+	confirmationCodeService *ConfirmationCode
+	notificationsService    *notifications.Notifications
+	outbox                  *events.Outbox
+	logger                  log15.Logger
+}
+
+func NewEmailChange(
+	db *gorm.DB,
+	userRepository *repositories.UsersRepository,
+	emailChangeRepository *repositories.EmailChangeRepository,
+	confirmationCodeService *ConfirmationCode,
+	notificationsService *notifications.Notifications,
+	outbox *events.Outbox,
+	logger log15.Logger,
+) *EmailChange {
+	return &EmailChange{
+		db,
+		userRepository,
+		emailChangeRepository,
+		confirmationCodeService,
+		notificationsService,
+		outbox,
+		logger.New("service", "EmailChange"),
+	}
+}
+
+// Pending returns the change of the email of the user which waits for confirmation
+func (s *EmailChange) Pending(user *models.User) (*models.EmailChange, error) {
+	return s.emailChangeRepository.FindPendingByUID(user.UID)
+}
+
+// Request stores the new address as pending, sends a code to it and alerts the current address.
+// A previous pending change of the user is cancelled. The new address must differ from the current one
+// and must not be taken by another user.
+func (s *EmailChange) Request(user *models.User, newEmail string) (*models.EmailChange, error) {
+	if strings.EqualFold(strings.TrimSpace(newEmail), user.Email) {
+		return nil, &errors.PublicError{
+			Title:      "New email must differ from the current one",
+			Code:       responses.EmailNotChanged,
+			HttpStatus: http.StatusUnprocessableEntity,
+		}
+	}
+	if err := s.checkEmailIsFree(user, newEmail); err != nil {
+		return nil, err
+	}
+
+	cancelCode, err := s.confirmationCodeService.GenerateCode(
+		user,
+		models.ConfirmationCodeSubjectEmailChangeCancel,
+		emailChangeCancelCodeLength,
+		emailChangeCancelPeriod,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	change := &models.EmailChange{
+		UserUID:           user.UID,
+		OldEmail:          user.Email,
+		OldEmailConfirmed: user.IsEmailConfirmed,
+		NewEmail:          newEmail,
+		Status:            models.EmailChangeStatusPending,
+		CancelCodeID:      cancelCode.ID,
+	}
+
+	tx := s.db.Begin()
+	repo := s.emailChangeRepository.WrapContext(tx)
+	if err := repo.CancelPendingByUID(user.UID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := repo.Create(change); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	code, err := s.confirmationCodeService.CreateNewVerificationCode(user, models.ConfirmationCodeSubjectEmailChange)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.notificationsService.EmailChangeRequested(user.UID, cancelCode.Code); err != nil {
+		s.logger.Error("failed to alert the current address", "error", err, "uid", user.UID)
+	}
+	if _, err := s.notificationsService.EmailChangeConfirmation(newEmail, code.Code); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// Confirm replaces the email of the user by the pending address if the code sent to it is valid,
+// the new address is confirmed by the code
+func (s *EmailChange) Confirm(user *models.User, code string) error {
+	change, err := s.emailChangeRepository.FindPendingByUID(user.UID)
+	if err == gorm.ErrRecordNotFound {
+		return &errors.PublicError{
+			Title:      "There is no email change to confirm",
+			Code:       responses.EmailChangeNotFound,
+			HttpStatus: http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	codeModel, err := s.confirmationCodeService.FindActiveByCodeSubjectAndUser(code, models.ConfirmationCodeSubjectEmailChange, user)
+	if err != nil {
+		return invalidEmailChangeCode()
+	}
+
+	// the address might be taken while the change was waiting for confirmation
+	if err := s.checkEmailIsFree(user, change.NewEmail); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	change.Status = models.EmailChangeStatusConfirmed
+	change.ConfirmedAt = &now
+	user.Email = change.NewEmail
+	user.IsEmailConfirmed = true
+
+	tx := s.db.Begin()
+	if err := s.saveEmail(tx, user, change); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := s.confirmationCodeService.WrapContext(tx).DeleteConfirmationCode(codeModel); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// Cancel cancels the change of the email the code was sent for and pending changes of the user
+// the code belongs to. If the change is already confirmed the old address is restored and reverted is true,
+// the account is likely taken over in that case.
+func (s *EmailChange) Cancel(cancelCode string) (user *models.User, reverted bool, err error) {
+	codeModel, err := s.confirmationCodeService.FindByCodeAndSubject(cancelCode, models.ConfirmationCodeSubjectEmailChangeCancel)
+	if err != nil || codeModel.User == nil || codeModel.ExpiresAt.Before(time.Now()) {
+		return nil, false, invalidEmailChangeCode()
+	}
+	user = codeModel.User
+
+	change, err := s.emailChangeRepository.FindByCancelCodeID(codeModel.ID)
+	if err == gorm.ErrRecordNotFound {
+		return nil, false, invalidEmailChangeCode()
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	tx := s.db.Begin()
+	// the change could be replaced by another one which is cancelled as well
+	if err := s.emailChangeRepository.WrapContext(tx).CancelPendingByUID(user.UID); err != nil {
+		tx.Rollback()
+		return nil, false, err
+	}
+
+	// the email could be changed once again after the confirmation, it is not overwritten then
+	if change.Status == models.EmailChangeStatusConfirmed && user.Email == change.NewEmail {
+		user.Email = change.OldEmail
+		user.IsEmailConfirmed = change.OldEmailConfirmed
+		change.Status = models.EmailChangeStatusCancelled
+		if err := s.saveEmail(tx, user, change); err != nil {
+			tx.Rollback()
+			return nil, false, err
+		}
+		reverted = true
+	}
+
+	if err := s.confirmationCodeService.WrapContext(tx).DeleteConfirmationCode(codeModel); err != nil {
+		tx.Rollback()
+		return nil, false, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, false, err
+	}
+	return user, reverted, nil
+}
+
+// saveEmail stores the email of the user and the change, user.updated event is recorded in the same transaction
+func (s *EmailChange) saveEmail(tx *gorm.DB, user *models.User, change *models.EmailChange) error {
+	if err := s.userRepository.WrapContext(tx).UpdateEmail(user); err != nil {
+		return err
+	}
+	if err := s.emailChangeRepository.WrapContext(tx).Save(change); err != nil {
+		return err
+	}
+	return s.outbox.Record(tx, events.UserUpdated, events.NewUserData(user))
+}
+
+// checkEmailIsFree returns an error if the address is taken by another user
+func (s *EmailChange) checkEmailIsFree(user *models.User, email string) error {
+	exists, err := s.userRepository.IsExistByEmailWithoutCurrent(email, user.UID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return &errors.PublicError{
+			Title:      "Email already exists",
+			Code:       responses.EmailAlreadyExists,
+			HttpStatus: http.StatusUnprocessableEntity,
+		}
+	}
+	return nil
+}
+
+func invalidEmailChangeCode() error {
+	return &errors.PublicError{
+		Title:      "Invalid code",
+		Code:       responses.InvalidEmailChangeCode,
+		HttpStatus: http.StatusBadRequest,
+	}
+}
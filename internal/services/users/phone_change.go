@@ -0,0 +1,177 @@
+package users
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Confialink/wallet-pkg-errors"
+	"github.com/inconshreveable/log15"
+	"github.com/jinzhu/gorm"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	"github.com/Confialink/wallet-users/internal/services/events"
+	"github.com/Confialink/wallet-users/internal/services/notifications"
+)
+
+// PhoneChange replaces phone numbers of users only after the new number is verified by a code sent by SMS,
+// the current number is alerted about the change
+type PhoneChange struct {
+	db                      *gorm.DB
+	userRepository          *repositories.UsersRepository
+	phoneChangeRepository   *repositories.PhoneChangeRepository
+	confirmationCodeService *ConfirmationCode
+	notificationsService    *notifications.Notifications
+	outbox                  *events.Outbox
+	logger                  log15.Logger
+}
+
+func NewPhoneChange(
+	db *gorm.DB,
+	userRepository *repositories.UsersRepository,
+	phoneChangeRepository *repositories.PhoneChangeRepository,
+	confirmationCodeService *ConfirmationCode,
+	notificationsService *notifications.Notifications,
+	outbox *events.Outbox,
+	logger log15.Logger,
+) *PhoneChange {
+	return &PhoneChange{
+		db,
+		userRepository,
+		phoneChangeRepository,
+		confirmationCodeService,
+		notificationsService,
+		outbox,
+		logger.New("service", "PhoneChange"),
+	}
+}
+
+// Pending returns the change of a phone number of the user which waits for confirmation
+func (s *PhoneChange) Pending(user *models.User) (*models.PhoneChange, error) {
+	return s.phoneChangeRepository.FindPendingByUID(user.UID)
+}
+
+// Request stores the new number of the field as pending, sends a code to it and alerts the current number.
+// A previous pending change of the user is cancelled. Uniqueness of the number is checked on confirmation.
+func (s *PhoneChange) Request(user *models.User, field, newPhoneNumber string) (*models.PhoneChange, error) {
+	change := &models.PhoneChange{
+		UserUID:        user.UID,
+		Field:          field,
+		OldPhoneNumber: currentPhoneNumber(user, field),
+		NewPhoneNumber: newPhoneNumber,
+		Status:         models.PhoneChangeStatusPending,
+	}
+
+	tx := s.db.Begin()
+	// the code replaces the phone verification code, only the code linked to the change confirms it
+	code, err := s.confirmationCodeService.WrapContext(tx).CreateNewVerificationCode(user, models.ConfirmationCodeSubjectPhoneVerificationCode)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	change.ConfirmationCodeID = &code.ID
+
+	repo := s.phoneChangeRepository.WrapContext(tx)
+	if err := repo.CancelPendingByUID(user.UID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := repo.Create(change); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	if change.OldPhoneNumber != "" {
+		if _, err := s.notificationsService.PhoneChangeRequested(change.OldPhoneNumber); err != nil {
+			s.logger.Error("failed to alert the current phone number", "error", err, "uid", user.UID)
+		}
+	}
+	if _, err := s.notificationsService.PhoneChangeConfirmation(newPhoneNumber, code.Code); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// Confirm replaces the phone number of the user by the pending one if the code sent to it is valid,
+// the new phone number is confirmed by the code. Only the code issued for the pending change is accepted.
+func (s *PhoneChange) Confirm(user *models.User, code string) error {
+	change, err := s.phoneChangeRepository.FindPendingByUID(user.UID)
+	if err == gorm.ErrRecordNotFound {
+		return &errors.PublicError{
+			Title:      "There is no phone number change to confirm",
+			Code:       responses.PhoneChangeNotFound,
+			HttpStatus: http.StatusNotFound,
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	codeModel, err := s.confirmationCodeService.FindActiveByCodeSubjectAndUser(code, models.ConfirmationCodeSubjectPhoneVerificationCode, user)
+	if err != nil || change.ConfirmationCodeID == nil || *change.ConfirmationCodeID != codeModel.ID {
+		return &errors.PublicError{
+			Title:      "Invalid code",
+			Code:       responses.InvalidConfirmationCode,
+			HttpStatus: http.StatusBadRequest,
+		}
+	}
+
+	// only the phone number is used to sign in, so it must not belong to another user
+	if change.Field == models.PhoneChangeFieldPhoneNumber {
+		exists, err := s.userRepository.IsExistByPhoneNumberWithoutCurrent(change.NewPhoneNumber, user.UID)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return &errors.PublicError{
+				Title:      "Phone number already exists",
+				Code:       responses.PhoneAlreadyExists,
+				HttpStatus: http.StatusUnprocessableEntity,
+			}
+		}
+	}
+
+	now := time.Now()
+	change.Status = models.PhoneChangeStatusConfirmed
+	change.ConfirmedAt = &now
+	if change.Field == models.PhoneChangeFieldPhoneNumber {
+		user.PhoneNumber = change.NewPhoneNumber
+		user.IsPhoneConfirmed = true
+	} else {
+		newPhoneNumber := change.NewPhoneNumber
+		user.SmsPhoneNumber = &newPhoneNumber
+	}
+
+	tx := s.db.Begin()
+	if err := s.userRepository.WrapContext(tx).UpdatePhoneNumbers(user); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := s.phoneChangeRepository.WrapContext(tx).Save(change); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := s.outbox.Record(tx, events.UserUpdated, events.NewUserData(user)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := s.confirmationCodeService.WrapContext(tx).DeleteConfirmationCode(codeModel); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func currentPhoneNumber(user *models.User, field string) string {
+	if field == models.PhoneChangeFieldPhoneNumber {
+		return user.PhoneNumber
+	}
+	if user.SmsPhoneNumber != nil {
+		return *user.SmsPhoneNumber
+	}
+	return ""
+}
@@ -0,0 +1,53 @@
+package users
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Confialink/wallet-pkg-errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/inconshreveable/log15"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Confialink/wallet-users/internal/db/models"
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/internal/http/responses"
+	"github.com/Confialink/wallet-users/internal/tests/mocks/helpers"
+)
+
+func TestPhoneChangeConfirmRejectsCodeOfAnotherChange(t *testing.T) {
+	gormMock := helpers.DbMock.GetGormMock()
+	dbMock := helpers.DbMock.GetDbMock()
+
+	logger := log15.New()
+	logger.SetHandler(log15.DiscardHandler())
+	service := NewPhoneChange(
+		gormMock,
+		repositories.NewUsersRepository(gormMock),
+		repositories.NewPhoneChangeRepository(gormMock),
+		NewConfirmationCode(repositories.NewConfirmationCodeRepository(gormMock)),
+		nil,
+		nil,
+		logger,
+	)
+	user := &models.User{UID: "user-uid", PhoneNumber: "+10000000001"}
+
+	dbMock.ExpectQuery("SELECT (.+) FROM `users_phone_changes` WHERE \\(user_uid = \\? AND status = \\?\\)").
+		WithArgs("user-uid", models.PhoneChangeStatusPending).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_uid", "field", "new_phone_number", "status", "confirmation_code_id"}).
+			AddRow(1, "user-uid", models.PhoneChangeFieldPhoneNumber, "+10000000002", models.PhoneChangeStatusPending, 5))
+	// a code of the same subject which was not issued for the pending change
+	dbMock.ExpectQuery("SELECT (.+) FROM `confirmation_codes`").
+		WithArgs("user-uid", models.ConfirmationCodeSubjectPhoneVerificationCode, "123456", AnyValue{}).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code", "user_uid", "subject"}).
+			AddRow(6, "123456", "user-uid", models.ConfirmationCodeSubjectPhoneVerificationCode))
+
+	err := service.Confirm(user, "123456")
+
+	if assert.IsType(t, &errors.PublicError{}, err) {
+		assert.Equal(t, responses.InvalidConfirmationCode, err.(*errors.PublicError).Code)
+		assert.Equal(t, http.StatusBadRequest, err.(*errors.PublicError).HttpStatus)
+	}
+	assert.Equal(t, "+10000000001", user.PhoneNumber)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
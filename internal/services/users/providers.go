@@ -5,6 +5,8 @@ func Providers() []interface{} {
 		NewConfirmationCode,
 		NewPermissionGroupsFiller,
 		NewUserService,
+		NewEmailChange,
+		NewPhoneChange,
 		NewAttributeService,
 		NewUserLoaderService,
 		NewCompanyService,
@@ -12,9 +12,11 @@ import (
 	"github.com/Confialink/wallet-users/internal/db/repositories"
 	"github.com/Confialink/wallet-users/internal/http/responses"
 	"github.com/Confialink/wallet-users/internal/services"
+	"github.com/Confialink/wallet-users/internal/services/events"
 	"github.com/Confialink/wallet-users/internal/services/files"
 	"github.com/Confialink/wallet-users/internal/services/gdpr"
 	"github.com/Confialink/wallet-users/internal/services/notifications"
+	"github.com/Confialink/wallet-users/internal/services/passwordpolicy"
 	"github.com/Confialink/wallet-users/internal/services/syssettings"
 )
 
@@ -31,6 +33,9 @@ type UserService struct {
 	settings                *syssettings.SysSettings
 	files                   *files.FilesService
 	gdprService             *gdpr.Service
+	consents                *gdpr.Consents
+	outbox                  *events.Outbox
+	passwordPolicy          *passwordpolicy.Policy
 }
 
 func NewUserService(
@@ -46,6 +51,9 @@ func NewUserService(
 	settings *syssettings.SysSettings,
 	files *files.FilesService,
 	gdprService *gdpr.Service,
+	consents *gdpr.Consents,
+	outbox *events.Outbox,
+	passwordPolicy *passwordpolicy.Policy,
 ) *UserService {
 	return &UserService{
 		db,
@@ -60,6 +68,9 @@ func NewUserService(
 		settings,
 		files,
 		gdprService,
+		consents,
+		outbox,
+		passwordPolicy,
 	}
 }
 
@@ -78,6 +89,23 @@ func (this *UserService) Update(user *models.User, tx *gorm.DB) error {
 		user.UserGroup = nil
 	}
 
+	// the stored state is needed to find out which events happened
+	previous, err := userRepo.FindByUID(user.UID)
+	if err != nil {
+		if localTransaction {
+			tx.Rollback()
+		}
+		return err
+	}
+
+	// the address and the number are set without a confirmation from the user, e.g. by an admin
+	if previous.Email != user.Email {
+		user.IsEmailConfirmed = false
+	}
+	if previous.PhoneNumber != user.PhoneNumber {
+		user.IsPhoneConfirmed = false
+	}
+
 	// Update Addresses
 	addressRepo := this.addressRepo.WrapContext(tx)
 	if err := this.attachAddresses(user.MailingAddresses, models.AddressTypeMailing, user.UID, addressRepo); err != nil {
@@ -111,7 +139,7 @@ func (this *UserService) Update(user *models.User, tx *gorm.DB) error {
 	}
 
 	// Use Save() to save empty fields
-	_, err := userRepo.Save(user)
+	_, err = userRepo.Save(user)
 	if err != nil {
 		this.logger.Error("failed update user", "error", err)
 		if localTransaction {
@@ -120,6 +148,14 @@ func (this *UserService) Update(user *models.User, tx *gorm.DB) error {
 		return err
 	}
 
+	if err := this.recordUpdateEvents(previous, user, tx); err != nil {
+		this.logger.Error("failed to record user events", "error", err)
+		if localTransaction {
+			tx.Rollback()
+		}
+		return err
+	}
+
 	if localTransaction {
 		tx.Commit()
 	}
@@ -136,6 +172,13 @@ func (this *UserService) Create(initUser *models.User, confirmed bool, newPasswo
 	userRepo = this.userRepository.WrapContext(tx)
 
 	if !initUser.IsPasswordEncrypted() {
+		if err := this.ValidatePassword(initUser, initUser.Password, "password"); err != nil {
+			if localTransaction {
+				tx.Rollback()
+			}
+			return nil, err
+		}
+
 		// Generates a hashed version of our password
 		hash, err := this.passwordService.UserHashPassword(initUser.Password)
 		if err != nil {
@@ -162,6 +205,14 @@ func (this *UserService) Create(initUser *models.User, confirmed bool, newPasswo
 		return nil, err
 	}
 
+	if err := this.passwordPolicy.Record(tx, user); err != nil {
+		this.logger.Error("failed to record password history", "error", err)
+		if localTransaction {
+			tx.Rollback()
+		}
+		return nil, err
+	}
+
 	// Addresses
 	addressRepo := this.addressRepo.WrapContext(tx)
 	if err := this.attachAddresses(initUser.MailingAddresses, models.AddressTypeMailing, user.UID, addressRepo); err != nil {
@@ -184,6 +235,14 @@ func (this *UserService) Create(initUser *models.User, confirmed bool, newPasswo
 		return nil, err
 	}
 
+	if err := this.outbox.Record(tx, events.UserCreated, events.NewUserData(user)); err != nil {
+		this.logger.Error("failed to record user created event", "error", err)
+		if localTransaction {
+			tx.Rollback()
+		}
+		return nil, err
+	}
+
 	if localTransaction {
 		tx.Commit()
 	}
@@ -191,7 +250,9 @@ func (this *UserService) Create(initUser *models.User, confirmed bool, newPasswo
 	return user, nil
 }
 
-func (this *UserService) CreateNew(user *models.User) (*models.User, error) {
+// CreateNew registers the user who signs up. If GDPR is enabled the accepted policy is uploaded as PDF
+// and the consent to it is recorded with the IP the user signs up from.
+func (this *UserService) CreateNew(user *models.User, ip string) (*models.User, error) {
 	tx := this.db.Begin()
 	user, err := this.Create(user, true, false, tx)
 	if err != nil {
@@ -207,7 +268,7 @@ func (this *UserService) CreateNew(user *models.User) (*models.User, error) {
 	}
 
 	if gdprSettings.Enabled {
-		gdprBytes, err := this.gdprService.GdprHtmlBytes()
+		gdprBytes, version, err := this.gdprService.GdprHtmlBytes()
 		if err != nil {
 			tx.Rollback()
 			return nil, err
@@ -218,6 +279,12 @@ func (this *UserService) CreateNew(user *models.User) (*models.User, error) {
 			tx.Rollback()
 			return nil, err
 		}
+
+		_, err = this.consents.Record(tx, user.UID, models.ConsentDocumentPrivacyPolicy, version, ip)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
 	}
 
 	tx.Commit()
@@ -278,16 +345,23 @@ func (this *UserService) ConfirmForgotPassword(password string, code string, ema
 	}
 
 	user := codeModel.User
+	if err := this.ValidatePassword(user, password, "password"); err != nil {
+		return err
+	}
+
 	hash, err := this.passwordService.UserHashPassword(password)
+	if err != nil {
+		return err
+	}
 	user.Password = hash
+	completeNewPasswordChallenge(user)
 
 	err = this.confirmationCodeService.DeleteConfirmationCode(codeModel)
 	if err != nil {
 		return err
 	}
 
-	_, err = this.userRepository.Update(user)
-	return err
+	return this.UpdatePasswordAndChallengeName(user)
 }
 
 func (this *UserService) CheckPhoneCode(phoneCode string, user *models.User) (*models.User, error) {
@@ -298,7 +372,7 @@ func (this *UserService) CheckPhoneCode(phoneCode string, user *models.User) (*m
 
 	user.IsPhoneConfirmed = true
 
-	return this.userRepository.Update(user)
+	return this.update(user, events.UserUpdated, events.NewUserData(user))
 }
 
 func (this *UserService) CheckEmailCode(emailCode string, user *models.User) (*models.User, error) {
@@ -309,7 +383,7 @@ func (this *UserService) CheckEmailCode(emailCode string, user *models.User) (*m
 
 	user.IsEmailConfirmed = true
 
-	return this.userRepository.Update(user)
+	return this.update(user, events.UserUpdated, events.NewUserData(user))
 }
 
 func (s *UserService) ResetPassword(newPassword, code string) error {
@@ -318,6 +392,8 @@ func (s *UserService) ResetPassword(newPassword, code string) error {
 		return err
 	}
 
+	// the user has chosen the password, so the change is not required anymore
+	completeNewPasswordChallenge(codeModel.User)
 	err = s.SetPassword(codeModel.User, newPassword)
 	if err != nil {
 		return err
@@ -332,14 +408,146 @@ func (s *UserService) ResetPassword(newPassword, code string) error {
 
 // Set new password for user
 func (s *UserService) SetPassword(user *models.User, password string) error {
+	if err := s.ValidatePassword(user, password, "newPassword"); err != nil {
+		return err
+	}
+
 	hash, err := s.passwordService.UserHashPassword(password)
 	if err != nil {
 		return err
 	}
 
 	user.Password = hash
-	_, err = s.userRepository.Update(user)
-	return err
+	return s.UpdatePasswordAndChallengeName(user)
+}
+
+// ValidatePassword checks the password against the password policy, violations are returned as *errors.ValidationErrors.
+// The source is the name of the request field the password is passed in.
+func (s *UserService) ValidatePassword(user *models.User, password, source string) error {
+	return s.passwordPolicy.Validate(password, user, source)
+}
+
+// HashPassword returns a hash of the password, it is used for passwords generated by the system
+// which are stored without checking them against the password policy
+func (s *UserService) HashPassword(password string) (string, error) {
+	return s.passwordService.UserHashPassword(password)
+}
+
+// Patch updates not empty fields of the user
+func (s *UserService) Patch(user *models.User) (*models.User, error) {
+	return s.update(user, events.UserUpdated, events.NewUserData(user))
+}
+
+// UpdatePasswordAndChallengeName stores the new password of the user and clears the challenge if it is passed,
+// the password is added to the password history
+func (s *UserService) UpdatePasswordAndChallengeName(user *models.User) error {
+	tx := s.db.Begin()
+	if err := s.userRepository.WrapContext(tx).UpdatePasswordAndChallengeName(user, user); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := s.passwordPolicy.Record(tx, user); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := s.outbox.Record(tx, events.UserPasswordChanged, &events.UserPasswordChangedData{UID: user.UID}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// completeNewPasswordChallenge clears the challenge which is completed by setting a new password
+func completeNewPasswordChallenge(user *models.User) {
+	if user.ChallengeName != nil && *user.ChallengeName == models.ChallengeNameNewPasswordRequired {
+		user.ChallengeName = nil
+	}
+}
+
+// SetStatus changes status of the user and clears blocking, the event is recorded only if the status is changed
+func (s *UserService) SetStatus(user *models.User, status string, tx *gorm.DB) error {
+	previousStatus := user.Status
+	user.Status = status
+
+	if err := s.userRepository.WrapContext(tx).UpdateStatusInfo(user); err != nil {
+		return err
+	}
+	if previousStatus == status {
+		return nil
+	}
+
+	return s.outbox.Record(tx, events.UserStatusChanged, &events.UserStatusChangedData{
+		UID:            user.UID,
+		PreviousStatus: previousStatus,
+		Status:         status,
+	})
+}
+
+// SetRoleAndGroup changes role and user group of the user, the event is recorded only if one of them is changed
+func (s *UserService) SetRoleAndGroup(user *models.User, roleName string, userGroupID *uint64, tx *gorm.DB) error {
+	changed := user.RoleName != roleName || !sameGroup(user.UserGroupId, userGroupID)
+	user.RoleName = roleName
+	user.UserGroupId = userGroupID
+	if !changed {
+		return nil
+	}
+
+	if err := s.userRepository.WrapContext(tx).UpdateRoleAndGroup(user); err != nil {
+		return err
+	}
+	return s.outbox.Record(tx, events.UserUpdated, events.NewUserData(user))
+}
+
+func sameGroup(a, b *uint64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// update saves not empty fields of the user and records the event in the same transaction
+func (s *UserService) update(user *models.User, eventType string, data interface{}) (*models.User, error) {
+	tx := s.db.Begin()
+	updated, err := s.userRepository.WrapContext(tx).Update(user)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := s.outbox.Record(tx, eventType, data); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// recordUpdateEvents records events according to the difference between the previous and the current state of the user
+func (s *UserService) recordUpdateEvents(previous, user *models.User, tx *gorm.DB) error {
+	if err := s.outbox.Record(tx, events.UserUpdated, events.NewUserData(user)); err != nil {
+		return err
+	}
+
+	if previous.Status != user.Status {
+		if err := s.outbox.Record(tx, events.UserStatusChanged, &events.UserStatusChangedData{
+			UID:            user.UID,
+			PreviousStatus: previous.Status,
+			Status:         user.Status,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if previous.Password != user.Password {
+		return s.outbox.Record(tx, events.UserPasswordChanged, &events.UserPasswordChangedData{UID: user.UID})
+	}
+	return nil
 }
 
 // Attaches addresses to the user
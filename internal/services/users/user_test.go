@@ -7,11 +7,14 @@ import (
 
 	pbSettings "github.com/Confialink/wallet-settings/rpc/proto/settings"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/inconshreveable/log15"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/Confialink/wallet-users/internal/db/models"
 	"github.com/Confialink/wallet-users/internal/db/repositories"
 	"github.com/Confialink/wallet-users/internal/services"
+	"github.com/Confialink/wallet-users/internal/services/events"
+	"github.com/Confialink/wallet-users/internal/services/passwordpolicy"
 	"github.com/Confialink/wallet-users/internal/services/syssettings"
 	"github.com/Confialink/wallet-users/internal/services/syssettings/mocks"
 	"github.com/Confialink/wallet-users/internal/tests/mocks/helpers"
@@ -20,6 +23,13 @@ import (
 
 type AnyValue struct{}
 
+// notCompromised is a breached passwords checker which knows no passwords
+type notCompromised struct{}
+
+func (notCompromised) IsCompromised(string) (bool, error) {
+	return false, nil
+}
+
 // Match satisfies sqlmock.Argument interface
 func (a AnyValue) Match(v driver.Value) bool {
 	return true
@@ -39,10 +49,13 @@ func TestUserCreateByRequest(t *testing.T) {
 	req := &pbSettings.Request{Path: "regional/modules/velmie_wallet_gdpr"}
 	resp := &pbSettings.Response{Setting: &pbSettings.Setting{Value: "disabled"}}
 	client.On("Get", context.Background(), req).Return(resp, nil)
+	policyReq := &pbSettings.Request{Path: "profile/password-policy/%"}
+	client.On("List", context.Background(), policyReq).Return(&pbSettings.Response{}, nil)
 	clientFactory := &mocks.ClientFactory{}
 	clientFactory.On("NewClient").Return(client, nil)
 	companyRepo := repositories.NewCompanyRepository(gormMock)
 	companyService := NewCompanyService(companyRepo)
+	sysSettings := syssettings.NewSysSettings(clientFactory, log15.New())
 
 	service := NewUserService(
 		gormMock,
@@ -54,9 +67,12 @@ func TestUserCreateByRequest(t *testing.T) {
 		services.NewPassword(),
 		nil,
 		nil,
-		syssettings.NewSysSettings(clientFactory),
+		sysSettings,
 		nil,
 		nil,
+		nil,
+		events.NewOutbox(repositories.NewOutboxEventRepository(gormMock), nil, log15.New()),
+		passwordpolicy.NewPolicy(sysSettings, repositories.NewPasswordHistoryRepository(gormMock), services.NewPassword(), notCompromised{}, log15.New()),
 	)
 
 	uid := "testUid"
@@ -64,7 +80,8 @@ func TestUserCreateByRequest(t *testing.T) {
 	attributeValue := "testValue"
 	email := "example@example.com"
 	user := &models.User{
-		Email: email,
+		Email:    email,
+		Password: "Str0ng!Pass",
 		PhysicalAddresses: []*models.Address{
 			{
 				Type: models.AddressTypePhysical,
@@ -93,6 +110,12 @@ func TestUserCreateByRequest(t *testing.T) {
 	sqlRows = sqlmock.NewRows([]string{"uid"}).AddRow(uid)
 	dbMock.ExpectQuery("^SELECT (.+) FROM `users` WHERE (.+)").WillReturnRows(sqlRows)
 
+	// Mock insert of the password history
+	dbMock.ExpectExec("INSERT INTO `users_password_history`").
+		WithArgs(AnyValue{}, AnyValue{}, AnyValue{}).WillReturnResult(sqlmock.NewResult(1, 1))
+	dbMock.ExpectExec("DELETE FROM users_password_history").
+		WithArgs(AnyValue{}, AnyValue{}, AnyValue{}).WillReturnResult(sqlmock.NewResult(0, 0))
+
 	// Mock insert MailingAddresses
 	dbMock.ExpectExec("INSERT INTO `addresses`").
 		WithArgs(uid, models.AddressTypeMailing, AnyValue{}, AnyValue{}, AnyValue{}, AnyValue{}, AnyValue{}, AnyValue{}, AnyValue{}, AnyValue{}, AnyValue{}, AnyValue{}, AnyValue{}, AnyValue{}, AnyValue{}).WillReturnResult(sqlmock.NewResult(1, 1))
@@ -108,9 +131,14 @@ func TestUserCreateByRequest(t *testing.T) {
 	dbMock.ExpectExec("UPDATE `user_attribute_values`").
 		WithArgs(attributeValue, AnyValue{}, uid, attributeId).WillReturnResult(sqlmock.NewResult(1, 1))
 
+	// Mock insert of the user created event
+	dbMock.ExpectExec("INSERT INTO `outbox_events`").
+		WithArgs(AnyValue{}, events.UserCreated, AnyValue{}, AnyValue{}, AnyValue{}, AnyValue{}, AnyValue{}).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
 	// Mock commit transaction
 	dbMock.ExpectCommit()
 
-	_, err := service.CreateNew(user)
+	_, err := service.CreateNew(user, "127.0.0.1")
 	assert.Nil(t, err, "service must not return an error")
 }
@@ -15,21 +15,21 @@ type ChangePassword struct {
 type ChangePasswordValidator struct {
 	Data struct {
 		PreviousPassword string `json:"previousPassword" binding:"required"`
-		ProposedPassword string `json:"proposedPassword" binding:"required,min=8,max=255,specialCharacterRequired,numberRequired,uppercaseLetterRequired,lowercaseLetterRequired"`
+		ProposedPassword string `json:"proposedPassword" binding:"required,max=255"`
 		ConfirmPassword  string `json:"confirmPassword" binding:"required,eqfield=ProposedPassword"`
 	} `json:"data"`
 	Model ChangePassword `json:"-"`
 }
 
 type SetPassword struct {
-	ProposedPassword string `json:"proposedPassword" binding:"required,min=8,max=255,specialCharacterRequired,numberRequired,uppercaseLetterRequired,lowercaseLetterRequired"`
+	ProposedPassword string `json:"proposedPassword" binding:"required,max=255"`
 	ConfirmPassword  string `json:"confirmPassword" binding:"required,eqfield=ProposedPassword"`
 }
 
 // ResetPassword struct contains validation rule for reset password
 type ResetPassword struct {
 	ConfirmationCode string `json:"confirmationCode" binding:"required"`
-	NewPassword      string `json:"newPassword" binding:"required,min=8,max=255,specialCharacterRequired,numberRequired,uppercaseLetterRequired,lowercaseLetterRequired"`
+	NewPassword      string `json:"newPassword" binding:"required,max=255"`
 }
 
 // BindJSON binding from JSON
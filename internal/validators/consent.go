@@ -0,0 +1,25 @@
+package validators
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// GrantConsentValidator is validator for consent to a document,
+// the version of documents published by the customization service is set by the service
+type GrantConsentValidator struct {
+	DocumentType string `json:"documentType" binding:"required,oneof=terms privacy_policy marketing"`
+	Version      string `json:"version" binding:"max=64"`
+}
+
+// BindJSON binding from JSON
+func (s *GrantConsentValidator) BindJSON(c *gin.Context) error {
+	b := binding.Default(c.Request.Method, c.ContentType())
+
+	err := c.ShouldBindWith(s, b)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -13,7 +13,7 @@ type CreateStaffValidator struct {
 	Data struct {
 		// Username        string `json:"username" binding:"omitempty,min=4,max=50,usernameChars"`
 		Email           string `json:"email" binding:"required,email,uniqueEmail"`
-		Password        string `json:"password" binding:"required,min=8,max=255,specialCharacterRequired,numberRequired,uppercaseLetterRequired,lowercaseLetterRequired"`
+		Password        string `json:"password" binding:"required,max=255"`
 		ConfirmPassword string `json:"confirmPassword" binding:"required,eqfield=Password"`
 		FirstName       string `json:"firstName" binding:"required,max=255"`
 		LastName        string `json:"lastName" binding:"required,max=255"`
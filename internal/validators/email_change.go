@@ -0,0 +1,42 @@
+package validators
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// RequestEmailChangeValidator is validator for a request to change the email,
+// the current password is required so a stolen session is not enough to take the account over
+type RequestEmailChangeValidator struct {
+	Email    string `json:"email" binding:"required,email,max=255,uniqueEmail"`
+	Password string `json:"password" binding:"required"`
+}
+
+// BindJSON binding from JSON
+func (s *RequestEmailChangeValidator) BindJSON(c *gin.Context) error {
+	b := binding.Default(c.Request.Method, c.ContentType())
+
+	err := c.ShouldBindWith(s, b)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// EmailChangeCodeValidator is validator for a code confirming or cancelling the change of the email
+type EmailChangeCodeValidator struct {
+	Code string `json:"code" binding:"required,max=64"`
+}
+
+// BindJSON binding from JSON
+func (s *EmailChangeCodeValidator) BindJSON(c *gin.Context) error {
+	b := binding.Default(c.Request.Method, c.ContentType())
+
+	err := c.ShouldBindWith(s, b)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
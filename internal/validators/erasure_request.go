@@ -0,0 +1,41 @@
+package validators
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// RequestErasureValidator is validator for a request of a user to erase personal data,
+// the current password is required so a stolen session is not enough to erase the account
+type RequestErasureValidator struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// BindJSON binding from JSON
+func (s *RequestErasureValidator) BindJSON(c *gin.Context) error {
+	b := binding.Default(c.Request.Method, c.ContentType())
+
+	err := c.ShouldBindWith(s, b)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RejectErasureValidator is validator for a rejection of an erasure request by an admin
+type RejectErasureValidator struct {
+	Reason string `json:"reason" binding:"required,max=255"`
+}
+
+// BindJSON binding from JSON
+func (s *RejectErasureValidator) BindJSON(c *gin.Context) error {
+	b := binding.Default(c.Request.Method, c.ContentType())
+
+	err := c.ShouldBindWith(s, b)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
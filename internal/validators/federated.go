@@ -0,0 +1,58 @@
+package validators
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// FederatedCallbackValidator is validator for parameters the identity provider redirected the user back with
+type FederatedCallbackValidator struct {
+	State string `json:"state" binding:"required,max=255"`
+	Code  string `json:"code" binding:"required,max=2048"`
+}
+
+// BindJSON binding from JSON
+func (s *FederatedCallbackValidator) BindJSON(c *gin.Context) error {
+	b := binding.Default(c.Request.Method, c.ContentType())
+
+	err := c.ShouldBindWith(s, b)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// IdentityProviderValidator is validator for an identity provider configured by an administrator.
+// Slug, issuer and mappings are checked by the federated sign in service.
+type IdentityProviderValidator struct {
+	Slug         string   `json:"slug" binding:"required,max=64"`
+	Name         string   `json:"name" binding:"required,max=255"`
+	Issuer       string   `json:"issuer" binding:"required,max=2048"`
+	ClientID     string   `json:"clientId" binding:"required,max=255"`
+	ClientSecret string   `json:"clientSecret" binding:"max=255"`
+	Scopes       []string `json:"scopes" binding:"max=16"`
+	Domains      []string `json:"domains" binding:"max=64,dive,fqdn"`
+	RoleClaim    string   `json:"roleClaim" binding:"max=255"`
+	// RoleMapping maps values of the role claim to role names
+	RoleMapping map[string]string `json:"roleMapping"`
+	DefaultRole string            `json:"defaultRole"`
+	GroupClaim  string            `json:"groupClaim" binding:"max=255"`
+	// GroupMapping maps values of the group claim to ids of user groups
+	GroupMapping          map[string]uint64 `json:"groupMapping"`
+	JitEnabled            bool              `json:"jitEnabled"`
+	PasswordLoginDisabled bool              `json:"passwordLoginDisabled"`
+	IsActive              *bool             `json:"isActive"`
+}
+
+// BindJSON binding from JSON
+func (s *IdentityProviderValidator) BindJSON(c *gin.Context) error {
+	b := binding.Default(c.Request.Method, c.ContentType())
+
+	err := c.ShouldBindWith(s, b)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -18,7 +18,7 @@ type InviteValidator struct {
 		DateOfBirthYear  uint64 `json:"dateOfBirthYear"`
 		DateOfBirthMonth uint64 `json:"dateOfBirthMonth"`
 		DateOfBirthDay   uint64 `json:"dateOfBirthDay"`
-		Password         string `json:"password" binding:"required,min=8,max=255,specialCharacterRequired,numberRequired,uppercaseLetterRequired,lowercaseLetterRequired"`
+		Password         string `json:"password" binding:"required,max=255"`
 		ConfirmPassword  string `json:"confirmPassword" binding:"required,eqfield=Password"`
 		// Addresses
 		PhysicalAdressValidator
@@ -11,6 +11,8 @@ type LoginValidator struct {
 	Data struct {
 		Email    string `json:"email" binding:"required,min=3,max=255"`
 		Password string `json:"password" binding:"required"`
+		// CaptchaToken is required after too many failed attempts
+		CaptchaToken string `json:"captchaToken"`
 	} `json:"data"`
 	UserModel models.User `json:"-"`
 }
@@ -0,0 +1,23 @@
+package validators
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// MfaCodeValidator is validator for a TOTP or a recovery code
+type MfaCodeValidator struct {
+	Code string `json:"code" binding:"required,max=32"`
+}
+
+// BindJSON binding from JSON
+func (s *MfaCodeValidator) BindJSON(c *gin.Context) error {
+	b := binding.Default(c.Request.Method, c.ContentType())
+
+	err := c.ShouldBindWith(s, b)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package validators
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// OAuthClientValidator is validator for an OAuth client registered by an administrator.
+// Redirect uris and scopes are checked by the OIDC service.
+type OAuthClientValidator struct {
+	Name         string   `json:"name" binding:"required,max=255"`
+	RedirectURIs []string `json:"redirectUris" binding:"required,min=1,max=16"`
+	Scopes       []string `json:"scopes"`
+	IsActive     *bool    `json:"isActive"`
+	// Confidential clients get a secret, it is ignored on update
+	Confidential bool `json:"confidential"`
+}
+
+// BindJSON binding from JSON
+func (s *OAuthClientValidator) BindJSON(c *gin.Context) error {
+	b := binding.Default(c.Request.Method, c.ContentType())
+
+	err := c.ShouldBindWith(s, b)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
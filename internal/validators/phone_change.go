@@ -0,0 +1,43 @@
+package validators
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// RequestPhoneChangeValidator is validator for a request to change a phone number,
+// uniqueness of the number is checked when the change is confirmed
+type RequestPhoneChangeValidator struct {
+	Field       string `json:"field" binding:"required,oneof=phoneNumber smsPhoneNumber"`
+	PhoneNumber string `json:"phoneNumber" binding:"required,max=20,phonenumber"`
+	Password    string `json:"password" binding:"required"`
+}
+
+// BindJSON binding from JSON
+func (s *RequestPhoneChangeValidator) BindJSON(c *gin.Context) error {
+	b := binding.Default(c.Request.Method, c.ContentType())
+
+	err := c.ShouldBindWith(s, b)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// PhoneChangeCodeValidator is validator for a code confirming the change of a phone number
+type PhoneChangeCodeValidator struct {
+	Code string `json:"code" binding:"required,max=64"`
+}
+
+// BindJSON binding from JSON
+func (s *PhoneChangeCodeValidator) BindJSON(c *gin.Context) error {
+	b := binding.Default(c.Request.Method, c.ContentType())
+
+	err := c.ShouldBindWith(s, b)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package validators
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"github.com/Confialink/wallet-users/pkg/webauthn"
+)
+
+// WebauthnRegistrationValidator is validator for a credential created by navigator.credentials.create().
+// The credential itself is checked during verification of the ceremony.
+type WebauthnRegistrationValidator struct {
+	Name       string                        `json:"name" binding:"max=255"`
+	Credential webauthn.RegistrationResponse `json:"credential"`
+}
+
+// BindJSON binding from JSON
+func (s *WebauthnRegistrationValidator) BindJSON(c *gin.Context) error {
+	b := binding.Default(c.Request.Method, c.ContentType())
+
+	err := c.ShouldBindWith(s, b)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// WebauthnAssertionValidator is validator for an assertion made by navigator.credentials.get()
+type WebauthnAssertionValidator struct {
+	Credential webauthn.AssertionResponse `json:"credential"`
+}
+
+// BindJSON binding from JSON
+func (s *WebauthnAssertionValidator) BindJSON(c *gin.Context) error {
+	b := binding.Default(c.Request.Method, c.ContentType())
+
+	err := c.ShouldBindWith(s, b)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
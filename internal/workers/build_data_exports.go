@@ -0,0 +1,38 @@
+package workers
+
+import (
+	"sync/atomic"
+
+	"github.com/inconshreveable/log15"
+
+	"github.com/Confialink/wallet-users/internal/services/gdpr"
+)
+
+// dataExportBatchSize limits the number of bundles built by a single run, building one takes a while
+const dataExportBatchSize = 5
+
+type buildDataExports struct {
+	export *gdpr.Export
+	logger log15.Logger
+	// running prevents overlapping of runs since the scheduler does not wait for the previous run
+	running int32
+}
+
+func (w *buildDataExports) execute() {
+	if !atomic.CompareAndSwapInt32(&w.running, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&w.running, 0)
+
+	exports, err := w.export.Pending(dataExportBatchSize)
+	if err != nil {
+		w.logger.Error("can't get pending data exports", "error", err)
+		return
+	}
+
+	for _, export := range exports {
+		if err := w.export.Build(export); err != nil {
+			w.logger.Error("can't build data export", "error", err, "uid", export.UserUID, "id", export.ID)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package workers
+
+import (
+	"sync/atomic"
+
+	"github.com/inconshreveable/log15"
+
+	"github.com/Confialink/wallet-users/internal/services/auth"
+	"github.com/Confialink/wallet-users/internal/services/gdpr"
+)
+
+// erasureBatchSize limits the number of users erased by a single run
+const erasureBatchSize = 50
+
+type eraseUsers struct {
+	erasure      *gdpr.Erasure
+	tokenService *auth.TokenService
+	logger       log15.Logger
+	// running prevents overlapping of runs since the scheduler does not wait for the previous run
+	running int32
+}
+
+func (w *eraseUsers) execute() {
+	if !atomic.CompareAndSwapInt32(&w.running, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&w.running, 0)
+
+	requests, err := w.erasure.Due(erasureBatchSize)
+	if err != nil {
+		w.logger.Error("can't get due erasure requests", "error", err)
+		return
+	}
+
+	for _, request := range requests {
+		user, err := w.erasure.Erase(request)
+		if err != nil {
+			w.logger.Error("can't erase user", "error", err, "uid", request.UserUID, "requestId", request.ID)
+			continue
+		}
+		if err := w.tokenService.RevokeUserTokens(user); err != nil {
+			w.logger.Error("can't log out erased user", "error", err, "uid", user.UID)
+		}
+	}
+}
@@ -1,8 +1,13 @@
 package workers
 
 import (
+	"time"
+
 	"github.com/Confialink/wallet-users/internal/db/repositories"
 	"github.com/Confialink/wallet-users/internal/services/auth"
+	"github.com/Confialink/wallet-users/internal/services/events"
+	"github.com/Confialink/wallet-users/internal/services/gdpr"
+	"github.com/Confialink/wallet-users/internal/services/retention"
 	"github.com/Confialink/wallet-users/internal/services/syssettings"
 	"github.com/inconshreveable/log15"
 )
@@ -11,20 +16,51 @@ func newUpdateDormantUsers(
 	repo *repositories.UsersRepository,
 	tokenService *auth.TokenService,
 	sysSettings *syssettings.SysSettings,
+	outbox *events.Outbox,
 	logger log15.Logger,
 ) *updateDormantUsers {
 	return &updateDormantUsers{
 		repo,
 		tokenService,
 		sysSettings,
+		outbox,
 		logger.New("Worker", "updateDormantUsers"),
 	}
 }
 
-func newRemoveInvalidTokens(repo *repositories.TokenRepository, service *auth.TokenService, logger log15.Logger) *removeInvalidTokens {
-	return &removeInvalidTokens{
+func newPurgeExpiredTokens(repo *repositories.TokenRepository, logger log15.Logger) *purgeExpiredTokens {
+	return &purgeExpiredTokens{
 		tokenRepository: repo,
-		tokenService:    service,
-		logger:          logger.New("Worker", "removeInvalidTokens"),
+		logger:          logger.New("Worker", "purgeExpiredTokens"),
+		now:             time.Now,
+	}
+}
+
+func newPublishOutboxEvents(outbox *events.Outbox, logger log15.Logger) *publishOutboxEvents {
+	return &publishOutboxEvents{
+		outbox: outbox,
+		logger: logger.New("Worker", "publishOutboxEvents"),
+	}
+}
+
+func newEraseUsers(erasure *gdpr.Erasure, tokenService *auth.TokenService, logger log15.Logger) *eraseUsers {
+	return &eraseUsers{
+		erasure:      erasure,
+		tokenService: tokenService,
+		logger:       logger.New("Worker", "eraseUsers"),
+	}
+}
+
+func newBuildDataExports(export *gdpr.Export, logger log15.Logger) *buildDataExports {
+	return &buildDataExports{
+		export: export,
+		logger: logger.New("Worker", "buildDataExports"),
+	}
+}
+
+func newPurgeRetention(purger *retention.Purger, logger log15.Logger) *purgeRetention {
+	return &purgeRetention{
+		purger: purger,
+		logger: logger.New("Worker", "purgeRetention"),
 	}
 }
@@ -0,0 +1,37 @@
+package workers
+
+import (
+	"sync/atomic"
+
+	"github.com/inconshreveable/log15"
+
+	"github.com/Confialink/wallet-users/internal/services/events"
+)
+
+// outboxBatchSize limits the number of events published by a single run
+const outboxBatchSize = 100
+
+type publishOutboxEvents struct {
+	outbox *events.Outbox
+	logger log15.Logger
+	// running prevents overlapping of runs since the scheduler does not wait for the previous run
+	running int32
+}
+
+func (w *publishOutboxEvents) execute() {
+	if !atomic.CompareAndSwapInt32(&w.running, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&w.running, 0)
+
+	for {
+		published, err := w.outbox.Relay(outboxBatchSize)
+		if err != nil {
+			w.logger.Error("can't publish outbox events", "error", err)
+			return
+		}
+		if published < outboxBatchSize {
+			return
+		}
+	}
+}
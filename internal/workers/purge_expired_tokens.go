@@ -0,0 +1,76 @@
+package workers
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/Confialink/wallet-pkg-utils"
+	"github.com/inconshreveable/log15"
+
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+	"github.com/Confialink/wallet-users/internal/services/auth"
+)
+
+const (
+	// tokensPurgeBatchSize is the number of tokens deleted by a single statement, small batches keep locks short
+	tokensPurgeBatchSize = 1000
+	// tokensPurgeMaxBatches limits tokens deleted by a single run, the rest is deleted by the next runs
+	tokensPurgeMaxBatches = 100
+)
+
+// purgeExpiredTokens deletes expired tokens by the indexed expiry time
+type purgeExpiredTokens struct {
+	tokenRepository *repositories.TokenRepository
+	logger          log15.Logger
+	now             func() time.Time
+	// running prevents overlapping of runs since the scheduler does not wait for the previous run
+	running int32
+}
+
+func (w *purgeExpiredTokens) execute() {
+	if !atomic.CompareAndSwapInt32(&w.running, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&w.running, 0)
+
+	now := w.now()
+	deleted, err := w.deleteInBatches(func() (int64, error) {
+		return w.tokenRepository.DeleteExpired(now, tokensPurgeBatchSize)
+	})
+	if err != nil {
+		w.logger.Error("can't delete expired tokens", "error", err, "deleted", deleted)
+		return
+	}
+
+	// tokens issued before the expiry time was stored live no longer than the default refresh token lifetime,
+	// the access token lifetime is added since an access token may be issued shortly before its refresh token expires
+	createdBefore := now.Add(-utils.MustParseDuration(auth.ClaimRefreshTokenExp)).Add(-utils.MustParseDuration(auth.ClaimAccessTokenExp))
+	deletedWithoutExpiry, err := w.deleteInBatches(func() (int64, error) {
+		return w.tokenRepository.DeleteCreatedBeforeWithoutExpiry(createdBefore, tokensPurgeBatchSize)
+	})
+	deleted += deletedWithoutExpiry
+	if err != nil {
+		w.logger.Error("can't delete tokens without expiry time", "error", err, "deleted", deleted)
+		return
+	}
+
+	if deleted > 0 {
+		w.logger.Info("expired tokens are deleted", "deleted", deleted)
+	}
+}
+
+// deleteInBatches calls deleteBatch until a batch is not full or the limit of batches is reached
+func (w *purgeExpiredTokens) deleteInBatches(deleteBatch func() (int64, error)) (int64, error) {
+	var total int64
+	for i := 0; i < tokensPurgeMaxBatches; i++ {
+		deleted, err := deleteBatch()
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+		if deleted < tokensPurgeBatchSize {
+			break
+		}
+	}
+	return total, nil
+}
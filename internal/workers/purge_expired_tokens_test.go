@@ -0,0 +1,88 @@
+package workers
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/inconshreveable/log15"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Confialink/wallet-users/internal/db/repositories"
+)
+
+var (
+	deleteExpiredTokensQuery          = regexp.QuoteMeta("DELETE FROM `tokens` WHERE expires_at < ? LIMIT ?")
+	deleteTokensWithoutExpiryQuery    = regexp.QuoteMeta("DELETE FROM `tokens` WHERE expires_at IS NULL AND created_at < ? LIMIT ?")
+	purgeNow                          = time.Date(2021, 5, 10, 12, 0, 0, 0, time.UTC)
+	tokensWithoutExpiryCreationCutoff = purgeNow.Add(-720 * time.Hour).Add(-30 * time.Minute)
+)
+
+func newTestPurgeExpiredTokens(t *testing.T) (*purgeExpiredTokens, sqlmock.Sqlmock) {
+	db, dbMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gormDb, err := gorm.Open("mysql", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worker := newPurgeExpiredTokens(repositories.NewTokenRepository(gormDb), log15.New())
+	worker.now = func() time.Time { return purgeNow }
+	return worker, dbMock
+}
+
+func TestPurgeExpiredTokensDeletesInBatches(t *testing.T) {
+	worker, dbMock := newTestPurgeExpiredTokens(t)
+	dbMock.ExpectExec(deleteExpiredTokensQuery).
+		WithArgs(purgeNow, tokensPurgeBatchSize).
+		WillReturnResult(sqlmock.NewResult(0, tokensPurgeBatchSize))
+	dbMock.ExpectExec(deleteExpiredTokensQuery).
+		WithArgs(purgeNow, tokensPurgeBatchSize).
+		WillReturnResult(sqlmock.NewResult(0, 10))
+	dbMock.ExpectExec(deleteTokensWithoutExpiryQuery).
+		WithArgs(tokensWithoutExpiryCreationCutoff, tokensPurgeBatchSize).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	worker.execute()
+
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestPurgeExpiredTokensStopsAtMaxBatches(t *testing.T) {
+	worker, dbMock := newTestPurgeExpiredTokens(t)
+	for i := 0; i < tokensPurgeMaxBatches; i++ {
+		dbMock.ExpectExec(deleteExpiredTokensQuery).
+			WillReturnResult(sqlmock.NewResult(0, tokensPurgeBatchSize))
+	}
+	dbMock.ExpectExec(deleteTokensWithoutExpiryQuery).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	worker.execute()
+
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestPurgeExpiredTokensStopsOnError(t *testing.T) {
+	worker, dbMock := newTestPurgeExpiredTokens(t)
+	dbMock.ExpectExec(deleteExpiredTokensQuery).
+		WillReturnError(errors.New("lock wait timeout exceeded"))
+
+	worker.execute()
+
+	// tokens without the expiry time are not deleted after a failure
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestPurgeExpiredTokensSkipsOverlappingRun(t *testing.T) {
+	worker, dbMock := newTestPurgeExpiredTokens(t)
+	worker.running = 1
+
+	worker.execute()
+
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
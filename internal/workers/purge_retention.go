@@ -0,0 +1,28 @@
+package workers
+
+import (
+	"sync/atomic"
+
+	"github.com/inconshreveable/log15"
+
+	"github.com/Confialink/wallet-users/internal/services/retention"
+)
+
+type purgeRetention struct {
+	purger *retention.Purger
+	logger log15.Logger
+	// running prevents overlapping of runs since the scheduler does not wait for the previous run
+	running int32
+}
+
+func (w *purgeRetention) execute() {
+	if !atomic.CompareAndSwapInt32(&w.running, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&w.running, 0)
+
+	// results are reported by the purger itself
+	if _, err := w.purger.Purge(false); err != nil {
+		w.logger.Error("can't purge expired data", "error", err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/Confialink/wallet-pkg-list_params"
 	"github.com/Confialink/wallet-users/internal/services/auth"
+	"github.com/Confialink/wallet-users/internal/services/events"
 	"github.com/inconshreveable/log15"
 
 	notificationspb "github.com/Confialink/wallet-notifications/rpc/proto/notifications"
@@ -20,6 +21,7 @@ type updateDormantUsers struct {
 	repo         *repositories.UsersRepository
 	tokenService *auth.TokenService
 	sysSettings  *syssettings.SysSettings
+	outbox       *events.Outbox
 	logger       log15.Logger
 }
 
@@ -46,8 +48,7 @@ func (w *updateDormantUsers) execute() {
 }
 
 func (w *updateDormantUsers) setDormantStatus(user *models.User) {
-	user.Status = models.StatusDormant
-	if _, err := w.repo.Update(user); err != nil {
+	if err := w.updateStatus(user, models.StatusDormant); err != nil {
 		w.logger.Error("Can't update user", "error", err)
 	}
 
@@ -69,6 +70,29 @@ func (w *updateDormantUsers) setDormantStatus(user *models.User) {
 
 }
 
+// updateStatus stores the status and records the status change in the same transaction
+func (w *updateDormantUsers) updateStatus(user *models.User, status string) error {
+	previousStatus := user.Status
+	user.Status = status
+
+	tx := w.repo.DB.Begin()
+	if _, err := w.repo.WrapContext(tx).Update(user); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := w.outbox.Record(tx, events.UserStatusChanged, &events.UserStatusChangedData{
+		UID:            user.UID,
+		PreviousStatus: previousStatus,
+		Status:         status,
+	}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
 func (s *updateDormantUsers) getClient() (notificationspb.NotificationHandler, error) {
 	notificationsUrl, err := srvdiscovery.ResolveRPC(srvdiscovery.ServiceNameNotifications)
 	if nil != err {
@@ -3,6 +3,9 @@ package workers
 import (
 	"github.com/Confialink/wallet-users/internal/db/repositories"
 	"github.com/Confialink/wallet-users/internal/services/auth"
+	"github.com/Confialink/wallet-users/internal/services/events"
+	"github.com/Confialink/wallet-users/internal/services/gdpr"
+	"github.com/Confialink/wallet-users/internal/services/retention"
 	"github.com/Confialink/wallet-users/internal/services/syssettings"
 	"github.com/inconshreveable/log15"
 	"github.com/jasonlvhit/gocron"
@@ -11,11 +14,16 @@ import (
 func Start(
 	scheduler *gocron.Scheduler,
 	repo *repositories.UsersRepository,
+	tokenRepository *repositories.TokenRepository,
 	tokenService *auth.TokenService,
 	sysSettings *syssettings.SysSettings,
+	outbox *events.Outbox,
+	erasure *gdpr.Erasure,
+	export *gdpr.Export,
+	purger *retention.Purger,
 	logger log15.Logger,
 ) {
-	register(scheduler, repo, tokenService, sysSettings, logger)
+	register(scheduler, repo, tokenRepository, tokenService, sysSettings, outbox, erasure, export, purger, logger)
 	scheduler.Start()
 	log15.New().Info("Scheduler is started")
 }
@@ -23,10 +31,19 @@ func Start(
 func register(
 	scheduler *gocron.Scheduler,
 	repo *repositories.UsersRepository,
+	tokenRepository *repositories.TokenRepository,
 	tokenService *auth.TokenService,
 	sysSettings *syssettings.SysSettings,
+	outbox *events.Outbox,
+	erasure *gdpr.Erasure,
+	export *gdpr.Export,
+	purger *retention.Purger,
 	logger log15.Logger,
 ) {
-	scheduler.Every(1).Hour().Do(newUpdateDormantUsers(repo, tokenService, sysSettings, logger).execute)
-	// scheduler.Every(24).Hour().Do(newRemoveInvalidTokens().execute)
+	scheduler.Every(1).Hour().Do(newUpdateDormantUsers(repo, tokenService, sysSettings, outbox, logger).execute)
+	scheduler.Every(5).Seconds().Do(newPublishOutboxEvents(outbox, logger).execute)
+	scheduler.Every(1).Hour().Do(newEraseUsers(erasure, tokenService, logger).execute)
+	scheduler.Every(1).Minute().Do(newBuildDataExports(export, logger).execute)
+	scheduler.Every(1).Hour().Do(newPurgeRetention(purger, logger).execute)
+	scheduler.Every(10).Minutes().Do(newPurgeExpiredTokens(tokenRepository, logger).execute)
 }
@@ -0,0 +1,52 @@
+<?php
+
+use Illuminate\Support\Facades\Schema;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Database\Migrations\Migration;
+
+class AddUsersTotpTable extends Migration
+{
+    /**
+     * Run the migrations.
+     *
+     * @return void
+     */
+    public function up()
+    {
+        Schema::create('users_totp', function (Blueprint $table) {
+            $table->charset = 'utf8';
+            $table->collation = 'utf8_general_ci';
+            $table->increments('id');
+            $table->string('user_uid', 255)->nullable(false)->unique();
+            $table->string('secret', 255)->nullable(false);
+            $table->boolean('is_confirmed')->nullable(false)->default(false);
+            $table->bigInteger('last_used_step')->nullable(false)->default(0);
+            $table->timestamp('confirmed_at')->nullable(true);
+            $table->timestamps();
+            $table->foreign('user_uid')->references('uid')->on('users')->onDelete('cascade');
+        });
+
+        Schema::create('mfa_recovery_codes', function (Blueprint $table) {
+            $table->charset = 'utf8';
+            $table->collation = 'utf8_general_ci';
+            $table->increments('id');
+            $table->string('user_uid', 255)->nullable(false);
+            $table->string('code_hash', 64)->nullable(false);
+            $table->timestamp('used_at')->nullable(true);
+            $table->timestamp('created_at')->nullable(true);
+            $table->foreign('user_uid')->references('uid')->on('users')->onDelete('cascade');
+            $table->index(['user_uid', 'code_hash']);
+        });
+    }
+
+    /**
+     * Reverse the migrations.
+     *
+     * @return void
+     */
+    public function down()
+    {
+        Schema::dropIfExists('mfa_recovery_codes');
+        Schema::dropIfExists('users_totp');
+    }
+}
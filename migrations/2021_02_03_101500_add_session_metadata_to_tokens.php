@@ -0,0 +1,38 @@
+<?php
+
+use Illuminate\Support\Facades\Schema;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Database\Migrations\Migration;
+
+class AddSessionMetadataToTokens extends Migration
+{
+    /**
+     * Run the migrations.
+     *
+     * @return void
+     */
+    public function up()
+    {
+        Schema::table('tokens', function (Blueprint $table) {
+            $table->string('device_id', 255)->nullable(false)->default('');
+            $table->string('user_agent', 512)->nullable(false)->default('');
+            $table->string('ip', 45)->nullable(false)->default('');
+            $table->timestamp('first_seen_at')->nullable(true);
+            $table->timestamp('last_used_at')->nullable(true);
+            $table->index(['user_uid', 'subject'], 'tokens_user_uid_subject_index');
+        });
+    }
+
+    /**
+     * Reverse the migrations.
+     *
+     * @return void
+     */
+    public function down()
+    {
+        Schema::table('tokens', function (Blueprint $table) {
+            $table->dropIndex('tokens_user_uid_subject_index');
+            $table->dropColumn(['device_id', 'user_agent', 'ip', 'first_seen_at', 'last_used_at']);
+        });
+    }
+}
@@ -0,0 +1,51 @@
+<?php
+
+use Illuminate\Support\Facades\Schema;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Database\Migrations\Migration;
+
+class AddRefreshTokenFamilies extends Migration
+{
+    /**
+     * Run the migrations.
+     *
+     * @return void
+     */
+    public function up()
+    {
+        Schema::table('tokens', function (Blueprint $table) {
+            $table->string('family_id', 36)->nullable(false)->default('');
+            $table->timestamp('revoked_at')->nullable(true);
+            $table->index('family_id', 'tokens_family_id_index');
+        });
+
+        Schema::create('security_events', function (Blueprint $table) {
+            $table->charset = 'utf8';
+            $table->collation = 'utf8_general_ci';
+            $table->increments('id');
+            $table->string('user_uid', 255)->nullable(false);
+            $table->string('type', 64)->nullable(false);
+            $table->string('ip', 45)->nullable(false)->default('');
+            $table->string('user_agent', 512)->nullable(false)->default('');
+            $table->text('details')->nullable(true);
+            $table->timestamp('created_at')->nullable(true);
+            $table->foreign('user_uid')->references('uid')->on('users')->onDelete('cascade');
+            $table->index(['user_uid', 'created_at']);
+        });
+    }
+
+    /**
+     * Reverse the migrations.
+     *
+     * @return void
+     */
+    public function down()
+    {
+        Schema::dropIfExists('security_events');
+
+        Schema::table('tokens', function (Blueprint $table) {
+            $table->dropIndex('tokens_family_id_index');
+            $table->dropColumn(['family_id', 'revoked_at']);
+        });
+    }
+}
@@ -0,0 +1,42 @@
+<?php
+
+use Illuminate\Support\Facades\Schema;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Database\Migrations\Migration;
+
+class AddJwtKeysTable extends Migration
+{
+    /**
+     * Run the migrations.
+     *
+     * @return void
+     */
+    public function up()
+    {
+        Schema::create('jwt_keys', function (Blueprint $table) {
+            $table->charset = 'utf8';
+            $table->collation = 'utf8_general_ci';
+            $table->increments('id');
+            $table->string('kid', 64)->nullable(false)->unique();
+            $table->string('algorithm', 16)->nullable(false);
+            $table->text('private_key')->nullable(false);
+            $table->text('public_key')->nullable(false);
+            $table->string('status', 16)->nullable(false);
+            $table->timestamp('created_at')->nullable(true);
+            $table->timestamp('promoted_at')->nullable(true);
+            $table->timestamp('demoted_at')->nullable(true);
+            $table->timestamp('retired_at')->nullable(true);
+            $table->index(['algorithm', 'status']);
+        });
+    }
+
+    /**
+     * Reverse the migrations.
+     *
+     * @return void
+     */
+    public function down()
+    {
+        Schema::dropIfExists('jwt_keys');
+    }
+}
@@ -0,0 +1,40 @@
+<?php
+
+use Illuminate\Support\Facades\Schema;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Database\Migrations\Migration;
+
+class AddOutboxEventsTable extends Migration
+{
+    /**
+     * Run the migrations.
+     *
+     * @return void
+     */
+    public function up()
+    {
+        Schema::create('outbox_events', function (Blueprint $table) {
+            $table->charset = 'utf8';
+            $table->collation = 'utf8_general_ci';
+            $table->bigIncrements('id');
+            $table->string('event_id', 36)->nullable(false)->unique();
+            $table->string('subject', 128)->nullable(false);
+            $table->mediumText('payload')->nullable(false);
+            $table->unsignedInteger('attempts')->nullable(false)->default(0);
+            $table->string('last_error', 512)->nullable(false)->default('');
+            $table->timestamp('created_at')->nullable(true);
+            $table->timestamp('published_at')->nullable(true);
+            $table->index(['published_at', 'id']);
+        });
+    }
+
+    /**
+     * Reverse the migrations.
+     *
+     * @return void
+     */
+    public function down()
+    {
+        Schema::dropIfExists('outbox_events');
+    }
+}
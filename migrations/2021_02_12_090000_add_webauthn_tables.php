@@ -0,0 +1,57 @@
+<?php
+
+use Illuminate\Support\Facades\Schema;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Database\Migrations\Migration;
+
+class AddWebauthnTables extends Migration
+{
+    /**
+     * Run the migrations.
+     *
+     * @return void
+     */
+    public function up()
+    {
+        Schema::create('users_webauthn_credentials', function (Blueprint $table) {
+            $table->charset = 'utf8';
+            $table->collation = 'utf8_general_ci';
+            $table->increments('id');
+            $table->string('user_uid', 255)->nullable(false);
+            $table->string('credential_id', 255)->nullable(false)->unique();
+            $table->binary('public_key')->nullable(false);
+            $table->unsignedInteger('sign_count')->nullable(false)->default(0);
+            $table->string('aaguid', 36)->nullable(false)->default('');
+            $table->string('name', 255)->nullable(false)->default('');
+            $table->string('transports', 255)->nullable(false)->default('');
+            $table->boolean('user_verified')->nullable(false)->default(false);
+            $table->timestamp('last_used_at')->nullable(true);
+            $table->timestamps();
+            $table->foreign('user_uid')->references('uid')->on('users')->onDelete('cascade');
+            $table->index('user_uid');
+        });
+
+        Schema::create('webauthn_challenges', function (Blueprint $table) {
+            $table->charset = 'utf8';
+            $table->collation = 'utf8_general_ci';
+            $table->increments('id');
+            $table->string('challenge_hash', 64)->nullable(false)->unique();
+            $table->string('ceremony', 32)->nullable(false);
+            $table->string('user_uid', 255)->nullable(false)->default('');
+            $table->timestamp('expires_at')->nullable(true);
+            $table->timestamp('created_at')->nullable(true);
+            $table->index('expires_at');
+        });
+    }
+
+    /**
+     * Reverse the migrations.
+     *
+     * @return void
+     */
+    public function down()
+    {
+        Schema::dropIfExists('webauthn_challenges');
+        Schema::dropIfExists('users_webauthn_credentials');
+    }
+}
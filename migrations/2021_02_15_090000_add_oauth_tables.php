@@ -0,0 +1,70 @@
+<?php
+
+use Illuminate\Support\Facades\Schema;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Database\Migrations\Migration;
+
+class AddOauthTables extends Migration
+{
+    /**
+     * Run the migrations.
+     *
+     * @return void
+     */
+    public function up()
+    {
+        Schema::create('oauth_clients', function (Blueprint $table) {
+            $table->charset = 'utf8';
+            $table->collation = 'utf8_general_ci';
+            $table->increments('id');
+            $table->string('client_id', 64)->nullable(false)->unique();
+            $table->string('secret_hash', 64)->nullable(false)->default('');
+            $table->string('name', 255)->nullable(false)->default('');
+            $table->text('redirect_uris')->nullable(false);
+            $table->string('scopes', 255)->nullable(false)->default('');
+            $table->boolean('is_active')->nullable(false)->default(true);
+            $table->timestamps();
+        });
+
+        Schema::create('oauth_authorization_codes', function (Blueprint $table) {
+            $table->charset = 'utf8';
+            $table->collation = 'utf8_general_ci';
+            $table->increments('id');
+            $table->string('code_hash', 64)->nullable(false)->unique();
+            $table->string('client_id', 64)->nullable(false);
+            $table->string('user_uid', 255)->nullable(false);
+            $table->string('redirect_uri', 2048)->nullable(false)->default('');
+            $table->string('scope', 255)->nullable(false)->default('');
+            $table->string('nonce', 255)->nullable(false)->default('');
+            $table->string('code_challenge', 128)->nullable(false)->default('');
+            $table->string('code_challenge_method', 16)->nullable(false)->default('');
+            $table->timestamp('auth_time')->nullable(true);
+            $table->timestamp('expires_at')->nullable(true);
+            $table->timestamp('created_at')->nullable(true);
+            $table->foreign('user_uid')->references('uid')->on('users')->onDelete('cascade');
+            $table->index('expires_at');
+        });
+
+        Schema::table('tokens', function (Blueprint $table) {
+            $table->string('client_id', 64)->nullable(false)->default('');
+            $table->string('scope', 255)->nullable(false)->default('');
+            $table->index('client_id', 'tokens_client_id_index');
+        });
+    }
+
+    /**
+     * Reverse the migrations.
+     *
+     * @return void
+     */
+    public function down()
+    {
+        Schema::table('tokens', function (Blueprint $table) {
+            $table->dropIndex('tokens_client_id_index');
+            $table->dropColumn(['client_id', 'scope']);
+        });
+
+        Schema::dropIfExists('oauth_authorization_codes');
+        Schema::dropIfExists('oauth_clients');
+    }
+}
@@ -0,0 +1,81 @@
+<?php
+
+use Illuminate\Support\Facades\Schema;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Database\Migrations\Migration;
+
+class AddIdentityProvidersTables extends Migration
+{
+    /**
+     * Run the migrations.
+     *
+     * @return void
+     */
+    public function up()
+    {
+        Schema::create('identity_providers', function (Blueprint $table) {
+            $table->charset = 'utf8';
+            $table->collation = 'utf8_general_ci';
+            $table->increments('id');
+            $table->string('slug', 64)->nullable(false)->unique();
+            $table->string('name', 255)->nullable(false)->default('');
+            $table->string('issuer', 2048)->nullable(false)->default('');
+            $table->string('client_id', 255)->nullable(false)->default('');
+            $table->string('client_secret', 255)->nullable(false)->default('');
+            $table->string('scopes', 255)->nullable(false)->default('');
+            $table->text('domains')->nullable(false);
+            $table->string('role_claim', 255)->nullable(false)->default('');
+            $table->text('role_mapping')->nullable(false);
+            $table->string('default_role', 32)->nullable(false)->default('');
+            $table->string('group_claim', 255)->nullable(false)->default('');
+            $table->text('group_mapping')->nullable(false);
+            $table->boolean('jit_enabled')->nullable(false)->default(false);
+            $table->boolean('password_login_disabled')->nullable(false)->default(false);
+            $table->boolean('is_active')->nullable(false)->default(true);
+            $table->timestamps();
+        });
+
+        Schema::create('users_identities', function (Blueprint $table) {
+            $table->charset = 'utf8';
+            $table->collation = 'utf8_general_ci';
+            $table->increments('id');
+            $table->string('user_uid', 255)->nullable(false);
+            $table->unsignedInteger('provider_id')->nullable(false);
+            $table->string('subject', 255)->nullable(false);
+            $table->string('email', 255)->nullable(false)->default('');
+            $table->timestamp('last_login_at')->nullable(true);
+            $table->timestamps();
+            $table->foreign('user_uid')->references('uid')->on('users')->onDelete('cascade');
+            $table->foreign('provider_id')->references('id')->on('identity_providers')->onDelete('cascade');
+            $table->unique(['provider_id', 'subject']);
+            $table->unique(['user_uid', 'provider_id']);
+        });
+
+        Schema::create('federated_login_states', function (Blueprint $table) {
+            $table->charset = 'utf8';
+            $table->collation = 'utf8_general_ci';
+            $table->increments('id');
+            $table->string('state_hash', 64)->nullable(false)->unique();
+            $table->unsignedInteger('provider_id')->nullable(false);
+            $table->string('nonce', 64)->nullable(false)->default('');
+            $table->string('code_verifier', 128)->nullable(false)->default('');
+            $table->string('user_uid', 255)->nullable(false)->default('');
+            $table->timestamp('expires_at')->nullable(true);
+            $table->timestamp('created_at')->nullable(true);
+            $table->foreign('provider_id')->references('id')->on('identity_providers')->onDelete('cascade');
+            $table->index('expires_at');
+        });
+    }
+
+    /**
+     * Reverse the migrations.
+     *
+     * @return void
+     */
+    public function down()
+    {
+        Schema::dropIfExists('federated_login_states');
+        Schema::dropIfExists('users_identities');
+        Schema::dropIfExists('identity_providers');
+    }
+}
@@ -0,0 +1,41 @@
+<?php
+
+use Illuminate\Support\Facades\Schema;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Database\Migrations\Migration;
+use Illuminate\Support\Facades\DB;
+
+class AddUsersPasswordHistoryTable extends Migration
+{
+    /**
+     * Run the migrations.
+     *
+     * @return void
+     */
+    public function up()
+    {
+        Schema::create('users_password_history', function (Blueprint $table) {
+            $table->charset = 'utf8';
+            $table->collation = 'utf8_general_ci';
+            $table->increments('id');
+            $table->string('user_uid', 255)->nullable(false);
+            $table->string('password', 255)->nullable(false)->default('');
+            $table->timestamp('created_at')->nullable(true);
+            $table->foreign('user_uid')->references('uid')->on('users')->onDelete('cascade');
+            $table->index(['user_uid', 'id']);
+        });
+
+        // the age of current passwords is counted from the migration
+        DB::statement('INSERT INTO users_password_history (user_uid, password, created_at) SELECT uid, password, NOW() FROM users');
+    }
+
+    /**
+     * Reverse the migrations.
+     *
+     * @return void
+     */
+    public function down()
+    {
+        Schema::dropIfExists('users_password_history');
+    }
+}
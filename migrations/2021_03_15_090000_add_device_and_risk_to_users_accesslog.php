@@ -0,0 +1,36 @@
+<?php
+
+use Illuminate\Support\Facades\Schema;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Database\Migrations\Migration;
+
+class AddDeviceAndRiskToUsersAccesslog extends Migration
+{
+    /**
+     * Run the migrations.
+     *
+     * @return void
+     */
+    public function up()
+    {
+        Schema::table('users_accesslog', function (Blueprint $table) {
+            $table->string('user_agent', 512)->nullable(false)->default('');
+            $table->string('device_fingerprint', 64)->nullable(false)->default('');
+            $table->unsignedTinyInteger('risk_score')->nullable(false)->default(0);
+            $table->index(['uid', 'created_at'], 'users_accesslog_uid_created_at_index');
+        });
+    }
+
+    /**
+     * Reverse the migrations.
+     *
+     * @return void
+     */
+    public function down()
+    {
+        Schema::table('users_accesslog', function (Blueprint $table) {
+            $table->dropIndex('users_accesslog_uid_created_at_index');
+            $table->dropColumn(['user_agent', 'device_fingerprint', 'risk_score']);
+        });
+    }
+}
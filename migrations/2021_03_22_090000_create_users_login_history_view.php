@@ -0,0 +1,35 @@
+<?php
+
+use Illuminate\Database\Migrations\Migration;
+use Illuminate\Support\Facades\DB;
+
+class CreateUsersLoginHistoryView extends Migration
+{
+    /**
+     * Run the migrations.
+     *
+     * @return void
+     */
+    public function up()
+    {
+        DB::statement("
+            CREATE VIEW users_login_history AS
+            SELECT uid, INET_NTOA(ip) AS ip, user_agent, risk_score, 'success' AS status, created_at
+            FROM users_accesslog
+            UNION ALL
+            SELECT uid, INET_NTOA(ip) AS ip, '' AS user_agent, 0 AS risk_score, 'failed' AS status, created_at
+            FROM fail_auth_attempts
+            WHERE uid <> ''
+        ");
+    }
+
+    /**
+     * Reverse the migrations.
+     *
+     * @return void
+     */
+    public function down()
+    {
+        DB::statement('DROP VIEW IF EXISTS users_login_history');
+    }
+}
@@ -0,0 +1,43 @@
+<?php
+
+use Illuminate\Support\Facades\Schema;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Database\Migrations\Migration;
+
+class AddUsersEmailChangesTable extends Migration
+{
+    /**
+     * Run the migrations.
+     *
+     * @return void
+     */
+    public function up()
+    {
+        Schema::create('users_email_changes', function (Blueprint $table) {
+            $table->charset = 'utf8';
+            $table->collation = 'utf8_general_ci';
+            $table->increments('id');
+            $table->string('user_uid', 255)->nullable(false);
+            $table->string('old_email', 255)->nullable(false)->default('');
+            $table->boolean('old_email_confirmed')->nullable(false)->default(false);
+            $table->string('new_email', 255)->nullable(false);
+            $table->string('status', 16)->nullable(false);
+            $table->unsignedInteger('cancel_code_id')->nullable(false);
+            $table->timestamp('confirmed_at')->nullable(true);
+            $table->timestamps();
+            $table->foreign('user_uid')->references('uid')->on('users')->onDelete('cascade');
+            $table->index(['user_uid', 'id']);
+            $table->index('cancel_code_id');
+        });
+    }
+
+    /**
+     * Reverse the migrations.
+     *
+     * @return void
+     */
+    public function down()
+    {
+        Schema::dropIfExists('users_email_changes');
+    }
+}
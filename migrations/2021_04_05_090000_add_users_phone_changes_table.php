@@ -0,0 +1,41 @@
+<?php
+
+use Illuminate\Support\Facades\Schema;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Database\Migrations\Migration;
+
+class AddUsersPhoneChangesTable extends Migration
+{
+    /**
+     * Run the migrations.
+     *
+     * @return void
+     */
+    public function up()
+    {
+        Schema::create('users_phone_changes', function (Blueprint $table) {
+            $table->charset = 'utf8';
+            $table->collation = 'utf8_general_ci';
+            $table->increments('id');
+            $table->string('user_uid', 255)->nullable(false);
+            $table->string('field', 32)->nullable(false);
+            $table->string('old_phone_number', 100)->nullable(false)->default('');
+            $table->string('new_phone_number', 100)->nullable(false);
+            $table->string('status', 16)->nullable(false);
+            $table->timestamp('confirmed_at')->nullable(true);
+            $table->timestamps();
+            $table->foreign('user_uid')->references('uid')->on('users')->onDelete('cascade');
+            $table->index(['user_uid', 'id']);
+        });
+    }
+
+    /**
+     * Reverse the migrations.
+     *
+     * @return void
+     */
+    public function down()
+    {
+        Schema::dropIfExists('users_phone_changes');
+    }
+}
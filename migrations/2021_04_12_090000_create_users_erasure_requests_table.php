@@ -0,0 +1,43 @@
+<?php
+
+use Illuminate\Support\Facades\Schema;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Database\Migrations\Migration;
+
+class CreateUsersErasureRequestsTable extends Migration
+{
+    /**
+     * Run the migrations.
+     *
+     * @return void
+     */
+    public function up()
+    {
+        Schema::create('users_erasure_requests', function (Blueprint $table) {
+            $table->charset = 'utf8';
+            $table->collation = 'utf8_general_ci';
+            $table->increments('id');
+            $table->string('user_uid', 255)->nullable(false);
+            $table->string('status', 16)->nullable(false);
+            $table->string('reviewed_by', 255)->nullable(false)->default('');
+            $table->timestamp('reviewed_at')->nullable(true);
+            $table->string('reason', 255)->nullable(false)->default('');
+            $table->timestamp('erase_after')->nullable(true);
+            $table->timestamp('erased_at')->nullable(true);
+            $table->timestamps();
+            $table->foreign('user_uid')->references('uid')->on('users')->onDelete('cascade');
+            $table->index(['user_uid', 'status']);
+            $table->index(['status', 'erase_after']);
+        });
+    }
+
+    /**
+     * Reverse the migrations.
+     *
+     * @return void
+     */
+    public function down()
+    {
+        Schema::dropIfExists('users_erasure_requests');
+    }
+}
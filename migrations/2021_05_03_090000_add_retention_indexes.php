@@ -0,0 +1,49 @@
+<?php
+
+use Illuminate\Support\Facades\Schema;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Database\Migrations\Migration;
+
+class AddRetentionIndexes extends Migration
+{
+    /**
+     * Indexed columns the retention purge deletes rows by.
+     *
+     * @var array
+     */
+    private $indexes = [
+        'confirmation_codes' => 'expires_at',
+        'fail_auth_attempts' => 'created_at',
+        'blocked_ips' => 'blocked_until',
+        'invites' => 'expires_at',
+        'users_accesslog' => 'created_at',
+    ];
+
+    /**
+     * Run the migrations.
+     *
+     * @return void
+     */
+    public function up()
+    {
+        foreach ($this->indexes as $tableName => $column) {
+            Schema::table($tableName, function (Blueprint $table) use ($tableName, $column) {
+                $table->index($column, $tableName . '_' . $column . '_retention_index');
+            });
+        }
+    }
+
+    /**
+     * Reverse the migrations.
+     *
+     * @return void
+     */
+    public function down()
+    {
+        foreach ($this->indexes as $tableName => $column) {
+            Schema::table($tableName, function (Blueprint $table) use ($tableName, $column) {
+                $table->dropIndex($tableName . '_' . $column . '_retention_index');
+            });
+        }
+    }
+}
@@ -0,0 +1,36 @@
+<?php
+
+use Illuminate\Support\Facades\Schema;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Database\Migrations\Migration;
+
+class AddExpiresAtToTokens extends Migration
+{
+    /**
+     * Run the migrations.
+     *
+     * Tokens issued before the migration keep NULL, they are purged by the creation time.
+     *
+     * @return void
+     */
+    public function up()
+    {
+        Schema::table('tokens', function (Blueprint $table) {
+            $table->timestamp('expires_at')->nullable(true);
+            $table->index(['expires_at', 'created_at'], 'tokens_expires_at_created_at_index');
+        });
+    }
+
+    /**
+     * Reverse the migrations.
+     *
+     * @return void
+     */
+    public function down()
+    {
+        Schema::table('tokens', function (Blueprint $table) {
+            $table->dropIndex('tokens_expires_at_created_at_index');
+            $table->dropColumn('expires_at');
+        });
+    }
+}
@@ -0,0 +1,34 @@
+<?php
+
+use Illuminate\Support\Facades\Schema;
+use Illuminate\Database\Schema\Blueprint;
+use Illuminate\Database\Migrations\Migration;
+
+class AddConfirmationCodeIdToUsersPhoneChanges extends Migration
+{
+    /**
+     * Run the migrations.
+     *
+     * Pending changes requested before the migration keep NULL and can not be confirmed, users request them again.
+     *
+     * @return void
+     */
+    public function up()
+    {
+        Schema::table('users_phone_changes', function (Blueprint $table) {
+            $table->unsignedInteger('confirmation_code_id')->nullable(true);
+        });
+    }
+
+    /**
+     * Reverse the migrations.
+     *
+     * @return void
+     */
+    public function down()
+    {
+        Schema::table('users_phone_changes', function (Blueprint $table) {
+            $table->dropColumn('confirmation_code_id');
+        });
+    }
+}
@@ -0,0 +1,54 @@
+package captcha
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSiteVerify(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "s3cret", r.PostForm.Get("secret"))
+		assert.Equal(t, "203.0.113.7", r.PostForm.Get("remoteip"))
+
+		switch r.PostForm.Get("response") {
+		case "solved":
+			w.Write([]byte(`{"success": true, "hostname": "wallet.example.com"}`))
+		case "broken":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+		}
+	}))
+	defer server.Close()
+
+	verifier := NewSiteVerify(server.URL, "s3cret")
+
+	ok, err := verifier.Verify("solved", "203.0.113.7")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = verifier.Verify("guessed", "203.0.113.7")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = verifier.Verify("broken", "203.0.113.7")
+	assert.Error(t, err, "the provider is not available")
+
+	ok, err = verifier.Verify("", "203.0.113.7")
+	assert.NoError(t, err)
+	assert.False(t, ok, "empty tokens are not sent to the provider")
+}
+
+func TestStub(t *testing.T) {
+	ok, _ := NewStub("test-token").Verify("test-token", "")
+	assert.True(t, ok)
+	ok, _ = NewStub("test-token").Verify("other", "")
+	assert.False(t, ok)
+	ok, _ = NewStub("").Verify("", "")
+	assert.False(t, ok)
+}
@@ -0,0 +1,170 @@
+// Package geoip finds approximate locations of IP addresses in an offline database.
+// The database is a CSV file of IP ranges, either in the layout of the free DB-IP "IP to City Lite" database
+// or in a short layout which keeps only the fields used here:
+//
+//	ip_start,ip_end,continent,country,stateprov,city,latitude,longitude
+//	1.0.0.0,1.0.0.255,OC,AU,Queensland,South Brisbane,-27.4748,153.017
+//
+//	ip_start,ip_end,country,latitude,longitude
+//	1.0.0.0,1.0.0.255,AU,-27.4748,153.017
+//
+// Both IPv4 and IPv6 ranges are supported. The whole database is kept in memory, no network is used:
+//
+//	db, err := geoip.Open("/var/lib/geoip/dbip-city-lite.csv")
+//	location, ok := db.Lookup(net.ParseIP("1.0.0.1"))
+package geoip
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// earthRadiusKm is the mean radius of the Earth
+const earthRadiusKm = 6371.0
+
+// Location is an approximate location of an IP address
+type Location struct {
+	// Country is ISO 3166-1 alpha-2 code of the country
+	Country   string
+	Latitude  float64
+	Longitude float64
+}
+
+type ipRange struct {
+	start    net.IP
+	end      net.IP
+	location Location
+}
+
+// Database is a sorted list of IP ranges
+type Database struct {
+	ranges []ipRange
+}
+
+// Open reads the database from the CSV file
+func Open(path string) (*Database, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Read(f)
+}
+
+// Read reads the database in CSV, the first line is skipped if it is a header
+func Read(r io.Reader) (*Database, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	// country codes are repeated in many ranges, so they are shared
+	countries := make(map[string]string)
+	db := &Database{}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := parseRange(record, countries)
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		db.ranges = append(db.ranges, r)
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return bytes.Compare(db.ranges[i].start, db.ranges[j].start) < 0
+	})
+	return db, nil
+}
+
+// Len returns the number of ranges in the database
+func (d *Database) Len() int {
+	return len(d.ranges)
+}
+
+// Lookup returns the location of the IP, it returns false if the IP is not in the database
+func (d *Database) Lookup(ip net.IP) (Location, bool) {
+	ip = ip.To16()
+	if ip == nil {
+		return Location{}, false
+	}
+
+	// the first range which starts after the IP, the IP may only be in the previous one
+	i := sort.Search(len(d.ranges), func(i int) bool {
+		return bytes.Compare(d.ranges[i].start, ip) > 0
+	})
+	if i == 0 {
+		return Location{}, false
+	}
+
+	r := d.ranges[i-1]
+	if bytes.Compare(ip, r.end) > 0 {
+		return Location{}, false
+	}
+	return r.location, true
+}
+
+// Distance returns the great-circle distance between the locations in kilometers
+func Distance(a, b Location) float64 {
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func parseRange(record []string, countries map[string]string) (ipRange, error) {
+	var country, latitude, longitude string
+	switch len(record) {
+	case 5:
+		country, latitude, longitude = record[2], record[3], record[4]
+	case 8:
+		country, latitude, longitude = record[3], record[6], record[7]
+	default:
+		return ipRange{}, fmt.Errorf("unexpected number of fields %d", len(record))
+	}
+
+	start := net.ParseIP(record[0]).To16()
+	end := net.ParseIP(record[1]).To16()
+	if start == nil || end == nil {
+		return ipRange{}, fmt.Errorf("invalid range %s - %s", record[0], record[1])
+	}
+	if bytes.Compare(start, end) > 0 {
+		return ipRange{}, fmt.Errorf("range %s - %s is reversed", record[0], record[1])
+	}
+
+	lat, err := strconv.ParseFloat(latitude, 64)
+	if err != nil {
+		return ipRange{}, fmt.Errorf("invalid latitude %q", latitude)
+	}
+	lon, err := strconv.ParseFloat(longitude, 64)
+	if err != nil {
+		return ipRange{}, fmt.Errorf("invalid longitude %q", longitude)
+	}
+
+	if shared, ok := countries[country]; ok {
+		country = shared
+	} else {
+		countries[country] = country
+	}
+
+	return ipRange{start: start, end: end, location: Location{Country: country, Latitude: lat, Longitude: lon}}, nil
+}
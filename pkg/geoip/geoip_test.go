@@ -0,0 +1,95 @@
+package geoip
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const cityLite = `ip_start,ip_end,continent,country,stateprov,city,latitude,longitude
+8.8.8.0,8.8.8.255,NA,US,California,Mountain View,37.4056,-122.0775
+1.0.0.0,1.0.0.255,OC,AU,Queensland,South Brisbane,-27.4748,153.017
+2a00:1450::,2a00:1450:ffff:ffff:ffff:ffff:ffff:ffff,EU,IE,Leinster,Dublin,53.3498,-6.26031
+`
+
+func TestLookup(t *testing.T) {
+	db, err := Read(strings.NewReader(cityLite))
+	require.NoError(t, err)
+	assert.Equal(t, 3, db.Len())
+
+	location, ok := db.Lookup(net.ParseIP("8.8.8.8"))
+	assert.True(t, ok)
+	assert.Equal(t, Location{Country: "US", Latitude: 37.4056, Longitude: -122.0775}, location)
+
+	location, ok = db.Lookup(net.ParseIP("1.0.0.0"))
+	assert.True(t, ok, "start of a range is included")
+	assert.Equal(t, "AU", location.Country)
+
+	location, ok = db.Lookup(net.ParseIP("1.0.0.255"))
+	assert.True(t, ok, "end of a range is included")
+	assert.Equal(t, "AU", location.Country)
+
+	location, ok = db.Lookup(net.ParseIP("2a00:1450:4001::1"))
+	assert.True(t, ok)
+	assert.Equal(t, "IE", location.Country)
+
+	_, ok = db.Lookup(net.ParseIP("1.0.1.0"))
+	assert.False(t, ok, "the IP is between ranges")
+
+	_, ok = db.Lookup(net.ParseIP("0.0.0.1"))
+	assert.False(t, ok, "the IP is before all ranges")
+
+	_, ok = db.Lookup(nil)
+	assert.False(t, ok)
+}
+
+func TestReadShortLayout(t *testing.T) {
+	db, err := Read(strings.NewReader("1.0.0.0,1.0.0.255,AU,-27.4748,153.017\n"))
+	require.NoError(t, err)
+
+	location, ok := db.Lookup(net.ParseIP("1.0.0.1"))
+	assert.True(t, ok)
+	assert.Equal(t, Location{Country: "AU", Latitude: -27.4748, Longitude: 153.017}, location)
+}
+
+func TestReadInvalid(t *testing.T) {
+	_, err := Read(strings.NewReader("1.0.0.0,1.0.0.255,AU,-27.4748,153.017\n1.0.1.0,1.0.1.255,AU\n"))
+	assert.Error(t, err, "unexpected number of fields")
+
+	_, err = Read(strings.NewReader("1.0.0.0,1.0.0.255,AU,-27.4748,153.017\n1.0.1.255,1.0.1.0,AU,-27.4748,153.017\n"))
+	assert.Error(t, err, "reversed range")
+
+	_, err = Read(strings.NewReader("1.0.0.0,1.0.0.255,AU,-27.4748,153.017\n1.0.1.0,1.0.1.255,AU,north,153.017\n"))
+	assert.Error(t, err, "invalid latitude")
+}
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "geoip")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "dbip-city-lite.csv")
+	require.NoError(t, ioutil.WriteFile(path, []byte(cityLite), 0600))
+
+	db, err := Open(path)
+	require.NoError(t, err)
+	assert.Equal(t, 3, db.Len())
+
+	_, err = Open(filepath.Join(dir, "missing.csv"))
+	assert.Error(t, err)
+}
+
+func TestDistance(t *testing.T) {
+	london := Location{Latitude: 51.5074, Longitude: -0.1278}
+	newYork := Location{Latitude: 40.7128, Longitude: -74.006}
+
+	assert.InDelta(t, 5570, Distance(london, newYork), 10)
+	assert.InDelta(t, 5570, Distance(newYork, london), 10)
+	assert.Equal(t, 0.0, Distance(london, london))
+}
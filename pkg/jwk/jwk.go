@@ -0,0 +1,158 @@
+// Package jwk implements a subset of JSON Web Key (RFC 7517) which is needed
+// to publish and consume public keys of access tokens signed with ECDSA
+// and to consume RSA keys of external identity providers.
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+const (
+	KeyTypeEC  = "EC"
+	KeyTypeRSA = "RSA"
+	UseSig     = "sig"
+)
+
+// Key is a public JSON Web Key
+type Key struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+}
+
+// Set is a JSON Web Key Set
+type Set struct {
+	Keys []Key `json:"keys"`
+}
+
+// FromECDSA creates a key from ECDSA public key, kid is the RFC 7638 thumbprint of the key
+func FromECDSA(publicKey *ecdsa.PublicKey, alg string) (Key, error) {
+	crv, size, err := curveParams(publicKey.Curve)
+	if err != nil {
+		return Key{}, err
+	}
+
+	key := Key{
+		Kty: KeyTypeEC,
+		Crv: crv,
+		X:   encodeCoordinate(publicKey.X, size),
+		Y:   encodeCoordinate(publicKey.Y, size),
+		Use: UseSig,
+		Alg: alg,
+	}
+	key.Kid = key.Thumbprint()
+	return key, nil
+}
+
+// ECDSA converts the key back to ECDSA public key
+func (k Key) ECDSA() (*ecdsa.PublicKey, error) {
+	if k.Kty != KeyTypeEC {
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	if !curve.IsOnCurve(publicKey.X, publicKey.Y) {
+		return nil, errors.New("point is not on the curve")
+	}
+	return publicKey, nil
+}
+
+// FromRSA creates a key from RSA public key, kid is the RFC 7638 thumbprint of the key
+func FromRSA(publicKey *rsa.PublicKey, alg string) Key {
+	key := Key{
+		Kty: KeyTypeRSA,
+		N:   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+		Use: UseSig,
+		Alg: alg,
+	}
+	key.Kid = key.Thumbprint()
+	return key
+}
+
+// RSA converts the key back to RSA public key
+func (k Key) RSA() (*rsa.PublicKey, error) {
+	if k.Kty != KeyTypeRSA {
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid RSA key")
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+// Thumbprint returns base64url encoded SHA-256 thumbprint of the key as described in RFC 7638
+func (k Key) Thumbprint() string {
+	// members must be in lexicographic order and without whitespaces
+	canonical := fmt.Sprintf(`{"crv":"%s","kty":"%s","x":"%s","y":"%s"}`, k.Crv, k.Kty, k.X, k.Y)
+	if k.Kty == KeyTypeRSA {
+		canonical = fmt.Sprintf(`{"e":"%s","kty":"%s","n":"%s"}`, k.E, k.Kty, k.N)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func curveParams(curve elliptic.Curve) (name string, size int, err error) {
+	params := curve.Params()
+	switch params.Name {
+	case "P-256", "P-384", "P-521":
+		return params.Name, (params.BitSize + 7) / 8, nil
+	}
+	return "", 0, fmt.Errorf("unsupported curve %q", params.Name)
+}
+
+// encodeCoordinate encodes the coordinate padded to the curve size
+func encodeCoordinate(v *big.Int, size int) string {
+	b := v.Bytes()
+	if len(b) < size {
+		padded := make([]byte, size)
+		copy(padded[size-len(b):], b)
+		b = padded
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,167 @@
+// Package oauth contains protocol helpers of OAuth 2.0 authorization server with OpenID Connect:
+// PKCE verification (RFC 7636), scope handling, redirect building and errors in the format of RFC 6749.
+package oauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/url"
+	"strings"
+)
+
+// CodeChallengeMethodS256 is the only supported PKCE method, "plain" does not protect the code if it is intercepted
+const CodeChallengeMethodS256 = "S256"
+
+// Scopes of OpenID Connect
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+	ScopePhone   = "phone"
+)
+
+// Grant and response types
+const (
+	ResponseTypeCode           = "code"
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+)
+
+// Error codes of RFC 6749 and OpenID Connect
+const (
+	ErrorInvalidRequest          = "invalid_request"
+	ErrorInvalidClient           = "invalid_client"
+	ErrorInvalidGrant            = "invalid_grant"
+	ErrorInvalidScope            = "invalid_scope"
+	ErrorInvalidToken            = "invalid_token"
+	ErrorUnauthorizedClient      = "unauthorized_client"
+	ErrorUnsupportedGrantType    = "unsupported_grant_type"
+	ErrorUnsupportedResponseType = "unsupported_response_type"
+	ErrorAccessDenied            = "access_denied"
+	ErrorServerError             = "server_error"
+)
+
+// PKCE code verifier is 43-128 characters long
+const (
+	codeVerifierMinLength = 43
+	codeVerifierMaxLength = 128
+)
+
+// Error is an error response of the authorization and token endpoints
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func NewError(code, description string) *Error {
+	return &Error{Code: code, Description: description}
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// ComputeCodeChallenge returns the S256 code challenge of the verifier
+func ComputeCodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// VerifyCodeChallenge checks that the verifier sent to the token endpoint matches the challenge
+// sent to the authorization endpoint
+func VerifyCodeChallenge(verifier, challenge, method string) bool {
+	if method != CodeChallengeMethodS256 || !IsValidCodeVerifier(verifier) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(ComputeCodeChallenge(verifier)), []byte(challenge)) == 1
+}
+
+// IsValidCodeVerifier checks length and characters of the verifier, both the verifier
+// and the S256 challenge consist of unreserved URI characters
+func IsValidCodeVerifier(verifier string) bool {
+	if len(verifier) < codeVerifierMinLength || len(verifier) > codeVerifierMaxLength {
+		return false
+	}
+	for _, c := range verifier {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '-', c == '.', c == '_', c == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ParseScope splits a space delimited scope, duplicates are removed and the order is kept
+func ParseScope(scope string) []string {
+	fields := strings.Fields(scope)
+	list := make([]string, 0, len(fields))
+	seen := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		if !seen[field] {
+			seen[field] = true
+			list = append(list, field)
+		}
+	}
+	return list
+}
+
+// HasScope checks if the space delimited scope contains the given value
+func HasScope(scope, value string) bool {
+	for _, field := range strings.Fields(scope) {
+		if field == value {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSubset checks if every value of the requested scope is allowed
+func IsSubset(requested, allowed []string) bool {
+	for _, value := range requested {
+		found := false
+		for _, a := range allowed {
+			if a == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// RedirectURL adds the parameters to the query of the redirect uri keeping its own query parameters
+func RedirectURL(redirectURI string, params url.Values) (string, error) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	for key, values := range params {
+		for _, value := range values {
+			if value != "" {
+				query.Add(key, value)
+			}
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// IsValidRedirectURI checks that the uri may be registered for a client: it must be absolute
+// and must not contain a fragment
+func IsValidRedirectURI(redirectURI string) bool {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return false
+	}
+	return u.IsAbs() && u.Host != "" && u.Fragment == ""
+}
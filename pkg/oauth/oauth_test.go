@@ -0,0 +1,73 @@
+package oauth
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	// the example of RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	assert.Equal(t, challenge, ComputeCodeChallenge(verifier))
+	assert.True(t, VerifyCodeChallenge(verifier, challenge, CodeChallengeMethodS256))
+	assert.False(t, VerifyCodeChallenge(verifier, challenge, "plain"))
+	assert.False(t, VerifyCodeChallenge(verifier, verifier, "plain"))
+	assert.False(t, VerifyCodeChallenge(verifier+"a", challenge, CodeChallengeMethodS256))
+	assert.False(t, VerifyCodeChallenge("", ComputeCodeChallenge(""), CodeChallengeMethodS256))
+}
+
+func TestIsValidCodeVerifier(t *testing.T) {
+	assert.True(t, IsValidCodeVerifier(strings.Repeat("a", 43)))
+	assert.True(t, IsValidCodeVerifier(strings.Repeat("Z9-._~", 22)[:128]))
+	assert.False(t, IsValidCodeVerifier(strings.Repeat("a", 42)))
+	assert.False(t, IsValidCodeVerifier(strings.Repeat("a", 129)))
+	assert.False(t, IsValidCodeVerifier(strings.Repeat("a", 42)+"+"))
+}
+
+func TestScope(t *testing.T) {
+	assert.Equal(t, []string{"openid", "email"}, ParseScope(" openid  email openid "))
+	assert.Empty(t, ParseScope(""))
+
+	assert.True(t, HasScope("openid email", "email"))
+	assert.False(t, HasScope("openid emails", "email"))
+
+	assert.True(t, IsSubset([]string{"openid"}, []string{"openid", "email"}))
+	assert.True(t, IsSubset(nil, []string{"openid"}))
+	assert.False(t, IsSubset([]string{"openid", "phone"}, []string{"openid", "email"}))
+}
+
+func TestRedirectURL(t *testing.T) {
+	redirect, err := RedirectURL("https://app.example.com/cb?tenant=1", url.Values{
+		"code":  {"abc"},
+		"state": {""},
+	})
+	require.NoError(t, err)
+
+	u, err := url.Parse(redirect)
+	require.NoError(t, err)
+	assert.Equal(t, "app.example.com", u.Host)
+	assert.Equal(t, "/cb", u.Path)
+	assert.Equal(t, "1", u.Query().Get("tenant"))
+	assert.Equal(t, "abc", u.Query().Get("code"))
+	_, hasState := u.Query()["state"]
+	assert.False(t, hasState, "empty parameters must be omitted")
+}
+
+func TestIsValidRedirectURI(t *testing.T) {
+	assert.True(t, IsValidRedirectURI("https://app.example.com/cb"))
+	assert.True(t, IsValidRedirectURI("http://localhost:8080/cb?x=1"))
+	assert.False(t, IsValidRedirectURI("/cb"))
+	assert.False(t, IsValidRedirectURI("https://app.example.com/cb#fragment"))
+	assert.False(t, IsValidRedirectURI("app.example.com/cb"))
+}
+
+func TestError(t *testing.T) {
+	assert.Equal(t, "invalid_grant: code is expired", NewError(ErrorInvalidGrant, "code is expired").Error())
+	assert.Equal(t, "invalid_client", NewError(ErrorInvalidClient, "").Error())
+}
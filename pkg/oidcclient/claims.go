@@ -0,0 +1,89 @@
+package oidcclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Claims are claims of a verified ID token
+type Claims map[string]interface{}
+
+func (c Claims) Subject() string {
+	return c.String("sub")
+}
+
+func (c Claims) Email() string {
+	return c.String("email")
+}
+
+// EmailVerified shows if the provider has verified the email, some providers send the flag as a string
+func (c Claims) EmailVerified() bool {
+	switch value := c["email_verified"].(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	}
+	return false
+}
+
+// String returns the claim if it is a string
+func (c Claims) String(name string) string {
+	value, _ := c[name].(string)
+	return value
+}
+
+// Time returns the claim of NumericDate type
+func (c Claims) Time(name string) (time.Time, bool) {
+	switch value := c[name].(type) {
+	case float64:
+		return time.Unix(int64(value), 0), true
+	case json.Number:
+		n, err := value.Int64()
+		return time.Unix(n, 0), err == nil
+	}
+	return time.Time{}, false
+}
+
+// Values returns the claim as a list of strings, a single value is returned as a list of one value.
+// Nested claims are addressed by a path of names delimited by dots, e.g. "realm_access.roles",
+// a claim which name contains dots is found by its full name first.
+func (c Claims) Values(path string) []string {
+	value, ok := c[path]
+	if !ok {
+		value = c.lookup(strings.Split(path, "."))
+	}
+
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if v != nil {
+				values = append(values, fmt.Sprint(v))
+			}
+		}
+		return values
+	case []string:
+		return value
+	case map[string]interface{}:
+		return nil
+	default:
+		return []string{fmt.Sprint(value)}
+	}
+}
+
+func (c Claims) lookup(names []string) interface{} {
+	var value interface{} = map[string]interface{}(c)
+	for _, name := range names {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+	return value
+}
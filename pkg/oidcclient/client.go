@@ -0,0 +1,369 @@
+// Package oidcclient is a relying party of an external OpenID Connect provider.
+// It implements the authorization code flow with PKCE and validation of ID tokens:
+//
+//	client := oidcclient.New("https://idp.example.com", "wallet", "secret", "https://wallet/callback", []string{"openid", "email"})
+//	redirect, err := client.AuthCodeURL(state, nonce, oauth.ComputeCodeChallenge(verifier))
+//	...
+//	res, err := client.Exchange(code, verifier)
+//	claims, err := client.VerifyIDToken(res.IDToken, nonce)
+package oidcclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	base "github.com/dgrijalva/jwt-go"
+
+	"github.com/Confialink/wallet-users/pkg/jwk"
+	"github.com/Confialink/wallet-users/pkg/oauth"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+
+	defaultRefreshInterval = time.Hour
+	// minRefreshInterval protects the JWKS endpoint from being flooded by tokens with unknown key id
+	minRefreshInterval = 30 * time.Second
+	// leeway tolerates the clock skew between the provider and the service
+	leeway = time.Minute
+	// maxResponseSize limits responses of the provider
+	maxResponseSize = 1 << 20
+)
+
+var (
+	ErrInvalidIssuer   = errors.New("issuer of the provider does not match")
+	ErrUnknownKey      = errors.New("unknown signing key")
+	ErrInvalidToken    = errors.New("invalid id token")
+	ErrInvalidAudience = errors.New("id token is issued for another client")
+	ErrInvalidNonce    = errors.New("nonce of id token does not match")
+	ErrTokenExpired    = errors.New("id token is expired")
+	ErrMissingIDToken  = errors.New("token response does not contain id token")
+)
+
+// Client is a client registered at the provider
+type Client struct {
+	issuer          string
+	clientID        string
+	clientSecret    string
+	redirectURL     string
+	scopes          []string
+	httpClient      *http.Client
+	refreshInterval time.Duration
+
+	mu          sync.RWMutex
+	metadata    *oauth.ProviderMetadata
+	keys        map[string]interface{}
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+// Option configures the client
+type Option func(c *Client)
+
+// WithHTTPClient sets a client which is used to call the provider
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = client
+	}
+}
+
+// WithRefreshInterval sets how often keys are refetched
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		c.refreshInterval = interval
+	}
+}
+
+// New creates a client, clientSecret may be empty for public clients.
+// The provider metadata is discovered on the first use.
+func New(issuer, clientID, clientSecret, redirectURL string, scopes []string, options ...Option) *Client {
+	c := &Client{
+		issuer:          strings.TrimRight(issuer, "/"),
+		clientID:        clientID,
+		clientSecret:    clientSecret,
+		redirectURL:     redirectURL,
+		scopes:          scopes,
+		httpClient:      &http.Client{Timeout: 10 * time.Second},
+		refreshInterval: defaultRefreshInterval,
+		keys:            make(map[string]interface{}),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Metadata returns the discovery document of the provider
+func (c *Client) Metadata() (*oauth.ProviderMetadata, error) {
+	c.mu.RLock()
+	metadata := c.metadata
+	c.mu.RUnlock()
+	if metadata != nil {
+		return metadata, nil
+	}
+
+	metadata = &oauth.ProviderMetadata{}
+	if err := c.get(c.issuer+discoveryPath, metadata); err != nil {
+		return nil, err
+	}
+	// the issuer must be exactly the one the document is requested for (OpenID Connect Discovery 4.3)
+	if strings.TrimRight(metadata.Issuer, "/") != c.issuer {
+		return nil, ErrInvalidIssuer
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JwksURI == "" {
+		return nil, errors.New("provider metadata is incomplete")
+	}
+
+	c.mu.Lock()
+	c.metadata = metadata
+	c.mu.Unlock()
+	return metadata, nil
+}
+
+// AuthCodeURL returns the url of the authorization endpoint the user must be redirected to
+func (c *Client) AuthCodeURL(state, nonce, codeChallenge string) (string, error) {
+	metadata, err := c.Metadata()
+	if err != nil {
+		return "", err
+	}
+
+	return oauth.RedirectURL(metadata.AuthorizationEndpoint, url.Values{
+		"response_type":         {oauth.ResponseTypeCode},
+		"client_id":             {c.clientID},
+		"redirect_uri":          {c.redirectURL},
+		"scope":                 {strings.Join(c.scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {oauth.CodeChallengeMethodS256},
+	})
+}
+
+// Exchange exchanges the authorization code for tokens, an error of the provider is returned as *oauth.Error
+func (c *Client) Exchange(code, codeVerifier string) (*oauth.TokenResponse, error) {
+	metadata, err := c.Metadata()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {oauth.GrantTypeAuthorizationCode},
+		"code":          {code},
+		"redirect_uri":  {c.redirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	if c.clientSecret == "" {
+		form.Set("client_id", c.clientID)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.clientSecret != "" {
+		// client credentials of the basic scheme are form encoded (RFC 6749 2.3.1)
+		req.SetBasicAuth(url.QueryEscape(c.clientID), url.QueryEscape(c.clientSecret))
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body := io.LimitReader(res.Body, maxResponseSize)
+	if res.StatusCode != http.StatusOK {
+		e := &oauth.Error{}
+		if err := json.NewDecoder(body).Decode(e); err != nil || e.Code == "" {
+			return nil, fmt.Errorf("unexpected token response status %d", res.StatusCode)
+		}
+		return nil, e
+	}
+
+	tokens := &oauth.TokenResponse{}
+	if err := json.NewDecoder(body).Decode(tokens); err != nil {
+		return nil, err
+	}
+	if tokens.IDToken == "" {
+		return nil, ErrMissingIDToken
+	}
+	return tokens, nil
+}
+
+// VerifyIDToken checks signature, issuer, audience, expiration and nonce of the ID token
+func (c *Client) VerifyIDToken(rawToken, nonce string) (Claims, error) {
+	parser := &base.Parser{
+		// time based claims are verified below with the leeway
+		SkipClaimsValidation: true,
+		ValidMethods: []string{
+			base.SigningMethodRS256.Alg(), base.SigningMethodRS384.Alg(), base.SigningMethodRS512.Alg(),
+			base.SigningMethodES256.Alg(), base.SigningMethodES384.Alg(), base.SigningMethodES512.Alg(),
+		},
+	}
+	token, err := parser.Parse(rawToken, c.keyFunc)
+	if err != nil {
+		return nil, err
+	}
+	mapClaims, ok := token.Claims.(base.MapClaims)
+	if !token.Valid || !ok {
+		return nil, ErrInvalidToken
+	}
+
+	claims := Claims(mapClaims)
+	if strings.TrimRight(claims.String("iss"), "/") != c.issuer {
+		return nil, ErrInvalidIssuer
+	}
+	if claims.Subject() == "" {
+		return nil, ErrInvalidToken
+	}
+
+	audience := claims.Values("aud")
+	if !contains(audience, c.clientID) {
+		return nil, ErrInvalidAudience
+	}
+	// the authorized party must be the client if it is present or the token has several audiences
+	if azp := claims.String("azp"); (azp != "" || len(audience) > 1) && azp != c.clientID {
+		return nil, ErrInvalidAudience
+	}
+
+	now := time.Now()
+	exp, ok := claims.Time("exp")
+	if !ok || now.After(exp.Add(leeway)) {
+		return nil, ErrTokenExpired
+	}
+	if iat, ok := claims.Time("iat"); ok && iat.After(now.Add(leeway)) {
+		return nil, ErrInvalidToken
+	}
+	if nbf, ok := claims.Time("nbf"); ok && nbf.After(now.Add(leeway)) {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.String("nonce") != nonce {
+		return nil, ErrInvalidNonce
+	}
+	return claims, nil
+}
+
+func (c *Client) keyFunc(token *base.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key := c.key(kid, c.refreshInterval)
+	if key == nil {
+		// the key might be rotated or the cache is outdated, so the keys are refetched
+		err := c.refresh()
+		// known keys remain usable if the endpoint is temporary unavailable
+		if key = c.key(kid, 0); key == nil {
+			if err != nil {
+				return nil, err
+			}
+			return nil, ErrUnknownKey
+		}
+	}
+
+	// the key must be of the type of the algorithm, otherwise a key could be misused
+	switch key.(type) {
+	case *rsa.PublicKey:
+		if _, ok := token.Method.(*base.SigningMethodRSA); ok {
+			return key, nil
+		}
+	case *ecdsa.PublicKey:
+		if _, ok := token.Method.(*base.SigningMethodECDSA); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+}
+
+// key returns cached key if the cache is not older than maxAge, zero maxAge means any age.
+// Tokens without key id are accepted only if the provider publishes the single key.
+func (c *Client) key(kid string, maxAge time.Duration) interface{} {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if maxAge > 0 && time.Since(c.fetchedAt) > maxAge {
+		return nil
+	}
+	if kid == "" && len(c.keys) == 1 {
+		for _, key := range c.keys {
+			return key
+		}
+	}
+	return c.keys[kid]
+}
+
+func (c *Client) refresh() error {
+	metadata, err := c.Metadata()
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.attemptedAt) < minRefreshInterval {
+		return nil
+	}
+	c.attemptedAt = time.Now()
+
+	var set jwk.Set
+	if err := c.get(metadata.JwksURI, &set); err != nil {
+		return err
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != jwk.UseSig {
+			continue
+		}
+		var publicKey interface{}
+		var err error
+		switch key.Kty {
+		case jwk.KeyTypeRSA:
+			publicKey, err = key.RSA()
+		case jwk.KeyTypeEC:
+			publicKey, err = key.ECDSA()
+		default:
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		keys[key.Kid] = publicKey
+	}
+	c.keys = keys
+	c.fetchedAt = time.Now()
+	return nil
+}
+
+func (c *Client) get(url string, v interface{}) error {
+	res, err := c.httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response status %d of %s", res.StatusCode, url)
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, maxResponseSize)).Decode(v)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
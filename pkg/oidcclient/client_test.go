@@ -0,0 +1,258 @@
+package oidcclient
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	base "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Confialink/wallet-users/pkg/jwk"
+	"github.com/Confialink/wallet-users/pkg/oauth"
+)
+
+const (
+	testClientID     = "wallet"
+	testClientSecret = "s3cr/et"
+	testRedirectURL  = "https://wallet.example.com/callback"
+)
+
+// stubProvider is a minimal OpenID Connect provider which issues the ID token for any code
+type stubProvider struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	jwk    jwk.Key
+	claims base.MapClaims
+	// verifier is the code verifier the token request must contain
+	verifier string
+}
+
+func newStubProvider(t *testing.T) *stubProvider {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	p := &stubProvider{key: privateKey, jwk: jwk.FromRSA(&privateKey.PublicKey, base.SigningMethodRS256.Alg())}
+	mux := http.NewServeMux()
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(oauth.ProviderMetadata{
+			Issuer:                p.URL,
+			AuthorizationEndpoint: p.URL + "/authorize",
+			TokenEndpoint:         p.URL + "/token",
+			JwksURI:               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jwk.Set{Keys: []jwk.Key{p.jwk}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+		if id != testClientID || secret != testClientSecret {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(oauth.NewError(oauth.ErrorInvalidClient, ""))
+			return
+		}
+		if r.PostFormValue("code") != "code" || !oauth.VerifyCodeChallenge(r.PostFormValue("code_verifier"), oauth.ComputeCodeChallenge(p.verifier), oauth.CodeChallengeMethodS256) {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(oauth.NewError(oauth.ErrorInvalidGrant, ""))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(oauth.TokenResponse{AccessToken: "access", TokenType: "Bearer", IDToken: p.sign(t, p.claims)})
+	})
+	p.Server = httptest.NewServer(mux)
+
+	now := time.Now()
+	p.claims = base.MapClaims{
+		"iss":            p.URL,
+		"sub":            "external-subject",
+		"aud":            testClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute).Unix(),
+		"nonce":          "nonce",
+		"email":          "user@example.com",
+		"email_verified": true,
+	}
+	p.verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	return p
+}
+
+func (p *stubProvider) sign(t *testing.T, claims base.MapClaims) string {
+	token := base.NewWithClaims(base.SigningMethodRS256, claims)
+	token.Header["kid"] = p.jwk.Kid
+	signed, err := token.SignedString(p.key)
+	require.NoError(t, err)
+	return signed
+}
+
+func (p *stubProvider) client() *Client {
+	return New(p.URL+"/", testClientID, testClientSecret, testRedirectURL, []string{"openid", "email"})
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	provider := newStubProvider(t)
+	defer provider.Close()
+
+	redirect, err := provider.client().AuthCodeURL("state", "nonce", "challenge")
+	require.NoError(t, err)
+
+	u, err := url.Parse(redirect)
+	require.NoError(t, err)
+	assert.Equal(t, "/authorize", u.Path)
+	assert.Equal(t, "code", u.Query().Get("response_type"))
+	assert.Equal(t, testClientID, u.Query().Get("client_id"))
+	assert.Equal(t, testRedirectURL, u.Query().Get("redirect_uri"))
+	assert.Equal(t, "openid email", u.Query().Get("scope"))
+	assert.Equal(t, "state", u.Query().Get("state"))
+	assert.Equal(t, "nonce", u.Query().Get("nonce"))
+	assert.Equal(t, "challenge", u.Query().Get("code_challenge"))
+	assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+}
+
+func TestMetadataChecksIssuer(t *testing.T) {
+	provider := newStubProvider(t)
+	defer provider.Close()
+
+	client := New(provider.URL+"/other", testClientID, testClientSecret, testRedirectURL, nil)
+	// the document is served by the path of the issuer only
+	_, err := client.Metadata()
+	assert.Error(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the provider pretends to be another issuer
+		_ = json.NewEncoder(w).Encode(oauth.ProviderMetadata{
+			Issuer:                provider.URL,
+			AuthorizationEndpoint: provider.URL + "/authorize",
+			TokenEndpoint:         provider.URL + "/token",
+			JwksURI:               provider.URL + "/jwks",
+		})
+	}))
+	defer server.Close()
+
+	_, err = New(server.URL, testClientID, testClientSecret, testRedirectURL, nil).Metadata()
+	assert.Equal(t, ErrInvalidIssuer, err)
+}
+
+func TestExchangeAndVerify(t *testing.T) {
+	provider := newStubProvider(t)
+	defer provider.Close()
+	client := provider.client()
+
+	res, err := client.Exchange("code", provider.verifier)
+	require.NoError(t, err)
+
+	claims, err := client.VerifyIDToken(res.IDToken, "nonce")
+	require.NoError(t, err)
+	assert.Equal(t, "external-subject", claims.Subject())
+	assert.Equal(t, "user@example.com", claims.Email())
+	assert.True(t, claims.EmailVerified())
+}
+
+func TestExchangeReturnsProviderError(t *testing.T) {
+	provider := newStubProvider(t)
+	defer provider.Close()
+
+	_, err := provider.client().Exchange("code", "wrong-verifier-wrong-verifier-wrong-verifier")
+	require.IsType(t, &oauth.Error{}, err)
+	assert.Equal(t, oauth.ErrorInvalidGrant, err.(*oauth.Error).Code)
+
+	client := New(provider.URL, testClientID, "wrong", testRedirectURL, nil)
+	_, err = client.Exchange("code", provider.verifier)
+	require.IsType(t, &oauth.Error{}, err)
+	assert.Equal(t, oauth.ErrorInvalidClient, err.(*oauth.Error).Code)
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	provider := newStubProvider(t)
+	defer provider.Close()
+	client := provider.client()
+
+	with := func(name string, value interface{}) base.MapClaims {
+		claims := base.MapClaims{}
+		for k, v := range provider.claims {
+			claims[k] = v
+		}
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	_, err := client.VerifyIDToken(provider.sign(t, provider.claims), "other")
+	assert.Equal(t, ErrInvalidNonce, err)
+
+	_, err = client.VerifyIDToken(provider.sign(t, with("iss", "https://evil.example.com")), "nonce")
+	assert.Equal(t, ErrInvalidIssuer, err)
+
+	_, err = client.VerifyIDToken(provider.sign(t, with("aud", "other")), "nonce")
+	assert.Equal(t, ErrInvalidAudience, err)
+
+	_, err = client.VerifyIDToken(provider.sign(t, with("aud", []string{"other", testClientID})), "nonce")
+	assert.Equal(t, ErrInvalidAudience, err, "azp is required for several audiences")
+
+	_, err = client.VerifyIDToken(provider.sign(t, with("azp", testClientID)), "nonce")
+	assert.NoError(t, err)
+
+	_, err = client.VerifyIDToken(provider.sign(t, with("exp", time.Now().Add(-2*time.Minute).Unix())), "nonce")
+	assert.Equal(t, ErrTokenExpired, err)
+
+	_, err = client.VerifyIDToken(provider.sign(t, with("exp", nil)), "nonce")
+	assert.Equal(t, ErrTokenExpired, err)
+
+	_, err = client.VerifyIDToken(provider.sign(t, with("sub", nil)), "nonce")
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func TestVerifyIDTokenRejectsUnexpectedAlgorithms(t *testing.T) {
+	provider := newStubProvider(t)
+	defer provider.Close()
+	client := provider.client()
+
+	unsigned, err := base.NewWithClaims(base.SigningMethodNone, provider.claims).SignedString(base.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	_, err = client.VerifyIDToken(unsigned, "nonce")
+	assert.Error(t, err)
+
+	// the public key must not be accepted as a secret of HMAC
+	hmac := base.NewWithClaims(base.SigningMethodHS256, provider.claims)
+	hmac.Header["kid"] = provider.jwk.Kid
+	signed, err := hmac.SignedString([]byte(provider.jwk.N))
+	require.NoError(t, err)
+	_, err = client.VerifyIDToken(signed, "nonce")
+	assert.Error(t, err)
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	forged := base.NewWithClaims(base.SigningMethodRS256, provider.claims)
+	forged.Header["kid"] = provider.jwk.Kid
+	signed, err = forged.SignedString(other)
+	require.NoError(t, err)
+	_, err = client.VerifyIDToken(signed, "nonce")
+	assert.Error(t, err)
+}
+
+func TestClaimsValues(t *testing.T) {
+	var claims Claims
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"groups": ["admins", "staff"],
+		"role": "admin",
+		"realm_access": {"roles": ["client"]},
+		"https://example.com/roles": ["root"]
+	}`), &claims))
+
+	assert.Equal(t, []string{"admins", "staff"}, claims.Values("groups"))
+	assert.Equal(t, []string{"admin"}, claims.Values("role"))
+	assert.Equal(t, []string{"client"}, claims.Values("realm_access.roles"))
+	assert.Equal(t, []string{"root"}, claims.Values("https://example.com/roles"))
+	assert.Empty(t, claims.Values("realm_access"))
+	assert.Empty(t, claims.Values("missing.path"))
+}
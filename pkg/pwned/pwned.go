@@ -0,0 +1,101 @@
+// Package pwned checks passwords against a local copy of the Pwned Passwords dataset of Have I Been Pwned.
+// The dataset is a directory of range files, one file per the first 5 hex characters of SHA-1 hashes
+// (the k-anonymity prefix), as served by https://api.pwnedpasswords.com/range/{prefix} and saved by
+// the official downloader. Every line of a file is the 35 remaining characters of a hash and the number
+// of breaches it is seen in:
+//
+//	dataset/21BD1(.txt): 0018A45C4D1DEF81644B54AB7F969B88D65:1
+//
+// No network is used, only the file of the prefix of the password is read:
+//
+//	dataset, err := pwned.NewRangeDirectory("/var/lib/pwned-passwords")
+//	count, err := dataset.Count(password)
+package pwned
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// prefixLength is the number of hex characters of a hash which name a range file
+	prefixLength = 5
+	// suffixLength is the number of hex characters of a hash in a line of a range file
+	suffixLength = sha1.Size*2 - prefixLength
+)
+
+// RangeDirectory is a directory of range files
+type RangeDirectory struct {
+	dir string
+}
+
+// NewRangeDirectory returns the dataset in the directory, it fails if the directory does not exist
+func NewRangeDirectory(dir string) (*RangeDirectory, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	return &RangeDirectory{dir: dir}, nil
+}
+
+// IsCompromised tells if the password is seen in at least one breach
+func (d *RangeDirectory) IsCompromised(password string) (bool, error) {
+	count, err := d.Count(password)
+	return count > 0, err
+}
+
+// Count returns the number of breaches the password is seen in.
+// A prefix without a range file is treated as a range without passwords, so partial datasets may be used.
+func (d *RangeDirectory) Count(password string) (uint64, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:prefixLength], hash[prefixLength:]
+
+	file, err := d.open(prefix)
+	if err != nil || file == nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) < suffixLength || !strings.EqualFold(line[:suffixLength], suffix) {
+			continue
+		}
+
+		// lines without a count are treated as seen once
+		count := uint64(1)
+		if colon := strings.IndexByte(line, ':'); colon >= 0 {
+			// padding lines added by the range API have the zero count
+			if count, err = strconv.ParseUint(strings.TrimSpace(line[colon+1:]), 10, 64); err != nil {
+				return 0, fmt.Errorf("invalid line in range file %s: %s", prefix, err)
+			}
+		}
+		return count, nil
+	}
+	return 0, scanner.Err()
+}
+
+// open opens the range file of the prefix, it returns nil if there is no such file
+func (d *RangeDirectory) open(prefix string) (*os.File, error) {
+	for _, name := range []string{prefix, prefix + ".txt", strings.ToLower(prefix), strings.ToLower(prefix) + ".txt"} {
+		file, err := os.Open(filepath.Join(d.dir, name))
+		if err == nil {
+			return file, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
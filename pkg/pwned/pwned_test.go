@@ -0,0 +1,79 @@
+package pwned
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+func newDataset(t *testing.T, files map[string]string) (*RangeDirectory, func()) {
+	dir, err := ioutil.TempDir("", "pwned")
+	require.NoError(t, err)
+
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+
+	dataset, err := NewRangeDirectory(dir)
+	require.NoError(t, err)
+	return dataset, func() { os.RemoveAll(dir) }
+}
+
+func TestCount(t *testing.T) {
+	dataset, cleanup := newDataset(t, map[string]string{
+		"5BAA6": "003D68EB55068C33ACE09247EE4C639306B:3\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\r\n",
+	})
+	defer cleanup()
+
+	count, err := dataset.Count("password")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(9545824), count)
+
+	compromised, err := dataset.IsCompromised("password")
+	assert.NoError(t, err)
+	assert.True(t, compromised)
+
+	compromised, err = dataset.IsCompromised("Password")
+	assert.NoError(t, err)
+	assert.False(t, compromised, "a prefix without a range file has no passwords")
+}
+
+func TestCountFileNames(t *testing.T) {
+	for _, name := range []string{"5BAA6.txt", "5baa6", "5baa6.txt"} {
+		dataset, cleanup := newDataset(t, map[string]string{name: "1e4c9b93f3f0682250b6cf8331b7ee68fd8:2\n"})
+		count, err := dataset.Count("password")
+		cleanup()
+		assert.NoError(t, err, name)
+		assert.Equal(t, uint64(2), count, name)
+	}
+}
+
+func TestCountPaddingAndInvalidLines(t *testing.T) {
+	dataset, cleanup := newDataset(t, map[string]string{"5BAA6": "1E4C9B93F3F0682250B6CF8331B7EE68FD8:0\n"})
+	compromised, err := dataset.IsCompromised("password")
+	cleanup()
+	assert.NoError(t, err)
+	assert.False(t, compromised, "padding lines are not breached passwords")
+
+	dataset, cleanup = newDataset(t, map[string]string{"5BAA6": "1E4C9B93F3F0682250B6CF8331B7EE68FD8:many\n"})
+	defer cleanup()
+	_, err = dataset.Count("password")
+	assert.Error(t, err)
+}
+
+func TestNewRangeDirectory(t *testing.T) {
+	_, err := NewRangeDirectory(filepath.Join(os.TempDir(), "pwned-does-not-exist"))
+	assert.Error(t, err)
+
+	file, err := ioutil.TempFile("", "pwned")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	file.Close()
+	_, err = NewRangeDirectory(file.Name())
+	assert.Error(t, err)
+}
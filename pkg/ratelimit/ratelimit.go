@@ -0,0 +1,116 @@
+// Package ratelimit throttles requests by token buckets.
+// A bucket holds up to Limit.Requests tokens, a request takes one token and tokens are refilled evenly,
+// so Requests requests are allowed in a burst and Requests per Per on average:
+//
+//	limit, err := ratelimit.ParseLimit("5/1m")
+//	allowed, retryAfter, err := store.Take("signin:ip:203.0.113.7", limit)
+//
+// Buckets are kept by a Store: MemoryStore keeps them in the process, a shared Store lets
+// several instances of a service use the same buckets.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is the number of requests allowed per the period
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// Enabled tells if requests are throttled by the limit, the zero limit allows everything
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Per > 0
+}
+
+// String returns the limit in the format of ParseLimit
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "0"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Per)
+}
+
+// interval returns the time a single token is refilled in
+func (l Limit) interval() time.Duration {
+	return l.Per / time.Duration(l.Requests)
+}
+
+// ParseLimit parses limits like "5/1m" or "100/24h", "0" and "" mean no limit
+func ParseLimit(value string) (Limit, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "0" {
+		return Limit{}, nil
+	}
+
+	parts := strings.Split(value, "/")
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, expected requests/period, e.g. 5/1m", value)
+	}
+	requests, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || requests < 0 {
+		return Limit{}, fmt.Errorf("invalid number of requests in rate limit %q", value)
+	}
+	per, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil || per <= 0 {
+		return Limit{}, fmt.Errorf("invalid period in rate limit %q", value)
+	}
+	return Limit{Requests: requests, Per: per}, nil
+}
+
+// Bucket is the state of a token bucket, the zero bucket is full
+type Bucket struct {
+	// Taken is the number of tokens taken from the bucket and not refilled yet
+	Taken float64
+	// UpdatedAt is the time tokens were taken last
+	UpdatedAt time.Time
+}
+
+// Take takes a token from the bucket at the given time. If the bucket is empty the request is not allowed
+// and the time until the next token is returned.
+func (b *Bucket) Take(limit Limit, now time.Time) (bool, time.Duration) {
+	if !limit.Enabled() {
+		return true, 0
+	}
+
+	interval := limit.interval()
+	// the clock of a shared store may lag behind, such buckets are not refilled
+	if now.After(b.UpdatedAt) {
+		if !b.UpdatedAt.IsZero() {
+			refilled := float64(now.Sub(b.UpdatedAt)) / float64(interval)
+			b.Taken = math.Max(0, b.Taken-refilled)
+		}
+		b.UpdatedAt = now
+	}
+
+	if b.Taken+1 > float64(limit.Requests) {
+		wait := time.Duration((b.Taken + 1 - float64(limit.Requests)) * float64(interval))
+		return false, wait
+	}
+	b.Taken++
+	return true, 0
+}
+
+// FullAt returns the time the bucket is refilled at
+func (b *Bucket) FullAt(limit Limit) time.Time {
+	if !limit.Enabled() {
+		return b.UpdatedAt
+	}
+	return b.UpdatedAt.Add(time.Duration(b.Taken * float64(limit.interval())))
+}
+
+// Full tells if the bucket is refilled at the given time, such buckets may be forgotten
+func (b *Bucket) Full(limit Limit, now time.Time) bool {
+	return !now.Before(b.FullAt(limit))
+}
+
+// Store keeps buckets by keys
+type Store interface {
+	// Take takes a token from the bucket of the key, see Bucket.Take
+	Take(key string, limit Limit) (allowed bool, retryAfter time.Duration, err error)
+}
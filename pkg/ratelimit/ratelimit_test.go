@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ParseLimit("5/1m")
+	assert.NoError(t, err)
+	assert.Equal(t, Limit{Requests: 5, Per: time.Minute}, limit)
+	assert.Equal(t, "5/1m0s", limit.String())
+
+	for _, value := range []string{"", "0", " 0 "} {
+		limit, err = ParseLimit(value)
+		assert.NoError(t, err)
+		assert.False(t, limit.Enabled(), value)
+	}
+
+	for _, value := range []string{"5", "five/1m", "5/minute", "5/0s", "-1/1m", "5/1m/1h"} {
+		_, err = ParseLimit(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestBucketTake(t *testing.T) {
+	limit := Limit{Requests: 3, Per: 30 * time.Second}
+	now := time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC)
+	bucket := &Bucket{}
+
+	for i := 0; i < 3; i++ {
+		allowed, _ := bucket.Take(limit, now)
+		assert.True(t, allowed, "burst of %d requests is allowed", limit.Requests)
+	}
+	allowed, retryAfter := bucket.Take(limit, now)
+	assert.False(t, allowed)
+	assert.Equal(t, 10*time.Second, retryAfter)
+
+	allowed, retryAfter = bucket.Take(limit, now.Add(4*time.Second))
+	assert.False(t, allowed)
+	assert.Equal(t, 6*time.Second, retryAfter)
+
+	allowed, _ = bucket.Take(limit, now.Add(10*time.Second))
+	assert.True(t, allowed, "a token is refilled every 10 seconds")
+	allowed, _ = bucket.Take(limit, now.Add(10*time.Second))
+	assert.False(t, allowed)
+
+	assert.Equal(t, now.Add(40*time.Second), bucket.FullAt(limit))
+	assert.False(t, bucket.Full(limit, now.Add(39*time.Second)))
+	assert.True(t, bucket.Full(limit, now.Add(40*time.Second)))
+	allowed, _ = bucket.Take(limit, now.Add(time.Hour))
+	assert.True(t, allowed)
+	assert.Equal(t, float64(1), bucket.Taken, "tokens are not refilled above the limit")
+}
+
+func TestBucketTakeWithoutLimit(t *testing.T) {
+	bucket := &Bucket{}
+	for i := 0; i < 100; i++ {
+		allowed, _ := bucket.Take(Limit{}, time.Now())
+		assert.True(t, allowed)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limit := Limit{Requests: 1, Per: time.Minute}
+
+	allowed, _, err := store.Take("signin:ip:203.0.113.7", limit)
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+	allowed, retryAfter, err := store.Take("signin:ip:203.0.113.7", limit)
+	assert.NoError(t, err)
+	assert.False(t, allowed)
+	assert.Equal(t, time.Minute, retryAfter)
+
+	allowed, _, _ = store.Take("signin:ip:203.0.113.8", limit)
+	assert.True(t, allowed, "buckets are separated by keys")
+
+	now = now.Add(time.Minute)
+	for i := 0; i < sweepEvery; i++ {
+		store.Take(fmt.Sprintf("key-%d", i), Limit{})
+	}
+	_, ok := store.buckets["signin:ip:203.0.113.7"]
+	assert.False(t, ok, "refilled buckets are removed")
+}
@@ -0,0 +1,113 @@
+package tokenverifier
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// RevocationsSubject is the message broker subject revocations of access tokens are published to.
+// Every instance of a consumer service must receive all messages, so a queue group must not be used.
+// Messages published during the last MaxRevocationTTL are replayed on start by Subscribe.
+const RevocationsSubject = "users.tokens.revoked"
+
+// MaxRevocationTTL is the upper bound of revocation lifetime, an access token can not live longer
+const MaxRevocationTTL = 24 * time.Hour
+
+// Revocation is a denylist entry.
+// If SessionID is set, all access tokens issued within the session are revoked.
+// If UserUID is set, all access tokens of the user issued before IssuedBefore or within that second are revoked.
+// IssuedBefore is a unix time in seconds like the iat claim, the issuer does not issue tokens to the user
+// within the second of the revocation, so a token issued right after it stays valid.
+type Revocation struct {
+	SessionID    string `json:"sessionId,omitempty"`
+	UserUID      string `json:"uid,omitempty"`
+	IssuedBefore int64  `json:"issuedBefore,omitempty"`
+	// ExpiresAt is the unix time after which all revoked tokens are expired, so the entry can be forgotten
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// Denylist keeps revocations in memory until revoked tokens expire
+type Denylist struct {
+	mu       sync.RWMutex
+	sessions map[string]int64
+	users    map[string]Revocation
+	now      func() time.Time
+}
+
+func NewDenylist() *Denylist {
+	return &Denylist{
+		sessions: make(map[string]int64),
+		users:    make(map[string]Revocation),
+		now:      time.Now,
+	}
+}
+
+// Add puts the revocation into the list
+func (d *Denylist) Add(revocation Revocation) {
+	now := d.now().Unix()
+	if revocation.ExpiresAt <= now || revocation.ExpiresAt > now+int64(MaxRevocationTTL/time.Second) {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.purge(now)
+	if revocation.SessionID != "" {
+		if d.sessions[revocation.SessionID] < revocation.ExpiresAt {
+			d.sessions[revocation.SessionID] = revocation.ExpiresAt
+		}
+	}
+	if revocation.UserUID != "" {
+		current, ok := d.users[revocation.UserUID]
+		if !ok || current.IssuedBefore < revocation.IssuedBefore {
+			current.IssuedBefore = revocation.IssuedBefore
+		}
+		if current.ExpiresAt < revocation.ExpiresAt {
+			current.ExpiresAt = revocation.ExpiresAt
+		}
+		d.users[revocation.UserUID] = current
+	}
+}
+
+// Handle accepts a revocation message received from the message broker
+func (d *Denylist) Handle(jsonData string) {
+	var revocation Revocation
+	if err := json.Unmarshal([]byte(jsonData), &revocation); err != nil {
+		return
+	}
+	d.Add(revocation)
+}
+
+// IsRevoked checks whether the token with given claims is in the list
+func (d *Denylist) IsRevoked(claims *Claims) bool {
+	now := d.now().Unix()
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if claims.SessionID != "" {
+		if expiresAt, ok := d.sessions[claims.SessionID]; ok && expiresAt > now {
+			return true
+		}
+	}
+	if revocation, ok := d.users[claims.UID]; ok && revocation.ExpiresAt > now {
+		return claims.IssuedAt <= revocation.IssuedBefore
+	}
+	return false
+}
+
+// purge removes expired entries, the lock must be held by the caller
+func (d *Denylist) purge(now int64) {
+	for id, expiresAt := range d.sessions {
+		if expiresAt <= now {
+			delete(d.sessions, id)
+		}
+	}
+	for uid, revocation := range d.users {
+		if revocation.ExpiresAt <= now {
+			delete(d.users, uid)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package tokenverifier
+
+import (
+	"github.com/nats-io/stan.go"
+)
+
+// Subscribe feeds the denylist with revocations published to RevocationsSubject.
+// NATS Streaming keeps published messages, so revocations of the last MaxRevocationTTL are replayed
+// and the denylist is complete right after the start. Every instance gets its own subscription,
+// neither a durable name nor a queue group is used.
+func Subscribe(conn stan.Conn, denylist *Denylist) (stan.Subscription, error) {
+	return conn.Subscribe(RevocationsSubject, func(msg *stan.Msg) {
+		denylist.Handle(string(msg.Data))
+	}, stan.StartAtTimeDelta(MaxRevocationTTL))
+}
@@ -0,0 +1,272 @@
+// Package tokenverifier validates access tokens issued by the users service locally,
+// without a call to the users service on every request.
+//
+// Only tokens signed with ECDSA can be verified, the users service must be configured with an ES* signing method.
+// HMAC secrets are not published, so tokens signed with HS* methods are rejected with ErrUnsupportedSigningMethod
+// and must be validated by the users service.
+//
+// Public keys are fetched from the JWKS endpoint of the users service and cached.
+// Revoked tokens are rejected by the Denylist which is fed with messages published to RevocationsSubject:
+//
+//	denylist := tokenverifier.NewDenylist()
+//	subscription, err := tokenverifier.Subscribe(stanConn, denylist)
+//	verifier := tokenverifier.New("http://users/users/public/v1/.well-known/jwks.json", denylist)
+//	claims, err := verifier.Verify(accessToken)
+package tokenverifier
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	base "github.com/dgrijalva/jwt-go"
+
+	"github.com/Confialink/wallet-users/pkg/jwk"
+)
+
+const (
+	accessTokenSubject = "access"
+	// walletScope must be granted to tokens issued to OAuth clients in order to call the wallet API
+	walletScope = "wallet"
+
+	defaultRefreshInterval = 10 * time.Minute
+	// minRefreshInterval protects the JWKS endpoint from being flooded by tokens with unknown key id
+	minRefreshInterval = 30 * time.Second
+)
+
+var (
+	ErrUnknownKey     = errors.New("unknown signing key")
+	ErrInvalidToken   = errors.New("invalid token")
+	ErrInvalidSubject = errors.New("invalid token subject")
+	ErrRevoked        = errors.New("token is revoked")
+	// ErrUnsupportedSigningMethod is returned for tokens which are not signed with ECDSA
+	ErrUnsupportedSigningMethod = errors.New("token is not signed with ECDSA, the users service must use an ES* signing method")
+	// ErrClientNotAllowed is returned for tokens of OAuth clients which are not allowed to call the wallet API
+	ErrClientNotAllowed = errors.New("token is not issued for wallet scope")
+)
+
+// Claims contains user data of a verified access token
+type Claims struct {
+	UID       string
+	RoleName  string
+	ParentID  string
+	Username  string
+	FirstName string
+	LastName  string
+	// SessionID is the id of the sign in which the token was issued within
+	SessionID string
+	// ClientID and Scope are set if the token is issued to an OAuth client
+	ClientID  string
+	Scope     string
+	IssuedAt  int64
+	ExpiresAt int64
+}
+
+// Verifier validates access tokens by published public keys
+type Verifier struct {
+	jwksURL         string
+	denylist        *Denylist
+	httpClient      *http.Client
+	refreshInterval time.Duration
+
+	mu          sync.RWMutex
+	keys        map[string]*ecdsa.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+// Option configures the verifier
+type Option func(v *Verifier)
+
+// WithHTTPClient sets a client which is used to fetch keys
+func WithHTTPClient(client *http.Client) Option {
+	return func(v *Verifier) {
+		v.httpClient = client
+	}
+}
+
+// WithRefreshInterval sets how often keys are refetched
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(v *Verifier) {
+		v.refreshInterval = interval
+	}
+}
+
+// New creates a verifier, denylist may be nil if revocations are not tracked
+func New(jwksURL string, denylist *Denylist, options ...Option) *Verifier {
+	v := &Verifier{
+		jwksURL:         jwksURL,
+		denylist:        denylist,
+		httpClient:      &http.Client{Timeout: 10 * time.Second},
+		refreshInterval: defaultRefreshInterval,
+		keys:            make(map[string]*ecdsa.PublicKey),
+	}
+	for _, option := range options {
+		option(v)
+	}
+	return v
+}
+
+// Verify checks signature, expiration, subject and revocation of the access token
+func (v *Verifier) Verify(signedToken string) (*Claims, error) {
+	token, err := base.Parse(signedToken, v.keyFunc)
+	if err != nil {
+		// errors of the key function are wrapped by the library
+		if validationErr, ok := err.(*base.ValidationError); ok && validationErr.Errors&base.ValidationErrorUnverifiable != 0 && validationErr.Inner != nil {
+			return nil, validationErr.Inner
+		}
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	mapClaims, ok := token.Claims.(base.MapClaims)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	// the claim is optional for the library, but it is mandatory for access tokens
+	if !mapClaims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, ErrInvalidToken
+	}
+	if mapClaims["sub"] != accessTokenSubject {
+		return nil, ErrInvalidSubject
+	}
+
+	claims := newClaims(mapClaims)
+	if claims.ClientID != "" && !hasScope(claims.Scope, walletScope) {
+		return nil, ErrClientNotAllowed
+	}
+	if v.denylist != nil && v.denylist.IsRevoked(claims) {
+		return nil, ErrRevoked
+	}
+	return claims, nil
+}
+
+func (v *Verifier) keyFunc(token *base.Token) (interface{}, error) {
+	if _, ok := token.Method.(*base.SigningMethodECDSA); !ok {
+		return nil, ErrUnsupportedSigningMethod
+	}
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, ErrUnknownKey
+	}
+
+	if key := v.key(kid, v.refreshInterval); key != nil {
+		return key, nil
+	}
+	// the key might be rotated or the cache is outdated, so the keys are refetched
+	err := v.refresh()
+	// known keys remain usable if the endpoint is temporary unavailable
+	if key := v.key(kid, 0); key != nil {
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, ErrUnknownKey
+}
+
+// key returns cached key if the cache is not older than maxAge, zero maxAge means any age
+func (v *Verifier) key(kid string, maxAge time.Duration) *ecdsa.PublicKey {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if maxAge > 0 && time.Since(v.fetchedAt) > maxAge {
+		return nil
+	}
+	return v.keys[kid]
+}
+
+func (v *Verifier) refresh() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if time.Since(v.attemptedAt) < minRefreshInterval {
+		return nil
+	}
+	v.attemptedAt = time.Now()
+
+	keys, err := v.fetch()
+	if err != nil {
+		return err
+	}
+	v.keys = keys
+	v.fetchedAt = time.Now()
+	return nil
+}
+
+func (v *Verifier) fetch() (map[string]*ecdsa.PublicKey, error) {
+	res, err := v.httpClient.Get(v.jwksURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected JWKS response status %d", res.StatusCode)
+	}
+
+	var set jwk.Set
+	if err := json.NewDecoder(res.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*ecdsa.PublicKey, len(set.Keys))
+	for _, key := range set.Keys {
+		if key.Kty != jwk.KeyTypeEC || key.Kid == "" {
+			continue
+		}
+		publicKey, err := key.ECDSA()
+		if err != nil {
+			continue
+		}
+		keys[key.Kid] = publicKey
+	}
+	return keys, nil
+}
+
+func newClaims(mapClaims base.MapClaims) *Claims {
+	str := func(name string) string {
+		value, _ := mapClaims[name].(string)
+		return value
+	}
+	num := func(name string) int64 {
+		switch value := mapClaims[name].(type) {
+		case float64:
+			return int64(value)
+		case json.Number:
+			n, _ := value.Int64()
+			return n
+		}
+		return 0
+	}
+
+	return &Claims{
+		UID:       str("uid"),
+		RoleName:  str("roleName"),
+		ParentID:  str("parentId"),
+		Username:  str("username"),
+		FirstName: str("firstName"),
+		LastName:  str("lastName"),
+		SessionID: str("sid"),
+		ClientID:  str("client_id"),
+		Scope:     str("scope"),
+		IssuedAt:  num("iat"),
+		ExpiresAt: num("exp"),
+	}
+}
+
+func hasScope(scope, value string) bool {
+	for _, field := range strings.Fields(scope) {
+		if field == value {
+			return true
+		}
+	}
+	return false
+}
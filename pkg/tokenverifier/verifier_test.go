@@ -0,0 +1,172 @@
+package tokenverifier
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	base "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Confialink/wallet-users/pkg/jwk"
+)
+
+type testIssuer struct {
+	key *ecdsa.PrivateKey
+	jwk jwk.Key
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	require.NoError(t, err)
+	key, err := jwk.FromECDSA(&privateKey.PublicKey, base.SigningMethodES512.Alg())
+	require.NoError(t, err)
+	return &testIssuer{key: privateKey, jwk: key}
+}
+
+func (i *testIssuer) sign(t *testing.T, claims base.MapClaims) string {
+	token := base.NewWithClaims(base.SigningMethodES512, claims)
+	token.Header["kid"] = i.jwk.Kid
+	signed, err := token.SignedString(i.key)
+	require.NoError(t, err)
+	return signed
+}
+
+func (i *testIssuer) server() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jwk.Set{Keys: []jwk.Key{i.jwk}})
+	}))
+}
+
+func accessClaims() base.MapClaims {
+	now := time.Now()
+	return base.MapClaims{
+		"sub":      "access",
+		"uid":      "user-uid",
+		"roleName": "client",
+		"sid":      "session-id",
+		"iat":      now.Unix(),
+		"exp":      now.Add(time.Minute).Unix(),
+	}
+}
+
+func TestVerify(t *testing.T) {
+	issuer := newTestIssuer(t)
+	server := issuer.server()
+	defer server.Close()
+
+	verifier := New(server.URL, NewDenylist())
+
+	claims, err := verifier.Verify(issuer.sign(t, accessClaims()))
+	require.NoError(t, err)
+	assert.Equal(t, "user-uid", claims.UID)
+	assert.Equal(t, "client", claims.RoleName)
+	assert.Equal(t, "session-id", claims.SessionID)
+}
+
+func TestVerifyRejectsInvalidTokens(t *testing.T) {
+	issuer := newTestIssuer(t)
+	server := issuer.server()
+	defer server.Close()
+
+	verifier := New(server.URL, nil)
+
+	refresh := accessClaims()
+	refresh["sub"] = "refresh"
+	_, err := verifier.Verify(issuer.sign(t, refresh))
+	assert.Equal(t, ErrInvalidSubject, err)
+
+	expired := accessClaims()
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+	_, err = verifier.Verify(issuer.sign(t, expired))
+	assert.Error(t, err)
+
+	_, err = verifier.Verify(newTestIssuer(t).sign(t, accessClaims()))
+	assert.Error(t, err)
+
+	hmac, err := base.NewWithClaims(base.SigningMethodHS512, accessClaims()).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	_, err = verifier.Verify(hmac)
+	assert.Equal(t, ErrUnsupportedSigningMethod, err)
+}
+
+func TestVerifyChecksScopeOfClientTokens(t *testing.T) {
+	issuer := newTestIssuer(t)
+	server := issuer.server()
+	defer server.Close()
+
+	verifier := New(server.URL, nil)
+
+	client := accessClaims()
+	client["client_id"] = "client-id"
+	client["scope"] = "openid profile"
+	_, err := verifier.Verify(issuer.sign(t, client))
+	assert.Equal(t, ErrClientNotAllowed, err)
+
+	client["scope"] = "openid wallet"
+	claims, err := verifier.Verify(issuer.sign(t, client))
+	require.NoError(t, err)
+	assert.Equal(t, "client-id", claims.ClientID)
+	assert.Equal(t, "openid wallet", claims.Scope)
+}
+
+func TestVerifyRejectsRevokedTokens(t *testing.T) {
+	issuer := newTestIssuer(t)
+	server := issuer.server()
+	defer server.Close()
+
+	denylist := NewDenylist()
+	verifier := New(server.URL, denylist)
+	expiresAt := time.Now().Add(time.Hour).Unix()
+
+	denylist.Handle(`{"sessionId":"session-id","expiresAt":` + jsonInt(expiresAt) + `}`)
+	_, err := verifier.Verify(issuer.sign(t, accessClaims()))
+	assert.Equal(t, ErrRevoked, err)
+
+	other := accessClaims()
+	other["sid"] = "other-session-id"
+	_, err = verifier.Verify(issuer.sign(t, other))
+	assert.NoError(t, err)
+
+	denylist.Add(Revocation{UserUID: "user-uid", IssuedBefore: time.Now().Add(-time.Minute).Unix(), ExpiresAt: expiresAt})
+	other["iat"] = time.Now().Add(-2 * time.Minute).Unix()
+	_, err = verifier.Verify(issuer.sign(t, other))
+	assert.Equal(t, ErrRevoked, err)
+
+	other["iat"] = time.Now().Unix()
+	_, err = verifier.Verify(issuer.sign(t, other))
+	assert.NoError(t, err)
+}
+
+func TestVerifyRejectsTokenIssuedWithinRevocationSecond(t *testing.T) {
+	issuer := newTestIssuer(t)
+	server := issuer.server()
+	defer server.Close()
+
+	denylist := NewDenylist()
+	verifier := New(server.URL, denylist)
+	now := time.Now()
+	revokedAt := now.Unix() - 1
+	denylist.Add(Revocation{UserUID: "user-uid", IssuedBefore: revokedAt, ExpiresAt: now.Add(time.Hour).Unix()})
+
+	claims := accessClaims()
+	claims["sid"] = "new-session-id"
+	claims["iat"] = revokedAt
+	_, err := verifier.Verify(issuer.sign(t, claims))
+	assert.Equal(t, ErrRevoked, err)
+
+	claims["iat"] = revokedAt + 1
+	_, err = verifier.Verify(issuer.sign(t, claims))
+	assert.NoError(t, err)
+}
+
+func jsonInt(v int64) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,165 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+)
+
+// COSE algorithm identifiers (RFC 8152) which are accepted for credentials
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgES384 int64 = -35
+	AlgES512 int64 = -36
+	AlgRS256 int64 = -257
+)
+
+// SupportedAlgorithms are offered to authenticators in the order of preference
+var SupportedAlgorithms = []int64{AlgES256, AlgEdDSA, AlgRS256}
+
+// COSE key parameters
+const (
+	coseKeyType   int64 = 1
+	coseAlgorithm int64 = 3
+
+	coseKeyTypeOKP int64 = 1
+	coseKeyTypeEC2 int64 = 2
+	coseKeyTypeRSA int64 = 3
+
+	coseCurve   int64 = -1
+	coseX       int64 = -2
+	coseY       int64 = -3
+	coseRSAN    int64 = -1
+	coseRSAE    int64 = -2
+	coseP256    int64 = 1
+	coseP384    int64 = 2
+	coseP521    int64 = 3
+	coseEd25519 int64 = 6
+)
+
+// PublicKey is a credential public key parsed from the COSE_Key format
+type PublicKey struct {
+	Algorithm int64
+	key       crypto.PublicKey
+}
+
+// ParsePublicKey parses a CBOR encoded COSE_Key
+func ParsePublicKey(data []byte) (*PublicKey, error) {
+	decoded, rest, err := decodeCbor(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("cose: unexpected data after the key")
+	}
+	return publicKeyFromMap(decoded)
+}
+
+func publicKeyFromMap(decoded interface{}) (*PublicKey, error) {
+	params, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("cose: key must be a map")
+	}
+
+	kty, _ := params[coseKeyType].(int64)
+	alg, _ := params[coseAlgorithm].(int64)
+
+	switch kty {
+	case coseKeyTypeEC2:
+		curve, err := ecdsaCurve(alg, params[coseCurve])
+		if err != nil {
+			return nil, err
+		}
+		x, _ := params[coseX].([]byte)
+		y, _ := params[coseY].([]byte)
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, errors.New("cose: invalid EC2 coordinates")
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("cose: point is not on the curve")
+		}
+		return &PublicKey{Algorithm: alg, key: key}, nil
+	case coseKeyTypeOKP:
+		crv, _ := params[coseCurve].(int64)
+		x, _ := params[coseX].([]byte)
+		if alg != AlgEdDSA || crv != coseEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedAlgorithm
+		}
+		return &PublicKey{Algorithm: alg, key: ed25519.PublicKey(x)}, nil
+	case coseKeyTypeRSA:
+		n, _ := params[coseRSAN].([]byte)
+		e, _ := params[coseRSAE].([]byte)
+		if alg != AlgRS256 || len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, ErrUnsupportedAlgorithm
+		}
+		exponent := int(new(big.Int).SetBytes(e).Int64())
+		return &PublicKey{Algorithm: alg, key: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}}, nil
+	}
+
+	return nil, ErrUnsupportedAlgorithm
+}
+
+func ecdsaCurve(alg int64, crv interface{}) (elliptic.Curve, error) {
+	switch {
+	case alg == AlgES256 && crv == coseP256:
+		return elliptic.P256(), nil
+	case alg == AlgES384 && crv == coseP384:
+		return elliptic.P384(), nil
+	case alg == AlgES512 && crv == coseP521:
+		return elliptic.P521(), nil
+	}
+	return nil, ErrUnsupportedAlgorithm
+}
+
+// Verify checks the signature of the data made by the private key of the credential
+func (k *PublicKey) Verify(data, signature []byte) error {
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		var sig struct {
+			R, S *big.Int
+		}
+		rest, err := asn1.Unmarshal(signature, &sig)
+		if err != nil || len(rest) != 0 || sig.R == nil || sig.S == nil {
+			return ErrInvalidSignature
+		}
+		if !ecdsa.Verify(key, ecdsaDigest(k.Algorithm, data), sig.R, sig.S) {
+			return ErrInvalidSignature
+		}
+		return nil
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, data, signature) {
+			return ErrInvalidSignature
+		}
+		return nil
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+	return ErrUnsupportedAlgorithm
+}
+
+func ecdsaDigest(alg int64, data []byte) []byte {
+	switch alg {
+	case AlgES384:
+		digest := sha512.Sum384(data)
+		return digest[:]
+	case AlgES512:
+		digest := sha512.Sum512(data)
+		return digest[:]
+	}
+	digest := sha256.Sum256(data)
+	return digest[:]
+}
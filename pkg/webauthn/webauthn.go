@@ -0,0 +1,338 @@
+// Package webauthn implements the relying party side of WebAuthn (FIDO2) registration and authentication ceremonies.
+// Attestation statements are not verified: the relying party requests "none" attestation conveyance,
+// so a credential is trusted by the fact it was registered by an authenticated user.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+const (
+	// ChallengeSize is a number of random bytes in a challenge
+	ChallengeSize = 32
+
+	ceremonyCreate = "webauthn.create"
+	ceremonyGet    = "webauthn.get"
+
+	credentialType = "public-key"
+
+	flagUserPresent            = 0x01
+	flagUserVerified           = 0x04
+	flagAttestedCredentialData = 0x40
+	flagExtensionData          = 0x80
+
+	// authenticator data contains rpIdHash (32), flags (1) and signCount (4)
+	authenticatorDataMinLength = 37
+)
+
+// User verification requirements
+const (
+	UserVerificationRequired  = "required"
+	UserVerificationPreferred = "preferred"
+)
+
+var (
+	ErrMalformedResponse    = errors.New("webauthn: malformed response")
+	ErrCeremonyMismatch     = errors.New("webauthn: unexpected ceremony type")
+	ErrChallengeMismatch    = errors.New("webauthn: challenge does not match")
+	ErrOriginMismatch       = errors.New("webauthn: origin is not allowed")
+	ErrRPIDMismatch         = errors.New("webauthn: relying party id does not match")
+	ErrUserNotPresent       = errors.New("webauthn: user presence is not confirmed")
+	ErrUserNotVerified      = errors.New("webauthn: user is not verified")
+	ErrUnsupportedAlgorithm = errors.New("webauthn: unsupported credential algorithm")
+	ErrInvalidSignature     = errors.New("webauthn: invalid signature")
+	// ErrSignCountInvalid means that the signature counter did not increase, the authenticator may be cloned
+	ErrSignCountInvalid = errors.New("webauthn: signature counter did not increase")
+)
+
+// URLEncodedBytes is a byte slice which is encoded to JSON as base64url string, as it is done by browsers
+type URLEncodedBytes []byte
+
+func (b URLEncodedBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *URLEncodedBytes) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(str, "="))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// RelyingParty verifies ceremonies for the given relying party id (domain) and allowed origins
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+func NewRelyingParty(id, name string, origins []string) *RelyingParty {
+	return &RelyingParty{ID: id, Name: name, Origins: origins}
+}
+
+// NewChallenge generates a random challenge for a ceremony
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, ChallengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// Credential is a verified credential created during registration
+type Credential struct {
+	ID        []byte
+	PublicKey []byte
+	SignCount uint32
+	AAGUID    []byte
+	// UserVerified shows if the user was verified by the authenticator (PIN, biometrics) during registration
+	UserVerified bool
+}
+
+// RegistrationResponse is a PublicKeyCredential returned by navigator.credentials.create()
+type RegistrationResponse struct {
+	ID       string          `json:"id"`
+	RawID    URLEncodedBytes `json:"rawId"`
+	Type     string          `json:"type"`
+	Response struct {
+		ClientDataJSON    URLEncodedBytes `json:"clientDataJSON"`
+		AttestationObject URLEncodedBytes `json:"attestationObject"`
+		Transports        []string        `json:"transports"`
+	} `json:"response"`
+}
+
+// AssertionResponse is a PublicKeyCredential returned by navigator.credentials.get()
+type AssertionResponse struct {
+	ID       string          `json:"id"`
+	RawID    URLEncodedBytes `json:"rawId"`
+	Type     string          `json:"type"`
+	Response struct {
+		ClientDataJSON    URLEncodedBytes `json:"clientDataJSON"`
+		AuthenticatorData URLEncodedBytes `json:"authenticatorData"`
+		Signature         URLEncodedBytes `json:"signature"`
+		UserHandle        URLEncodedBytes `json:"userHandle"`
+	} `json:"response"`
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type authenticatorData struct {
+	rpIDHash            []byte
+	flags               byte
+	signCount           uint32
+	aaguid              []byte
+	credentialID        []byte
+	credentialPublicKey []byte
+}
+
+// VerifyRegistration verifies the response of the registration ceremony started with the given challenge
+func (rp *RelyingParty) VerifyRegistration(challenge []byte, response *RegistrationResponse, requireUserVerification bool) (*Credential, error) {
+	if response.Type != credentialType {
+		return nil, ErrMalformedResponse
+	}
+	if err := rp.verifyClientData(response.Response.ClientDataJSON, ceremonyCreate, challenge); err != nil {
+		return nil, err
+	}
+
+	decoded, rest, err := decodeCbor(response.Response.AttestationObject)
+	if err != nil || len(rest) != 0 {
+		return nil, ErrMalformedResponse
+	}
+	attestation, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrMalformedResponse
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, ErrMalformedResponse
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.verifyAuthenticatorData(authData, requireUserVerification); err != nil {
+		return nil, err
+	}
+	if authData.flags&flagAttestedCredentialData == 0 {
+		return nil, ErrMalformedResponse
+	}
+	if !bytes.Equal(authData.credentialID, response.RawID) {
+		return nil, ErrMalformedResponse
+	}
+	if _, err := ParsePublicKey(authData.credentialPublicKey); err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:           authData.credentialID,
+		PublicKey:    authData.credentialPublicKey,
+		SignCount:    authData.signCount,
+		AAGUID:       authData.aaguid,
+		UserVerified: authData.flags&flagUserVerified != 0,
+	}, nil
+}
+
+// VerifyAssertion verifies the response of the authentication ceremony started with the given challenge
+// using the stored public key of the credential. It returns the new value of the signature counter.
+func (rp *RelyingParty) VerifyAssertion(
+	challenge []byte,
+	response *AssertionResponse,
+	publicKey []byte,
+	storedSignCount uint32,
+	requireUserVerification bool,
+) (uint32, error) {
+	if response.Type != credentialType {
+		return 0, ErrMalformedResponse
+	}
+	if err := rp.verifyClientData(response.Response.ClientDataJSON, ceremonyGet, challenge); err != nil {
+		return 0, err
+	}
+
+	authData, err := parseAuthenticatorData(response.Response.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	if err := rp.verifyAuthenticatorData(authData, requireUserVerification); err != nil {
+		return 0, err
+	}
+
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(response.Response.ClientDataJSON)
+	signed := append(append([]byte{}, response.Response.AuthenticatorData...), clientDataHash[:]...)
+	if err := key.Verify(signed, response.Response.Signature); err != nil {
+		return 0, err
+	}
+
+	// authenticators which do not support counters always return zero
+	if (authData.signCount != 0 || storedSignCount != 0) && authData.signCount <= storedSignCount {
+		return 0, ErrSignCountInvalid
+	}
+
+	return authData.signCount, nil
+}
+
+// IsUserVerified checks the user verification flag of the assertion, it must be called after VerifyAssertion
+func (r *AssertionResponse) IsUserVerified() bool {
+	data := r.Response.AuthenticatorData
+	return len(data) >= authenticatorDataMinLength && data[32]&flagUserVerified != 0
+}
+
+// ChallengeFromClientData returns the challenge signed by the authenticator, it allows to find the pending ceremony.
+// The response must be verified with the returned challenge only if the challenge was issued by the relying party.
+func ChallengeFromClientData(clientDataJSON []byte) ([]byte, error) {
+	data := clientData{}
+	if err := json.Unmarshal(clientDataJSON, &data); err != nil {
+		return nil, ErrMalformedResponse
+	}
+	challenge, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(data.Challenge, "="))
+	if err != nil || len(challenge) == 0 {
+		return nil, ErrMalformedResponse
+	}
+	return challenge, nil
+}
+
+func (rp *RelyingParty) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+	data := clientData{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return ErrMalformedResponse
+	}
+	if data.Type != ceremony {
+		return ErrCeremonyMismatch
+	}
+
+	received, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(data.Challenge, "="))
+	if err != nil || len(challenge) == 0 || subtle.ConstantTimeCompare(received, challenge) != 1 {
+		return ErrChallengeMismatch
+	}
+
+	for _, origin := range rp.Origins {
+		if data.Origin == origin {
+			return nil
+		}
+	}
+	return ErrOriginMismatch
+}
+
+func (rp *RelyingParty) verifyAuthenticatorData(data *authenticatorData, requireUserVerification bool) error {
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if subtle.ConstantTimeCompare(data.rpIDHash, rpIDHash[:]) != 1 {
+		return ErrRPIDMismatch
+	}
+	if data.flags&flagUserPresent == 0 {
+		return ErrUserNotPresent
+	}
+	if requireUserVerification && data.flags&flagUserVerified == 0 {
+		return ErrUserNotVerified
+	}
+	return nil
+}
+
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < authenticatorDataMinLength {
+		return nil, ErrMalformedResponse
+	}
+
+	result := &authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[authenticatorDataMinLength:]
+
+	if result.flags&flagAttestedCredentialData != 0 {
+		// aaguid (16) and credential id length (2)
+		if len(rest) < 18 {
+			return nil, ErrMalformedResponse
+		}
+		result.aaguid = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < idLength {
+			return nil, ErrMalformedResponse
+		}
+		result.credentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		_, afterKey, err := decodeCbor(rest)
+		if err != nil {
+			return nil, ErrMalformedResponse
+		}
+		result.credentialPublicKey = rest[:len(rest)-len(afterKey)]
+		rest = afterKey
+	}
+
+	if result.flags&flagExtensionData != 0 {
+		_, afterExtensions, err := decodeCbor(rest)
+		if err != nil {
+			return nil, ErrMalformedResponse
+		}
+		rest = afterExtensions
+	}
+
+	if len(rest) != 0 {
+		return nil, ErrMalformedResponse
+	}
+	return result, nil
+}
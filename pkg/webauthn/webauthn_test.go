@@ -0,0 +1,337 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testRPID   = "wallet.example.com"
+	testOrigin = "https://wallet.example.com"
+)
+
+// softwareAuthenticator emulates a FIDO2 authenticator with a single ES256 credential
+type softwareAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	signCount    uint32
+	rpID         string
+	origin       string
+	flags        byte
+}
+
+func newSoftwareAuthenticator(t *testing.T) *softwareAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	credentialID := make([]byte, 16)
+	_, err = rand.Read(credentialID)
+	require.NoError(t, err)
+
+	return &softwareAuthenticator{
+		key:          key,
+		credentialID: credentialID,
+		rpID:         testRPID,
+		origin:       testOrigin,
+		flags:        flagUserPresent | flagUserVerified,
+	}
+}
+
+func (a *softwareAuthenticator) coseKey() []byte {
+	return encodeCbor(map[int64]interface{}{
+		coseKeyType:   coseKeyTypeEC2,
+		coseAlgorithm: AlgES256,
+		coseCurve:     coseP256,
+		coseX:         padTo32(a.key.X.Bytes()),
+		coseY:         padTo32(a.key.Y.Bytes()),
+	})
+}
+
+func (a *softwareAuthenticator) authenticatorData(flags byte, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	if attested {
+		flags |= flagAttestedCredentialData
+	}
+	data = append(data, flags)
+	counter := make([]byte, 4)
+	binary.BigEndian.PutUint32(counter, a.signCount)
+	data = append(data, counter...)
+
+	if attested {
+		data = append(data, make([]byte, 16)...)
+		length := make([]byte, 2)
+		binary.BigEndian.PutUint16(length, uint16(len(a.credentialID)))
+		data = append(data, length...)
+		data = append(data, a.credentialID...)
+		data = append(data, a.coseKey()...)
+	}
+	return data
+}
+
+func (a *softwareAuthenticator) clientData(ceremony string, challenge []byte) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":        ceremony,
+		"challenge":   base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":      a.origin,
+		"crossOrigin": false,
+	})
+	return data
+}
+
+func (a *softwareAuthenticator) create(challenge []byte) *RegistrationResponse {
+	response := &RegistrationResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		RawID: a.credentialID,
+		Type:  credentialType,
+	}
+	response.Response.ClientDataJSON = a.clientData(ceremonyCreate, challenge)
+	response.Response.AttestationObject = encodeCbor(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": a.authenticatorData(a.flags, true),
+	})
+	return response
+}
+
+func (a *softwareAuthenticator) get(t *testing.T, challenge []byte) *AssertionResponse {
+	a.signCount++
+	response := &AssertionResponse{
+		ID:    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		RawID: a.credentialID,
+		Type:  credentialType,
+	}
+	response.Response.ClientDataJSON = a.clientData(ceremonyGet, challenge)
+	response.Response.AuthenticatorData = a.authenticatorData(a.flags, false)
+
+	clientDataHash := sha256.Sum256(response.Response.ClientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, response.Response.AuthenticatorData...), clientDataHash[:]...))
+	r, s, err := ecdsa.Sign(rand.Reader, a.key, digest[:])
+	require.NoError(t, err)
+	response.Response.Signature = encodeDERSignature(r.Bytes(), s.Bytes())
+	return response
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	rp := NewRelyingParty(testRPID, "Wallet", []string{testOrigin})
+	authenticator := newSoftwareAuthenticator(t)
+
+	challenge, err := NewChallenge()
+	require.NoError(t, err)
+	credential, err := rp.VerifyRegistration(challenge, authenticator.create(challenge), true)
+	require.NoError(t, err)
+	assert.Equal(t, authenticator.credentialID, credential.ID)
+	assert.True(t, credential.UserVerified)
+
+	for i := 0; i < 2; i++ {
+		challenge, err = NewChallenge()
+		require.NoError(t, err)
+		assertion := authenticator.get(t, challenge)
+		signCount, err := rp.VerifyAssertion(challenge, assertion, credential.PublicKey, credential.SignCount, true)
+		require.NoError(t, err)
+		assert.Equal(t, authenticator.signCount, signCount)
+		assert.True(t, assertion.IsUserVerified())
+		credential.SignCount = signCount
+	}
+}
+
+func TestResponseJSONRoundTrip(t *testing.T) {
+	rp := NewRelyingParty(testRPID, "Wallet", []string{testOrigin})
+	authenticator := newSoftwareAuthenticator(t)
+	challenge, _ := NewChallenge()
+
+	data, err := json.Marshal(authenticator.create(challenge))
+	require.NoError(t, err)
+	response := &RegistrationResponse{}
+	require.NoError(t, json.Unmarshal(data, response))
+
+	_, err = rp.VerifyRegistration(challenge, response, true)
+	assert.NoError(t, err)
+}
+
+func TestVerifyRegistrationErrors(t *testing.T) {
+	rp := NewRelyingParty(testRPID, "Wallet", []string{testOrigin})
+	challenge, _ := NewChallenge()
+
+	authenticator := newSoftwareAuthenticator(t)
+	otherChallenge, _ := NewChallenge()
+	_, err := rp.VerifyRegistration(otherChallenge, authenticator.create(challenge), false)
+	assert.Equal(t, ErrChallengeMismatch, err)
+
+	authenticator.origin = "https://phishing.example.com"
+	_, err = rp.VerifyRegistration(challenge, authenticator.create(challenge), false)
+	assert.Equal(t, ErrOriginMismatch, err)
+
+	authenticator = newSoftwareAuthenticator(t)
+	authenticator.rpID = "phishing.example.com"
+	_, err = rp.VerifyRegistration(challenge, authenticator.create(challenge), false)
+	assert.Equal(t, ErrRPIDMismatch, err)
+
+	authenticator = newSoftwareAuthenticator(t)
+	authenticator.flags = flagUserPresent
+	_, err = rp.VerifyRegistration(challenge, authenticator.create(challenge), true)
+	assert.Equal(t, ErrUserNotVerified, err)
+	_, err = rp.VerifyRegistration(challenge, authenticator.create(challenge), false)
+	assert.NoError(t, err)
+
+	authenticator.flags = 0
+	_, err = rp.VerifyRegistration(challenge, authenticator.create(challenge), false)
+	assert.Equal(t, ErrUserNotPresent, err)
+
+	response := newSoftwareAuthenticator(t).create(challenge)
+	response.Response.AttestationObject = response.Response.AttestationObject[:20]
+	_, err = rp.VerifyRegistration(challenge, response, false)
+	assert.Equal(t, ErrMalformedResponse, err)
+}
+
+func TestVerifyAssertionErrors(t *testing.T) {
+	rp := NewRelyingParty(testRPID, "Wallet", []string{testOrigin})
+	authenticator := newSoftwareAuthenticator(t)
+	challenge, _ := NewChallenge()
+	credential, err := rp.VerifyRegistration(challenge, authenticator.create(challenge), true)
+	require.NoError(t, err)
+
+	t.Run("registration response is not accepted as an assertion", func(t *testing.T) {
+		assertion := authenticator.get(t, challenge)
+		assertion.Response.ClientDataJSON = authenticator.clientData(ceremonyCreate, challenge)
+		_, err := rp.VerifyAssertion(challenge, assertion, credential.PublicKey, 0, true)
+		assert.Equal(t, ErrCeremonyMismatch, err)
+	})
+
+	t.Run("signature of another key", func(t *testing.T) {
+		other := newSoftwareAuthenticator(t)
+		_, err := rp.VerifyAssertion(challenge, other.get(t, challenge), credential.PublicKey, 0, true)
+		assert.Equal(t, ErrInvalidSignature, err)
+	})
+
+	t.Run("tampered authenticator data", func(t *testing.T) {
+		assertion := authenticator.get(t, challenge)
+		assertion.Response.AuthenticatorData[36]++
+		_, err := rp.VerifyAssertion(challenge, assertion, credential.PublicKey, 0, true)
+		assert.Equal(t, ErrInvalidSignature, err)
+	})
+
+	t.Run("counter did not increase", func(t *testing.T) {
+		assertion := authenticator.get(t, challenge)
+		_, err := rp.VerifyAssertion(challenge, assertion, credential.PublicKey, authenticator.signCount, true)
+		assert.Equal(t, ErrSignCountInvalid, err)
+	})
+
+	t.Run("authenticator without counter", func(t *testing.T) {
+		// the counter overflows to zero on the next assertion
+		authenticator.signCount = math.MaxUint32
+		assertion := authenticator.get(t, challenge)
+
+		signCount, err := rp.VerifyAssertion(challenge, assertion, credential.PublicKey, 0, true)
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(0), signCount)
+	})
+}
+
+func TestParsePublicKeyRejectsUnsupportedKeys(t *testing.T) {
+	_, err := ParsePublicKey(encodeCbor(map[int64]interface{}{
+		coseKeyType:   coseKeyTypeEC2,
+		coseAlgorithm: AlgES256,
+		coseCurve:     coseP384,
+		coseX:         make([]byte, 32),
+		coseY:         make([]byte, 32),
+	}))
+	assert.Equal(t, ErrUnsupportedAlgorithm, err)
+
+	_, err = ParsePublicKey(encodeCbor(map[int64]interface{}{
+		coseKeyType:   coseKeyTypeEC2,
+		coseAlgorithm: AlgES256,
+		coseCurve:     coseP256,
+		coseX:         make([]byte, 32),
+		coseY:         make([]byte, 32),
+	}))
+	assert.Error(t, err)
+}
+
+func TestDecodeCborRejectsForgedLengths(t *testing.T) {
+	// an array of 2^32-1 items with no data
+	_, _, err := decodeCbor([]byte{0x9a, 0xff, 0xff, 0xff, 0xff})
+	assert.Error(t, err)
+	// indefinite length map
+	_, _, err = decodeCbor([]byte{0xbf, 0xff})
+	assert.Error(t, err)
+}
+
+func padTo32(b []byte) []byte {
+	return append(make([]byte, 32-len(b)), b...)
+}
+
+func encodeDERSignature(r, s []byte) []byte {
+	integer := func(b []byte) []byte {
+		if len(b) > 0 && b[0]&0x80 != 0 {
+			b = append([]byte{0}, b...)
+		}
+		return append([]byte{0x02, byte(len(b))}, b...)
+	}
+	body := append(integer(r), integer(s)...)
+	return append([]byte{0x30, byte(len(body))}, body...)
+}
+
+// encodeCbor encodes the subset of CBOR which is needed to emulate an authenticator
+func encodeCbor(value interface{}) []byte {
+	header := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n < 1<<8:
+			return []byte{major<<5 | 24, byte(n)}
+		default:
+			b := make([]byte, 3)
+			b[0] = major<<5 | 25
+			binary.BigEndian.PutUint16(b[1:], uint16(n))
+			return b
+		}
+	}
+
+	switch v := value.(type) {
+	case int64:
+		if v < 0 {
+			return header(cborNegative, uint64(-1-v))
+		}
+		return header(cborUnsigned, uint64(v))
+	case []byte:
+		return append(header(cborBytes, uint64(len(v))), v...)
+	case string:
+		return append(header(cborText, uint64(len(v))), v...)
+	case map[int64]interface{}:
+		keys := make([]int64, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		data := header(cborMap, uint64(len(v)))
+		for _, k := range keys {
+			data = append(data, encodeCbor(k)...)
+			data = append(data, encodeCbor(v[k])...)
+		}
+		return data
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		data := header(cborMap, uint64(len(v)))
+		for _, k := range keys {
+			data = append(data, encodeCbor(k)...)
+			data = append(data, encodeCbor(v[k])...)
+		}
+		return data
+	}
+	panic("unsupported type")
+}
@@ -2,12 +2,44 @@ package usersserver
 
 import (
 	"context"
-	"errors"
+	"strconv"
+	"time"
 
+	"github.com/Confialink/wallet-users/internal/services/auth"
 	pb "github.com/Confialink/wallet-users/rpc/proto/users"
 )
 
-// GetDevicesByUID returns users by uid
+// GetDevicesByUID returns active sessions (devices) of the user
 func (s *UsersHandlerServer) GetDevicesByUID(ctx context.Context, req *pb.DevicesRequest) (res *pb.DevicesResponse, err error) {
-	return nil, errors.New("this method id deprecated")
+	sessions, err := s.tokenService.ListSessions(req.UID)
+	if err != nil {
+		s.logger.Error("failed to retrieve sessions", "error", err, "uid", req.UID)
+		return &pb.DevicesResponse{
+			Error: &pb.Error{
+				Title:   "Cannot retrieve devices",
+				Details: err.Error(),
+			},
+		}, err
+	}
+
+	devices := make([]*pb.Device, 0, len(sessions))
+	for _, session := range sessions {
+		id := session.DeviceID
+		if id == "" {
+			id = strconv.FormatUint(session.ID, 10)
+		}
+		device := &pb.Device{
+			ID:     id,
+			OsType: auth.DeviceOsType(session.UserAgent),
+		}
+		if session.FirstSeenAt != nil {
+			device.CreatedAt = session.FirstSeenAt.Format(time.RFC3339)
+		}
+		if session.LastUsedAt != nil {
+			device.UpdatedAt = session.LastUsedAt.Format(time.RFC3339)
+		}
+		devices = append(devices, device)
+	}
+
+	return &pb.DevicesResponse{Devices: devices}, nil
 }
@@ -5,6 +5,8 @@ import (
 	"context"
 	"errors"
 
+	base "github.com/dgrijalva/jwt-go"
+
 	pb "github.com/Confialink/wallet-users/rpc/proto/users"
 
 	"github.com/Confialink/wallet-users/rpc/internal/usersserver/middlewares"
@@ -12,7 +14,7 @@ import (
 
 // ValidateAccessToken validates token and returns current user
 func (s *UsersHandlerServer) ValidateAccessToken(ctx context.Context, req *pb.Request) (res *pb.Response, err error) {
-	_, err = s.tokenService.VerifyToken(req.AccessToken)
+	token, err := s.tokenService.VerifyToken(req.AccessToken)
 	if err != nil {
 
 		result := &pb.Response{
@@ -24,6 +26,16 @@ func (s *UsersHandlerServer) ValidateAccessToken(ctx context.Context, req *pb.Re
 		return result, err
 	}
 
+	if !auth.IsClientTokenAllowed(token.Claims.(base.MapClaims)) {
+		result := &pb.Response{
+			Error: &pb.Error{
+				Title:   "Token is not valid",
+				Details: "token is not issued for wallet scope",
+			},
+		}
+		return result, errors.New("token is not issued for wallet scope")
+	}
+
 	user, err := s.Repository.GetUsersRepository().FindUserByTokenAndSubject(req.AccessToken, auth.ClaimAccessSub)
 	if err != nil {
 		result := &pb.Response{